@@ -0,0 +1,620 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+var adminRegistryRegex = regexp.MustCompile("^/_admin/(exchanges|asset_proxies|assets|asset_pairs)(/[^/]+)?$")
+
+// hexBytes is a byte slice encoded in JSON as a 0x prefixed hex string
+type hexBytes []byte
+
+func (data hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%#x", []byte(data)))
+}
+
+func (data *hexBytes) UnmarshalJSON(jsonData []byte) error {
+	hexString := ""
+	if err := json.Unmarshal(jsonData, &hexString); err != nil {
+		return err
+	}
+	value, err := hex.DecodeString(strings.TrimPrefix(hexString, "0x"))
+	if err != nil {
+		return err
+	}
+	*data = value
+	return nil
+}
+
+type adminExchange struct {
+	Address         *types.Address `json:"address"`
+	NetworkID       uint64         `json:"networkId"`
+	ProtocolVersion uint8          `json:"protocolVersion"`
+	ChainID         uint64         `json:"chainId"`
+	DomainName      string         `json:"domainName"`
+	DomainVersion   string         `json:"domainVersion"`
+	DomainSalt      hexBytes       `json:"domainSalt"`
+}
+
+type adminAssetProxy struct {
+	ID              hexBytes       `json:"id"`
+	Name            string         `json:"name"`
+	Address         *types.Address `json:"address"`
+	ExchangeAddress *types.Address `json:"exchangeAddress"`
+	ZeroEx          bool           `json:"zeroEx"`
+}
+
+type adminAsset struct {
+	Symbol         string         `json:"symbol"`
+	Name           string         `json:"name"`
+	Address        *types.Address `json:"address"`
+	Decimals       uint16         `json:"decimals"`
+	ProxyID        hexBytes       `json:"proxyId"`
+	AssetData      hexBytes       `json:"assetData"`
+	Precision      uint16         `json:"precision"`
+	MinTradeAmount *types.Uint256 `json:"minTradeAmount"`
+	MaxTradeAmount *types.Uint256 `json:"maxTradeAmount"`
+	ZeroEx         bool           `json:"zeroEx"`
+	Active         bool           `json:"active"`
+	Quote          bool           `json:"quote"`
+}
+
+type adminAssetPair struct {
+	ID           uint64 `json:"id"`
+	AssetSymbolA string `json:"assetSymbolA"`
+	AssetSymbolB string `json:"assetSymbolB"`
+	Active       bool   `json:"active"`
+}
+
+// adminInputError is a malformed key or request body
+type adminInputError struct {
+	reason string
+}
+
+func (err *adminInputError) Error() string {
+	return err.reason
+}
+
+// adminResource implements the admin API for one of the registry tables.
+// Keys are the final path segment of a row's URL.
+type adminResource struct {
+	list   func(db *gorm.DB) (interface{}, error)
+	get    func(db *gorm.DB, key string) (interface{}, error)
+	create func(db *gorm.DB, body []byte) (string, interface{}, error)
+	update func(db *gorm.DB, key string, body []byte) (interface{}, error)
+	remove func(db *gorm.DB, key string) error
+}
+
+func decodeAdminBody(body []byte, value interface{}) error {
+	if err := json.Unmarshal(body, value); err != nil {
+		return &adminInputError{fmt.Sprintf("Malformed JSON: %v", err.Error())}
+	}
+	return nil
+}
+
+func parseAdminAddress(key string) (*types.Address, error) {
+	address, err := common.HexToAddress(key)
+	if err != nil || len(strings.TrimPrefix(key, "0x")) != 40 {
+		return nil, &adminInputError{"Invalid address"}
+	}
+	return address, nil
+}
+
+func parseAdminHex(key string) ([]byte, error) {
+	value, err := hex.DecodeString(strings.TrimPrefix(key, "0x"))
+	if err != nil {
+		return nil, &adminInputError{"Invalid hex value"}
+	}
+	return value, nil
+}
+
+func parseAdminID(key string) (uint64, error) {
+	id, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return 0, &adminInputError{"Invalid ID"}
+	}
+	return id, nil
+}
+
+func toAdminExchange(exchange *dbModule.Exchange) *adminExchange {
+	return &adminExchange{
+		exchange.Address,
+		exchange.Network,
+		exchange.ProtocolVersion,
+		exchange.ChainID,
+		exchange.DomainName,
+		exchange.DomainVersion,
+		exchange.DomainSalt,
+	}
+}
+
+func (exchange *adminExchange) model() (*dbModule.Exchange, error) {
+	if exchange.Address == nil {
+		return nil, &adminInputError{"address is required"}
+	}
+	if exchange.ProtocolVersion != types.ProtocolV2 && exchange.ProtocolVersion != types.ProtocolV3 {
+		return nil, &adminInputError{"protocolVersion must be 2 or 3"}
+	}
+	if len(exchange.DomainSalt) != 0 && len(exchange.DomainSalt) != 32 {
+		return nil, &adminInputError{"domainSalt must be 32 bytes"}
+	}
+	return &dbModule.Exchange{
+		Address:         exchange.Address,
+		Network:         exchange.NetworkID,
+		ProtocolVersion: exchange.ProtocolVersion,
+		ChainID:         exchange.ChainID,
+		DomainName:      exchange.DomainName,
+		DomainVersion:   exchange.DomainVersion,
+		DomainSalt:      exchange.DomainSalt,
+	}, nil
+}
+
+func toAdminAssetProxy(assetProxy *dbModule.AssetProxy) *adminAssetProxy {
+	return &adminAssetProxy{
+		assetProxy.ID,
+		assetProxy.Name,
+		assetProxy.Address,
+		assetProxy.ExchangeAddress,
+		assetProxy.ZeroEx,
+	}
+}
+
+func (assetProxy *adminAssetProxy) model() (*dbModule.AssetProxy, error) {
+	if len(assetProxy.ID) != 4 {
+		return nil, &adminInputError{"id must be 4 bytes"}
+	}
+	if assetProxy.Address == nil || assetProxy.ExchangeAddress == nil {
+		return nil, &adminInputError{"address and exchangeAddress are required"}
+	}
+	return &dbModule.AssetProxy{
+		ID:              assetProxy.ID,
+		Name:            assetProxy.Name,
+		Address:         assetProxy.Address,
+		ExchangeAddress: assetProxy.ExchangeAddress,
+		ZeroEx:          assetProxy.ZeroEx,
+	}, nil
+}
+
+func toAdminAsset(asset *dbModule.Asset) *adminAsset {
+	result := &adminAsset{
+		Symbol:         asset.Symbol,
+		Name:           asset.Name,
+		Address:        asset.Address,
+		Decimals:       asset.Decimals,
+		ProxyID:        asset.ProxyID,
+		Precision:      asset.Precision,
+		MinTradeAmount: asset.MinTradeAmount,
+		MaxTradeAmount: asset.MaxTradeAmount,
+		ZeroEx:         asset.ZeroEx,
+		Active:         asset.Active,
+		Quote:          asset.Quote,
+	}
+	if asset.Data != nil {
+		result.AssetData = hexBytes(*asset.Data)
+	}
+	return result
+}
+
+func (asset *adminAsset) model() (*dbModule.Asset, error) {
+	if asset.Symbol == "" {
+		return nil, &adminInputError{"symbol is required"}
+	}
+	if len(asset.ProxyID) != 4 {
+		return nil, &adminInputError{"proxyId must be 4 bytes"}
+	}
+	proxyID := [4]byte{}
+	copy(proxyID[:], asset.ProxyID)
+	if len(asset.AssetData) < 4 || !types.AssetData(asset.AssetData).IsType(proxyID) {
+		return nil, &adminInputError{"assetData must be for the asset's proxy"}
+	}
+	if asset.MinTradeAmount == nil || asset.MaxTradeAmount == nil {
+		return nil, &adminInputError{"minTradeAmount and maxTradeAmount are required"}
+	}
+	if asset.MinTradeAmount.Big().Cmp(asset.MaxTradeAmount.Big()) > 0 {
+		return nil, &adminInputError{"minTradeAmount may not exceed maxTradeAmount"}
+	}
+	data := types.AssetData(asset.AssetData)
+	return &dbModule.Asset{
+		Symbol:         asset.Symbol,
+		Name:           asset.Name,
+		Address:        asset.Address,
+		Decimals:       asset.Decimals,
+		ProxyID:        asset.ProxyID,
+		Data:           &data,
+		Precision:      asset.Precision,
+		MinTradeAmount: asset.MinTradeAmount,
+		MaxTradeAmount: asset.MaxTradeAmount,
+		ZeroEx:         asset.ZeroEx,
+		Active:         asset.Active,
+		Quote:          asset.Quote,
+	}, nil
+}
+
+func toAdminAssetPair(assetPair *dbModule.AssetPair) *adminAssetPair {
+	return &adminAssetPair{
+		assetPair.ID,
+		assetPair.AssetSymbolA,
+		assetPair.AssetSymbolB,
+		assetPair.Active,
+	}
+}
+
+func (assetPair *adminAssetPair) model() (*dbModule.AssetPair, error) {
+	if assetPair.AssetSymbolA == "" || assetPair.AssetSymbolB == "" {
+		return nil, &adminInputError{"assetSymbolA and assetSymbolB are required"}
+	}
+	if assetPair.AssetSymbolA == assetPair.AssetSymbolB {
+		return nil, &adminInputError{"An asset can't be paired with itself"}
+	}
+	return &dbModule.AssetPair{
+		ID:           assetPair.ID,
+		AssetSymbolA: assetPair.AssetSymbolA,
+		AssetSymbolB: assetPair.AssetSymbolB,
+		Active:       assetPair.Active,
+	}, nil
+}
+
+var adminResources = map[string]*adminResource{
+	"exchanges": &adminResource{
+		list: func(db *gorm.DB) (interface{}, error) {
+			exchanges, err := dbModule.ListExchanges(db)
+			result := []*adminExchange{}
+			for _, exchange := range exchanges {
+				result = append(result, toAdminExchange(exchange))
+			}
+			return result, err
+		},
+		get: func(db *gorm.DB, key string) (interface{}, error) {
+			address, err := parseAdminAddress(key)
+			if err != nil {
+				return nil, err
+			}
+			exchange := &dbModule.Exchange{}
+			if err := db.Model(&dbModule.Exchange{}).Where("address = ?", address).First(exchange).Error; err != nil {
+				return nil, err
+			}
+			return toAdminExchange(exchange), nil
+		},
+		create: func(db *gorm.DB, body []byte) (string, interface{}, error) {
+			request := &adminExchange{ProtocolVersion: types.ProtocolV2}
+			if err := decodeAdminBody(body, request); err != nil {
+				return "", nil, err
+			}
+			exchange, err := request.model()
+			if err != nil {
+				return "", nil, err
+			}
+			if err := dbModule.CreateExchange(db, exchange); err != nil {
+				return "", nil, err
+			}
+			return exchange.Address.String(), toAdminExchange(exchange), nil
+		},
+		update: func(db *gorm.DB, key string, body []byte) (interface{}, error) {
+			address, err := parseAdminAddress(key)
+			if err != nil {
+				return nil, err
+			}
+			request := &adminExchange{ProtocolVersion: types.ProtocolV2}
+			if err := decodeAdminBody(body, request); err != nil {
+				return nil, err
+			}
+			request.Address = address
+			exchange, err := request.model()
+			if err != nil {
+				return nil, err
+			}
+			if err := dbModule.UpdateExchange(db, exchange); err != nil {
+				return nil, err
+			}
+			return toAdminExchange(exchange), nil
+		},
+		remove: func(db *gorm.DB, key string) error {
+			address, err := parseAdminAddress(key)
+			if err != nil {
+				return err
+			}
+			return dbModule.DeleteExchange(db, address)
+		},
+	},
+	"asset_proxies": &adminResource{
+		list: func(db *gorm.DB) (interface{}, error) {
+			assetProxies, err := dbModule.ListAssetProxies(db)
+			result := []*adminAssetProxy{}
+			for _, assetProxy := range assetProxies {
+				result = append(result, toAdminAssetProxy(assetProxy))
+			}
+			return result, err
+		},
+		get: func(db *gorm.DB, key string) (interface{}, error) {
+			id, err := parseAdminHex(key)
+			if err != nil {
+				return nil, err
+			}
+			assetProxy := &dbModule.AssetProxy{}
+			if err := db.Model(&dbModule.AssetProxy{}).Where("id = ?", id).First(assetProxy).Error; err != nil {
+				return nil, err
+			}
+			return toAdminAssetProxy(assetProxy), nil
+		},
+		create: func(db *gorm.DB, body []byte) (string, interface{}, error) {
+			request := &adminAssetProxy{}
+			if err := decodeAdminBody(body, request); err != nil {
+				return "", nil, err
+			}
+			assetProxy, err := request.model()
+			if err != nil {
+				return "", nil, err
+			}
+			if err := dbModule.CreateAssetProxy(db, assetProxy); err != nil {
+				return "", nil, err
+			}
+			return fmt.Sprintf("%#x", assetProxy.ID), toAdminAssetProxy(assetProxy), nil
+		},
+		update: func(db *gorm.DB, key string, body []byte) (interface{}, error) {
+			id, err := parseAdminHex(key)
+			if err != nil {
+				return nil, err
+			}
+			request := &adminAssetProxy{}
+			if err := decodeAdminBody(body, request); err != nil {
+				return nil, err
+			}
+			request.ID = id
+			assetProxy, err := request.model()
+			if err != nil {
+				return nil, err
+			}
+			if err := dbModule.UpdateAssetProxy(db, assetProxy); err != nil {
+				return nil, err
+			}
+			return toAdminAssetProxy(assetProxy), nil
+		},
+		remove: func(db *gorm.DB, key string) error {
+			id, err := parseAdminHex(key)
+			if err != nil {
+				return err
+			}
+			return dbModule.DeleteAssetProxy(db, id)
+		},
+	},
+	"assets": &adminResource{
+		list: func(db *gorm.DB) (interface{}, error) {
+			assets, err := dbModule.ListAssets(db)
+			result := []*adminAsset{}
+			for _, asset := range assets {
+				result = append(result, toAdminAsset(asset))
+			}
+			return result, err
+		},
+		get: func(db *gorm.DB, key string) (interface{}, error) {
+			asset := &dbModule.Asset{}
+			if err := db.Model(&dbModule.Asset{}).Where("symbol = ?", key).First(asset).Error; err != nil {
+				return nil, err
+			}
+			return toAdminAsset(asset), nil
+		},
+		create: func(db *gorm.DB, body []byte) (string, interface{}, error) {
+			request := &adminAsset{}
+			if err := decodeAdminBody(body, request); err != nil {
+				return "", nil, err
+			}
+			asset, err := request.model()
+			if err != nil {
+				return "", nil, err
+			}
+			if err := dbModule.CreateAsset(db, asset); err != nil {
+				return "", nil, err
+			}
+			return asset.Symbol, toAdminAsset(asset), nil
+		},
+		update: func(db *gorm.DB, key string, body []byte) (interface{}, error) {
+			request := &adminAsset{}
+			if err := decodeAdminBody(body, request); err != nil {
+				return nil, err
+			}
+			request.Symbol = key
+			asset, err := request.model()
+			if err != nil {
+				return nil, err
+			}
+			if err := dbModule.UpdateAsset(db, asset); err != nil {
+				return nil, err
+			}
+			return toAdminAsset(asset), nil
+		},
+		remove: func(db *gorm.DB, key string) error {
+			return dbModule.DeleteAsset(db, key)
+		},
+	},
+	"asset_pairs": &adminResource{
+		list: func(db *gorm.DB) (interface{}, error) {
+			assetPairs, err := dbModule.ListAssetPairs(db)
+			result := []*adminAssetPair{}
+			for _, assetPair := range assetPairs {
+				result = append(result, toAdminAssetPair(assetPair))
+			}
+			return result, err
+		},
+		get: func(db *gorm.DB, key string) (interface{}, error) {
+			id, err := parseAdminID(key)
+			if err != nil {
+				return nil, err
+			}
+			assetPair := &dbModule.AssetPair{}
+			if err := db.Model(&dbModule.AssetPair{}).Where("id = ?", id).First(assetPair).Error; err != nil {
+				return nil, err
+			}
+			return toAdminAssetPair(assetPair), nil
+		},
+		create: func(db *gorm.DB, body []byte) (string, interface{}, error) {
+			request := &adminAssetPair{}
+			if err := decodeAdminBody(body, request); err != nil {
+				return "", nil, err
+			}
+			assetPair, err := request.model()
+			if err != nil {
+				return "", nil, err
+			}
+			if err := dbModule.CreateAssetPair(db, assetPair); err != nil {
+				return "", nil, err
+			}
+			return strconv.FormatUint(assetPair.ID, 10), toAdminAssetPair(assetPair), nil
+		},
+		update: func(db *gorm.DB, key string, body []byte) (interface{}, error) {
+			id, err := parseAdminID(key)
+			if err != nil {
+				return nil, err
+			}
+			request := &adminAssetPair{}
+			if err := decodeAdminBody(body, request); err != nil {
+				return nil, err
+			}
+			request.ID = id
+			assetPair, err := request.model()
+			if err != nil {
+				return nil, err
+			}
+			if err := dbModule.UpdateAssetPair(db, assetPair); err != nil {
+				return nil, err
+			}
+			return toAdminAssetPair(assetPair), nil
+		},
+		remove: func(db *gorm.DB, key string) error {
+			id, err := parseAdminID(key)
+			if err != nil {
+				return err
+			}
+			return dbModule.DeleteAssetPair(db, id)
+		},
+	},
+}
+
+func respondAdminError(w http.ResponseWriter, err error) {
+	switch err.(type) {
+	case *adminInputError, *dbModule.RegistryReferenceError:
+		respondError(w, &zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: err.Error(),
+		}, http.StatusBadRequest)
+	case *dbModule.RegistryConflictError:
+		respondError(w, &zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: err.Error(),
+		}, http.StatusConflict)
+	default:
+		if err == gorm.ErrRecordNotFound {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Not found",
+			}, http.StatusNotFound)
+			return
+		}
+		log.Printf("Error updating registry: %v", err.Error())
+		respondError(w, &zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Unable to update registry",
+		}, http.StatusInternalServerError)
+	}
+}
+
+func respondAdmin(w http.ResponseWriter, value interface{}, status int) {
+	response, err := json.Marshal(value)
+	if err != nil {
+		respondAdminError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// AdminRegistry serves CRUD for the exchanges, asset proxies, assets and
+// asset pairs tables under /_admin/<table>/<key>. Rows referred to by other
+// rows can't be deleted, and rows must refer to existing rows. Each change is
+// announced on publisher, so services caching the registry can refresh.
+func AdminRegistry(db *gorm.DB, publisher channels.Publisher) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		match := adminRegistryRegex.FindStringSubmatch(r.URL.Path)
+		if len(match) != 3 {
+			http.NotFound(w, r)
+			return
+		}
+		table, resource, key := match[1], adminResources[match[1]], strings.TrimPrefix(match[2], "/")
+
+		var body []byte
+		if r.Method == "POST" || r.Method == "PUT" {
+			var err error
+			body, err = ioutil.ReadAll(r.Body)
+			if err != nil && err != io.EOF {
+				log.Printf("Error reading content: %v", err.Error())
+				respondError(w, &zeroex.Error{
+					Code:   zeroex.ErrorCodeValidationFailed,
+					Reason: "Error reading content",
+				}, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		switch {
+		case r.Method == "GET" && key == "":
+			rows, err := resource.list(db)
+			if err != nil {
+				respondAdminError(w, err)
+				return
+			}
+			respondAdmin(w, rows, http.StatusOK)
+		case r.Method == "GET":
+			row, err := resource.get(db, key)
+			if err != nil {
+				respondAdminError(w, err)
+				return
+			}
+			respondAdmin(w, row, http.StatusOK)
+		case r.Method == "POST" && key == "":
+			createdKey, row, err := resource.create(db, body)
+			if err != nil {
+				respondAdminError(w, err)
+				return
+			}
+			dbModule.PublishRegistryChange(publisher, &dbModule.RegistryChange{Table: table, Key: createdKey, Action: "create"})
+			respondAdmin(w, row, http.StatusCreated)
+		case r.Method == "PUT" && key != "":
+			row, err := resource.update(db, key, body)
+			if err != nil {
+				respondAdminError(w, err)
+				return
+			}
+			dbModule.PublishRegistryChange(publisher, &dbModule.RegistryChange{Table: table, Key: key, Action: "update"})
+			respondAdmin(w, row, http.StatusOK)
+		case r.Method == "DELETE" && key != "":
+			if err := resource.remove(db, key); err != nil {
+				respondAdminError(w, err)
+				return
+			}
+			dbModule.PublishRegistryChange(publisher, &dbModule.RegistryChange{Table: table, Key: key, Action: "delete"})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request method",
+			}, http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/apikeys"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+const (
+	adminExchangeAddress = "0x90fe2af704b34e0224bf2299c838e04d4dcf1364"
+	adminProxyAddress    = "0x1dc4c1cefef38a777b15aa20260a54e584b16c48"
+)
+
+func adminAssetJSON(symbol, token string) string {
+	return fmt.Sprintf(
+		`{"symbol": "%v", "address": "%v", "decimals": 18, "proxyId": "0xf47261b0", "assetData": "0xf47261b0000000000000000000000000%v", "minTradeAmount": "0", "maxTradeAmount": "1000", "active": true}`,
+		symbol, token, strings.TrimPrefix(token, "0x"),
+	)
+}
+
+func adminRequest(handler func(http.ResponseWriter, *http.Request), method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+// awaitRegistryChange returns the next change published by the admin API
+func awaitRegistryChange(t *testing.T, published chan channels.Delivery) *dbModule.RegistryChange {
+	select {
+	case delivery := <-published:
+		change := &dbModule.RegistryChange{}
+		if err := json.Unmarshal([]byte(delivery.Payload()), change); err != nil {
+			t.Fatalf(err.Error())
+		}
+		return change
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a registry change to be published")
+	}
+	return nil
+}
+
+func TestAdminRegistry(t *testing.T) {
+	db := getDb(t)
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Exchange{}, &dbModule.AssetProxy{}, &dbModule.Asset{}, &dbModule.AssetPair{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	publisher, published := channels.MockPublisher()
+	handler := handlers.AdminRegistry(tx, publisher)
+
+	for _, step := range []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"POST", "/_admin/exchanges", `{"address": "` + adminExchangeAddress + `", "networkId": 1}`, http.StatusCreated},
+		{"POST", "/_admin/asset_proxies", `{"id": "0xf47261b0", "name": "erc20", "address": "` + adminProxyAddress + `", "exchangeAddress": "` + adminExchangeAddress + `"}`, http.StatusCreated},
+		{"POST", "/_admin/assets", adminAssetJSON("ZRX", "0x1dad4783cf3fe3085c1426157ab175a6119a04ba"), http.StatusCreated},
+		{"POST", "/_admin/assets", adminAssetJSON("WETH", "0x05d090b51c40b020eab3bfcb6a2dff130df22e9c"), http.StatusCreated},
+	} {
+		if w := adminRequest(handler, step.method, step.path, step.body); w.Code != step.code {
+			t.Fatalf("Expected %v for %v %v, got %v: %v", step.code, step.method, step.path, w.Code, w.Body.String())
+		}
+		if change := awaitRegistryChange(t, published); change.Action != "create" || !strings.HasSuffix(step.path, change.Table) {
+			t.Errorf("Unexpected change for %v: %v", step.path, change)
+		}
+	}
+
+	w := adminRequest(handler, "POST", "/_admin/asset_pairs", `{"assetSymbolA": "ZRX", "assetSymbolB": "WETH", "active": true}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected asset pair to be created, got %v: %v", w.Code, w.Body.String())
+	}
+	assetPair := &struct {
+		ID     uint64 `json:"id"`
+		Active bool   `json:"active"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), assetPair); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if change := awaitRegistryChange(t, published); change.Key != fmt.Sprintf("%v", assetPair.ID) {
+		t.Errorf("Expected the change to name pair %v, got %v", assetPair.ID, change.Key)
+	}
+	assetPairPath := fmt.Sprintf("/_admin/asset_pairs/%v", assetPair.ID)
+
+	w = adminRequest(handler, "PUT", assetPairPath, `{"assetSymbolA": "ZRX", "assetSymbolB": "WETH", "active": false}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected asset pair to be updated, got %v: %v", w.Code, w.Body.String())
+	}
+	if change := awaitRegistryChange(t, published); change.Action != "update" {
+		t.Errorf("Expected an update, got %v", change)
+	}
+	w = adminRequest(handler, "GET", assetPairPath, "")
+	if err := json.Unmarshal(w.Body.Bytes(), assetPair); err != nil || w.Code != http.StatusOK || assetPair.Active {
+		t.Errorf("Expected the pair to be inactive, got %v: %v", w.Code, w.Body.String())
+	}
+	w = adminRequest(handler, "GET", "/_admin/assets", "")
+	assets := []map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &assets); err != nil || len(assets) != 2 {
+		t.Errorf("Expected 2 assets, got %v", w.Body.String())
+	}
+
+	// Failed requests change nothing, so nothing is published
+	for _, step := range []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"POST", "/_admin/exchanges", `{"address": "` + adminExchangeAddress + `", "networkId": 1}`, http.StatusConflict},
+		{"POST", "/_admin/exchanges", `{"address": "` + adminProxyAddress + `", "protocolVersion": 4}`, http.StatusBadRequest},
+		{"POST", "/_admin/asset_proxies", `{"id": "0x02571792", "address": "` + adminProxyAddress + `", "exchangeAddress": "` + adminProxyAddress + `"}`, http.StatusBadRequest},
+		{"POST", "/_admin/assets", `{"symbol": "DAI", "proxyId": "0xf47261b0", "assetData": "0x02571792", "minTradeAmount": "0", "maxTradeAmount": "1"}`, http.StatusBadRequest},
+		{"POST", "/_admin/assets", `{"symbol":`, http.StatusBadRequest},
+		{"DELETE", "/_admin/assets/ZRX", "", http.StatusConflict},
+		{"DELETE", "/_admin/exchanges/" + adminExchangeAddress, "", http.StatusConflict},
+		{"GET", "/_admin/exchanges/0x1234", "", http.StatusBadRequest},
+		{"GET", "/_admin/assets/DAI", "", http.StatusNotFound},
+		{"PUT", "/_admin/assets", adminAssetJSON("ZRX", "0x1dad4783cf3fe3085c1426157ab175a6119a04ba"), http.StatusMethodNotAllowed},
+		{"PATCH", "/_admin/assets/ZRX", "", http.StatusMethodNotAllowed},
+		{"GET", "/_admin/orders", "", http.StatusNotFound},
+	} {
+		if w := adminRequest(handler, step.method, step.path, step.body); w.Code != step.code {
+			t.Errorf("Expected %v for %v %v, got %v: %v", step.code, step.method, step.path, w.Code, w.Body.String())
+		}
+	}
+	select {
+	case delivery := <-published:
+		t.Errorf("Unexpected change published: %v", delivery.Payload())
+	default:
+	}
+
+	if w := adminRequest(handler, "DELETE", assetPairPath, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected asset pair to be deleted, got %v: %v", w.Code, w.Body.String())
+	}
+	if change := awaitRegistryChange(t, published); change.Action != "delete" || change.Table != "asset_pairs" {
+		t.Errorf("Expected the pair's deletion, got %v", change)
+	}
+	if w := adminRequest(handler, "GET", assetPairPath, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted pair not to be found, got %v", w.Code)
+	}
+}
+
+func TestAdminDecorator(t *testing.T) {
+	handler := handlers.AdminDecorator(okHandler)
+	for _, c := range []struct {
+		key  *apikeys.APIKey
+		code int
+	}{
+		{nil, http.StatusUnauthorized},
+		{&apikeys.APIKey{}, http.StatusForbidden},
+		{&apikeys.APIKey{Admin: true}, http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", "/_admin/exchanges", nil)
+		if c.key != nil {
+			r = r.WithContext(apikeys.NewContext(r.Context(), c.key))
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != c.code {
+			t.Errorf("Expected %v for key %v, got %v", c.code, c.key, w.Code)
+		}
+	}
+}
@@ -24,6 +24,20 @@ var emptyAddress = types.Address{}
 // ExchangeLookup .
 type ExchangeLookup interface {
 	ExchangeIsKnown(*types.Address) <-chan uint64
+	GetExchange(*types.Address) (*dbModule.Exchange, error)
+}
+
+// AssetRegistry looks up the assets and asset pairs listed for trading, which
+// pools may restrict orders to.
+type AssetRegistry interface {
+	GetAsset(types.AssetData, uint64) (*dbModule.Asset, error)
+	GetAssetPair(*dbModule.Asset, *dbModule.Asset) (*dbModule.AssetPair, error)
+}
+
+// SoftCancelLookup reports whether a maker has soft cancelled an order, which
+// keeps it out of the order book for good.
+type SoftCancelLookup interface {
+	IsSoftCancelled(*types.Order) (bool, error)
 }
 
 func getFormattedOrder(dbOrder *dbModule.Order) *zeroex.OrderEx {
@@ -42,9 +56,11 @@ func getFormattedOrder(dbOrder *dbModule.Order) *zeroex.OrderEx {
 		Order: &order,
 		Metadata: zeroex.OrderMetadata{
 			fmt.Sprintf("%#x", dbOrder.OrderHash[:]),
-			dbOrder.FeeRate,
+			dbOrder.FeeRate.Float64(),
 			dbOrder.Status,
 			new(big.Int).Sub(dbOrder.TakerAssetAmount.Big(), dbOrder.TakerAssetAmountFilled.Big()).String(),
+			dbOrder.RemainingFillable().String(),
+			dbOrder.OverCommitted,
 		},
 	}
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/notegio/openrelay/zeroex"
+)
+
+// AdminDecorator restricts fn to requests authenticated with an admin API
+// key. It must be wrapped by APIKeyDecorator, which authenticates the key.
+func AdminDecorator(fn func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := RequestAPIKey(r)
+		if key == nil {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "API key required",
+			}, http.StatusUnauthorized)
+			return
+		}
+		if !key.Admin {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "API key may not use the admin API",
+			}, http.StatusForbidden)
+			return
+		}
+		fn(w, r)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/notegio/openrelay/apikeys"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+// RequestAPIKey returns the API key a request was authenticated with by
+// APIKeyDecorator, or nil for anonymous requests
+func RequestAPIKey(r *http.Request) *apikeys.APIKey {
+	return apikeys.FromRequest(r)
+}
+
+// APIKeyDecorator authenticates requests carrying an `Authorization: Bearer`
+// API key, making the key available to fn through RequestAPIKey. Requests
+// without the header are passed through anonymously, while invalid keys and
+// keys not allowed to use the requested pool are rejected.
+func APIKeyDecorator(
+	keys apikeys.KeyStore,
+	fn func(http.ResponseWriter, *http.Request),
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			fn(w, r)
+			return
+		}
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported authorization scheme",
+			}, http.StatusUnauthorized)
+			return
+		}
+		key, err := keys.Authenticate(strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer ")))
+		if err == apikeys.ErrInvalidKey {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Invalid API key",
+			}, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			log.Printf("Error authenticating API key: %v", err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unable to authenticate API key",
+			}, http.StatusInternalServerError)
+			return
+		}
+		if match := poolRegex.FindStringSubmatch(r.URL.Path); len(match) == 2 {
+			if poolName := strings.TrimPrefix(match[1], "/"); !key.AllowsPool(poolName) {
+				respondError(w, &zeroex.Error{
+					Code:   zeroex.ErrorCodeValidationFailed,
+					Reason: "API key may not use this pool",
+				}, http.StatusForbidden)
+				return
+			}
+		}
+		fn(w, r.WithContext(apikeys.NewContext(r.Context(), key)))
+	}
+}
@@ -16,7 +16,7 @@ import (
 	"github.com/notegio/openrelay/zeroex"
 )
 
-var poolRegex = regexp.MustCompile("^(/[^/]*)?/0x/v2/")
+var poolRegex = regexp.MustCompile("^(/[^/]*)?/0x/v[23]/")
 
 // PoolDecorator .
 func PoolDecorator(
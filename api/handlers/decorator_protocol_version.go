@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+)
+
+var protocolVersionRegex = regexp.MustCompile("/0x/v([23])/")
+
+// ProtocolVersionDecorator limits searches to orders for the 0x protocol
+// version in the request path, so /v2/ endpoints serve v2 orders and /v3/
+// endpoints serve v3 orders. An explicit protocolVersion parameter takes
+// precedence.
+func ProtocolVersionDecorator(fn func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if match := protocolVersionRegex.FindStringSubmatch(r.URL.Path); len(match) == 2 {
+			queryObject := r.URL.Query()
+			if queryObject.Get("protocolVersion") == "" {
+				queryObject.Set("protocolVersion", match[1])
+				r.URL.RawQuery = queryObject.Encode()
+			}
+		}
+		fn(w, r)
+	}
+}
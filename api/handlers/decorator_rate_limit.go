@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notegio/openrelay/ratelimit"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+const (
+	// RateLimitByIP counts requests against the client's IP address
+	RateLimitByIP = "ip"
+	// RateLimitByMaker counts requests against the maker of each submitted
+	// order
+	RateLimitByMaker = "maker"
+)
+
+// RateLimit is a limit on one route, counted by client IP or by maker
+type RateLimit struct {
+	By    string
+	Limit ratelimit.Limit
+}
+
+// ParseRateLimit parses a route's limit of the form "<route>:<ip|maker>=<count>/<period>",
+// such as "post_order:maker=10/1m".
+func ParseRateLimit(value string) (string, *RateLimit, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("Rate limit should be <route>:<ip|maker>=<count>/<period>: %v", value)
+	}
+	routeParts := strings.SplitN(parts[0], ":", 2)
+	if len(routeParts) != 2 || (routeParts[1] != RateLimitByIP && routeParts[1] != RateLimitByMaker) {
+		return "", nil, fmt.Errorf("Rate limit should be <route>:<ip|maker>=<count>/<period>: %v", value)
+	}
+	limit, err := ratelimit.ParseLimit(parts[1])
+	if err != nil {
+		return "", nil, err
+	}
+	return routeParts[0], &RateLimit{routeParts[1], limit}, nil
+}
+
+// maxMakerBodySize bounds the request bodies read for maker addresses,
+// comfortably above a full batch of orders
+const maxMakerBodySize = 4 << 20
+
+// clientIP returns the client's address. Behind trustedProxies load balancers
+// or CDNs, each of which appends the address it received the request from to
+// X-Forwarded-For, the client is the address appended by the outermost of
+// them. Entries further left are whatever the client chose to send.
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		forwarded := []string{}
+		for _, header := range r.Header["X-Forwarded-For"] {
+			for _, address := range strings.Split(header, ",") {
+				if address = strings.TrimSpace(address); address != "" {
+					forwarded = append(forwarded, address)
+				}
+			}
+		}
+		if len(forwarded) >= trustedProxies {
+			return forwarded[len(forwarded)-trustedProxies]
+		} else if len(forwarded) > 0 {
+			return forwarded[0]
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// requestMakers returns the distinct maker addresses in a request body holding
+// an order or cancel, or an array of orders. The body is left intact for the
+// decorated handler. An error is returned for bodies that can't be read,
+// including those over maxMakerBodySize.
+func requestMakers(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxMakerBodySize))
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	type makerField struct {
+		MakerAddress string `json:"makerAddress"`
+	}
+	items := []makerField{}
+	if err := json.Unmarshal(body, &items); err != nil {
+		item := makerField{}
+		if err := json.Unmarshal(body, &item); err != nil {
+			return nil, nil
+		}
+		items = append(items, item)
+	}
+	makers := []string{}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		maker := strings.ToLower(item.MakerAddress)
+		if maker != "" && !seen[maker] {
+			seen[maker] = true
+			makers = append(makers, maker)
+		}
+	}
+	return makers, nil
+}
+
+// RateLimitDecorator rejects requests to route beyond any of its limits with
+// a 429 response. Requests made with an API key count against the key rather
+// than the client's IP, which is found through X-Forwarded-For behind
+// trustedProxies proxies. If the limiter is unavailable requests are allowed
+// through, so a redis outage doesn't take down the API.
+func RateLimitDecorator(
+	limiter ratelimit.Limiter,
+	route string,
+	limits []*RateLimit,
+	trustedProxies int,
+	fn func(http.ResponseWriter, *http.Request),
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := RequestAPIKey(r)
+		for _, limit := range limits {
+			var keys []string
+			by, rate := limit.By, limit.Limit
+			if limit.By == RateLimitByMaker {
+				makers, err := requestMakers(w, r)
+				if err != nil {
+					respondError(w, &zeroex.Error{
+						Code:   zeroex.ErrorCodeValidationFailed,
+						Reason: "Request body too large",
+					}, http.StatusRequestEntityTooLarge)
+					return
+				}
+				keys = makers
+			} else if apiKey != nil {
+				// Requests with an API key are counted against the key, at
+				// the key's own rate if it has one
+				by, keys = "key", []string{apiKey.Prefix}
+				if keyLimit := apiKey.Limit(); keyLimit != nil {
+					rate = *keyLimit
+				}
+			} else {
+				keys = []string{clientIP(r, trustedProxies)}
+			}
+			for _, key := range keys {
+				allowed, wait, err := limiter.Allow(fmt.Sprintf("%v::%v::%v", route, by, key), rate)
+				if err != nil {
+					log.Printf("Error checking rate limit: %v", err.Error())
+					continue
+				}
+				if !allowed {
+					w.Header().Set("Retry-After", strconv.FormatInt(int64((wait+time.Second-1)/time.Second), 10))
+					respondError(w, &zeroex.Error{
+						Code:   zeroex.ErrorCodeThrottled,
+						Reason: "Too Many Requests",
+					}, http.StatusTooManyRequests)
+					return
+				}
+			}
+		}
+		fn(w, r)
+	}
+}
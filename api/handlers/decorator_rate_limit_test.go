@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/ratelimit"
+)
+
+// recordingLimiter allows every request, recording the keys it's asked about
+type recordingLimiter struct {
+	keys []string
+}
+
+func (limiter *recordingLimiter) Allow(key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	limiter.keys = append(limiter.keys, key)
+	return true, 0, nil
+}
+
+func rateLimit(t *testing.T, value string) []*handlers.RateLimit {
+	_, limit, err := handlers.ParseRateLimit(value)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return []*handlers.RateLimit{limit}
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRateLimitRejection(t *testing.T) {
+	handler := handlers.RateLimitDecorator(
+		ratelimit.NewMockLimiter(false, 1500*time.Millisecond, nil),
+		"post_order",
+		rateLimit(t, "post_order:ip=1/1m"),
+		1,
+		okHandler,
+	)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/v2/order", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %v", w.Code)
+	}
+	// Waits are rounded up to whole seconds
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected Retry-After 2, got '%v'", retryAfter)
+	}
+	if !strings.Contains(w.Body.String(), "103") {
+		t.Errorf("Expected throttled error code, got '%v'", w.Body.String())
+	}
+}
+
+func TestRateLimitLimiterUnavailable(t *testing.T) {
+	handler := handlers.RateLimitDecorator(
+		ratelimit.NewMockLimiter(false, 0, errors.New("Redis unavailable")),
+		"post_order",
+		rateLimit(t, "post_order:ip=1/1m"),
+		1,
+		okHandler,
+	)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/v2/order", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected requests through while the limiter is down, got %v", w.Code)
+	}
+}
+
+func TestRateLimitMakers(t *testing.T) {
+	limiter := &recordingLimiter{}
+	var body string
+	handler := handlers.RateLimitDecorator(limiter, "post_orders", rateLimit(t, "post_orders:maker=10/1m"), 1, func(w http.ResponseWriter, r *http.Request) {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		body = string(content)
+	})
+	content := `[
+		{"makerAddress": "0x00000000000000000000000000000000000000AA"},
+		{"makerAddress": "0x00000000000000000000000000000000000000aa"},
+		{"makerAddress": "0x00000000000000000000000000000000000000bb"}
+	]`
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/v2/orders", strings.NewReader(content)))
+	expected := []string{
+		"post_orders::maker::0x00000000000000000000000000000000000000aa",
+		"post_orders::maker::0x00000000000000000000000000000000000000bb",
+	}
+	if !reflect.DeepEqual(limiter.keys, expected) {
+		t.Errorf("Expected %v, got %v", expected, limiter.keys)
+	}
+	if body != content {
+		t.Errorf("Expected the body to reach the handler intact, got '%v'", body)
+	}
+}
+
+func TestRateLimitBodyTooLarge(t *testing.T) {
+	limiter := &recordingLimiter{}
+	handler := handlers.RateLimitDecorator(limiter, "post_orders", rateLimit(t, "post_orders:maker=10/1m"), 1, okHandler)
+	w := httptest.NewRecorder()
+	body := `{"makerAddress": "` + strings.Repeat("0", 5<<20) + `"}`
+	handler(w, httptest.NewRequest("POST", "/v2/orders", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %v", w.Code)
+	}
+	if len(limiter.keys) != 0 {
+		t.Errorf("Expected no limits checked, got %v", limiter.keys)
+	}
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	cases := []struct {
+		name           string
+		trustedProxies int
+		forwarded      []string
+		expected       string
+	}{
+		{"no header", 1, nil, "192.0.2.1"},
+		{"ignored header", 0, []string{"198.51.100.1"}, "192.0.2.1"},
+		{"one proxy", 1, []string{"203.0.113.9, 198.51.100.1"}, "198.51.100.1"},
+		{"two proxies", 2, []string{"203.0.113.9, 198.51.100.1, 198.51.100.2"}, "198.51.100.1"},
+		{"repeated header", 2, []string{"203.0.113.9, 198.51.100.1", "198.51.100.2"}, "198.51.100.1"},
+		{"fewer entries than proxies", 3, []string{"198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, c := range cases {
+		limiter := &recordingLimiter{}
+		handler := handlers.RateLimitDecorator(limiter, "orders", rateLimit(t, "orders:ip=10/1m"), c.trustedProxies, okHandler)
+		r := httptest.NewRequest("GET", "/v2/orders", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		for _, header := range c.forwarded {
+			r.Header.Add("X-Forwarded-For", header)
+		}
+		handler(httptest.NewRecorder(), r)
+		expected := []string{"orders::ip::" + c.expected}
+		if !reflect.DeepEqual(limiter.keys, expected) {
+			t.Errorf("%v: expected %v, got %v", c.name, expected, limiter.keys)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"golang.org/x/net/websocket"
+
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/search"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+// streamClientBuffer is how many messages may be waiting to be written to a
+// websocket client before it's considered too slow and disconnected.
+const streamClientBuffer = 64
+
+// streamMessage is a message of the SRA v2 websocket protocol.
+// https://github.com/0xProject/standard-relayer-api/blob/master/ws/v2.md
+type streamMessage struct {
+	Type      string      `json:"type"`
+	Channel   string      `json:"channel"`
+	RequestID string      `json:"requestId"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+type streamClient struct {
+	conn *websocket.Conn
+	send chan *streamMessage
+	done chan struct{}
+	once sync.Once
+}
+
+// push queues a message for the client without blocking. Clients that fall
+// too far behind are disconnected rather than holding up everyone else.
+func (client *streamClient) push(msg *streamMessage) {
+	select {
+	case <-client.done:
+	case client.send <- msg:
+	default:
+		log.Printf("Websocket client %v fell behind, disconnecting", client.conn.Request().RemoteAddr)
+		client.close()
+	}
+}
+
+func (client *streamClient) close() {
+	client.once.Do(func() {
+		close(client.done)
+		client.conn.Close()
+	})
+}
+
+func (client *streamClient) writeMessages() {
+	for {
+		select {
+		case <-client.done:
+			return
+		case msg := <-client.send:
+			if err := websocket.JSON.Send(client.conn, msg); err != nil {
+				client.close()
+				return
+			}
+		}
+	}
+}
+
+type streamSubscription struct {
+	client    *streamClient
+	requestID string
+	filter    url.Values
+	pool      types.Pool
+	// key identifies subscriptions with the same pool and filter, which can
+	// share a query
+	key string
+}
+
+// OrderStream serves the SRA v2 websocket API. Clients subscribe to the
+// orders channel with the same filters /v2/orders accepts, and are sent an
+// update whenever a matching order is added, filled, cancelled, becomes
+// unfunded or expires.
+//
+// OrderStream consumes the db.StatusChange messages the indexers publish, and
+// must be added as a consumer on that channel.
+type OrderStream struct {
+	db            *gorm.DB
+	mutex         sync.Mutex
+	subscriptions map[*streamSubscription]struct{}
+	changes       chan []byte
+}
+
+// Consume queues the order from a db.StatusChange to be sent to subscribers.
+func (stream *OrderStream) Consume(msg channels.Delivery) {
+	change := &dbModule.StatusChange{}
+	if err := json.Unmarshal([]byte(msg.Payload()), change); err != nil {
+		log.Printf("Failed to parse status change: %v", err.Error())
+		msg.Reject()
+		return
+	}
+	orderHash, err := hex.DecodeString(strings.TrimPrefix(change.OrderHash, "0x"))
+	if err != nil {
+		log.Printf("Invalid order hash in status change: %v", change.OrderHash)
+		msg.Reject()
+		return
+	}
+	stream.changes <- orderHash
+	msg.Ack()
+}
+
+// Run sends queued changes to subscribers every interval, along with any
+// orders that expired since the last interval. It returns when stop is
+// closed.
+func (stream *OrderStream) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastExpiry := time.Now().Unix()
+	pending := make(map[string][]byte)
+	for {
+		select {
+		case <-stop:
+			return
+		case orderHash := <-stream.changes:
+			pending[string(orderHash)] = orderHash
+		case <-ticker.C:
+			now := time.Now().Unix()
+			expired := [][]byte{}
+			if err := stream.db.Model(&dbModule.Order{}).Where(
+				"status = ? AND expiration_timestamp_in_sec > ? AND expiration_timestamp_in_sec <= ?",
+				dbModule.StatusOpen,
+				common.BigToUint256(big.NewInt(lastExpiry)),
+				common.BigToUint256(big.NewInt(now)),
+			).Pluck("order_hash", &expired).Error; err != nil {
+				log.Printf("Error finding expired orders: %v", err.Error())
+			} else {
+				lastExpiry = now
+			}
+			for _, orderHash := range expired {
+				pending[string(orderHash)] = orderHash
+			}
+			if len(pending) == 0 {
+				continue
+			}
+			orderHashes := make([][]byte, 0, len(pending))
+			for _, orderHash := range pending {
+				orderHashes = append(orderHashes, orderHash)
+			}
+			pending = make(map[string][]byte)
+			stream.publish(orderHashes)
+		}
+	}
+}
+
+// publish sends each subscriber the changed orders matching their filter
+func (stream *OrderStream) publish(orderHashes [][]byte) {
+	stream.mutex.Lock()
+	groups := make(map[string][]*streamSubscription)
+	for subscription := range stream.subscriptions {
+		groups[subscription.key] = append(groups[subscription.key], subscription)
+	}
+	stream.mutex.Unlock()
+	if len(groups) == 0 {
+		return
+	}
+
+	orders := []dbModule.Order{}
+	if err := stream.db.Model(&dbModule.Order{}).Where("order_hash IN (?)", orderHashes).Find(&orders).Error; err != nil {
+		log.Printf("Error loading changed orders: %v", err.Error())
+		return
+	}
+	ordersByHash := make(map[string]*dbModule.Order)
+	for i := range orders {
+		ordersByHash[string(orders[i].OrderHash)] = &orders[i]
+	}
+
+	for _, subscriptions := range groups {
+		// The filters are applied by the database, so they match the orders
+		// /v2/orders would return for them. No status or expiration condition is
+		// applied, as subscribers need to hear about orders leaving the order
+		// book as much as those joining it.
+		query, _ := search.OrderFilter(stream.db.Model(&dbModule.Order{}).Where("order_hash IN (?)", orderHashes), subscriptions[0].filter)
+		query, err := subscriptions[0].pool.Filter(query)
+		if err != nil {
+			log.Printf("Pool filter error: %v", err.Error())
+			continue
+		}
+		matched := [][]byte{}
+		if err := query.Pluck("order_hash", &matched).Error; err != nil {
+			log.Printf("Error matching changed orders: %v", err.Error())
+			continue
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		records := []*zeroex.OrderEx{}
+		for _, orderHash := range matched {
+			if order, ok := ordersByHash[string(orderHash)]; ok {
+				records = append(records, getFormattedOrder(order))
+			}
+		}
+		for _, subscription := range subscriptions {
+			subscription.client.push(&streamMessage{
+				Type:      "update",
+				Channel:   "orders",
+				RequestID: subscription.requestID,
+				Payload:   records,
+			})
+		}
+	}
+}
+
+// subscribe parses a subscribe request's payload into a filter. SRA's
+// traderAssetData is our assetData filter; other fields are named the same as
+// /v2/orders query parameters. Values may be strings or numbers, which are
+// written out in full as a query parameter would be.
+func (stream *OrderStream) subscribe(client *streamClient, pool types.Pool, msg *streamMessage) (*streamSubscription, []search.ValidationError) {
+	filter := url.Values{}
+	errs := []search.ValidationError{}
+	if payload, ok := msg.Payload.(map[string]interface{}); ok {
+		for field, value := range payload {
+			key := field
+			if key == "traderAssetData" {
+				key = "assetData"
+			}
+			switch v := value.(type) {
+			case string:
+				filter.Set(key, v)
+			case float64:
+				filter.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				errs = append(errs, search.ValidationError{Err: fmt.Sprintf("Expected a string or number, got %v", value), Code: 1001, Field: field})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if _, errs := search.OrderFilter(stream.db.Model(&dbModule.Order{}), filter); len(errs) > 0 {
+		return nil, errs
+	}
+	key := filter.Encode()
+	if p, ok := pool.(*poolModule.Pool); ok {
+		key = fmt.Sprintf("%#x?%v", p.ID, key)
+	}
+	subscription := &streamSubscription{client, msg.RequestID, filter, pool, key}
+	stream.mutex.Lock()
+	stream.subscriptions[subscription] = struct{}{}
+	stream.mutex.Unlock()
+	return subscription, nil
+}
+
+func (stream *OrderStream) unsubscribe(subscriptions []*streamSubscription) {
+	stream.mutex.Lock()
+	for _, subscription := range subscriptions {
+		delete(stream.subscriptions, subscription)
+	}
+	stream.mutex.Unlock()
+}
+
+// Handler accepts websocket connections for the pool given by PoolDecorator
+func (stream *OrderStream) Handler() func(http.ResponseWriter, *http.Request, types.Pool) {
+	return func(w http.ResponseWriter, r *http.Request, pool types.Pool) {
+		// websocket.Server skips the origin check websocket.Handler applies, as
+		// this API is meant to be used from any site
+		server := websocket.Server{Handler: func(conn *websocket.Conn) {
+			client := &streamClient{conn, make(chan *streamMessage, streamClientBuffer), make(chan struct{}), sync.Once{}}
+			subscriptions := []*streamSubscription{}
+			defer func() {
+				stream.unsubscribe(subscriptions)
+				client.close()
+			}()
+			go client.writeMessages()
+			for {
+				msg := &streamMessage{}
+				if err := websocket.JSON.Receive(conn, msg); err != nil {
+					return
+				}
+				if msg.Type != "subscribe" || msg.Channel != "orders" {
+					client.push(&streamMessage{
+						Type:      "error",
+						Channel:   msg.Channel,
+						RequestID: msg.RequestID,
+						Payload:   fmt.Sprintf("Unsupported message type %v on channel %v", msg.Type, msg.Channel),
+					})
+					continue
+				}
+				subscription, errs := stream.subscribe(client, pool, msg)
+				if len(errs) > 0 {
+					client.push(&streamMessage{
+						Type:      "error",
+						Channel:   msg.Channel,
+						RequestID: msg.RequestID,
+						Payload:   errs,
+					})
+					continue
+				}
+				subscriptions = append(subscriptions, subscription)
+			}
+		}}
+		server.ServeHTTP(w, r)
+	}
+}
+
+// NewOrderStream creates an OrderStream reading orders from db
+func NewOrderStream(db *gorm.DB) *OrderStream {
+	return &OrderStream{
+		db,
+		sync.Mutex{},
+		make(map[*streamSubscription]struct{}),
+		make(chan []byte, 1024),
+	}
+}
@@ -0,0 +1,259 @@
+package handlers_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"golang.org/x/net/websocket"
+
+	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+)
+
+func getDb(t *testing.T) *gorm.DB {
+	connectionString := fmt.Sprintf(
+		"postgres://%v@%v",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_HOST"),
+	)
+	db, err := dbModule.GetDB(connectionString, os.Getenv("POSTGRES_PASSWORD"))
+	if err != nil {
+		t.Fatalf("Could not get db: %v", err.Error())
+	}
+	return db
+}
+
+func sampleOrder(t *testing.T) *dbModule.Order {
+	order := &types.Order{}
+	orderData, err := ioutil.ReadFile("../../formatted_transaction.json")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := json.Unmarshal(orderData, order); err != nil {
+		t.Fatalf(err.Error())
+	}
+	dbOrder := &dbModule.Order{}
+	dbOrder.Order = *order
+	dbOrder.Populate()
+	return dbOrder
+}
+
+// signedSampleOrder returns a sample order with a maker and salt of its own,
+// changed by amend before it is signed
+func signedSampleOrder(t *testing.T, amend func(order *dbModule.Order)) *dbModule.Order {
+	key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	order := sampleOrder(t)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	copy(order.Maker[:], address[:])
+	rand.Read(order.Salt[:])
+	amend(order)
+	hashedBytes := append([]byte("\x19Ethereum Signed Message:\n32"), order.Hash()...)
+	sig, _ := crypto.Sign(crypto.Keccak256(hashedBytes), key)
+	order.Signature[0] = sig[64] + 27
+	copy(order.Signature[1:33], sig[0:32])
+	copy(order.Signature[33:65], sig[32:64])
+	order.Signature[65] = types.SigTypeEthSign
+	order.Populate()
+	return order
+}
+
+type streamUpdate struct {
+	Type      string `json:"type"`
+	RequestID string `json:"requestId"`
+	Payload   []struct {
+		MetaData struct {
+			Hash string `json:"hash"`
+		} `json:"metaData"`
+	} `json:"payload"`
+}
+
+// awaitUpdate reads messages until an update lists orderHash
+func awaitUpdate(t *testing.T, conn *websocket.Conn, orderHash []byte) {
+	expected := fmt.Sprintf("%#x", orderHash)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		msg := &streamUpdate{}
+		if err := websocket.JSON.Receive(conn, msg); err != nil {
+			t.Fatalf("Expected an update for %v: %v", expected, err.Error())
+		}
+		for _, record := range msg.Payload {
+			if msg.Type == "update" && record.MetaData.Hash == expected {
+				return
+			}
+		}
+	}
+}
+
+func TestOrderStreamStatusChanges(t *testing.T) {
+	db := getDb(t)
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	// Every order shares a fee recipient, for the subscription to match
+	feeRecipient, _ := common.HexToAddress("0x00000000000000000000000000000000000fee01")
+	withFeeRecipient := func(order *dbModule.Order) {
+		copy(order.FeeRecipient[:], feeRecipient[:])
+	}
+	filled := signedSampleOrder(t, withFeeRecipient)
+	cancelled := signedSampleOrder(t, withFeeRecipient)
+	// Expires a couple of seconds from now, after the stream has started
+	expiring := signedSampleOrder(t, func(order *dbModule.Order) {
+		withFeeRecipient(order)
+		order.ExpirationTimestampInSec = common.BigToUint256(big.NewInt(time.Now().Unix() + 2))
+	})
+	for _, order := range []*dbModule.Order{filled, cancelled, expiring} {
+		if err := order.Save(tx, dbModule.StatusOpen).Error; err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+
+	stream := handlers.NewOrderStream(tx)
+	publisher, consumerChannel := channels.MockChannel()
+	consumerChannel.AddConsumer(stream)
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	stop := make(chan struct{})
+	defer close(stop)
+	go stream.Run(50*time.Millisecond, stop)
+
+	handler := stream.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, &pool.Pool{SearchTerms: "", ID: []byte("default")})
+	}))
+	defer server.Close()
+	conn, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", "http://localhost/")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer conn.Close()
+	websocket.JSON.Send(conn, map[string]interface{}{
+		"type":      "subscribe",
+		"channel":   "orders",
+		"requestId": "1",
+		"payload":   map[string]interface{}{"feeRecipient": feeRecipient.String()},
+	})
+	// Messages are handled in order, so the error for a bad subscription
+	// shows the first has been registered
+	websocket.JSON.Send(conn, map[string]interface{}{"type": "subscribe", "channel": "nothing", "requestId": "2"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg := &struct {
+		Type      string `json:"type"`
+		RequestID string `json:"requestId"`
+	}{}
+	if err := websocket.JSON.Receive(conn, msg); err != nil || msg.Type != "error" || msg.RequestID != "2" {
+		t.Fatalf("Expected an error for the bad subscription")
+	}
+
+	filled.TakerAssetAmountFilled = filled.TakerAssetAmount
+	if err := filled.Save(tx, dbModule.StatusOpen).Error; err != nil || filled.Status != dbModule.StatusFilled {
+		t.Fatalf("Expected order to be filled: %v", err)
+	}
+	dbModule.PublishStatusChanges(publisher, [][]byte{filled.OrderHash}, filled.Status)
+	awaitUpdate(t, conn, filled.OrderHash)
+
+	cancelled.Status = dbModule.StatusCancelled
+	if err := cancelled.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	dbModule.PublishStatusChanges(publisher, [][]byte{cancelled.OrderHash}, cancelled.Status)
+	awaitUpdate(t, conn, cancelled.OrderHash)
+
+	// Expiry isn't announced by a status change, the stream finds it
+	awaitUpdate(t, conn, expiring.OrderHash)
+}
+
+func TestOrderStreamNumericFilter(t *testing.T) {
+	db := getDb(t)
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	// Large amounts arrive as JSON numbers like 2e21, which must be written
+	// out in full to parse as a uint256
+	large := signedSampleOrder(t, func(order *dbModule.Order) {
+		order.TakerAssetAmount = common.BigToUint256(new(big.Int).Mul(big.NewInt(3000), big.NewInt(1000000000000000000)))
+	})
+	if err := large.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	stream := handlers.NewOrderStream(tx)
+	publisher, consumerChannel := channels.MockChannel()
+	consumerChannel.AddConsumer(stream)
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	stop := make(chan struct{})
+	defer close(stop)
+	go stream.Run(50*time.Millisecond, stop)
+
+	handler := stream.Handler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, &pool.Pool{SearchTerms: "", ID: []byte("default")})
+	}))
+	defer server.Close()
+	conn, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", "http://localhost/")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer conn.Close()
+	websocket.JSON.Send(conn, map[string]interface{}{
+		"type":      "subscribe",
+		"channel":   "orders",
+		"requestId": "1",
+		"payload":   map[string]interface{}{"minTakerAssetAmount": 2e21},
+	})
+	// Only strings and numbers can be written as a filter
+	websocket.JSON.Send(conn, map[string]interface{}{
+		"type":      "subscribe",
+		"channel":   "orders",
+		"requestId": "2",
+		"payload":   map[string]interface{}{"makerAddress": []string{large.Maker.String()}},
+	})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg := &struct {
+		Type      string `json:"type"`
+		RequestID string `json:"requestId"`
+		Payload   []struct {
+			Field string `json:"field"`
+		} `json:"payload"`
+	}{}
+	if err := websocket.JSON.Receive(conn, msg); err != nil || msg.Type != "error" || msg.RequestID != "2" {
+		t.Fatalf("Expected an error for the array filter, got %v %v", msg, err)
+	}
+	if len(msg.Payload) != 1 || msg.Payload[0].Field != "makerAddress" {
+		t.Errorf("Expected a makerAddress validation error, got %v", msg.Payload)
+	}
+
+	large.TakerAssetAmountFilled = large.TakerAssetAmount
+	if err := large.Save(tx, dbModule.StatusOpen).Error; err != nil || large.Status != dbModule.StatusFilled {
+		t.Fatalf("Expected order to be filled: %v", err)
+	}
+	dbModule.PublishStatusChanges(publisher, [][]byte{large.OrderHash}, large.Status)
+	awaitUpdate(t, conn, large.OrderHash)
+}
@@ -15,6 +15,7 @@ import (
 	accountsModule "github.com/notegio/openrelay/accounts"
 	affiliatesModule "github.com/notegio/openrelay/affiliates"
 	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
 	poolModule "github.com/notegio/openrelay/pool"
 	"github.com/notegio/openrelay/types"
 	"github.com/notegio/openrelay/zeroex"
@@ -26,6 +27,9 @@ func PostOrder(
 	accounts accountsModule.AccountService,
 	affiliates affiliatesModule.AffiliateService,
 	exchangeLookup ExchangeLookup,
+	assetRegistry AssetRegistry,
+	softCancels SoftCancelLookup,
+	commitment dbModule.CommitmentScreen,
 ) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
 
 	return func(w http.ResponseWriter, r *http.Request, pool *poolModule.Pool) {
@@ -72,265 +76,576 @@ func PostOrder(
 			return
 		}
 
-		// Request network ID from exchange address asynchronously since this may have some latency
-		chanNetworkID := exchangeLookup.ExchangeIsKnown(order.ExchangeAddress)
-
-		// Check order assets
-		if !order.MakerAssetData.SupportedType() {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "makerAssetData",
-					Code:   zeroex.ValidationErrorCodeUnsupportedOption,
-					Reason: fmt.Sprintf("Unsupported asset type: %#x", order.MakerAssetData.ProxyId()),
-				}},
-			}, http.StatusBadRequest)
+		validationErr, status, blacklisted := checkOrder(&order, pool, accounts, affiliates, exchangeLookup, assetRegistry, softCancels, commitment)
+		if validationErr != nil {
+			respondError(w, validationErr, status)
 			return
 		}
-		if !order.TakerAssetData.SupportedType() {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "takerAssetData",
-					Code:   zeroex.ValidationErrorCodeUnsupportedOption,
-					Reason: fmt.Sprintf("Unsupported asset type: %#x", order.TakerAssetData.ProxyId()),
-				}},
-			}, http.StatusBadRequest)
+
+		// Check if account is denied
+		if blacklisted {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintf(w, "")
 			return
 		}
 
-		// Check order signature type
-		if !order.Signature.Supported() {
+		// Send validated order to redis
+		order.PoolID = pool.ID
+		orderBytes := order.Bytes()
+		if ok := publisher.Publish(string(orderBytes[:])); !ok {
+			log.Println("Unable to publish order with hash %#x", order.Hash())
 			respondError(w, &zeroex.Error{
 				Code:   zeroex.ErrorCodeValidationFailed,
 				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "signature",
-					Code:   zeroex.ValidationErrorCodeInvalidSignatureOrHash,
-					Reason: "Unsupported signature type",
-				}},
-			}, http.StatusBadRequest)
-			return
+			}, http.StatusInternalServerError)
 		}
 
-		// Verify order signature
-		if !order.Signature.Verify(order.Maker, order.Hash()) {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "signature",
-					Code:   zeroex.ValidationErrorCodeInvalidSignatureOrHash,
-					Reason: "Signature validation failed",
-				}},
-			}, http.StatusBadRequest)
-			return
+		// Everything is OK so just respond with success HTTP status code
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "")
+	}
+}
+
+// orderCheck collects the failures found while checking an order. Unless it
+// is exhaustive, checking stops at the first failure.
+type orderCheck struct {
+	exhaustive bool
+	err        *zeroex.Error
+	status     int
+}
+
+// fail records a failed check, reporting whether checking should stop.
+func (check *orderCheck) fail(err *zeroex.Error, status int) bool {
+	if !check.exhaustive {
+		check.err, check.status = err, status
+		return true
+	}
+	if check.err == nil {
+		check.err = &zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
 		}
+	}
+	if status > check.status {
+		check.status = status
+	}
+	if len(err.ValidationErrors) == 0 {
+		// The only check without a field of its own is the pool expiration
+		check.err.ValidationErrors = append(check.err.ValidationErrors, zeroex.ValidationError{
+			Field:  "pool",
+			Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+			Reason: err.Reason,
+		})
+	} else {
+		check.err.ValidationErrors = append(check.err.ValidationErrors, err.ValidationErrors...)
+	}
+	return false
+}
 
-		// Check order expiration time
-		timeNow := big.NewInt(time.Now().Unix())
-		if timeNow.Cmp(order.ExpirationTimestampInSec.Big()) > 0 {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "expirationUnixTimestampSec",
-					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
-					Reason: "Order already expired",
-				}},
-			}, http.StatusBadRequest)
-			return
+func (check *orderCheck) result() (*zeroex.Error, int, bool) {
+	return check.err, check.status, false
+}
+
+// checkOrder runs the validations PostOrder applies to a submitted order. It
+// returns the error to report along with its HTTP status, or reports that the
+// maker is blacklisted, in which case the order should be quietly dropped.
+func checkOrder(
+	order *types.Order,
+	pool *poolModule.Pool,
+	accounts accountsModule.AccountService,
+	affiliates affiliatesModule.AffiliateService,
+	exchangeLookup ExchangeLookup,
+	assetRegistry AssetRegistry,
+	softCancels SoftCancelLookup,
+	commitment dbModule.CommitmentScreen,
+) (*zeroex.Error, int, bool) {
+	return runOrderChecks(&orderCheck{}, order, pool, accounts, affiliates, exchangeLookup, assetRegistry, softCancels, commitment)
+}
+
+// runOrderChecks applies PostOrder's validations, recording failures on check.
+// Checks that depend on an earlier one are skipped when it fails.
+func runOrderChecks(
+	check *orderCheck,
+	order *types.Order,
+	pool *poolModule.Pool,
+	accounts accountsModule.AccountService,
+	affiliates affiliatesModule.AffiliateService,
+	exchangeLookup ExchangeLookup,
+	assetRegistry AssetRegistry,
+	softCancels SoftCancelLookup,
+	commitment dbModule.CommitmentScreen,
+) (*zeroex.Error, int, bool) {
+	// Orders for exchanges forked from 0x are signed under the fork's domain,
+	// which has to be known before the order can be hashed
+	if exchange, err := exchangeLookup.GetExchange(order.ExchangeAddress); err == nil {
+		exchange.ApplyDomain(order)
+	}
+	chanNetworkID := exchangeLookup.ExchangeIsKnown(order.ExchangeAddress)
+
+	// Check order assets
+	if !order.MakerAssetData.SupportedType() {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "makerAssetData",
+				Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+				Reason: fmt.Sprintf("Unsupported asset type: %#x", order.MakerAssetData.ProxyId()),
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+	if !order.TakerAssetData.SupportedType() {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "takerAssetData",
+				Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+				Reason: fmt.Sprintf("Unsupported asset type: %#x", order.TakerAssetData.ProxyId()),
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
 		}
+	}
 
-		// Check order expiration time
-		timeFuture := big.NewInt(0).Add(timeNow, big.NewInt(31536000000))
-		if timeFuture.Cmp(order.ExpirationTimestampInSec.Big()) < 0 {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "expirationUnixTimestampSec",
-					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
-					Reason: "Expiration in distant future",
-				}},
-			}, http.StatusBadRequest)
-			return
+	// v3 orders pay fees in the asset named by the fee asset data
+	if order.IsV3() && order.MakerFee.Big().Sign() != 0 && !order.MakerFeeAssetData.SupportedType() {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "makerFeeAssetData",
+				Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+				Reason: fmt.Sprintf("Unsupported fee asset data: %#x", order.MakerFeeAssetData),
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
 		}
+	}
+	if order.IsV3() && order.TakerFee.Big().Sign() != 0 && !order.TakerFeeAssetData.SupportedType() {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "takerFeeAssetData",
+				Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+				Reason: fmt.Sprintf("Unsupported fee asset data: %#x", order.TakerFeeAssetData),
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
 
-		// Check order asset amounts
-		// makerAssetDataIsRoboDex := bytes.Equal(order.MakerAssetData[:4], types.RoboDexProxyID[:])
-		// takerAssetDataIsRoboDex := bytes.Equal(order.TakerAssetData[:4], types.RoboDexProxyID[:])
-		// if (makerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.MakerAssetAmount.Big()) != 0) ||
-		// 	(!makerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.MakerAssetAmount.Big()) == 0) {
-		if big.NewInt(0).Cmp(order.MakerAssetAmount.Big()) == 0 {
-			respondError(w, &zeroex.Error{
+	// Check order signature type
+	if !order.Signature.Supported() {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "signature",
+				Code:   zeroex.ValidationErrorCodeInvalidSignatureOrHash,
+				Reason: "Unsupported signature type",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Verify order signature
+	if order.Signature.Supported() && !order.Signature.Verify(order.Maker, order.Hash()) {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "signature",
+				Code:   zeroex.ValidationErrorCodeInvalidSignatureOrHash,
+				Reason: "Signature validation failed",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Check the maker hasn't soft cancelled the order. Cancels can be limited
+	// to a pool, so the order is checked as it would be indexed.
+	pooledOrder := *order
+	pooledOrder.PoolID = pool.ID
+	if cancelled, err := softCancels.IsSoftCancelled(&pooledOrder); err != nil {
+		log.Printf("Error checking soft cancels for %#x: %v", order.Hash(), err.Error())
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "salt",
+				Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+				Reason: "Unable to check soft cancellations",
+			}},
+		}, http.StatusInternalServerError) {
+			return check.result()
+		}
+	} else if cancelled {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "salt",
+				Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+				Reason: "Order has been soft cancelled by its maker",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Check order expiration time
+	timeNow := big.NewInt(time.Now().Unix())
+	if timeNow.Cmp(order.ExpirationTimestampInSec.Big()) > 0 {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "expirationUnixTimestampSec",
+				Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+				Reason: "Order already expired",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Check order expiration time
+	timeFuture := big.NewInt(0).Add(timeNow, big.NewInt(31536000000))
+	if timeFuture.Cmp(order.ExpirationTimestampInSec.Big()) < 0 {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "expirationUnixTimestampSec",
+				Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+				Reason: "Expiration in distant future",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Check order asset amounts
+	// makerAssetDataIsRoboDex := bytes.Equal(order.MakerAssetData[:4], types.RoboDexProxyID[:])
+	// takerAssetDataIsRoboDex := bytes.Equal(order.TakerAssetData[:4], types.RoboDexProxyID[:])
+	// if (makerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.MakerAssetAmount.Big()) != 0) ||
+	// 	(!makerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.MakerAssetAmount.Big()) == 0) {
+	if big.NewInt(0).Cmp(order.MakerAssetAmount.Big()) == 0 {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "MakerAssetAmount",
+				Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+				Reason: "makerAssetAmount must be > 0",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+	// if (takerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.TakerAssetAmount.Big()) != 0) ||
+	// 	(!takerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.TakerAssetAmount.Big()) == 0) {
+	if big.NewInt(0).Cmp(order.TakerAssetAmount.Big()) == 0 {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "TakerAssetAmount",
+				Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+				Reason: "takerAssetAmount must be > 0",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Request the account from redis asynchronously since this may have some latency
+	chanAccount := make(chan accountsModule.Account, 1)
+	chanAffiliate := make(chan affiliatesModule.Affiliate, 1)
+	go func() {
+		chanAccount <- accounts.Get(order.Maker)
+	}()
+	go func() {
+		feeRecipient, err := affiliates.Get(order.FeeRecipient)
+		if err != nil {
+			log.Printf("Error retrieving fee recipient: %v", err.Error())
+			chanAffiliate <- nil
+		} else {
+			chanAffiliate <- feeRecipient
+		}
+	}()
+
+	// Check network ID
+	networkID := <-chanNetworkID
+	if networkID == 0 {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "exchangeContractAddress",
+				Code:   zeroex.ValidationErrorCodeInvalidAddress,
+				Reason: "Unknown exchangeContractAddress",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Check the order is for the exchange's version of the protocol. The chain
+	// ID is part of what v3 orders sign, so it must match the exchange's too.
+	if networkID != 0 {
+		if exchange, err := exchangeLookup.GetExchange(order.ExchangeAddress); err != nil {
+			log.Printf("Error retrieving exchange: %v", err.Error())
+		} else if exchange.IsV3() != order.IsV3() {
+			if check.fail(&zeroex.Error{
 				Code:   zeroex.ErrorCodeValidationFailed,
 				Reason: "Validation Failed",
 				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "MakerAssetAmount",
-					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
-					Reason: "makerAssetAmount must be > 0",
+					Field:  "exchangeAddress",
+					Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+					Reason: fmt.Sprintf("Exchange only accepts v%v orders", exchange.ProtocolVersion),
 				}},
-			}, http.StatusBadRequest)
-			return
-		}
-		// if (takerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.TakerAssetAmount.Big()) != 0) ||
-		// 	(!takerAssetDataIsRoboDex && big.NewInt(0).Cmp(order.TakerAssetAmount.Big()) == 0) {
-		if big.NewInt(0).Cmp(order.TakerAssetAmount.Big()) == 0 {
-			respondError(w, &zeroex.Error{
+			}, http.StatusBadRequest) {
+				return check.result()
+			}
+		} else if order.IsV3() && order.ChainID != exchange.ChainID {
+			if check.fail(&zeroex.Error{
 				Code:   zeroex.ErrorCodeValidationFailed,
 				Reason: "Validation Failed",
 				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "TakerAssetAmount",
+					Field:  "chainId",
 					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
-					Reason: "takerAssetAmount must be > 0",
+					Reason: fmt.Sprintf("Exchange is on chain %v", exchange.ChainID),
 				}},
-			}, http.StatusBadRequest)
-			return
+			}, http.StatusBadRequest) {
+				return check.result()
+			}
 		}
+	}
 
-		// Request the account from redis asynchronously since this may have some latency
-		chanAccount := make(chan accountsModule.Account)
-		chanAffiliate := make(chan affiliatesModule.Affiliate)
-		go func() {
-			chanAccount <- accounts.Get(order.Maker)
-		}()
-		go func() {
-			feeRecipient, err := affiliates.Get(order.FeeRecipient)
-			if err != nil {
-				log.Printf("Error retrieving fee recipient: %v", err.Error())
-				chanAffiliate <- nil
-			} else {
-				chanAffiliate <- feeRecipient
-			}
-		}()
+	// Check the order against the asset listings, for pools that restrict
+	// trading to them
+	if networkID != 0 && pool.EnforceAssetRegistry {
+		if checkAssetRegistry(check, order, networkID, assetRegistry) {
+			return check.result()
+		}
+	}
 
-		// Check network ID
-		networkID := <-chanNetworkID
-		if networkID == 0 {
-			respondError(w, &zeroex.Error{
+	// Check sender address
+	if networkID != 0 && len(pool.SenderAddresses) != 0 {
+		exchangeAddress := pool.SenderAddresses[networkID][:]
+		exchangeAddressEmpty := bytes.Equal(exchangeAddress, emptyAddress[:])
+		exchangeAddressValid := bytes.Equal(exchangeAddress, order.SenderAddress[:])
+		if !exchangeAddressEmpty && !exchangeAddressValid {
+			if check.fail(&zeroex.Error{
 				Code:   zeroex.ErrorCodeValidationFailed,
 				Reason: "Validation Failed",
 				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "exchangeContractAddress",
+					Field:  "senderAddress",
 					Code:   zeroex.ValidationErrorCodeInvalidAddress,
-					Reason: "Unknown exchangeContractAddress",
+					Reason: "Invalid sender for this order pool / network",
 				}},
-			}, http.StatusBadRequest)
-			return
+			}, http.StatusBadRequest) {
+				return check.result()
+			}
 		}
+	}
 
-		// Check sender address
-		if len(pool.SenderAddresses) != 0 {
-			exchangeAddress := pool.SenderAddresses[networkID][:]
-			exchangeAddressEmpty := bytes.Equal(exchangeAddress, emptyAddress[:])
-			exchangeAddressValid := bytes.Equal(exchangeAddress, order.SenderAddress[:])
-			if !exchangeAddressEmpty && !exchangeAddressValid {
-				respondError(w, &zeroex.Error{
-					Code:   zeroex.ErrorCodeValidationFailed,
-					Reason: "Validation Failed",
-					ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-						Field:  "senderAddress",
-						Code:   zeroex.ValidationErrorCodeInvalidAddress,
-						Reason: "Invalid sender for this order pool / network",
-					}},
-				}, http.StatusBadRequest)
-				return
-			}
+	// Check pool expiration
+	if pool.Expiration > 0 && pool.Expiration < timeNow.Uint64() {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeOrderSubmissionDisabled,
+			Reason: "Order Pool Expired",
+		}, http.StatusBadRequest) {
+			return check.result()
 		}
+	}
 
-		// Check pool expiration
-		if pool.Expiration > 0 && pool.Expiration < timeNow.Uint64() {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeOrderSubmissionDisabled,
-				Reason: "Order Pool Expired",
-			}, http.StatusBadRequest)
-			return
+	// Check fee recipient address
+	feeRecipient := <-chanAffiliate
+	if feeRecipient == nil {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "feeRecipient",
+				Code:   zeroex.ValidationErrorCodeInvalidAddress,
+				Reason: "Invalid fee recipient",
+			}},
+		}, http.StatusBadRequest) {
+			return check.result()
 		}
+	}
 
-		// Check fee recipient address
-		feeRecipient := <-chanAffiliate
-		if feeRecipient == nil {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "feeRecipient",
-					Code:   zeroex.ValidationErrorCodeInvalidAddress,
-					Reason: "Invalid fee recipient",
-				}},
-			}, http.StatusBadRequest)
-			return
+	// Get pool fee
+	poolFee, err := pool.Fee()
+	if err != nil {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  "pool",
+				Code:   zeroex.ValidationErrorCodeInvalidAddress,
+				Reason: err.Error(),
+			}},
+		}, http.StatusInternalServerError) {
+			return check.result()
 		}
+	}
 
-		// Get pool fee
-		poolFee, err := pool.Fee()
-		if err != nil {
-			respondError(w, &zeroex.Error{
+	// A pool's Fee() value is the base fee for that pool. A maker's Discount()
+	// is the discount that recipient gets from the base fee. Thus, the minimum
+	// fee required is pool.Fee() - maker.Discount()
+	account := <-chanAccount
+	minFee := new(big.Int)
+	makerFee := new(big.Int).SetBytes(order.MakerFee[:])
+	takerFee := new(big.Int).SetBytes(order.TakerFee[:])
+	totalFee := new(big.Int).Add(makerFee, takerFee)
+	if poolFee != nil {
+		minFee.Sub(poolFee, account.Discount())
+	}
+	if poolFee != nil && totalFee.Cmp(minFee) < 0 {
+		if check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{
+				zeroex.ValidationError{
+					Field:  "makerFee",
+					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+					Reason: "Total fee must be at least: " + minFee.Text(10),
+				},
+				zeroex.ValidationError{
+					Field:  "takerFee",
+					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+					Reason: "Total fee must be at least: " + minFee.Text(10),
+				},
+			},
+		}, http.StatusBadRequest) {
+			return check.result()
+		}
+	}
+
+	// Check the maker can cover their open orders along with this one, for
+	// pools that reject over-committed makers. The indexer screens orders
+	// again, and drops any it rejects that weren't screened here, such as when
+	// no Ethereum node is configured or the check failed.
+	if commitment != nil && pool.CommitmentPolicy == poolModule.CommitmentPolicyReject {
+		if reject, _, err := commitment.Screen(&pooledOrder); err != nil {
+			log.Printf("Error screening %#x for over-commitment: %v", order.Hash(), err.Error())
+		} else if reject {
+			if check.fail(&zeroex.Error{
 				Code:   zeroex.ErrorCodeValidationFailed,
 				Reason: "Validation Failed",
 				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
-					Field:  "pool",
-					Code:   zeroex.ValidationErrorCodeInvalidAddress,
-					Reason: err.Error(),
+					Field:  "makerAssetAmount",
+					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+					Reason: dbModule.ErrOverCommitted.Error(),
 				}},
-			}, http.StatusInternalServerError)
-			return
+			}, http.StatusBadRequest) {
+				return check.result()
+			}
 		}
+	}
 
-		// A pool's Fee() value is the base fee for that pool. A maker's Discount()
-		// is the discount that recipient gets from the base fee. Thus, the minimum
-		// fee required is pool.Fee() - maker.Discount()
-		account := <-chanAccount
-		minFee := new(big.Int)
-		makerFee := new(big.Int).SetBytes(order.MakerFee[:])
-		takerFee := new(big.Int).SetBytes(order.TakerFee[:])
-		totalFee := new(big.Int).Add(makerFee, takerFee)
-		minFee.Sub(poolFee, account.Discount())
-		if totalFee.Cmp(minFee) < 0 {
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-				ValidationErrors: []zeroex.ValidationError{
-					zeroex.ValidationError{
-						Field:  "makerFee",
-						Code:   zeroex.ValidationErrorCodeValueOutOfRange,
-						Reason: "Total fee must be at least: " + minFee.Text(10),
-					},
-					zeroex.ValidationError{
-						Field:  "takerFee",
-						Code:   zeroex.ValidationErrorCodeValueOutOfRange,
-						Reason: "Total fee must be at least: " + minFee.Text(10),
-					},
-				},
-			}, http.StatusBadRequest)
-			return
-		}
+	if check.err != nil {
+		return check.result()
+	}
 
-		// Check if account is denied
-		if account.Blacklisted() {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusAccepted)
-			fmt.Fprintf(w, "")
-			return
-		}
+	// Orders from denied accounts are accepted but never published
+	if account.Blacklisted() {
+		return nil, http.StatusAccepted, true
+	}
 
-		// Send validated order to redis
-		order.PoolID = pool.ID
-		orderBytes := order.Bytes()
-		if ok := publisher.Publish(string(orderBytes[:])); !ok {
-			log.Println("Unable to publish order with hash %#x", order.Hash())
-			respondError(w, &zeroex.Error{
-				Code:   zeroex.ErrorCodeValidationFailed,
-				Reason: "Validation Failed",
-			}, http.StatusInternalServerError)
+	return nil, http.StatusOK, false
+}
+
+// checkAssetRegistry checks that the order trades an active pair of listed
+// assets, within each asset's trade limits, at a price no finer than the
+// quote asset's precision. It reports whether checking should stop.
+func checkAssetRegistry(check *orderCheck, order *types.Order, networkID uint64, assetRegistry AssetRegistry) bool {
+	assetFail := func(field, reason string, code zeroex.ValidationErrorCode, status int) bool {
+		return check.fail(&zeroex.Error{
+			Code:   zeroex.ErrorCodeValidationFailed,
+			Reason: "Validation Failed",
+			ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+				Field:  field,
+				Code:   code,
+				Reason: reason,
+			}},
+		}, status)
+	}
+
+	makerAsset, err := assetRegistry.GetAsset(order.MakerAssetData, networkID)
+	if err != nil {
+		log.Printf("Error retrieving asset: %v", err.Error())
+		return assetFail("makerAssetData", "Unable to check asset listing", zeroex.ValidationErrorCodeAddressNotSupported, http.StatusInternalServerError)
+	}
+	takerAsset, err := assetRegistry.GetAsset(order.TakerAssetData, networkID)
+	if err != nil {
+		log.Printf("Error retrieving asset: %v", err.Error())
+		return assetFail("takerAssetData", "Unable to check asset listing", zeroex.ValidationErrorCodeAddressNotSupported, http.StatusInternalServerError)
+	}
+	listed := true
+	for _, side := range []struct {
+		field  string
+		asset  *dbModule.Asset
+		amount *big.Int
+	}{
+		{"makerAssetData", makerAsset, order.MakerAssetAmount.Big()},
+		{"takerAssetData", takerAsset, order.TakerAssetAmount.Big()},
+	} {
+		if side.asset == nil {
+			listed = false
+			if assetFail(side.field, "Asset is not listed", zeroex.ValidationErrorCodeAddressNotSupported, http.StatusBadRequest) {
+				return true
+			}
+		} else if !side.asset.Active {
+			listed = false
+			if assetFail(side.field, fmt.Sprintf("%v is not active", side.asset.Symbol), zeroex.ValidationErrorCodeAddressNotSupported, http.StatusBadRequest) {
+				return true
+			}
+		} else if side.asset.MinTradeAmount != nil && side.amount.Cmp(side.asset.MinTradeAmount.Big()) < 0 {
+			amountField := strings.Replace(side.field, "Data", "Amount", 1)
+			if assetFail(amountField, fmt.Sprintf("%v amount must be at least %v", side.asset.Symbol, side.asset.MinTradeAmount.Big()), zeroex.ValidationErrorCodeValueOutOfRange, http.StatusBadRequest) {
+				return true
+			}
+		} else if side.asset.MaxTradeAmount != nil && side.amount.Cmp(side.asset.MaxTradeAmount.Big()) > 0 {
+			amountField := strings.Replace(side.field, "Data", "Amount", 1)
+			if assetFail(amountField, fmt.Sprintf("%v amount must be at most %v", side.asset.Symbol, side.asset.MaxTradeAmount.Big()), zeroex.ValidationErrorCodeValueOutOfRange, http.StatusBadRequest) {
+				return true
+			}
 		}
+	}
+	if !listed {
+		return false
+	}
 
-		// Everything is OK so just respond with success HTTP status code
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		fmt.Fprintf(w, "")
+	pair, err := assetRegistry.GetAssetPair(makerAsset, takerAsset)
+	if err != nil {
+		log.Printf("Error retrieving asset pair: %v", err.Error())
+		return assetFail("takerAssetData", "Unable to check asset pair listing", zeroex.ValidationErrorCodeAddressNotSupported, http.StatusInternalServerError)
+	}
+	if pair == nil || !pair.Active {
+		return assetFail("takerAssetData", fmt.Sprintf("%v-%v is not an active asset pair", makerAsset.Symbol, takerAsset.Symbol), zeroex.ValidationErrorCodeAddressNotSupported, http.StatusBadRequest)
+	}
+
+	// Prices are quoted in asset B of the pair
+	base, quote := makerAsset, takerAsset
+	baseAmount, quoteAmount := order.MakerAssetAmount.Big(), order.TakerAssetAmount.Big()
+	if pair.AssetSymbolA != makerAsset.Symbol {
+		base, quote = takerAsset, makerAsset
+		baseAmount, quoteAmount = quoteAmount, baseAmount
+	}
+	if !dbModule.PriceFitsPrecision(base, quote, baseAmount, quoteAmount) {
+		return assetFail("takerAssetAmount", fmt.Sprintf("%v price may have at most %v decimal places", pair.AssetSymbolA+"-"+pair.AssetSymbolB, quote.Precision), zeroex.ValidationErrorCodeValueOutOfRange, http.StatusBadRequest)
 	}
+	return false
 }
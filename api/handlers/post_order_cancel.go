@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+// PostOrderCancel accepts a maker's EIP712 signed soft cancel, which removes
+// orders from our order book without an on-chain transaction. Verified
+// requests are published for the cancellation indexer to apply.
+//
+// Soft cancels do not stop anyone who already holds a copy of the orders from
+// filling them on-chain. Makers who need that guarantee must cancel with the
+// exchange contract.
+func PostOrderCancel(publisher channels.Publisher, exchangeLookup ExchangeLookup) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Check HTTP request method
+		if r.Method != "POST" {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request method",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Check HTTP request content type
+		var contentType string
+		if contentTypeValue, ok := r.Header["Content-Type"]; ok {
+			contentType = strings.Split(contentTypeValue[0], ";")[0]
+		}
+		if contentType != "application/json" {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request content type",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Parse HTTP request body content
+		cancel := types.SoftCancel{}
+		contentBytes, err := ioutil.ReadAll(r.Body)
+		if err != nil && err != io.EOF {
+			log.Printf("Error reading content: %v", err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Error reading content",
+			}, http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(contentBytes, &cancel); err != nil {
+			log.Printf("Malformed JSON '%v': %v", string(contentBytes), err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeMalformedJSON,
+				Reason: "Malformed JSON",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		chanNetworkID := exchangeLookup.ExchangeIsKnown(cancel.ExchangeAddress)
+
+		if err := cancel.Validate(); err != nil {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Validation Failed",
+				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+					Field:  "orderHashes",
+					Code:   zeroex.ValidationErrorCodeRequiredField,
+					Reason: err.Error(),
+				}},
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Check cancel expiration time, so a captured request can't be replayed
+		// against orders the maker posts later
+		if big.NewInt(time.Now().Unix()).Cmp(cancel.Expiration.Big()) > 0 {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Validation Failed",
+				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+					Field:  "expirationTimeSeconds",
+					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+					Reason: "Cancel request expired",
+				}},
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Verify cancel signature
+		if !cancel.Signature.Supported() || !cancel.Signature.Verify(cancel.Maker, cancel.Hash()) {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Validation Failed",
+				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+					Field:  "signature",
+					Code:   zeroex.ValidationErrorCodeInvalidSignatureOrHash,
+					Reason: "Signature validation failed",
+				}},
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Check network ID
+		if networkID := <-chanNetworkID; networkID == 0 {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Validation Failed",
+				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+					Field:  "exchangeAddress",
+					Code:   zeroex.ValidationErrorCodeInvalidAddress,
+					Reason: "Unknown exchangeAddress",
+				}},
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Send validated cancel to redis
+		cancelBytes, err := json.Marshal(&cancel)
+		if err != nil {
+			log.Printf("Error encoding cancel: %v", err.Error())
+		}
+		if ok := publisher.Publish(string(cancelBytes)); err != nil || !ok {
+			log.Printf("Unable to publish soft cancel with hash %#x", cancel.Hash())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Validation Failed",
+			}, http.StatusInternalServerError)
+			return
+		}
+
+		// The orders are removed from the book asynchronously
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "{\"hash\":\"%#x\"}", cancel.Hash())
+	}
+}
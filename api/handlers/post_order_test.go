@@ -0,0 +1,245 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	accountsModule "github.com/notegio/openrelay/accounts"
+	affiliatesModule "github.com/notegio/openrelay/affiliates"
+	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+type testBaseFee struct{}
+
+func (baseFee *testBaseFee) Get() (*big.Int, error) { return big.NewInt(0), nil }
+func (baseFee *testBaseFee) Set(*big.Int) error     { return nil }
+
+type testAccount struct{}
+
+func (account *testAccount) Blacklisted() bool  { return false }
+func (account *testAccount) Discount() *big.Int { return big.NewInt(0) }
+
+type testAccountService struct{}
+
+func (service *testAccountService) Get(*types.Address) accountsModule.Account {
+	return &testAccount{}
+}
+func (service *testAccountService) Set(*types.Address, accountsModule.Account) error { return nil }
+
+type testAffiliate struct{}
+
+func (affiliate *testAffiliate) Fee() *big.Int { return big.NewInt(0) }
+
+type testAffiliateService struct {
+	known bool
+}
+
+func (service *testAffiliateService) Get(*types.Address) (affiliatesModule.Affiliate, error) {
+	if !service.known {
+		return nil, errors.New("affiliate not found")
+	}
+	return &testAffiliate{}, nil
+}
+func (service *testAffiliateService) Set(*types.Address, affiliatesModule.Affiliate) error {
+	return nil
+}
+func (service *testAffiliateService) List() ([]types.Address, error) {
+	return []types.Address{}, nil
+}
+
+// testExchangeLookup knows a single v2 exchange on network 1
+type testExchangeLookup struct {
+	exchange *dbModule.Exchange
+}
+
+func (lookup *testExchangeLookup) ExchangeIsKnown(address *types.Address) <-chan uint64 {
+	result := make(chan uint64, 1)
+	if *address == *lookup.exchange.Address {
+		result <- lookup.exchange.Network
+	} else {
+		result <- 0
+	}
+	return result
+}
+
+func (lookup *testExchangeLookup) GetExchange(address *types.Address) (*dbModule.Exchange, error) {
+	if *address != *lookup.exchange.Address {
+		return nil, errors.New("exchange not found")
+	}
+	return lookup.exchange, nil
+}
+
+func newTestExchangeLookup() *testExchangeLookup {
+	address, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	return &testExchangeLookup{&dbModule.Exchange{Address: address, Network: 1, ProtocolVersion: types.ProtocolV2}}
+}
+
+// testAssetRegistry lists assets by their asset data, and pairs by their
+// symbols
+type testAssetRegistry struct {
+	assets map[string]*dbModule.Asset
+	pairs  []*dbModule.AssetPair
+	err    error
+}
+
+func (registry *testAssetRegistry) GetAsset(assetData types.AssetData, networkID uint64) (*dbModule.Asset, error) {
+	return registry.assets[fmt.Sprintf("%#x", assetData[:])], registry.err
+}
+
+func (registry *testAssetRegistry) GetAssetPair(assetA, assetB *dbModule.Asset) (*dbModule.AssetPair, error) {
+	for _, pair := range registry.pairs {
+		if (pair.AssetSymbolA == assetA.Symbol && pair.AssetSymbolB == assetB.Symbol) ||
+			(pair.AssetSymbolA == assetB.Symbol && pair.AssetSymbolB == assetA.Symbol) {
+			return pair, nil
+		}
+	}
+	return nil, nil
+}
+
+type testSoftCancels struct{}
+
+func (softCancels *testSoftCancels) IsSoftCancelled(*types.Order) (bool, error) { return false, nil }
+
+// testAsset lists an 18 decimal ERC20 token, tradable in amounts of up to
+// 1000 tokens
+func testAsset(symbol, token string, precision uint16) *dbModule.Asset {
+	address, _ := common.HexToAddress(token)
+	data := types.AssetData(append(append([]byte{0xf4, 0x72, 0x61, 0xb0}, make([]byte, 12)...), address[:]...))
+	maxTradeAmount, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	return &dbModule.Asset{
+		Symbol:         symbol,
+		Address:        address,
+		Decimals:       18,
+		Data:           &data,
+		Precision:      precision,
+		MinTradeAmount: common.BigToUint256(big.NewInt(0)),
+		MaxTradeAmount: common.BigToUint256(maxTradeAmount),
+		Active:         true,
+	}
+}
+
+// newTestAssetRegistry lists the sample order's assets as an active ZRX-WETH
+// pair, with prices to 2 decimal places
+func newTestAssetRegistry() *testAssetRegistry {
+	registry := &testAssetRegistry{assets: map[string]*dbModule.Asset{}}
+	for _, asset := range []*dbModule.Asset{
+		testAsset("ZRX", "0x1dad4783cf3fe3085c1426157ab175a6119a04ba", 2),
+		testAsset("WETH", "0x05d090b51c40b020eab3bfcb6a2dff130df22e9c", 2),
+	} {
+		registry.assets[fmt.Sprintf("%#x", []byte(*asset.Data))] = asset
+	}
+	registry.pairs = []*dbModule.AssetPair{&dbModule.AssetPair{AssetSymbolA: "ZRX", AssetSymbolB: "WETH", Active: true}}
+	return registry
+}
+
+func testPool(enforceAssetRegistry bool) *poolModule.Pool {
+	pool := &poolModule.Pool{ID: []byte("default"), EnforceAssetRegistry: enforceAssetRegistry}
+	pool.SetBaseFee(&testBaseFee{})
+	return pool
+}
+
+func postOrderRequest(t *testing.T, handler func(http.ResponseWriter, *http.Request, *poolModule.Pool), pool *poolModule.Pool, order *dbModule.Order) *httptest.ResponseRecorder {
+	body, err := json.Marshal(&order.Order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	r := httptest.NewRequest("POST", "/v2/order", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler(w, r, pool)
+	return w
+}
+
+func TestPostOrderAssetRegistry(t *testing.T) {
+	tokens := func(amount string) *types.Uint256 {
+		value, _ := new(big.Int).SetString(amount, 10)
+		return common.BigToUint256(value)
+	}
+	for _, c := range []struct {
+		name     string
+		amend    func(order *dbModule.Order)
+		registry func(registry *testAssetRegistry)
+		code     int
+		field    string
+	}{
+		{"listed", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {}, http.StatusCreated, ""},
+		{"unlisted maker asset", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			delete(registry.assets, "0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba")
+		}, http.StatusBadRequest, "makerAssetData"},
+		{"inactive taker asset", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.assets["0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c"].Active = false
+		}, http.StatusBadRequest, "takerAssetData"},
+		{"unavailable registry", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.err = errors.New("connection refused")
+		}, http.StatusInternalServerError, "makerAssetData"},
+		{"unlisted pair", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.pairs = nil
+		}, http.StatusBadRequest, "takerAssetData"},
+		{"inactive pair", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.pairs[0].Active = false
+		}, http.StatusBadRequest, "takerAssetData"},
+		{"at maximum trade amount", func(order *dbModule.Order) {
+			order.MakerAssetAmount = tokens("1000000000000000000000")
+			order.TakerAssetAmount = tokens("20000000000000000000")
+		}, func(registry *testAssetRegistry) {}, http.StatusCreated, ""},
+		{"above maximum trade amount", func(order *dbModule.Order) {
+			order.MakerAssetAmount = tokens("1000000000000000000001")
+		}, func(registry *testAssetRegistry) {}, http.StatusBadRequest, "makerAssetAmount"},
+		{"at minimum trade amount", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.assets["0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c"].MinTradeAmount = tokens("1000000000000000000")
+		}, http.StatusCreated, ""},
+		{"below minimum trade amount", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.assets["0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c"].MinTradeAmount = tokens("1000000000000000001")
+		}, http.StatusBadRequest, "takerAssetAmount"},
+		{"price beyond precision", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			registry.assets["0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c"].Precision = 1
+		}, http.StatusBadRequest, "takerAssetAmount"},
+		{"price quoted in maker asset", func(order *dbModule.Order) {}, func(registry *testAssetRegistry) {
+			// Selling ZRX at 0.02 WETH is buying WETH at 50 ZRX
+			registry.assets["0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba"].Precision = 0
+			registry.pairs[0].AssetSymbolA, registry.pairs[0].AssetSymbolB = "WETH", "ZRX"
+		}, http.StatusCreated, ""},
+	} {
+		registry := newTestAssetRegistry()
+		c.registry(registry)
+		publisher, _ := channels.MockPublisher()
+		handler := handlers.PostOrder(publisher, &testAccountService{}, &testAffiliateService{true}, newTestExchangeLookup(), registry, &testSoftCancels{}, nil)
+		w := postOrderRequest(t, handler, testPool(true), signedSampleOrder(t, c.amend))
+		if w.Code != c.code {
+			t.Errorf("%v: expected %v, got %v: %v", c.name, c.code, w.Code, w.Body.String())
+			continue
+		}
+		if c.field == "" {
+			continue
+		}
+		result := &zeroex.Error{}
+		if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+			t.Fatalf(err.Error())
+		}
+		if len(result.ValidationErrors) != 1 || result.ValidationErrors[0].Field != c.field {
+			t.Errorf("%v: expected a %v error, got %v", c.name, c.field, w.Body.String())
+		}
+	}
+}
+
+func TestPostOrderAssetRegistryNotEnforced(t *testing.T) {
+	registry := newTestAssetRegistry()
+	registry.pairs = nil
+	publisher, _ := channels.MockPublisher()
+	handler := handlers.PostOrder(publisher, &testAccountService{}, &testAffiliateService{true}, newTestExchangeLookup(), registry, &testSoftCancels{}, nil)
+	if w := postOrderRequest(t, handler, testPool(false), signedSampleOrder(t, func(order *dbModule.Order) {})); w.Code != http.StatusCreated {
+		t.Errorf("Expected pools not enforcing the registry to accept unlisted pairs, got %v: %v", w.Code, w.Body.String())
+	}
+}
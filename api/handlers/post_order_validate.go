@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	accountsModule "github.com/notegio/openrelay/accounts"
+	affiliatesModule "github.com/notegio/openrelay/affiliates"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+// PostOrderValidate runs an order through every check PostOrder applies,
+// reporting all of the failures instead of stopping at the first. Given a
+// fundChecker and conn it also checks the maker's funds and the pool's filter
+// contract, which otherwise only happen after an order is accepted. Nothing is
+// published.
+func PostOrderValidate(
+	accounts accountsModule.AccountService,
+	affiliates affiliatesModule.AffiliateService,
+	exchangeLookup ExchangeLookup,
+	assetRegistry AssetRegistry,
+	softCancels SoftCancelLookup,
+	commitment dbModule.CommitmentScreen,
+	fundChecker funds.OrderValidator,
+	conn bind.ContractCaller,
+) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
+
+	return func(w http.ResponseWriter, r *http.Request, pool *poolModule.Pool) {
+
+		// Check HTTP request method
+		if r.Method != "POST" {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request method",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Check HTTP request content type
+		var contentType string
+		if contentTypeValue, ok := r.Header["Content-Type"]; ok {
+			contentType = strings.Split(contentTypeValue[0], ";")[0]
+		}
+		if contentType != "application/json" {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request content type",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Parse HTTP request body content
+		order := types.Order{}
+		contentBytes, err := ioutil.ReadAll(r.Body)
+		if err != nil && err != io.EOF {
+			log.Printf("Error reading content: %v", err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Error reading content",
+			}, http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(contentBytes, &order); err != nil {
+			log.Printf("Malformed JSON '%v': %v", string(contentBytes), err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeMalformedJSON,
+				Reason: "Malformed JSON",
+			}, http.StatusBadRequest)
+			return
+		}
+		order.PoolID = pool.ID
+
+		check := &orderCheck{exhaustive: true}
+		chanNetworkID := exchangeLookup.ExchangeIsKnown(order.ExchangeAddress)
+		chanFunded := make(chan error, 1)
+		go func() {
+			chanFunded <- checkFunds(fundChecker, &order)
+		}()
+		runOrderChecks(check, &order, pool, accounts, affiliates, exchangeLookup, assetRegistry, softCancels, commitment)
+
+		// Check the pool's filter contract
+		if networkID := <-chanNetworkID; networkID != 0 && conn != nil {
+			pool.SetConn(conn)
+			if ok, err := pool.CheckFilter(&order, networkID); err != nil {
+				log.Printf("Error checking pool filter: %v", err.Error())
+				check.fail(&zeroex.Error{
+					ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+						Field:  "pool",
+						Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+						Reason: "Unable to check pool filter",
+					}},
+				}, http.StatusInternalServerError)
+			} else if !ok {
+				check.fail(&zeroex.Error{
+					ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+						Field:  "pool",
+						Code:   zeroex.ValidationErrorCodeUnsupportedOption,
+						Reason: "Order rejected by pool filter",
+					}},
+				}, http.StatusBadRequest)
+			}
+		}
+
+		// Check maker funds
+		if err := <-chanFunded; err != nil {
+			check.fail(&zeroex.Error{
+				ValidationErrors: []zeroex.ValidationError{zeroex.ValidationError{
+					Field:  "makerAssetAmount",
+					Code:   zeroex.ValidationErrorCodeValueOutOfRange,
+					Reason: err.Error(),
+				}},
+			}, http.StatusBadRequest)
+		}
+
+		result := &zeroex.OrderValidationResult{
+			Hash:             fmt.Sprintf("%#x", order.Hash()),
+			Valid:            check.err == nil,
+			ValidationErrors: []zeroex.ValidationError{},
+		}
+		if check.err != nil {
+			result.ValidationErrors = check.err.ValidationErrors
+		}
+		response, err := json.Marshal(result)
+		if err != nil {
+			log.Printf(err.Error())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// checkFunds reports why the maker can't fund order, or nil if they can. The
+// RPC backed validators panic when the node can't be reached, which is
+// reported rather than left to take down the request.
+func checkFunds(fundChecker funds.OrderValidator, order *types.Order) (err error) {
+	if fundChecker == nil {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error checking funds: %v", r)
+			err = fmt.Errorf("Unable to check maker funds")
+		}
+	}()
+	funded, err := fundChecker.ValidateOrder(order)
+	if err != nil {
+		log.Printf("Error checking funds: %v", err.Error())
+		return fmt.Errorf("Unable to check maker funds")
+	}
+	if !funded {
+		return fmt.Errorf("Maker has insufficient balance or allowance")
+	}
+	return nil
+}
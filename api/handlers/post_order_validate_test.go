@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+type testFundChecker struct {
+	funded bool
+	err    error
+}
+
+func (checker *testFundChecker) ValidateOrder(*types.Order) (bool, error) {
+	return checker.funded, checker.err
+}
+
+// expiredOrder amends an order to expire long before it is checked
+func expiredOrder(order *dbModule.Order) {
+	order.ExpirationTimestampInSec = common.BigToUint256(big.NewInt(1))
+}
+
+func validationResult(t *testing.T, body []byte) *zeroex.OrderValidationResult {
+	result := &zeroex.OrderValidationResult{}
+	if err := json.Unmarshal(body, result); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return result
+}
+
+func failedFields(validationErrors []zeroex.ValidationError) string {
+	fields := []string{}
+	for _, validationError := range validationErrors {
+		fields = append(fields, validationError.Field)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+func TestPostOrderValidateValid(t *testing.T) {
+	handler := handlers.PostOrderValidate(&testAccountService{}, &testAffiliateService{true}, newTestExchangeLookup(), newTestAssetRegistry(), &testSoftCancels{}, nil, &testFundChecker{funded: true}, nil)
+	order := signedSampleOrder(t, func(order *dbModule.Order) {})
+	w := postOrderRequest(t, handler, testPool(true), order)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v: %v", w.Code, w.Body.String())
+	}
+	result := validationResult(t, w.Body.Bytes())
+	if !result.Valid || len(result.ValidationErrors) != 0 {
+		t.Errorf("Expected the order to be valid, got %v", w.Body.String())
+	}
+	if result.Hash != fmt.Sprintf("%#x", order.Hash()) {
+		t.Errorf("Unexpected hash %v", result.Hash)
+	}
+}
+
+func TestPostOrderValidateReportsAllFailures(t *testing.T) {
+	registry := newTestAssetRegistry()
+	registry.pairs = nil
+	pool := testPool(true)
+	pool.Expiration = 1
+	handler := handlers.PostOrderValidate(&testAccountService{}, &testAffiliateService{false}, newTestExchangeLookup(), registry, &testSoftCancels{}, nil, &testFundChecker{funded: false}, nil)
+	w := postOrderRequest(t, handler, pool, signedSampleOrder(t, expiredOrder))
+	// Validation reports on the order, so even failed checks are a success
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v: %v", w.Code, w.Body.String())
+	}
+	result := validationResult(t, w.Body.Bytes())
+	if result.Valid {
+		t.Errorf("Expected the order to be invalid")
+	}
+	expected := "expirationUnixTimestampSec,feeRecipient,makerAssetAmount,pool,takerAssetData"
+	if fields := failedFields(result.ValidationErrors); fields != expected {
+		t.Errorf("Expected failures of %v, got %v", expected, w.Body.String())
+	}
+}
+
+func TestPostOrderValidateFundCheckError(t *testing.T) {
+	handler := handlers.PostOrderValidate(&testAccountService{}, &testAffiliateService{true}, newTestExchangeLookup(), newTestAssetRegistry(), &testSoftCancels{}, nil, &testFundChecker{err: errors.New("connection refused")}, nil)
+	w := postOrderRequest(t, handler, testPool(true), signedSampleOrder(t, func(order *dbModule.Order) {}))
+	result := validationResult(t, w.Body.Bytes())
+	if result.Valid || len(result.ValidationErrors) != 1 || result.ValidationErrors[0].Reason != "Unable to check maker funds" {
+		t.Errorf("Expected the fund check to be reported as unavailable, got %v", w.Body.String())
+	}
+}
+
+func TestPostOrderStopsAtFirstFailure(t *testing.T) {
+	registry := newTestAssetRegistry()
+	registry.pairs = nil
+	pool := testPool(true)
+	pool.Expiration = 1
+	publisher, published := channels.MockPublisher()
+	handler := handlers.PostOrder(publisher, &testAccountService{}, &testAffiliateService{false}, newTestExchangeLookup(), registry, &testSoftCancels{}, nil)
+	w := postOrderRequest(t, handler, pool, signedSampleOrder(t, expiredOrder))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %v: %v", w.Code, w.Body.String())
+	}
+	result := &zeroex.Error{}
+	if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if fields := failedFields(result.ValidationErrors); fields != "expirationUnixTimestampSec" {
+		t.Errorf("Expected only the expiration to be reported, got %v", w.Body.String())
+	}
+	select {
+	case delivery := <-published:
+		t.Errorf("Unexpected order published: %v", delivery.Payload())
+	default:
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	accountsModule "github.com/notegio/openrelay/accounts"
+	affiliatesModule "github.com/notegio/openrelay/affiliates"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+	"github.com/notegio/openrelay/zeroex"
+)
+
+// DefaultMaxBatchSize is the largest number of orders PostOrders accepts in a
+// single request unless configured otherwise.
+const DefaultMaxBatchSize = 100
+
+// PostOrders accepts an array of orders, validating each independently the
+// same way PostOrder does. The response lists a result for each order in the
+// order they were submitted, and all accepted orders are published together.
+func PostOrders(
+	publisher channels.Publisher,
+	accounts accountsModule.AccountService,
+	affiliates affiliatesModule.AffiliateService,
+	exchangeLookup ExchangeLookup,
+	assetRegistry AssetRegistry,
+	softCancels SoftCancelLookup,
+	commitment dbModule.CommitmentScreen,
+	maxBatchSize int,
+) func(http.ResponseWriter, *http.Request, *poolModule.Pool) {
+
+	return func(w http.ResponseWriter, r *http.Request, pool *poolModule.Pool) {
+
+		// Check HTTP request method
+		if r.Method != "POST" {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request method",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Check HTTP request content type
+		var contentType string
+		if contentTypeValue, ok := r.Header["Content-Type"]; ok {
+			contentType = strings.Split(contentTypeValue[0], ";")[0]
+		}
+		if contentType != "application/json" {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Unsupported HTTP request content type",
+			}, http.StatusBadRequest)
+			return
+		}
+
+		// Parse HTTP request body content. Each element is parsed separately so
+		// one malformed order doesn't fail the whole batch.
+		contentBytes, err := ioutil.ReadAll(r.Body)
+		if err != nil && err != io.EOF {
+			log.Printf("Error reading content: %v", err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: "Error reading content",
+			}, http.StatusInternalServerError)
+			return
+		}
+		rawOrders := []json.RawMessage{}
+		if err := json.Unmarshal(contentBytes, &rawOrders); err != nil {
+			log.Printf("Malformed JSON '%v': %v", string(contentBytes), err.Error())
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeMalformedJSON,
+				Reason: "Malformed JSON",
+			}, http.StatusBadRequest)
+			return
+		}
+		if len(rawOrders) > maxBatchSize {
+			respondError(w, &zeroex.Error{
+				Code:   zeroex.ErrorCodeValidationFailed,
+				Reason: fmt.Sprintf("Batch exceeds maximum size of %v orders", maxBatchSize),
+			}, http.StatusBadRequest)
+			return
+		}
+
+		results := make([]zeroex.OrderSubmissionResult, len(rawOrders))
+		orders := make([]*types.Order, len(rawOrders))
+		publish := make([]bool, len(rawOrders))
+		var wg sync.WaitGroup
+		for i, rawOrder := range rawOrders {
+			order := &types.Order{}
+			if err := json.Unmarshal(rawOrder, order); err != nil {
+				results[i].Error = &zeroex.Error{
+					Code:   zeroex.ErrorCodeMalformedJSON,
+					Reason: "Malformed JSON",
+				}
+				continue
+			}
+			orders[i] = order
+			wg.Add(1)
+			go func(i int, order *types.Order) {
+				defer wg.Done()
+				validationErr, _, blacklisted := checkOrder(order, pool, accounts, affiliates, exchangeLookup, assetRegistry, softCancels, commitment)
+				// checkOrder sets the exchange's domain, so the order can't be
+				// hashed until it's run
+				results[i].Hash = fmt.Sprintf("%#x", order.Hash())
+				if validationErr != nil {
+					results[i].Error = validationErr
+					return
+				}
+				// Orders from denied accounts are reported as accepted, as they
+				// would be by PostOrder, but never published.
+				results[i].Accepted = true
+				publish[i] = !blacklisted
+			}(i, order)
+		}
+		wg.Wait()
+
+		// Send validated orders to redis
+		payloads := []string{}
+		for i, order := range orders {
+			if publish[i] {
+				order.PoolID = pool.ID
+				orderBytes := order.Bytes()
+				payloads = append(payloads, string(orderBytes[:]))
+			}
+		}
+		if len(payloads) > 0 {
+			if ok := channels.PublishBatch(publisher, payloads); !ok {
+				log.Printf("Unable to publish batch of %v orders", len(payloads))
+				respondError(w, &zeroex.Error{
+					Code:   zeroex.ErrorCodeValidationFailed,
+					Reason: "Validation Failed",
+				}, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		response, err := json.Marshal(results)
+		if err != nil {
+			log.Printf(err.Error())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
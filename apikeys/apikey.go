@@ -0,0 +1,259 @@
+package apikeys
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/ratelimit"
+)
+
+// tokenPrefix marks OpenRelay API keys, so they're recognizable if leaked
+const tokenPrefix = "or_"
+
+// prefixLength is how much of a key is stored in the clear to identify it
+const prefixLength = len(tokenPrefix) + 12
+
+var ErrInvalidKey = errors.New("Invalid API key")
+
+// APIKey is an API key issued to an integration partner. Only a hash of the
+// key is stored.
+type APIKey struct {
+	KeyHash []byte `gorm:"primary_key"`
+	// Prefix is the start of the key, to identify it for revocation
+	Prefix string `gorm:"unique_index"`
+	Owner  string `gorm:"index"`
+	// RateLimit replaces the per IP limits on each route for requests made
+	// with this key, in the form "<count>/<period>". Empty keeps the route's
+	// limits.
+	RateLimit string
+	// Pools is a JSON list of the pool names the key may use. The default
+	// pool's name is "". An empty value allows every pool.
+	Pools         string `gorm:"type:text"`
+	// PrivateOrders lets the key search orders in any status across the
+	// whole book, rather than only a single maker's order history
+	PrivateOrders bool
+	Admin         bool
+	CreatedAt     time.Time
+	RevokedAt     *time.Time
+}
+
+// Limit returns the key's own rate limit, or nil to use the route's limits
+func (key *APIKey) Limit() *ratelimit.Limit {
+	if key.RateLimit == "" {
+		return nil
+	}
+	limit, err := ratelimit.ParseLimit(key.RateLimit)
+	if err != nil {
+		log.Printf("Invalid rate limit on API key %v: %v", key.Prefix, err.Error())
+		return nil
+	}
+	return &limit
+}
+
+// AllowedPools returns the names of the pools the key may use, or nil if it
+// may use any pool
+func (key *APIKey) AllowedPools() []string {
+	if key.Pools == "" {
+		return nil
+	}
+	pools := []string{}
+	if err := json.Unmarshal([]byte(key.Pools), &pools); err != nil {
+		log.Printf("Invalid pools on API key %v: %v", key.Prefix, err.Error())
+		return []string{}
+	}
+	return pools
+}
+
+// SetAllowedPools limits the key to the named pools. nil allows any pool.
+func (key *APIKey) SetAllowedPools(pools []string) {
+	if pools == nil {
+		key.Pools = ""
+		return
+	}
+	data, _ := json.Marshal(pools)
+	key.Pools = string(data)
+}
+
+func (key *APIKey) AllowsPool(name string) bool {
+	pools := key.AllowedPools()
+	if pools == nil {
+		return true
+	}
+	for _, pool := range pools {
+		if pool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey returns the hash a key is stored under. Keys are random, so a fast
+// hash is sufficient.
+func HashKey(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// GenerateKey creates a new random API key
+func GenerateKey() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return tokenPrefix + hex.EncodeToString(data), nil
+}
+
+// Issue generates a key for the owner and privileges described by key, and
+// saves its hash. The key itself is returned, and can't be recovered later.
+func Issue(db *gorm.DB, key *APIKey) (string, error) {
+	if key.RateLimit != "" {
+		if _, err := ratelimit.ParseLimit(key.RateLimit); err != nil {
+			return "", err
+		}
+	}
+	token, err := GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	key.KeyHash = HashKey(token)
+	key.Prefix = token[:prefixLength]
+	if err := db.Create(key).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke revokes the key with the given prefix, returning the number of keys
+// revoked
+func Revoke(db *gorm.DB, prefix string) (int64, error) {
+	result := db.Model(&APIKey{}).Where("prefix = ? AND revoked_at IS NULL", prefix).Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying key
+func NewContext(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromRequest returns the API key a request was authenticated with, or nil
+// for anonymous requests
+func FromRequest(r *http.Request) *APIKey {
+	key, _ := r.Context().Value(contextKey{}).(*APIKey)
+	return key
+}
+
+type KeyStore interface {
+	// Authenticate returns the active key matching token, or ErrInvalidKey
+	Authenticate(token string) (*APIKey, error)
+}
+
+// DefaultCacheSize is how many keys a KeyStore from NewDBKeyStore holds by
+// default
+const DefaultCacheSize = 1000
+
+type cachedKey struct {
+	keyHash string
+	key     *APIKey
+	expires time.Time
+}
+
+type dbKeyStore struct {
+	db      *gorm.DB
+	ttl     time.Duration
+	size    int
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func (store *dbKeyStore) cached(keyHash string) (*APIKey, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	element, ok := store.entries[keyHash]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedKey)
+	if time.Now().After(entry.expires) {
+		store.order.Remove(element)
+		delete(store.entries, keyHash)
+		return nil, false
+	}
+	store.order.MoveToFront(element)
+	return entry.key, true
+}
+
+func (store *dbKeyStore) cache(keyHash string, key *APIKey) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	expires := time.Now().Add(store.ttl)
+	if element, ok := store.entries[keyHash]; ok {
+		element.Value.(*cachedKey).key = key
+		element.Value.(*cachedKey).expires = expires
+		store.order.MoveToFront(element)
+		return
+	}
+	store.entries[keyHash] = store.order.PushFront(&cachedKey{keyHash, key, expires})
+	for store.order.Len() > store.size {
+		oldest := store.order.Back()
+		store.order.Remove(oldest)
+		delete(store.entries, oldest.Value.(*cachedKey).keyHash)
+	}
+}
+
+func (store *dbKeyStore) Authenticate(token string) (*APIKey, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, ErrInvalidKey
+	}
+	keyHash := HashKey(token)
+	if key, ok := store.cached(string(keyHash)); ok {
+		return key, nil
+	}
+	key := &APIKey{}
+	if result := store.db.Model(&APIKey{}).Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(key); result.RecordNotFound() {
+		// Misses aren't cached, so made up keys can't fill the cache and push
+		// out real ones
+		return nil, ErrInvalidKey
+	} else if result.Error != nil {
+		return nil, result.Error
+	}
+	store.cache(string(keyHash), key)
+	return key, nil
+}
+
+// NewDBKeyStore creates a KeyStore reading keys from db. Up to size keys are
+// cached for ttl, so revoking a key can take up to ttl to take effect.
+func NewDBKeyStore(db *gorm.DB, ttl time.Duration, size int) KeyStore {
+	if size <= 0 {
+		size = 1
+	}
+	return &dbKeyStore{db, ttl, size, sync.Mutex{}, make(map[string]*list.Element), list.New()}
+}
+
+type MockKeyStore struct {
+	keys map[string]*APIKey
+}
+
+func (store *MockKeyStore) Authenticate(token string) (*APIKey, error) {
+	if key, ok := store.keys[token]; ok {
+		return key, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+func NewMockKeyStore(keys map[string]*APIKey) KeyStore {
+	return &MockKeyStore{keys}
+}
@@ -0,0 +1,114 @@
+package apikeys_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+
+	"github.com/notegio/openrelay/apikeys"
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+func TestGenerateKey(t *testing.T) {
+	first, err := apikeys.GenerateKey()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	second, err := apikeys.GenerateKey()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if first == second {
+		t.Errorf("Keys should be random")
+	}
+	if !strings.HasPrefix(first, "or_") {
+		t.Errorf("Unexpected key format: %v", first)
+	}
+	if bytes.Equal(apikeys.HashKey(first), apikeys.HashKey(second)) {
+		t.Errorf("Different keys should hash differently")
+	}
+}
+
+func TestAllowsPool(t *testing.T) {
+	key := &apikeys.APIKey{}
+	if !key.AllowsPool("anything") {
+		t.Errorf("Keys without pools should allow any pool")
+	}
+	key.SetAllowedPools([]string{"", "partner"})
+	if !key.AllowsPool("") || !key.AllowsPool("partner") {
+		t.Errorf("Key should allow its pools: %v", key.Pools)
+	}
+	if key.AllowsPool("other") {
+		t.Errorf("Key should not allow other pools")
+	}
+	key.SetAllowedPools([]string{})
+	if key.AllowsPool("") {
+		t.Errorf("Key with an empty pool list should not allow any pool")
+	}
+}
+
+func TestKeyLimit(t *testing.T) {
+	key := &apikeys.APIKey{}
+	if key.Limit() != nil {
+		t.Errorf("Keys without a rate limit should use the route's limits")
+	}
+	key.RateLimit = "1000/1m"
+	if limit := key.Limit(); limit == nil || limit.Count != 1000 || limit.Period != time.Minute {
+		t.Errorf("Unexpected limit: %v", limit)
+	}
+}
+
+func TestDBKeyStore(t *testing.T) {
+	db, err := dbModule.GetDB(fmt.Sprintf("postgres://%v@%v", os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_HOST")), os.Getenv("POSTGRES_PASSWORD"))
+	if err != nil {
+		t.Fatalf("Could not get db: %v", err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&apikeys.APIKey{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	store := apikeys.NewDBKeyStore(tx, time.Minute, 1)
+
+	// Unknown keys aren't cached, so a key saved after a failed lookup works
+	// straight away
+	token, err := apikeys.GenerateKey()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := store.Authenticate(token); err != apikeys.ErrInvalidKey {
+		t.Fatalf("Expected unknown key to be invalid, got %v", err)
+	}
+	if err := tx.Create(&apikeys.APIKey{KeyHash: apikeys.HashKey(token), Prefix: token[:15], Owner: "partner"}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	if key, err := store.Authenticate(token); err != nil || key.Owner != "partner" {
+		t.Fatalf("Expected key to authenticate, got %v", err)
+	}
+
+	// Revocations wait for the cache to expire, or for the key to be evicted
+	if _, err := apikeys.Revoke(tx, token[:15]); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := store.Authenticate(token); err != nil {
+		t.Errorf("Expected cached key to authenticate, got %v", err)
+	}
+	other, err := apikeys.Issue(tx, &apikeys.APIKey{Owner: "other"})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := store.Authenticate(other); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := store.Authenticate(token); err != apikeys.ErrInvalidKey {
+		t.Errorf("Expected evicted key to be revoked, got %v", err)
+	}
+}
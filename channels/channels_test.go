@@ -245,3 +245,41 @@ func TestRedisTopicAck(t *testing.T) {
 	defer consumerChannel.StopConsuming()
 	AckTest(publisher, consumerChannel, 1*time.Second, t)
 }
+
+func TestMultiPublisherBatch(t *testing.T) {
+	publisherA, deliveriesA := channels.MockPublisher()
+	publisherB, deliveriesB := channels.MockPublisher()
+	publisher := channels.MultiPublisher{publisherA, publisherB}
+	if !channels.PublishBatch(publisher, []string{"a", "b", "c"}) {
+		t.Fatalf("Expected batch to publish")
+	}
+	for _, deliveries := range []chan channels.Delivery{deliveriesA, deliveriesB} {
+		for _, expected := range []string{"a", "b", "c"} {
+			if payload := (<-deliveries).Payload(); payload != expected {
+				t.Errorf("Expected '%v', got '%v'", expected, payload)
+			}
+		}
+	}
+}
+
+type failingPublisher struct{}
+
+func (publisher failingPublisher) Publish(payload string) bool {
+	return false
+}
+
+func TestMultiPublisherBatchFailure(t *testing.T) {
+	publisherB, deliveriesB := channels.MockPublisher()
+	publisher := channels.MultiPublisher{failingPublisher{}, publisherB}
+	if channels.PublishBatch(publisher, []string{"a", "b"}) {
+		t.Errorf("Expected batch to report failure")
+	}
+	if publisher.Publish("c") {
+		t.Errorf("Expected publish to report failure")
+	}
+	for _, expected := range []string{"a", "b", "c"} {
+		if payload := (<-deliveriesB).Payload(); payload != expected {
+			t.Errorf("Expected '%v', got '%v'", expected, payload)
+		}
+	}
+}
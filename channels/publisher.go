@@ -9,6 +9,26 @@ type Publisher interface {
 	Publish(payload string) bool
 }
 
+// BatchPublisher is a Publisher that can send several payloads in a single
+// round trip.
+type BatchPublisher interface {
+	Publisher
+	PublishBatch(payloads []string) bool
+}
+
+// PublishBatch sends all of the payloads, using a single round trip if the
+// publisher supports it and publishing them one at a time otherwise.
+func PublishBatch(publisher Publisher, payloads []string) bool {
+	if batchPublisher, ok := publisher.(BatchPublisher); ok {
+		return batchPublisher.PublishBatch(payloads)
+	}
+	success := true
+	for _, payload := range payloads {
+		success = publisher.Publish(payload) && success
+	}
+	return success
+}
+
 type redisQueuePublisher struct {
 	key         string
 	redisClient *redis.Client
@@ -26,6 +46,31 @@ func (publisher *redisQueuePublisher) Publish(payload string) bool {
 	return !redisErrIsNil(publisher.redisClient.LPush(publisher.key, payload))
 }
 
+// PublishBatch pushes all of the payloads onto the queue in one pipelined
+// call.
+func (publisher *redisQueuePublisher) PublishBatch(payloads []string) bool {
+	if len(payloads) == 0 {
+		return true
+	}
+	for _, payload := range payloads {
+		if len(payload) == 0 {
+			log.Printf("Trying to publish empty message. Skipping batch")
+			return false
+		}
+	}
+	_, err := publisher.redisClient.Pipelined(func(pipe *redis.Pipeline) error {
+		for _, payload := range payloads {
+			pipe.LPush(publisher.key, payload)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error publishing batch: %v", err.Error())
+		return false
+	}
+	return true
+}
+
 type redisTopicPublisher struct {
 	key         string
 	redisClient *redis.Client
@@ -45,7 +90,16 @@ type MultiPublisher []Publisher
 func (mp MultiPublisher) Publish(payload string) bool {
 	success := true
 	for _, publisher := range mp {
-		success = success && publisher.Publish(payload)
+		success = publisher.Publish(payload) && success
+	}
+	return success
+}
+
+func (mp MultiPublisher) PublishBatch(payloads []string) bool {
+	success := true
+	for _, publisher := range mp {
+		// Publish first, so a failure doesn't skip the remaining publishers
+		success = PublishBatch(publisher, payloads) && success
 	}
 	return success
 }
@@ -11,6 +11,14 @@ type RelayFilter interface {
 	Filter(Delivery) bool
 }
 
+// PayloadFilter objects are RelayFilters that may also change the message
+// passed to the next stage. Relays publish the payload FilterPayload returns
+// in place of the original.
+type PayloadFilter interface {
+	RelayFilter
+	FilterPayload(Delivery) (string, bool)
+}
+
 type IncludeAll struct {
 	counter int64
 }
@@ -59,9 +67,15 @@ func (consumer *RelayConsumer) Consume(delivery Delivery) {
 	consumer.relay.s.Acquire()
 	go func() {
 		defer consumer.relay.s.Release()
-		if consumer.relay.filter.Filter(delivery) {
+		payload, ok := delivery.Payload(), false
+		if filter, isPayloadFilter := consumer.relay.filter.(PayloadFilter); isPayloadFilter {
+			payload, ok = filter.FilterPayload(delivery)
+		} else {
+			ok = consumer.relay.filter.Filter(delivery)
+		}
+		if ok {
 			for _, publisher := range consumer.relay.publishers {
-				publisher.Publish(delivery.Payload())
+				publisher.Publish(payload)
 			}
 		}
 		delivery.Ack()
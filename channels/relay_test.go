@@ -2,6 +2,7 @@ package channels_test
 
 import (
 	"github.com/notegio/openrelay/channels"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,31 @@ func TestInvertFilter(t *testing.T) {
 		t.Errorf("Message did not get relayed")
 	}
 }
+
+type upperFilter struct{}
+
+func (filter *upperFilter) Filter(delivery channels.Delivery) bool {
+	_, ok := filter.FilterPayload(delivery)
+	return ok
+}
+
+func (filter *upperFilter) FilterPayload(delivery channels.Delivery) (string, bool) {
+	return strings.ToUpper(delivery.Payload()), delivery.Payload() != "skip"
+}
+
+func TestPayloadFilter(t *testing.T) {
+	sourcePublisher, sourceChannel := channels.MockChannel()
+	destPublisher, destChannel := channels.MockChannel()
+	testConsumer := testConsumer{make(chan string), make(chan bool), make(chan bool)}
+	destChannel.AddConsumer(&testConsumer)
+	destChannel.StartConsuming()
+	relay := channels.NewRelay(sourceChannel, []channels.Publisher{destPublisher}, &upperFilter{}, 1)
+	relay.Start()
+	defer relay.Stop()
+	sourcePublisher.Publish("skip")
+	sourcePublisher.Publish("test")
+	message := <-testConsumer.channel
+	if message != "TEST" {
+		t.Errorf("Expected the filtered payload to be relayed, got '%v'", message)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/redis.v3"
+
+	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/apikeys"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+type route struct {
+	method  string
+	pattern *regexp.Regexp
+	handler http.Handler
+}
+
+type router struct {
+	routes []*route
+}
+
+func (router *router) HandleFunc(method string, pattern *regexp.Regexp, handler func(http.ResponseWriter, *http.Request)) {
+	router.routes = append(router.routes, &route{method, pattern, http.HandlerFunc(handler)})
+}
+
+// ServeHTTP dispatches on method and path. Every method a path supports is
+// registered, so a path matched by another method is refused outright.
+func (router *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathMatched := false
+	for _, route := range router.routes {
+		if route.pattern.MatchString(r.URL.Path) {
+			if route.method == r.Method {
+				route.handler.ServeHTTP(w, r)
+				return
+			}
+			pathMatched = true
+		}
+	}
+	if pathMatched {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func main() {
+	if len(os.Args) < 4 {
+		log.Fatalf("Usage: adminapi DB_CONNECTION_STRING DB_PASSWORD REDIS_URL [--registry-topic=topic://registry] [PORT]")
+	}
+	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: os.Args[3],
+	})
+	port := "8081"
+	registryTopicURL := "topic://registry"
+	for _, arg := range os.Args[4:] {
+		if strings.HasPrefix(arg, "--registry-topic=") {
+			registryTopicURL = strings.TrimPrefix(arg, "--registry-topic=")
+		} else {
+			port = arg
+		}
+	}
+
+	// Registry changes are announced to every service caching exchanges
+	registryPublisher, err := channels.PublisherFromURI(registryTopicURL, redisClient)
+	if err != nil {
+		log.Fatalf("Unable to create publisher to the Redis registry topic: %v", err.Error())
+	}
+	keyStore := apikeys.NewDBKeyStore(db, time.Minute, apikeys.DefaultCacheSize)
+	handlerAdminRegistry := handlers.APIKeyDecorator(keyStore, handlers.AdminDecorator(handlers.AdminRegistry(db, registryPublisher)))
+
+	tables := "^/_admin/(exchanges|asset_proxies|assets|asset_pairs)$"
+	rows := "^/_admin/(exchanges|asset_proxies|assets|asset_pairs)/[^/]+$"
+	mux := &router{[]*route{}}
+	mux.HandleFunc("GET", regexp.MustCompile(tables), handlerAdminRegistry)
+	mux.HandleFunc("POST", regexp.MustCompile(tables), handlerAdminRegistry)
+	mux.HandleFunc("GET", regexp.MustCompile(rows), handlerAdminRegistry)
+	mux.HandleFunc("PUT", regexp.MustCompile(rows), handlerAdminRegistry)
+	mux.HandleFunc("DELETE", regexp.MustCompile(rows), handlerAdminRegistry)
+	mux.HandleFunc("GET", regexp.MustCompile("^/_hc$"), func(w http.ResponseWriter, r *http.Request) {
+		if err := db.DB().Ping(); err != nil {
+			http.Error(w, "Database unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	log.Printf("Admin API Serving on :%v", port)
+	log.Fatalf(http.ListenAndServe(fmt.Sprintf(":%v", port), mux).Error())
+}
@@ -7,21 +7,31 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/fatih/color"
 	"gopkg.in/redis.v3"
 
 	"github.com/notegio/openrelay/accounts"
 	"github.com/notegio/openrelay/affiliates"
 	"github.com/notegio/openrelay/api/handlers"
+	"github.com/notegio/openrelay/apikeys"
 	"github.com/notegio/openrelay/blockhash"
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/config"
 	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/ratelimit"
 	"github.com/notegio/openrelay/search"
 	"github.com/rs/cors"
 )
 
 type route struct {
+	method  string
 	pattern *regexp.Regexp
 	handler http.Handler
 }
@@ -30,21 +40,34 @@ type router struct {
 	routes []*route
 }
 
-func (router *router) Handler(pattern *regexp.Regexp, handler http.Handler) {
-	router.routes = append(router.routes, &route{pattern, handler})
+func (router *router) Handler(method string, pattern *regexp.Regexp, handler http.Handler) {
+	router.routes = append(router.routes, &route{method, pattern, handler})
 }
 
-func (router *router) HandleFunc(pattern *regexp.Regexp, handler func(http.ResponseWriter, *http.Request)) {
-	router.routes = append(router.routes, &route{pattern, http.HandlerFunc(handler)})
+func (router *router) HandleFunc(method string, pattern *regexp.Regexp, handler func(http.ResponseWriter, *http.Request)) {
+	router.routes = append(router.routes, &route{method, pattern, http.HandlerFunc(handler)})
 }
 
 func (router *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// GET /v2/orders and POST /v2/orders share a path, so routes match on
+	// method as well. Unmatched methods fall through to the first route
+	// matching the path, which reports the unsupported method.
+	var pathMatch *route
 	for _, route := range router.routes {
 		if route.pattern.MatchString(r.URL.Path) {
-			route.handler.ServeHTTP(w, r)
-			return
+			if route.method == r.Method {
+				route.handler.ServeHTTP(w, r)
+				return
+			}
+			if pathMatch == nil {
+				pathMatch = route
+			}
 		}
 	}
+	if pathMatch != nil {
+		pathMatch.handler.ServeHTTP(w, r)
+		return
+	}
 	// no pattern matched; send 404 response
 	http.NotFound(w, r)
 }
@@ -58,11 +81,39 @@ func main() {
 	redisBlockQueueURL := os.Args[4]
 	redisOutputQueueURL := os.Args[5]
 	serviceFeeRecipientString := os.Args[6]
-	var servicePort string
-	if len(os.Args) > 7 {
-		servicePort = os.Args[7]
-	} else {
-		servicePort = "8080"
+	servicePort := "8080"
+	maxBatchSize := handlers.DefaultMaxBatchSize
+	softCancelQueueURL := "queue://softcancel"
+	rpcURL := ""
+	statusTopicURL := "topic://orderstatus"
+	registryTopicURL := "topic://registry"
+	rateLimits := make(map[string][]*handlers.RateLimit)
+	// By default the API is assumed to sit behind a single load balancer
+	trustedProxies := 1
+	for _, arg := range os.Args[7:] {
+		if strings.HasPrefix(arg, "--rate-limit=") {
+			route, limit, err := handlers.ParseRateLimit(strings.TrimPrefix(arg, "--rate-limit="))
+			handleError("Unable to parse rate limit", err)
+			rateLimits[route] = append(rateLimits[route], limit)
+		} else if strings.HasPrefix(arg, "--trusted-proxies=") {
+			count, err := strconv.Atoi(strings.TrimPrefix(arg, "--trusted-proxies="))
+			handleError("Unable to parse trusted proxy count", err)
+			trustedProxies = count
+		} else if strings.HasPrefix(arg, "--status-topic=") {
+			statusTopicURL = strings.TrimPrefix(arg, "--status-topic=")
+		} else if strings.HasPrefix(arg, "--registry-topic=") {
+			registryTopicURL = strings.TrimPrefix(arg, "--registry-topic=")
+		} else if strings.HasPrefix(arg, "--rpc=") {
+			rpcURL = strings.TrimPrefix(arg, "--rpc=")
+		} else if strings.HasPrefix(arg, "--soft-cancel-queue=") {
+			softCancelQueueURL = strings.TrimPrefix(arg, "--soft-cancel-queue=")
+		} else if strings.HasPrefix(arg, "--max-batch-size=") {
+			size, err := strconv.Atoi(strings.TrimPrefix(arg, "--max-batch-size="))
+			handleError("Unable to parse maximum batch size", err)
+			maxBatchSize = size
+		} else {
+			servicePort = arg
+		}
 	}
 
 	// Prepare fee recipient address as 20 bytes slice
@@ -90,16 +141,58 @@ func main() {
 	publisher, err := channels.PublisherFromURI(redisOutputQueueURL, redisClient)
 	handleError("Unable to create publisher to the Redis output queue", err)
 
+	// Create publisher for signed soft cancellations
+	softCancelPublisher, err := channels.PublisherFromURI(softCancelQueueURL, redisClient)
+	handleError("Unable to create publisher to the Redis soft cancel queue", err)
+
+	// Feed order status changes from the indexers to websocket subscribers
+	listenerStatus, err := channels.ConsumerFromURI(statusTopicURL, redisClient)
+	handleError("Unable to create listener of the Redis order status topic", err)
+	orderStream := handlers.NewOrderStream(db)
+	listenerStatus.AddConsumer(orderStream)
+	listenerStatus.StartConsuming()
+	go orderStream.Run(time.Second, nil)
+
+	// Registry changes made through the admin API are announced to every
+	// service caching exchanges
+	listenerRegistry, err := channels.ConsumerFromURI(registryTopicURL, redisClient)
+	handleError("Unable to create listener of the Redis registry topic", err)
+
 	// Create helper service objects
 	affiliateService := affiliates.NewRedisAffiliateService(redisClient)
 	accountService := accounts.NewRedisAccountService(redisClient)
 	exchangeLookup := dbModule.NewExchangeLookup(db)
+	assetRegistry := dbModule.NewAssetRegistry(db)
+	softCancelLookup := dbModule.NewSoftCancelLookup(db)
+	listenerRegistry.AddConsumer(exchangeLookup)
+	listenerRegistry.StartConsuming()
+
+	// Fund and pool filter checks for order validation dry runs, and screening
+	// orders for pools that reject over-committed makers, need an Ethereum
+	// node, and are skipped without one
+	var fundChecker funds.OrderValidator
+	var commitmentScreen dbModule.CommitmentScreen
+	var conn bind.ContractCaller
+	if rpcURL != "" {
+		client, err := ethclient.Dial(rpcURL)
+		handleError("Unable to connect to Ethereum RPC", err)
+		conn = client
+		feeToken, err := config.NewRpcFeeToken(rpcURL)
+		handleError("Unable to create fee token lookup", err)
+		tokenProxy, err := config.NewRpcTokenProxy(rpcURL)
+		handleError("Unable to create token proxy lookup", err)
+		fundChecker, err = funds.NewRpcOrderValidator(rpcURL, feeToken, tokenProxy, nil)
+		handleError("Unable to create order validator", err)
+		commitmentChecker, err := funds.NewRpcCommitmentChecker(db, rpcURL, feeToken, tokenProxy)
+		handleError("Unable to create commitment checker", err)
+		commitmentScreen = funds.NewPoolCommitmentScreen(db, commitmentChecker)
+	}
 
 	// Prepare handlers
 	handlerGetAssetPairs := handlers.GetAssetPairs(db)
-	handlerGetOrders := handlers.PoolDecorator(db, search.SearchHandler(db))
+	handlerGetOrders := handlers.ProtocolVersionDecorator(handlers.PoolDecorator(db, search.SearchHandler(db)))
 	handlerGetOrder := handlers.OrderHandler(db)
-	handlerGetOrderBook := handlers.PoolDecorator(db, search.OrderBookHandler(db))
+	handlerGetOrderBook := handlers.ProtocolVersionDecorator(handlers.PoolDecorator(db, search.OrderBookHandler(db)))
 	handlerGetFeeRecipients := handlers.FeeRecipientHandler(affiliateService)
 	handlerPostOrderConfig := handlers.PoolDecoratorBaseFee(db, redisClient, handlers.PostOrderConfig(
 		publisher,
@@ -113,27 +206,69 @@ func main() {
 		accountService,
 		affiliateService,
 		exchangeLookup,
+		assetRegistry,
+		softCancelLookup,
+		commitmentScreen,
+	))
+	handlerPostOrders := handlers.PoolDecoratorBaseFee(db, redisClient, handlers.PostOrders(
+		publisher,
+		accountService,
+		affiliateService,
+		exchangeLookup,
+		assetRegistry,
+		softCancelLookup,
+		commitmentScreen,
+		maxBatchSize,
 	))
+	handlerPostOrderValidate := handlers.PoolDecoratorBaseFee(db, redisClient, handlers.PostOrderValidate(
+		accountService,
+		affiliateService,
+		exchangeLookup,
+		assetRegistry,
+		softCancelLookup,
+		commitmentScreen,
+		fundChecker,
+		conn,
+	))
+	handlerPostOrderCancel := handlers.PostOrderCancel(softCancelPublisher, exchangeLookup)
+	handlerGetOrderStream := handlers.PoolDecorator(db, orderStream.Handler())
 	handlerGetHealthCheck := handlers.GetHealthCheck(db, redisClient, blockHash)
 
 	// Prepare HTTP handler which handles all incoming HTTP requests
 	handlerCases := []struct {
+		n string
 		m string
 		p string
 		f func(http.ResponseWriter, *http.Request)
 	}{
-		{m: "GET", p: "^(/[^/]+)?/0x/v2/asset_pairs$", f: handlerGetAssetPairs},
-		{m: "GET", p: "^(/[^/]+)?/0x/v2/orders$", f: handlerGetOrders}, // paginated, makerAssetProxyId, takerAssetProxyId, makerAssetAddress, takerAssetAddress, ...
-		{m: "GET", p: "^(/[^/]+)?/0x/v2/order/$", f: handlerGetOrder},
-		{m: "GET", p: "^(/[^/]+)?/0x/v2/orderbook$", f: handlerGetOrderBook}, // paginated, baseAssetData, quoteAssetData
-		{m: "GET", p: "^(/[^/]+)?/0x/v2/fee_recipients$", f: handlerGetFeeRecipients},
-		{m: "POST", p: "^(/[^/]+)?/0x/v2/order_config$", f: handlerPostOrderConfig},
-		{m: "POST", p: "^(/[^/]+)?/0x/v2/order$", f: handlerPostOrder},
-		{m: "GET", p: "^/_hc$", f: handlerGetHealthCheck},
+		{n: "asset_pairs", m: "GET", p: "^(/[^/]+)?/0x/v[23]/asset_pairs$", f: handlerGetAssetPairs},
+		{n: "orders", m: "GET", p: "^(/[^/]+)?/0x/v[23]/orders$", f: handlerGetOrders}, // paginated, makerAssetProxyId, takerAssetProxyId, makerAssetAddress, takerAssetAddress, ...
+		{n: "order", m: "GET", p: "^(/[^/]+)?/0x/v[23]/order/$", f: handlerGetOrder},
+		{n: "orderbook", m: "GET", p: "^(/[^/]+)?/0x/v[23]/orderbook$", f: handlerGetOrderBook}, // paginated, baseAssetData, quoteAssetData
+		{n: "fee_recipients", m: "GET", p: "^(/[^/]+)?/0x/v[23]/fee_recipients$", f: handlerGetFeeRecipients},
+		{n: "ws", m: "GET", p: "^(/[^/]+)?/0x/v[23]/ws$", f: handlerGetOrderStream},
+		{n: "order_config", m: "POST", p: "^(/[^/]+)?/0x/v[23]/order_config$", f: handlerPostOrderConfig},
+		{n: "post_order", m: "POST", p: "^(/[^/]+)?/0x/v[23]/order$", f: handlerPostOrder},
+		{n: "post_orders", m: "POST", p: "^(/[^/]+)?/0x/v[23]/orders$", f: handlerPostOrders},
+		{n: "post_order_validate", m: "POST", p: "^(/[^/]+)?/0x/v[23]/order/validate$", f: handlerPostOrderValidate},
+		{n: "post_order_cancel", m: "POST", p: "^(/[^/]+)?/0x/v[23]/order/cancel$", f: handlerPostOrderCancel},
+		{n: "health_check", m: "GET", p: "^/_hc$", f: handlerGetHealthCheck},
 	}
+	// Routes are rate limited by name, eg. --rate-limit=post_order:maker=10/1m
+	limiter := ratelimit.NewRedisLimiter(redisClient)
+	keyStore := apikeys.NewDBKeyStore(db, time.Minute, apikeys.DefaultCacheSize)
 	handlerMuxer := &router{[]*route{}}
 	for _, c := range handlerCases {
-		handlerMuxer.HandleFunc(regexp.MustCompile(c.p), c.f)
+		f := c.f
+		if limits, ok := rateLimits[c.n]; ok {
+			f = handlers.RateLimitDecorator(limiter, c.n, limits, trustedProxies, f)
+			delete(rateLimits, c.n)
+		}
+		f = handlers.APIKeyDecorator(keyStore, f)
+		handlerMuxer.HandleFunc(c.m, regexp.MustCompile(c.p), f)
+	}
+	for route := range rateLimits {
+		handleError("Unable to apply rate limit", fmt.Errorf("Unknown route %v", route))
 	}
 	httpHandler := cors.Default().Handler(handlerMuxer)
 
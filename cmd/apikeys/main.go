@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/notegio/openrelay/apikeys"
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+const usage = `Usage:
+  apikeys DB_CONNECTION_STRING DB_PASSWORD issue OWNER [--rate-limit=COUNT/PERIOD] [--pools=NAME,...] [--private-orders] [--admin]
+  apikeys DB_CONNECTION_STRING DB_PASSWORD revoke PREFIX
+  apikeys DB_CONNECTION_STRING DB_PASSWORD list [OWNER]`
+
+func main() {
+	if len(os.Args) < 4 {
+		log.Fatalf(usage)
+	}
+	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	switch os.Args[3] {
+	case "issue":
+		if len(os.Args) < 5 {
+			log.Fatalf(usage)
+		}
+		key := &apikeys.APIKey{Owner: os.Args[4]}
+		for _, arg := range os.Args[5:] {
+			if strings.HasPrefix(arg, "--rate-limit=") {
+				key.RateLimit = strings.TrimPrefix(arg, "--rate-limit=")
+			} else if strings.HasPrefix(arg, "--pools=") {
+				// The default pool's name is empty, so "--pools=,partner" allows
+				// the default pool and the partner pool
+				key.SetAllowedPools(strings.Split(strings.TrimPrefix(arg, "--pools="), ","))
+			} else if arg == "--private-orders" {
+				key.PrivateOrders = true
+			} else if arg == "--admin" {
+				key.Admin = true
+			} else {
+				log.Fatalf("Unknown option: %v\n%v", arg, usage)
+			}
+		}
+		token, err := apikeys.Issue(db, key)
+		if err != nil {
+			log.Fatalf("Error issuing key: %v", err.Error())
+		}
+		log.Printf("Issued key %v for %v. It will not be shown again.", key.Prefix, key.Owner)
+		fmt.Println(token)
+	case "revoke":
+		if len(os.Args) != 5 {
+			log.Fatalf(usage)
+		}
+		revoked, err := apikeys.Revoke(db, os.Args[4])
+		if err != nil {
+			log.Fatalf("Error revoking key: %v", err.Error())
+		}
+		if revoked == 0 {
+			log.Fatalf("No active key with prefix %v", os.Args[4])
+		}
+		log.Printf("Revoked key %v", os.Args[4])
+	case "list":
+		query := db.Model(&apikeys.APIKey{})
+		if len(os.Args) > 4 {
+			query = query.Where("owner = ?", os.Args[4])
+		}
+		keys := []apikeys.APIKey{}
+		if err := query.Order("created_at").Find(&keys).Error; err != nil {
+			log.Fatalf("Error listing keys: %v", err.Error())
+		}
+		for _, key := range keys {
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked " + key.RevokedAt.Format("2006-01-02")
+			}
+			fmt.Printf("%v\t%v\t%v\trate=%v\tpools=%v\tprivate=%v\tadmin=%v\n", key.Prefix, key.Owner, status, key.RateLimit, key.Pools, key.PrivateOrders, key.Admin)
+		}
+	default:
+		log.Fatalf(usage)
+	}
+}
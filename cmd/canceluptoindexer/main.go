@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 )
 
 func main() {
@@ -29,7 +30,27 @@ func main() {
 	if err != nil {
 		concurrency = 5
 	}
-	consumerChannel.AddConsumer(dbModule.NewRecordCancellationConsumer(db, concurrency))
+	soft := false
+	var statusPublisher channels.Publisher
+	for _, arg := range os.Args[5:] {
+		if arg == "--soft" {
+			// Consume signed off-chain cancel requests instead of on-chain
+			// CancelUpTo events
+			soft = true
+		} else if strings.HasPrefix(arg, "--status=") {
+			statusPublisher, err = channels.PublisherFromURI(strings.TrimPrefix(arg, "--status="), redisClient)
+			if err != nil {
+				log.Fatalf("Error establishing status publisher: %v", err.Error())
+			}
+		}
+	}
+	if soft {
+		consumerChannel.AddConsumer(dbModule.NewSoftCancellationConsumer(db, statusPublisher, concurrency))
+	} else {
+		cancelConsumer := dbModule.NewRecordCancellationConsumer(db, concurrency)
+		cancelConsumer.SetStatusPublisher(statusPublisher)
+		consumerChannel.AddConsumer(cancelConsumer)
+	}
 	consumerChannel.StartConsuming()
 	log.Printf("Starting db fill indexer consumer on '%v'", srcChannel)
 	c := make(chan os.Signal, 1)
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 )
 
 func main() {
@@ -29,7 +30,17 @@ func main() {
 	if err != nil {
 		concurrency = 5
 	}
-	consumerChannel.AddConsumer(dbModule.NewRecordFillConsumer(db, concurrency))
+	consumer := dbModule.NewRecordFillConsumer(db, concurrency)
+	for _, arg := range os.Args[5:] {
+		if strings.HasPrefix(arg, "--status=") {
+			statusPublisher, err := channels.PublisherFromURI(strings.TrimPrefix(arg, "--status="), redisClient)
+			if err != nil {
+				log.Fatalf("Error establishing status publisher: %v", err.Error())
+			}
+			consumer.SetStatusPublisher(statusPublisher)
+		}
+	}
+	consumerChannel.AddConsumer(consumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Starting db fill indexer consumer on '%v'", srcChannel)
 	c := make(chan os.Signal, 1)
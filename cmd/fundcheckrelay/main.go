@@ -2,7 +2,9 @@ package main
 
 import (
 	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/funds/balance"
 	"github.com/notegio/openrelay/types"
 	"github.com/notegio/openrelay/config"
 	"github.com/notegio/openrelay/cmd/cmdutils"
@@ -13,29 +15,42 @@ import (
 	"log"
 	"strings"
 	"strconv"
+	"time"
 )
 
 type FundFilter struct {
-	orderValidator funds.OrderValidator
+	orderValidator funds.FillableOrderValidator
 }
 
 func (filter *FundFilter) Filter(delivery channels.Delivery) bool {
+	_, ok := filter.FilterPayload(delivery)
+	return ok
+}
+
+// FilterPayload passes on orders the maker can fund at least part of, noting
+// how much of the order is funded for the indexer
+func (filter *FundFilter) FilterPayload(delivery channels.Delivery) (string, bool) {
 	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
 	if err != nil {
 		log.Printf("Invalid order format: %#x", delivery.Payload())
-		return false;
+		return "", false;
 	}
 	if !order.Signature.Verify(order.Maker, order.Hash()) {
 		log.Printf("Invalid order signature");
-		return false;
+		return "", false;
+	}
+	fillable, err := filter.orderValidator.FillableTakerAssetAmount(order)
+	if err != nil {
+		log.Printf("Error checking funds for order '%v': %v", hex.EncodeToString(order.Hash()), err.Error())
+		return "", false
 	}
-	valid, _ := filter.orderValidator.ValidateOrder(order)
-	if valid {
-		log.Printf("Order '%v' has funds", hex.EncodeToString(order.Hash()))
-	} else {
+	if fillable.Sign() == 0 {
 		log.Printf("Order '%v' lacks funds", hex.EncodeToString(order.Hash()))
+		return "", false
 	}
-	return valid
+	log.Printf("Order '%v' has funds for %v", hex.EncodeToString(order.Hash()), fillable)
+	order.FundedTakerAssetAmount = common.BigToUint256(fillable)
+	return string(order.Bytes()), true
 }
 
 func main() {
@@ -68,22 +83,91 @@ func main() {
 	// if err != nil { log.Fatalf(err.Error()) }
 	invert := false
 	var channelStrings []string
-	var invalidationChannel channels.ConsumerChannel
+	var invalidationChannels []channels.ConsumerChannel
+	validatorAddress := ""
+	validatorExchange := ""
+	batchSize := 0
+	batchWait := 50 * time.Millisecond
+	cacheType := ""
+	cacheSize := balance.DefaultCacheSize
+	cacheTTL := balance.DefaultCacheTTL
 	for _, arg := range os.Args[3:] {
 		if arg == "--invert" {
 			invert = true
 		} else if strings.HasPrefix(arg, "--invalidation=") {
+			// May be given more than once, such as for monitors publishing
+			// invalidations to topics of their own
 			arg = strings.TrimPrefix(arg, "--invalidation=")
-			invalidationChannel, err = channels.ConsumerFromURI(arg, redisClient)
+			invalidationChannel, err := channels.ConsumerFromURI(arg, redisClient)
 			if err != nil { log.Fatalf(err.Error()) }
+			invalidationChannels = append(invalidationChannels, invalidationChannel)
+		} else if strings.HasPrefix(arg, "--balance-cache=") {
+			cacheType = strings.TrimPrefix(arg, "--balance-cache=")
+		} else if strings.HasPrefix(arg, "--balance-cache-size=") {
+			cacheSize, err = strconv.Atoi(strings.TrimPrefix(arg, "--balance-cache-size="))
+			if err != nil { log.Fatalf("Invalid cache size: %v", err.Error()) }
+		} else if strings.HasPrefix(arg, "--balance-cache-ttl=") {
+			cacheTTL, err = time.ParseDuration(strings.TrimPrefix(arg, "--balance-cache-ttl="))
+			if err != nil { log.Fatalf("Invalid cache ttl: %v", err.Error()) }
+		} else if strings.HasPrefix(arg, "--validator=") {
+			validatorAddress = strings.TrimPrefix(arg, "--validator=")
+		} else if strings.HasPrefix(arg, "--validator-exchange=") {
+			validatorExchange = strings.TrimPrefix(arg, "--validator-exchange=")
+		} else if strings.HasPrefix(arg, "--batch-size=") {
+			batchSize, err = strconv.Atoi(strings.TrimPrefix(arg, "--batch-size="))
+			if err != nil { log.Fatalf("Invalid batch size: %v", err.Error()) }
+		} else if strings.HasPrefix(arg, "--batch-wait=") {
+			batchWait, err = time.ParseDuration(strings.TrimPrefix(arg, "--batch-wait="))
+			if err != nil { log.Fatalf("Invalid batch wait: %v", err.Error()) }
 		} else {
 			channelStrings = append(channelStrings, arg)
 		}
 	}
-	orderValidator, err := funds.NewRpcOrderValidator(rpcURL, feeToken, tokenProxy, invalidationChannel)
+	var cache balance.BalanceCache
+	switch cacheType {
+	case "redis":
+		// Shared by all fundcheck workers using the same redis server
+		cache = balance.NewRedisBalanceCache(redisClient, "balancecache:", cacheTTL)
+	case "", "memory":
+		cache = balance.NewLRUBalanceCache(cacheSize, cacheTTL)
+	default:
+		log.Fatalf("Unknown balance cache type: '%v'", cacheType)
+	}
+	orderValidator, err := funds.NewRpcCachedOrderValidator(rpcURL, feeToken, tokenProxy, invalidationChannels, cache)
 	if err != nil {
 		log.Fatalf("Error creating RpcOrderValidator: '%v'", err.Error())
 	}
+	if validatorAddress != "" {
+		// With an OrderValidator contract we can check the balances and
+		// allowances of many orders in a single eth_call, so group the orders
+		// coming off the relays into small batches. The contract only knows
+		// v2 orders for plain ERC20 and ERC721 assets on the exchange it was
+		// deployed for, so other orders are still checked by the
+		// RpcOrderValidator.
+		address, err := common.HexToAddress(validatorAddress)
+		if err != nil {
+			log.Fatalf("Invalid validator address: '%v'", err.Error())
+		}
+		if validatorExchange == "" {
+			log.Fatalf("--validator requires --validator-exchange, the exchange the contract was deployed for")
+		}
+		exchange, err := common.HexToAddress(validatorExchange)
+		if err != nil {
+			log.Fatalf("Invalid validator exchange address: '%v'", err.Error())
+		}
+		// A batch is sent once it's full or batchWait has passed, and no more
+		// orders arrive than the relays have in flight, so a larger batch
+		// would hold every order for the full batchWait.
+		inFlight := concurrency * len(channelStrings)
+		if batchSize <= 0 || batchSize > inFlight {
+			batchSize = inFlight
+		}
+		contractValidator, err := funds.NewRpcContractOrderValidator(rpcURL, address, exchange)
+		if err != nil {
+			log.Fatalf("Error creating ContractOrderValidator: '%v'", err.Error())
+		}
+		orderValidator = funds.NewBatchingOrderValidator(contractValidator, orderValidator, batchSize, batchWait)
+	}
 	var fundFilter channels.RelayFilter
 	fundFilter = &FundFilter{orderValidator}
 	if invert {
@@ -3,12 +3,14 @@ package main
 import (
 	"github.com/notegio/openrelay/channels"
 	dbModule "github.com/notegio/openrelay/db"
-	// "github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/config"
+	"github.com/notegio/openrelay/funds"
 	"gopkg.in/redis.v3"
 	"log"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 )
 
 func main() {
@@ -19,9 +21,15 @@ func main() {
 		log.Fatalf("Could not open database connection: %v", err.Error())
 	}
 	status := dbModule.StatusOpen
+	commitmentRpcURL := ""
+	statusURI := ""
 	for _, arg := range os.Args[5:] {
 		if arg == "--unfunded" {
 			status = dbModule.StatusUnfunded
+		} else if strings.HasPrefix(arg, "--commitment-rpc=") {
+			commitmentRpcURL = strings.TrimPrefix(arg, "--commitment-rpc=")
+		} else if strings.HasPrefix(arg, "--status=") {
+			statusURI = strings.TrimPrefix(arg, "--status=")
 		}
 	}
 	redisClient := redis.NewClient(&redis.Options{
@@ -35,7 +43,32 @@ func main() {
 	if err != nil {
 		concurrency = 5
 	}
-	consumerChannel.AddConsumer(dbModule.NewIndexConsumer(db, status, concurrency))
+	indexConsumer := dbModule.NewIndexConsumer(db, status, concurrency)
+	if commitmentRpcURL != "" {
+		// Pools with a commitment policy reject or flag orders whose makers
+		// have committed more across their open orders than they hold
+		feeToken, err := config.NewRpcFeeToken(commitmentRpcURL)
+		if err != nil {
+			log.Fatalf("Error creating fee token lookup: '%v'", err.Error())
+		}
+		tokenProxy, err := config.NewRpcTokenProxy(commitmentRpcURL)
+		if err != nil {
+			log.Fatalf("Error creating token proxy lookup: '%v'", err.Error())
+		}
+		checker, err := funds.NewRpcCommitmentChecker(db, commitmentRpcURL, feeToken, tokenProxy)
+		if err != nil {
+			log.Fatalf("Error creating commitment checker: '%v'", err.Error())
+		}
+		indexConsumer.SetCommitmentScreen(funds.NewPoolCommitmentScreen(db, checker))
+	}
+	if statusURI != "" {
+		statusPublisher, err := channels.PublisherFromURI(statusURI, redisClient)
+		if err != nil {
+			log.Fatalf("Error establishing status publisher: %v", err.Error())
+		}
+		indexConsumer.SetStatusPublisher(statusPublisher)
+	}
+	consumerChannel.AddConsumer(indexConsumer)
 	consumerChannel.StartConsuming()
 	log.Printf("Starting db indexer consumer on '%v'", srcChannel)
 	c := make(chan os.Signal, 1)
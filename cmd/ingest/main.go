@@ -79,6 +79,16 @@ func main() {
 		log.Fatalf(err.Error())
 	}
 	exchangeLookup := dbModule.NewExchangeLookup(db)
+	registryTopic := os.Getenv("OR_REGISTRY_TOPIC")
+	if registryTopic == "" {
+		registryTopic = "topic://registry"
+	}
+	registryConsumer, err := channels.ConsumerFromURI(registryTopic, redisClient)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	registryConsumer.AddConsumer(exchangeLookup)
+	registryConsumer.StartConsuming()
 	handler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.Handler(publisher, accountService, affiliateService, enforceTerms, dbModule.NewTermsManager(db), exchangeLookup))
 	feeHandler := pool.PoolDecoratorBaseFee(db, redisClient, ingest.FeeHandler(publisher, accountService, affiliateService, defaultFeeRecipientBytes, exchangeLookup))
 
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/migrations"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %v <db connection string> <db password uri> up [version] | down [steps] | status\n", os.Args[0])
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 4 {
+		usage()
+	}
+	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	// up takes the version to migrate up to, and down the number of
+	// migrations to revert
+	var arg uint64
+	if len(os.Args) > 4 {
+		if arg, err = strconv.ParseUint(os.Args[4], 10, 64); err != nil {
+			log.Fatalf("Invalid argument '%v': %v", os.Args[4], err.Error())
+		}
+	}
+	switch os.Args[3] {
+	case "up":
+		applied, err := migrations.Up(db, migrations.All, uint(arg))
+		for _, migration := range applied {
+			log.Printf("Applied migration %04d %v", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+		log.Printf("Applied %v migrations", len(applied))
+	case "down":
+		if arg == 0 {
+			arg = 1
+		}
+		reverted, err := migrations.Down(db, migrations.All, uint(arg))
+		for _, migration := range reverted {
+			log.Printf("Reverted migration %04d %v", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+		log.Printf("Reverted %v migrations", len(reverted))
+	case "status":
+		statuses, err := migrations.GetStatus(db, migrations.All)
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+		for _, status := range statuses {
+			fmt.Println(status)
+		}
+	default:
+		usage()
+	}
+}
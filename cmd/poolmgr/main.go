@@ -13,8 +13,8 @@ import (
 
 
 func main() {
-	if len(os.Args) != 9 {
-		log.Fatalf("Usage: poolmgr DB_CONNECTION_STRING DB_PASSWORD POOL_NAME SEARCH_STRING FEE_SHARE SENDER_ADDRESS FILTER_ADDRESS NETWORK_ID")
+	if len(os.Args) < 9 || len(os.Args) > 11 {
+		log.Fatalf("Usage: poolmgr DB_CONNECTION_STRING DB_PASSWORD POOL_NAME SEARCH_STRING FEE_SHARE SENDER_ADDRESS FILTER_ADDRESS NETWORK_ID [COMMITMENT_POLICY] [--enforce-asset-registry]")
 	}
 	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
 	if err != nil {
@@ -37,6 +37,19 @@ func main() {
 		log.Fatalf("Bad network id: %v", err.Error())
 	}
 
+	commitmentPolicy := ""
+	enforceAssetRegistry := false
+	for _, arg := range os.Args[9:] {
+		if arg == "--enforce-asset-registry" {
+			enforceAssetRegistry = true
+			continue
+		}
+		commitmentPolicy = arg
+		if commitmentPolicy != poolModule.CommitmentPolicyReject && commitmentPolicy != poolModule.CommitmentPolicyFlag {
+			log.Fatalf("Bad commitment policy: %v", commitmentPolicy)
+		}
+	}
+
 
 	pool := &poolModule.Pool{
 		SearchTerms: os.Args[4],
@@ -46,6 +59,8 @@ func main() {
 		ID: poolHash.Sum(nil),
 		SenderAddresses: types.NetworkAddressMap{uint(networkID): senderAddress},
 		FilterAddresses: types.NetworkAddressMap{uint(networkID): filterAddress},
+		CommitmentPolicy: commitmentPolicy,
+		EnforceAssetRegistry: enforceAssetRegistry,
 	}
 
 	err = db.Debug().Model(&poolModule.Pool{}).Assign(pool).FirstOrCreate(pool).Error
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/config"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds"
+	"gopkg.in/redis.v3"
+)
+
+func main() {
+	db, err := dbModule.GetDB(os.Args[1], os.Args[2])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	rpcURL := os.Args[3]
+	if rpcURL == "" {
+		log.Fatalf("Please specify RPC URL")
+	}
+	pageSize := 100
+	ordersPerSecond := 5.0
+	passInterval := 10 * time.Minute
+	redisURL := ""
+	statusURI := ""
+	for _, arg := range os.Args[4:] {
+		if strings.HasPrefix(arg, "--page-size=") {
+			if pageSize, err = strconv.Atoi(strings.TrimPrefix(arg, "--page-size=")); err != nil {
+				log.Fatalf("Invalid page size: %v", err.Error())
+			}
+		} else if strings.HasPrefix(arg, "--rate=") {
+			if ordersPerSecond, err = strconv.ParseFloat(strings.TrimPrefix(arg, "--rate="), 64); err != nil {
+				log.Fatalf("Invalid rate: %v", err.Error())
+			}
+		} else if strings.HasPrefix(arg, "--interval=") {
+			if passInterval, err = time.ParseDuration(strings.TrimPrefix(arg, "--interval=")); err != nil {
+				log.Fatalf("Invalid interval: %v", err.Error())
+			}
+		} else if strings.HasPrefix(arg, "--redis=") {
+			redisURL = strings.TrimPrefix(arg, "--redis=")
+		} else if strings.HasPrefix(arg, "--status=") {
+			statusURI = strings.TrimPrefix(arg, "--status=")
+		}
+	}
+	feeToken, err := config.NewRpcFeeToken(rpcURL)
+	if err != nil {
+		log.Fatalf("Error creating RpcFeeToken: '%v'", err.Error())
+	}
+	tokenProxy, err := config.NewRpcTokenProxy(rpcURL)
+	if err != nil {
+		log.Fatalf("Error creating RpcTokenProxy: '%v'", err.Error())
+	}
+	orderValidator, err := funds.NewRpcOrderValidator(rpcURL, feeToken, tokenProxy, nil)
+	if err != nil {
+		log.Fatalf("Error creating RpcOrderValidator: '%v'", err.Error())
+	}
+	// The bloom filter is deliberately left out here, as its false negatives
+	// are one of the things we're trying to catch.
+	filledLookup, err := funds.NewRPCFilledLookup(rpcURL, nil)
+	if err != nil {
+		log.Fatalf("Error creating RPCFilledLookup: '%v'", err.Error())
+	}
+	reconciler := funds.NewReconciler(
+		db,
+		filledLookup,
+		funds.NewDBCancellationLookup(db),
+		orderValidator,
+		pageSize,
+		ordersPerSecond,
+	)
+	if statusURI != "" {
+		if redisURL == "" {
+			log.Fatalf("--status requires --redis")
+		}
+		redisClient := redis.NewClient(&redis.Options{
+			Addr: redisURL,
+		})
+		statusPublisher, err := channels.PublisherFromURI(statusURI, redisClient)
+		if err != nil {
+			log.Fatalf("Error establishing status publisher: %v", err.Error())
+		}
+		reconciler.SetStatusPublisher(statusPublisher)
+	}
+	stop := make(chan struct{})
+	go reconciler.Run(passInterval, stop)
+	log.Printf("Starting reconciler: %v orders per page, %v orders per second, %v between passes", pageSize, ordersPerSecond, passInterval)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	for _ = range c {
+		break
+	}
+	close(stop)
+}
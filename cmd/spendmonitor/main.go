@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"os"
 	"log"
+	"strings"
 )
 
 func main() {
@@ -26,7 +27,16 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error constructing publisher: %v", err.Error())
 	}
-	consumer, err := spend.NewRPCSpendBlockConsumer(rpcURL, exchangeAddress, publisher)
+	var invalidationPublisher channels.Publisher
+	for _, arg := range os.Args[6:] {
+		if strings.HasPrefix(arg, "--invalidation=") {
+			invalidationPublisher, err = channels.PublisherFromURI(strings.TrimPrefix(arg, "--invalidation="), redisClient)
+			if err != nil {
+				log.Fatalf("Error constructing invalidation publisher: %v", err.Error())
+			}
+		}
+	}
+	consumer, err := spend.NewRPCSpendBlockConsumer(rpcURL, exchangeAddress, publisher, invalidationPublisher)
 	if err != nil {
 		log.Fatalf("Error constructing spend monitor: %v", err.Error())
 	}
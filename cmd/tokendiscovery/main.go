@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/notegio/openrelay/channels"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/discovery"
+	"gopkg.in/redis.v3"
+)
+
+func main() {
+	redisURL := os.Args[1]
+	rpcURL := os.Args[2]
+	src := os.Args[3]
+	db, err := dbModule.GetDB(os.Args[4], os.Args[5])
+	if err != nil {
+		log.Fatalf("Could not open database connection: %v", err.Error())
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+	conn, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		log.Fatalf("Error connecting to RPC: %v", err.Error())
+	}
+	consumerChannel, err := channels.ConsumerFromURI(src, redisClient)
+	if err != nil {
+		log.Fatalf("Error constructing consumer: %v", err.Error())
+	}
+	consumerChannel.AddConsumer(discovery.NewDiscoverer(db, discovery.NewRpcMetadataReader(conn)))
+	consumerChannel.StartConsuming()
+	log.Printf("Started discovering tokens of orders from channel %v", src)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	for _ = range c {
+		break
+	}
+	consumerChannel.StopConsuming()
+}
@@ -117,3 +117,58 @@ func (sem Semaphore) Acquire() {
 func (sem Semaphore) Release() {
 	<-sem
 }
+
+// ToMultiAssetData encodes a MultiAssetProxy bundle of the given assets, with
+// amounts[i] units of assets[i] per unit of the bundle.
+func ToMultiAssetData(amounts []*big.Int, assets []types.AssetData) (types.AssetData) {
+	word := func(value int) []byte {
+		return BigToUint256(big.NewInt(int64(value)))[:]
+	}
+	amountArray := word(len(amounts))
+	for _, amount := range amounts {
+		amountArray = append(amountArray, BigToUint256(amount)[:]...)
+	}
+	heads := []byte{}
+	tails := []byte{}
+	for _, asset := range assets {
+		heads = append(heads, word(32*len(assets)+len(tails))...)
+		padded := make([]byte, ((len(asset)+31)/32)*32)
+		copy(padded, asset)
+		tails = append(tails, word(len(asset))...)
+		tails = append(tails, padded...)
+	}
+	assetArray := append(word(len(assets)), append(heads, tails...)...)
+	assetData := append(types.AssetData{}, types.MultiAssetProxyID[:]...)
+	assetData = append(assetData, word(64)...)
+	assetData = append(assetData, word(64+len(amountArray))...)
+	assetData = append(assetData, amountArray...)
+	return append(assetData, assetArray...)
+}
+
+// ToERC1155AssetData encodes ERC1155 asset data for values[i] of each of the
+// token ids per unit of the asset.
+func ToERC1155AssetData(address *types.Address, ids, values []*big.Int, callbackData []byte) (types.AssetData) {
+	word := func(value int) []byte {
+		return BigToUint256(big.NewInt(int64(value)))[:]
+	}
+	idArray := word(len(ids))
+	for _, id := range ids {
+		idArray = append(idArray, BigToUint256(id)[:]...)
+	}
+	valueArray := word(len(values))
+	for _, value := range values {
+		valueArray = append(valueArray, BigToUint256(value)[:]...)
+	}
+	padded := make([]byte, ((len(callbackData)+31)/32)*32)
+	copy(padded, callbackData)
+	callbackBytes := append(word(len(callbackData)), padded...)
+	assetData := append(types.AssetData{}, types.ERC1155ProxyID[:]...)
+	assetData = append(assetData, make([]byte, 12)...)
+	assetData = append(assetData, address[:]...)
+	assetData = append(assetData, word(128)...)
+	assetData = append(assetData, word(128+len(idArray))...)
+	assetData = append(assetData, word(128+len(idArray)+len(valueArray))...)
+	assetData = append(assetData, idArray...)
+	assetData = append(assetData, valueArray...)
+	return append(assetData, callbackBytes...)
+}
@@ -11,6 +11,8 @@ import (
 	"log"
 )
 
+// FeeToken looks up the asset makers pay an order's fees in. That's the
+// exchange's ZRX token for v2 orders, while v3 orders name their own.
 type FeeToken interface {
 	Get(order *types.Order) (types.AssetData, error)
 	Set(types.AssetData) error
@@ -21,7 +23,10 @@ type staticFeeToken struct {
 }
 
 func (feeToken *staticFeeToken) Get(order *types.Order) (types.AssetData, error) {
-		return feeToken.value, nil
+	if order.IsV3() {
+		return order.MakerFeeAssetData, nil
+	}
+	return feeToken.value, nil
 }
 
 func (feeToken *staticFeeToken) Set(assetData types.AssetData) error {
@@ -35,6 +40,9 @@ type rpcFeeToken struct {
 }
 
 func (feeToken *rpcFeeToken) Get(order *types.Order) (types.AssetData, error) {
+	if order.IsV3() {
+		return order.MakerFeeAssetData, nil
+	}
 	feeTokenAssetData := types.AssetData{}
 	if feeTokenAssetData, ok := feeToken.exchangeTokenMap[*order.ExchangeAddress]; ok {
 		return feeTokenAssetData, nil
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+)
+
+const (
+	SideMaker = "maker"
+	SideTaker = "taker"
+)
+
+// AssetComponent records one component of a MultiAssetProxy bundle or ERC1155
+// asset traded by an order, so that orders can be found by the assets inside
+// their bundles.
+type AssetComponent struct {
+	OrderHash    []byte           `gorm:"primary_key"`
+	Side         string           `gorm:"primary_key"`
+	Position     int              `gorm:"primary_key;auto_increment:false"`
+	AssetData    *types.AssetData `gorm:"index"`
+	AssetAddress *types.Address   `gorm:"index"`
+	Amount       *types.Uint256
+}
+
+func orderComponents(orderHash []byte, side string, assetData types.AssetData) ([]AssetComponent, error) {
+	if !assetData.HasComponents() {
+		return []AssetComponent{}, nil
+	}
+	components, err := assetData.LeafComponents()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]AssetComponent, len(components))
+	for i, component := range components {
+		componentData := component.AssetData
+		result[i] = AssetComponent{
+			OrderHash:    orderHash,
+			Side:         side,
+			Position:     i,
+			AssetData:    &componentData,
+			AssetAddress: componentData.Address(),
+			Amount:       common.BigToUint256(component.Amount),
+		}
+	}
+	return result, nil
+}
+
+// SaveAssetComponents records the components of any MultiAssetProxy bundles
+// or ERC1155 assets on either side of the order. Orders without them need no
+// records.
+func SaveAssetComponents(db *gorm.DB, order *Order) error {
+	makerComponents, err := orderComponents(order.OrderHash, SideMaker, order.MakerAssetData)
+	if err != nil {
+		return err
+	}
+	takerComponents, err := orderComponents(order.OrderHash, SideTaker, order.TakerAssetData)
+	if err != nil {
+		return err
+	}
+	for _, component := range append(makerComponents, takerComponents...) {
+		if err := db.FirstOrCreate(&component, AssetComponent{OrderHash: component.OrderHash, Side: component.Side, Position: component.Position}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ComponentSubquery returns SQL matching order hashes that have a bundle
+// component on the given side matching dbField (asset_data or
+// asset_address), for use as `order_hash IN (...)`.
+func ComponentSubquery(side, dbField string) string {
+	return "SELECT order_hash FROM asset_components WHERE side = '" + side + "' AND " + dbField + " = ?"
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"math/big"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/types"
+)
+
+// AssetRegistry looks up the assets and asset pairs listed for trading. It
+// doesn't cache, so changes to the listings apply to the next order checked.
+type AssetRegistry struct {
+	db *gorm.DB
+}
+
+// GetAsset returns the asset listed with the given asset data on the
+// network, or nil if there isn't one.
+func (registry *AssetRegistry) GetAsset(assetData types.AssetData, networkID uint64) (*Asset, error) {
+	asset := &Asset{}
+	result := registry.db.Model(&Asset{}).
+		Select("assets.*").
+		Joins("JOIN asset_proxies ON asset_proxies.id = assets.proxy_id").
+		Joins("JOIN exchanges ON exchanges.address = asset_proxies.exchange_address").
+		Where("exchanges.network = ? AND assets.data = ?", networkID, []byte(assetData[:])).
+		First(asset)
+	if result.RecordNotFound() {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return asset, nil
+}
+
+// GetAssetPair returns the pair of the two assets, in either order, or nil if
+// they aren't listed as a pair.
+func (registry *AssetRegistry) GetAssetPair(assetA, assetB *Asset) (*AssetPair, error) {
+	assetPair := &AssetPair{}
+	result := registry.db.Model(&AssetPair{}).
+		Where(
+			"(asset_symbol_a = ? AND asset_symbol_b = ?) OR (asset_symbol_a = ? AND asset_symbol_b = ?)",
+			assetA.Symbol, assetB.Symbol, assetB.Symbol, assetA.Symbol,
+		).
+		First(assetPair)
+	if result.RecordNotFound() {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return assetPair, nil
+}
+
+// NewAssetRegistry creates an AssetRegistry reading listings from db
+func NewAssetRegistry(db *gorm.DB) *AssetRegistry {
+	return &AssetRegistry{db}
+}
+
+// PriceFitsPrecision indicates whether the price of baseAmount of the base
+// asset in terms of the quote asset, in whole tokens, has no more decimal
+// places than the quote asset's precision allows.
+func PriceFitsPrecision(base, quote *Asset, baseAmount, quoteAmount *big.Int) bool {
+	if baseAmount.Sign() == 0 {
+		return false
+	}
+	// price = (quoteAmount / 10^quote.Decimals) / (baseAmount / 10^base.Decimals),
+	// which fits if price * 10^quote.Precision is a whole number
+	numerator := new(big.Int).Mul(quoteAmount, pow10(uint64(base.Decimals)+uint64(quote.Precision)))
+	denominator := new(big.Int).Mul(baseAmount, pow10(uint64(quote.Decimals)))
+	return new(big.Int).Mod(numerator, denominator).Sign() == 0
+}
+
+func pow10(exponent uint64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), new(big.Int).SetUint64(exponent), nil)
+}
@@ -0,0 +1,42 @@
+package db_test
+
+import (
+	"math/big"
+	"testing"
+
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+func TestPriceFitsPrecision(t *testing.T) {
+	tokens := func(amount string) *big.Int {
+		value, _ := new(big.Int).SetString(amount, 10)
+		return value
+	}
+	for _, c := range []struct {
+		name          string
+		baseDecimals  uint16
+		quoteDecimals uint16
+		precision     uint16
+		baseAmount    *big.Int
+		quoteAmount   *big.Int
+		fits          bool
+	}{
+		{"at precision", 18, 18, 2, tokens("50000000000000000000"), tokens("1000000000000000000"), true},
+		{"beyond precision", 18, 18, 1, tokens("50000000000000000000"), tokens("1000000000000000000"), false},
+		{"whole price", 18, 18, 0, tokens("1000000000000000000"), tokens("50000000000000000000"), true},
+		{"fractional whole price", 18, 18, 0, tokens("2000000000000000000"), tokens("3000000000000000000"), false},
+		{"repeating price", 18, 18, 8, tokens("3000000000000000000"), tokens("1000000000000000000"), false},
+		{"fewer base decimals", 6, 18, 2, tokens("1000000"), tokens("1500000000000000000"), true},
+		{"fewer base decimals beyond precision", 6, 18, 2, tokens("1000000"), tokens("1505000000000000000"), false},
+		{"fewer quote decimals", 18, 6, 7, tokens("2000000000000000000"), tokens("3"), true},
+		{"fewer quote decimals beyond precision", 18, 6, 6, tokens("2000000000000000000"), tokens("3"), false},
+		{"zero base amount", 18, 18, 2, big.NewInt(0), tokens("1000000000000000000"), false},
+		{"zero quote amount", 18, 18, 2, tokens("1000000000000000000"), big.NewInt(0), true},
+	} {
+		base := &dbModule.Asset{Symbol: "ZRX", Decimals: c.baseDecimals}
+		quote := &dbModule.Asset{Symbol: "WETH", Decimals: c.quoteDecimals, Precision: c.precision}
+		if fits := dbModule.PriceFitsPrecision(base, quote, c.baseAmount, c.quoteAmount); fits != c.fits {
+			t.Errorf("%v: expected %v, got %v", c.name, c.fits, fits)
+		}
+	}
+}
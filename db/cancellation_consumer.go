@@ -34,6 +34,12 @@ func (consumer *CancellationConsumer) Consume(msg channels.Delivery) {
 	}()
 }
 
+// SetStatusPublisher makes the consumer publish a StatusChange for each order
+// it cancels.
+func (consumer *CancellationConsumer) SetStatusPublisher(publisher channels.Publisher) {
+	consumer.idx.SetStatusPublisher(publisher)
+}
+
 func NewRecordCancellationConsumer(db *gorm.DB, concurrency int) *CancellationConsumer {
 	return &CancellationConsumer{NewIndexer(db, StatusCancelled), make(common.Semaphore, concurrency)}
 }
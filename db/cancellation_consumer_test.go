@@ -19,7 +19,7 @@ func TestCancellationConsumer(t *testing.T) {
 		tx.Rollback()
 		db.Close()
 	}()
-	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
 	if err := tx.AutoMigrate(&dbModule.Cancellation{}).Error; err != nil {
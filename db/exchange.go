@@ -1,29 +1,59 @@
 package db
 
 import (
+	"sync"
 	"time"
 
 	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/types"
 )
 
-// Exchange contains information about supported exchange.
+// Exchange contains information about supported exchange. ProtocolVersion is
+// the version of the 0x protocol the exchange contract implements, which
+// determines the format of its orders. ChainID is the EIP-155 chain ID v3
+// orders for the exchange must be signed for, which may differ from Network.
+//
+// Exchanges forked from 0x may sign orders under an EIP-712 domain of their
+// own. DomainName and DomainVersion replace the 0x Protocol domain's name and
+// version when set, and DomainSalt adds a bytes32 salt to the domain.
 type Exchange struct {
-	Address   *types.Address `gorm:"primary_key"`
-	Network   uint64         `gorm:"index"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Address         *types.Address `gorm:"primary_key"`
+	Network         uint64         `gorm:"index"`
+	ProtocolVersion uint8          `gorm:"default:2"`
+	ChainID         uint64
+	DomainName      string
+	DomainVersion   string
+	DomainSalt      []byte
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// IsV3 indicates whether the exchange takes v3 orders
+func (exchange *Exchange) IsV3() bool {
+	return exchange.ProtocolVersion == types.ProtocolV3
+}
+
+// ApplyDomain sets the EIP-712 domain the exchange's orders are signed under
+// on order, which must be done before hashing orders for the exchange.
+func (exchange *Exchange) ApplyDomain(order *types.Order) {
+	order.DomainName = exchange.DomainName
+	order.DomainVersion = exchange.DomainVersion
+	order.DomainSalt = exchange.DomainSalt
 }
 
 // ExchangeLookup contains helper maps for fast lookup.
 type ExchangeLookup struct {
 	db             *gorm.DB
-	byAddressCache map[types.Address]uint64
+	mutex          sync.Mutex
+	byAddressCache map[types.Address]*Exchange
 	byNetworkCache map[uint64][]*types.Address
 }
 
 // GetExchangesByNetwork returns exchanges for specified network ID.
 func (lookup *ExchangeLookup) GetExchangesByNetwork(network uint64) ([]*types.Address, error) {
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
 	if addresses, ok := lookup.byNetworkCache[network]; ok {
 		return addresses, nil
 	}
@@ -39,16 +69,27 @@ func (lookup *ExchangeLookup) GetExchangesByNetwork(network uint64) ([]*types.Ad
 	return addresses, nil
 }
 
-// GetNetworkByExchange returns network ID for specified exchange address.
-func (lookup *ExchangeLookup) GetNetworkByExchange(address *types.Address) (uint64, error) {
-	if network, ok := lookup.byAddressCache[*address]; ok {
-		return network, nil
+// GetExchange returns the exchange with the specified address.
+func (lookup *ExchangeLookup) GetExchange(address *types.Address) (*Exchange, error) {
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
+	if exchange, ok := lookup.byAddressCache[*address]; ok {
+		return exchange, nil
 	}
 	exchange := &Exchange{}
 	if err := lookup.db.Model(&Exchange{}).Where("address = ?", address).First(exchange).Error; err != nil {
+		return nil, err
+	}
+	lookup.byAddressCache[*address] = exchange
+	return exchange, nil
+}
+
+// GetNetworkByExchange returns network ID for specified exchange address.
+func (lookup *ExchangeLookup) GetNetworkByExchange(address *types.Address) (uint64, error) {
+	exchange, err := lookup.GetExchange(address)
+	if err != nil {
 		return 0, err
 	}
-	lookup.byAddressCache[*address] = exchange.Network
 	return exchange.Network, nil
 }
 
@@ -62,11 +103,28 @@ func (lookup *ExchangeLookup) ExchangeIsKnown(address *types.Address) <-chan uin
 	return result
 }
 
+// Invalidate clears the lookup's caches, so exchanges are read from the
+// database again.
+func (lookup *ExchangeLookup) Invalidate() {
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
+	lookup.byAddressCache = make(map[types.Address]*Exchange)
+	lookup.byNetworkCache = make(map[uint64][]*types.Address)
+}
+
+// Consume clears the lookup's caches on each RegistryChange, picking up
+// exchanges changed through the admin API.
+func (lookup *ExchangeLookup) Consume(msg channels.Delivery) {
+	lookup.Invalidate()
+	msg.Ack()
+}
+
 // NewExchangeLookup creates helper object improving exchanges reading.
 func NewExchangeLookup(db *gorm.DB) *ExchangeLookup {
 	return &ExchangeLookup{
 		db,
-		make(map[types.Address]uint64),
+		sync.Mutex{},
+		make(map[types.Address]*Exchange),
 		make(map[uint64][]*types.Address),
 	}
 }
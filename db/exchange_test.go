@@ -24,7 +24,7 @@ func TestExchangesByNetwork(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 	address := &types.Address{}
-	tx.Model(&dbModule.Exchange{}).Create(&dbModule.Exchange{address, 1})
+	tx.Model(&dbModule.Exchange{}).Create(&dbModule.Exchange{Address: address, Network: 1})
 	lookup := dbModule.NewExchangeLookup(tx)
 	exchanges, err := lookup.GetExchangesByNetwork(1)
 	if err != nil {
@@ -53,7 +53,7 @@ func TestNetworkByExchange(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 	address := &types.Address{}
-	tx.Model(&dbModule.Exchange{}).Create(&dbModule.Exchange{address, 1})
+	tx.Model(&dbModule.Exchange{}).Create(&dbModule.Exchange{Address: address, Network: 1})
 	lookup := dbModule.NewExchangeLookup(tx)
 	networkID, err := lookup.GetNetworkByExchange(address)
 	if err != nil {
@@ -82,7 +82,7 @@ func TestExchangeIsKnown(t *testing.T) {
 	if (<-lookup.ExchangeIsKnown(address) != 0) {
 		t.Errorf("Expected exchange to be unknown")
 	}
-	tx.Model(&dbModule.Exchange{}).Create(&dbModule.Exchange{address, 1})
+	tx.Model(&dbModule.Exchange{}).Create(&dbModule.Exchange{Address: address, Network: 1})
 	if (<-lookup.ExchangeIsKnown(address) == 0) {
 		t.Errorf("Expected exchange to be known")
 	}
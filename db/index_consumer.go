@@ -14,15 +14,17 @@ type IndexConsumer struct {
 }
 
 func (consumer *IndexConsumer) Consume(msg channels.Delivery) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Failed to index order: %v", r)
-			msg.Reject()
-		}
-	}()
 	consumer.s.Acquire()
 	go func(){
 		defer consumer.s.Release()
+		// Indexing runs here rather than in Consume, so this is where a panic
+		// has to be caught
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Failed to index order: %v", r)
+				msg.Reject()
+			}
+		}()
 		order, err := types.OrderFromBytes([]byte(msg.Payload()))
 		if err != nil {
 			log.Printf("Error parsing order: %v", err.Error())
@@ -38,6 +40,18 @@ func (consumer *IndexConsumer) Consume(msg channels.Delivery) {
 	}()
 }
 
+// SetCommitmentScreen makes the consumer screen orders for maker
+// over-commitment before indexing them.
+func (consumer *IndexConsumer) SetCommitmentScreen(screen CommitmentScreen) {
+	consumer.idx.SetCommitmentScreen(screen)
+}
+
+// SetStatusPublisher makes the consumer publish a StatusChange for each order
+// it indexes.
+func (consumer *IndexConsumer) SetStatusPublisher(publisher channels.Publisher) {
+	consumer.idx.SetStatusPublisher(publisher)
+}
+
 func NewIndexConsumer(db *gorm.DB, status int64, concurrency int) *IndexConsumer {
 	return &IndexConsumer{NewIndexer(db, status), make(common.Semaphore, concurrency)}
 }
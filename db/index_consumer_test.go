@@ -3,6 +3,7 @@ package db_test
 import (
 	"github.com/notegio/openrelay/channels"
 	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
 	"reflect"
 	"testing"
 	"time"
@@ -19,7 +20,7 @@ func IndexConsumerDefaultStatus(status int64, t *testing.T) {
 		tx.Rollback()
 		db.Close()
 	}()
-	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
 	order := sampleOrder(t)
@@ -56,3 +57,36 @@ func TestIndexConsumerOpenStatus(t *testing.T) {
 func TestIndexConsumerUnfundedStatus(t *testing.T) {
 	IndexConsumerDefaultStatus(dbModule.StatusUnfunded, t)
 }
+
+type panicScreen struct{}
+
+func (panicScreen) Screen(order *types.Order) (bool, bool, error) {
+	panic("screen failed")
+}
+
+func TestIndexConsumerRecoversFromPanic(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	publisher, channel := channels.MockChannel()
+	consumer := dbModule.NewIndexConsumer(tx, dbModule.StatusOpen, 1)
+	consumer.SetCommitmentScreen(panicScreen{})
+	channel.AddConsumer(consumer)
+	channel.StartConsuming()
+	defer channel.StopConsuming()
+	orderBytes := sampleOrder(t).Bytes()
+	publisher.Publish(string(orderBytes[:]))
+	time.Sleep(100 * time.Millisecond)
+	if rejected := channel.PurgeRejected(); rejected != 1 {
+		t.Errorf("Expected the order to be rejected, got %v rejections", rejected)
+	}
+}
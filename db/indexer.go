@@ -2,9 +2,12 @@ package db
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/types"
 	"math/big"
 	"strings"
@@ -17,16 +20,57 @@ type FillRecord struct {
 	Cancel                    bool   `json:"cancel"`
 }
 
+// CommitmentScreen decides whether an order should be rejected or flagged
+// because its maker has over-committed their funds across open orders.
+type CommitmentScreen interface {
+	Screen(order *types.Order) (reject bool, flag bool, err error)
+}
+
+var ErrOverCommitted = errors.New("Maker has insufficient funds for their open orders")
+
 type Indexer struct {
-	db     *gorm.DB
-	status int64
+	db              *gorm.DB
+	status          int64
+	commitment      CommitmentScreen
+	statusPublisher channels.Publisher
+}
+
+// SetCommitmentScreen makes the indexer screen new orders for maker
+// over-commitment before saving them.
+func (indexer *Indexer) SetCommitmentScreen(screen CommitmentScreen) {
+	indexer.commitment = screen
 }
 
 // Index takes an order and saves it to the database
 func (indexer *Indexer) Index(order *types.Order) error {
 	dbOrder := Order{}
 	dbOrder.Order = *order
-	return dbOrder.Save(indexer.db, indexer.status).Error
+	// Orders the maker can only partly fund enter the book with just the
+	// funded part fillable
+	dbOrder.RemainingFillableTakerAssetAmount = order.FundedTakerAssetAmount
+	// An order a maker has soft cancelled never returns to the order book,
+	// even if it's submitted again
+	cancelled, err := SoftCancelled(indexer.db, order)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		dbOrder.Status = StatusCancelled
+	} else if indexer.commitment != nil {
+		reject, flag, err := indexer.commitment.Screen(order)
+		if err != nil {
+			return err
+		}
+		if reject {
+			return ErrOverCommitted
+		}
+		dbOrder.OverCommitted = flag
+	}
+	if err := dbOrder.Save(indexer.db, indexer.status).Error; err != nil {
+		return err
+	}
+	indexer.publishStatusChanges([][]byte{dbOrder.OrderHash}, dbOrder.Status)
+	return nil
 }
 
 // RecordFill takes information about a filled order and updates the corresponding
@@ -51,39 +95,185 @@ func (indexer *Indexer) RecordFill(fillRecord *FillRecord) error {
 	}
 	totalFilled := dbOrder.TakerAssetAmountFilled.Big()
 	copy(dbOrder.TakerAssetAmountFilled[:], abi.U256(totalFilled.Add(totalFilled, amountFilled)))
+	if dbOrder.RemainingFillableTakerAssetAmount != nil {
+		// The fill spent the maker's funds as well as the order's remaining
+		// amount, so if funds were the limit they still are.
+		fillable := new(big.Int).Sub(dbOrder.RemainingFillableTakerAssetAmount.Big(), amountFilled)
+		if fillable.Sign() < 0 {
+			fillable.SetInt64(0)
+		}
+		copy(dbOrder.RemainingFillableTakerAssetAmount[:], abi.U256(fillable))
+	}
 	dbOrder.Cancelled = dbOrder.Cancelled || fillRecord.Cancel
-	return dbOrder.Save(indexer.db, dbOrder.Status).Error
+	if err := dbOrder.Save(indexer.db, dbOrder.Status).Error; err != nil {
+		return err
+	}
+	indexer.publishStatusChanges([][]byte{dbOrder.OrderHash}, dbOrder.Status)
+	return nil
 }
 
 // RecordSpend takes information about a token transfer, and updates any
 // orders that might have become unfillable as a result of the transfer.
+// balance is the lesser of the maker's balance and allowance after the
+// transfer. Orders the maker can still partly cover stay open with a reduced
+// fillable amount, while orders they can't cover at all get the indexer's
+// status.
 func (indexer *Indexer) RecordSpend(makerAddress, tokenAddress, zrxAddress *types.Address, assetData types.AssetData, balance *types.Uint256) error {
-	// NOTE: Right now we're doing this as a single check/update. Eventually it
-	// might make sense to do a check against a read replica, and the update
-	// against the write node if the check passes. It's more work over-all, but
-	// if the write node is a major bottleneck, it could probably take a good
-	// bit of pressure off.
-	var query *gorm.DB
+	// NOTE: Right now we're doing this as a single check followed by an update
+	// per affected order. Eventually it might make sense to do the check
+	// against a read replica, and the updates against the write node. It's
+	// more work over-all, but if the write node is a major bottleneck, it
+	// could probably take a good bit of pressure off.
+	var assetCondition string
+	var assetValue interface{}
 	if len(assetData) == 0 {
-		query = indexer.db.Model(&Order{}).Where("status = ? AND maker_asset_address = ? AND maker = ? AND ? < maker_asset_remaining", StatusOpen, tokenAddress, makerAddress, balance)
+		assetCondition, assetValue = "maker_asset_address = ?", tokenAddress
+	} else {
+		assetCondition, assetValue = "maker_asset_data = ?", []byte(assetData)
+	}
+	// v2 orders pay fees in ZRX, while v3 orders name the asset they pay fees
+	// in. Spends identified only by token address are ERC20 transfers.
+	isFeeToken := bytes.Equal(tokenAddress[:], zrxAddress[:])
+	feeAssetData := assetData
+	if len(feeAssetData) == 0 {
+		feeAssetData = types.ERC20AssetData(tokenAddress)
+	}
+	query := indexer.db.Model(&Order{}).Where("status = ? AND maker = ?", StatusOpen, makerAddress)
+	if isFeeToken {
+		query = query.Where("("+assetCondition+" AND ? < maker_asset_remaining) OR (? < maker_fee_remaining AND (protocol_version <> ? OR maker_fee_asset_data = ?))", assetValue, balance, balance, types.ProtocolV3, []byte(feeAssetData))
 	} else {
-		query = indexer.db.Model(&Order{}).Where("status = ? AND maker_asset_data = ? AND maker = ? AND ? < maker_asset_remaining", StatusOpen, []byte(assetData), makerAddress, balance)
+		query = query.Where("("+assetCondition+" AND ? < maker_asset_remaining) OR (? < maker_fee_remaining AND protocol_version = ? AND maker_fee_asset_data = ?)", assetValue, balance, balance, types.ProtocolV3, []byte(feeAssetData))
+	}
+	orders := []Order{}
+	if err := query.Find(&orders).Error; err != nil {
+		return err
 	}
-	if(bytes.Equal(tokenAddress[:], zrxAddress[:])) {
-		query = query.Or("maker = ? AND ? < maker_fee_remaining", makerAddress, balance)
+	for i := range orders {
+		order := &orders[i]
+		var makerAvailable, feeAvailable *big.Int
+		// Bundles are measured in units of the bundle rather than the token,
+		// so they're handled by recordBundleSpend
+		spentMakerAsset := !order.MakerAssetData.HasComponents()
+		if len(assetData) == 0 {
+			spentMakerAsset = spentMakerAsset && bytes.Equal(order.MakerAssetData.Address()[:], tokenAddress[:])
+		} else {
+			spentMakerAsset = spentMakerAsset && bytes.Equal(order.MakerAssetData, assetData)
+		}
+		if spentMakerAsset {
+			makerAvailable = balance.Big()
+		}
+		spentFeeToken := isFeeToken
+		if order.IsV3() {
+			spentFeeToken = bytes.Equal(order.MakerFeeAssetData, feeAssetData)
+		}
+		if spentFeeToken {
+			feeAvailable = balance.Big()
+		}
+		fillable := order.FillableTakerAssetAmount(makerAvailable, feeAvailable, spentMakerAsset && spentFeeToken)
+		if err := indexer.limitFillable(order, fillable); err != nil {
+			return err
+		}
 	}
-	return query.Update("status", indexer.status).Error
+	return indexer.recordBundleSpend(makerAddress, tokenAddress, assetData, balance)
+}
+
+// recordBundleSpend updates bundle and ERC1155 orders with a component matching
+// the spent asset. The amount of the component needed depends on the amount
+// of the component in each bundle, so unlike RecordSpend this can't be done
+// in a single query.
+func (indexer *Indexer) recordBundleSpend(makerAddress, tokenAddress *types.Address, assetData types.AssetData, balance *types.Uint256) error {
+	orders := []Order{}
+	query := indexer.db.Model(&Order{}).Where("status = ? AND maker = ?", StatusOpen, makerAddress)
+	if len(assetData) == 0 {
+		query = query.Where("order_hash IN ("+ComponentSubquery(SideMaker, "asset_address")+")", tokenAddress)
+	} else {
+		query = query.Where("order_hash IN ("+ComponentSubquery(SideMaker, "asset_data")+")", []byte(assetData))
+	}
+	if err := query.Find(&orders).Error; err != nil {
+		return err
+	}
+	for i := range orders {
+		order := &orders[i]
+		components, err := order.MakerAssetData.LeafComponents()
+		if err != nil {
+			continue
+		}
+		var fillable *big.Int
+		for _, component := range components {
+			if len(assetData) == 0 {
+				if !bytes.Equal(component.AssetData.Address()[:], tokenAddress[:]) {
+					continue
+				}
+			} else if !bytes.Equal(component.AssetData, assetData) {
+				continue
+			}
+			required := new(big.Int).Mul(component.Amount, order.MakerAssetRemaining.Big())
+			if component.Amount.Sign() == 0 || balance.Big().Cmp(required) >= 0 {
+				continue
+			}
+			// The number of whole bundles the remaining balance can cover
+			units := new(big.Int).Div(balance.Big(), component.Amount)
+			componentFillable := order.FillableTakerAssetAmount(units, nil, false)
+			if fillable == nil || componentFillable.Cmp(fillable) < 0 {
+				fillable = componentFillable
+			}
+		}
+		if fillable == nil {
+			continue
+		}
+		if err := indexer.limitFillable(order, fillable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitFillable lowers the order's fillable amount to at most fillable. Orders
+// with nothing left fillable get the indexer's status.
+func (indexer *Indexer) limitFillable(order *Order, fillable *big.Int) error {
+	if current := order.RemainingFillableTakerAssetAmount; current != nil && current.Big().Cmp(fillable) < 0 {
+		fillable = current.Big()
+	}
+	updates := map[string]interface{}{
+		"remaining_fillable_taker_asset_amount": common.BigToUint256(fillable),
+	}
+	status := order.Status
+	if fillable.Sign() == 0 {
+		status = indexer.status
+		updates["status"] = status
+	}
+	if err := indexer.db.Model(&Order{}).Where("order_hash = ?", order.OrderHash).Updates(updates).Error; err != nil {
+		return err
+	}
+	indexer.publishStatusChanges([][]byte{order.OrderHash}, status)
+	return nil
 }
 
 func (indexer *Indexer) RecordCancellation(cancellation *Cancellation) error {
 	if err := cancellation.Save(indexer.db).Error; err != nil {
 		return err
 	}
-	return indexer.db.Model(&Order{}).Where(
+	query := indexer.db.Model(&Order{}).Where(
 		"status = ? AND maker = ? AND sender_address = ? AND salt < ?", StatusOpen, cancellation.Maker, cancellation.Sender, cancellation.Epoch,
-	).Update("status", indexer.status).Error
+	)
+	if indexer.statusPublisher == nil {
+		return query.Update("status", indexer.status).Error
+	}
+	// Find the affected orders first, so their status changes can be published
+	cancelledHashes := [][]byte{}
+	if err := query.Pluck("order_hash", &cancelledHashes).Error; err != nil {
+		return err
+	}
+	if len(cancelledHashes) == 0 {
+		return nil
+	}
+	if err := indexer.db.Model(&Order{}).Where("order_hash IN (?)", cancelledHashes).Update("status", indexer.status).Error; err != nil {
+		return err
+	}
+	indexer.publishStatusChanges(cancelledHashes, indexer.status)
+	return nil
 }
 
 func NewIndexer(db *gorm.DB, status int64) *Indexer {
-	return &Indexer{db, status}
+	return &Indexer{db, status, nil, nil}
 }
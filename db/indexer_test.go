@@ -22,7 +22,7 @@ func TestIndexOrder(t *testing.T) {
 		tx.Rollback()
 		db.Close()
 	}()
-	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen)
@@ -46,7 +46,7 @@ func TestFillIndex(t *testing.T) {
 		tx.Rollback()
 		db.Close()
 	}()
-	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen)
@@ -25,14 +25,20 @@ func DefaultSha3() []byte {
 
 type Order struct {
 	types.Order
-	CreatedAt           time.Time
-	UpdatedAt           time.Time
-	OrderHash           []byte  `gorm:"primary_key"`
-	Status              int64   `gorm:"index"`
-	Price               float64 `gorm:"index:price"`
-	FeeRate             float64 `gorm:"index:price"`
+	CreatedAt           time.Time      `gorm:"index"`
+	UpdatedAt           time.Time      `gorm:"index"`
+	OrderHash           []byte         `gorm:"primary_key"`
+	Status              int64          `gorm:"index"`
+	Price               *types.Decimal `gorm:"index:price"`
+	FeeRate             *types.Decimal `gorm:"index:price,idx_orders_fee_rate"`
 	MakerAssetRemaining *types.Uint256
 	MakerFeeRemaining   *types.Uint256
+	// RemainingFillableTakerAssetAmount is the portion of the remaining taker
+	// asset amount the maker's balances and allowances can cover
+	RemainingFillableTakerAssetAmount *types.Uint256
+	// OverCommitted is set when the order was indexed while its maker had more
+	// committed across their open orders than they could cover
+	OverCommitted bool
 }
 
 func (order *Order) Populate() {
@@ -59,12 +65,18 @@ func (order *Order) Populate() {
 	order.MakerAssetRemaining = makerRemaining
 	order.MakerFeeRemaining = makerFeeRemaining
 
-	takerTokenAmount := new(big.Float).SetInt(order.TakerAssetAmount.Big())
-	makerTokenAmount := new(big.Float).SetInt(order.MakerAssetAmount.Big())
-	takerFeeAmount := new(big.Float).SetInt(order.TakerFee.Big())
+	// Until we learn otherwise, assume the maker can cover the whole remaining
+	// amount, and never more than that.
+	fillable := remainingAmount
+	if fillable.Sign() < 0 {
+		fillable = big.NewInt(0)
+	}
+	if order.RemainingFillableTakerAssetAmount == nil || order.RemainingFillableTakerAssetAmount.Big().Cmp(fillable) > 0 {
+		order.RemainingFillableTakerAssetAmount = &types.Uint256{}
+		copy(order.RemainingFillableTakerAssetAmount[:], abi.U256(new(big.Int).Set(fillable)))
+	}
 
-	order.Price, _ = new(big.Float).Quo(takerTokenAmount, makerTokenAmount).Float64()
-	order.FeeRate, _ = new(big.Float).Quo(takerFeeAmount, takerTokenAmount).Float64()
+	order.PopulatePrice()
 
 	if order.Cancelled {
 		order.Status = StatusCancelled
@@ -78,6 +90,28 @@ func (order *Order) Populate() {
 	}
 }
 
+// PopulatePrice sets the order's Price, the taker asset amount per unit of
+// maker asset amount, and FeeRate, the taker fee per unit of taker asset
+// amount. Both are in base units, and nil when their denominator is zero.
+func (order *Order) PopulatePrice() {
+	order.Price = types.NewDecimal(order.TakerAssetAmount.Big(), order.MakerAssetAmount.Big())
+	order.FeeRate = types.NewDecimal(order.TakerFee.Big(), order.TakerAssetAmount.Big())
+}
+
+// RemainingFillable returns the portion of the remaining taker asset amount
+// the maker can cover. Orders recorded before fillable amounts were tracked
+// are assumed to be fully fillable.
+func (order *Order) RemainingFillable() *big.Int {
+	if order.RemainingFillableTakerAssetAmount == nil {
+		remaining := new(big.Int).Sub(order.TakerAssetAmount.Big(), order.TakerAssetAmountFilled.Big())
+		if remaining.Sign() < 0 {
+			return big.NewInt(0)
+		}
+		return remaining
+	}
+	return order.RemainingFillableTakerAssetAmount.Big()
+}
+
 // Save records the order in the database, defaulting to the specified status.
 // Status should either be db.StatusOpen, or db.StatusUnfunded. If the order
 // is filled based on order.TakerAssetAmountFilled + order.TakerAssetAmountCancelled
@@ -97,10 +131,12 @@ func (order *Order) Save(db *gorm.DB, status int64) *gorm.DB {
 	log.Printf("Attempting to save order %#x", order.Hash())
 
 	updates := map[string]interface{}{
-		"taker_asset_amount_filled": order.TakerAssetAmountFilled,
-		"maker_asset_remaining":     order.MakerAssetRemaining,
-		"maker_fee_remaining":       order.MakerFeeRemaining,
-		"status":                    order.Status,
+		"taker_asset_amount_filled":             order.TakerAssetAmountFilled,
+		"maker_asset_remaining":                 order.MakerAssetRemaining,
+		"maker_fee_remaining":                   order.MakerFeeRemaining,
+		"remaining_fillable_taker_asset_amount": order.RemainingFillableTakerAssetAmount,
+		"status":                                order.Status,
+		"over_committed":                        order.OverCommitted,
 	}
 
 	updateScope := db.Model(Order{}).Where("order_hash = ?", order.OrderHash).Updates(updates)
@@ -110,5 +146,11 @@ func (order *Order) Save(db *gorm.DB, status int64) *gorm.DB {
 	if updateScope.RowsAffected > 0 {
 		return updateScope
 	}
-	return db.Create(order)
+	createScope := db.Create(order)
+	if createScope.Error == nil {
+		if err := SaveAssetComponents(db, order); err != nil {
+			createScope.AddError(err)
+		}
+	}
+	return createScope
 }
@@ -28,6 +28,12 @@ func (consumer *RecordFillConsumer) Consume(msg channels.Delivery) {
 	}()
 }
 
+// SetStatusPublisher makes the consumer publish a StatusChange for each order
+// it updates with a fill.
+func (consumer *RecordFillConsumer) SetStatusPublisher(publisher channels.Publisher) {
+	consumer.idx.SetStatusPublisher(publisher)
+}
+
 func NewRecordFillConsumer(db *gorm.DB, concurrency int) *RecordFillConsumer {
 	return &RecordFillConsumer{NewIndexer(db, StatusOpen), make(common.Semaphore, concurrency)}
 }
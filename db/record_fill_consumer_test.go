@@ -20,7 +20,7 @@ func TestFillConsumer(t *testing.T) {
 		tx.Rollback()
 		db.Close()
 	}()
-	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
 		t.Errorf(err.Error())
 	}
 	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen)
@@ -68,6 +68,12 @@ func (consumer *RecordSpendConsumer) Consume(msg channels.Delivery) {
 	}()
 }
 
+// SetStatusPublisher makes the consumer publish a StatusChange for each order
+// it updates after a spend.
+func (consumer *RecordSpendConsumer) SetStatusPublisher(publisher channels.Publisher) {
+	consumer.idx.SetStatusPublisher(publisher)
+}
+
 func NewRecordSpendConsumer(db *gorm.DB, concurrency int) *RecordSpendConsumer {
 	return &RecordSpendConsumer{NewIndexer(db, StatusUnfunded), make(common.Semaphore, concurrency)}
 }
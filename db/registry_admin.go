@@ -0,0 +1,262 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/types"
+)
+
+// RegistryReferenceError indicates a registry row refers to a row of another
+// table that doesn't exist.
+type RegistryReferenceError struct {
+	Reason string
+}
+
+func (err *RegistryReferenceError) Error() string {
+	return err.Reason
+}
+
+// RegistryConflictError indicates a registry row can't be created because it
+// already exists, or can't be deleted because other rows refer to it.
+type RegistryConflictError struct {
+	Reason string
+}
+
+func (err *RegistryConflictError) Error() string {
+	return err.Reason
+}
+
+func registryRowExists(db *gorm.DB, model interface{}, query string, args ...interface{}) (bool, error) {
+	var count uint64
+	if err := db.Model(model).Where(query, args...).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListExchanges returns every exchange
+func ListExchanges(db *gorm.DB) ([]*Exchange, error) {
+	exchanges := []*Exchange{}
+	err := db.Model(&Exchange{}).Order("network, address").Find(&exchanges).Error
+	return exchanges, err
+}
+
+// CreateExchange adds a new exchange
+func CreateExchange(db *gorm.DB, exchange *Exchange) error {
+	if exists, err := registryRowExists(db, &Exchange{}, "address = ?", exchange.Address); err != nil {
+		return err
+	} else if exists {
+		return &RegistryConflictError{fmt.Sprintf("Exchange %v already exists", exchange.Address)}
+	}
+	return db.Create(exchange).Error
+}
+
+// UpdateExchange replaces the exchange with exchange's address, keeping its
+// creation time
+func UpdateExchange(db *gorm.DB, exchange *Exchange) error {
+	existing := &Exchange{}
+	if err := db.Where("address = ?", exchange.Address).First(existing).Error; err != nil {
+		return err
+	}
+	exchange.CreatedAt = existing.CreatedAt
+	return db.Save(exchange).Error
+}
+
+// DeleteExchange removes the exchange with the given address, provided no
+// asset proxies refer to it
+func DeleteExchange(db *gorm.DB, address *types.Address) error {
+	if inUse, err := registryRowExists(db, &AssetProxy{}, "exchange_address = ?", address); err != nil {
+		return err
+	} else if inUse {
+		return &RegistryConflictError{fmt.Sprintf("Exchange %v has asset proxies", address)}
+	}
+	result := db.Where("address = ?", address).Delete(&Exchange{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// ListAssetProxies returns every asset proxy
+func ListAssetProxies(db *gorm.DB) ([]*AssetProxy, error) {
+	assetProxies := []*AssetProxy{}
+	err := db.Model(&AssetProxy{}).Order("name").Find(&assetProxies).Error
+	return assetProxies, err
+}
+
+func checkAssetProxyReferences(db *gorm.DB, assetProxy *AssetProxy) error {
+	if exists, err := registryRowExists(db, &Exchange{}, "address = ?", assetProxy.ExchangeAddress); err != nil {
+		return err
+	} else if !exists {
+		return &RegistryReferenceError{fmt.Sprintf("Unknown exchange %v", assetProxy.ExchangeAddress)}
+	}
+	return nil
+}
+
+// CreateAssetProxy adds a new asset proxy for an existing exchange
+func CreateAssetProxy(db *gorm.DB, assetProxy *AssetProxy) error {
+	if err := checkAssetProxyReferences(db, assetProxy); err != nil {
+		return err
+	}
+	if exists, err := registryRowExists(db, &AssetProxy{}, "id = ?", assetProxy.ID); err != nil {
+		return err
+	} else if exists {
+		return &RegistryConflictError{fmt.Sprintf("Asset proxy %#x already exists", assetProxy.ID)}
+	}
+	return db.Create(assetProxy).Error
+}
+
+// UpdateAssetProxy replaces the asset proxy with assetProxy's ID, keeping its
+// creation time
+func UpdateAssetProxy(db *gorm.DB, assetProxy *AssetProxy) error {
+	existing := &AssetProxy{}
+	if err := db.Where("id = ?", assetProxy.ID).First(existing).Error; err != nil {
+		return err
+	}
+	assetProxy.CreatedAt = existing.CreatedAt
+	if err := checkAssetProxyReferences(db, assetProxy); err != nil {
+		return err
+	}
+	return db.Save(assetProxy).Error
+}
+
+// DeleteAssetProxy removes the asset proxy with the given ID, provided no
+// assets refer to it
+func DeleteAssetProxy(db *gorm.DB, id []byte) error {
+	if inUse, err := registryRowExists(db, &Asset{}, "proxy_id = ?", id); err != nil {
+		return err
+	} else if inUse {
+		return &RegistryConflictError{fmt.Sprintf("Asset proxy %#x has assets", id)}
+	}
+	result := db.Where("id = ?", id).Delete(&AssetProxy{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// ListAssets returns every asset
+func ListAssets(db *gorm.DB) ([]*Asset, error) {
+	assets := []*Asset{}
+	err := db.Model(&Asset{}).Order("symbol").Find(&assets).Error
+	return assets, err
+}
+
+func checkAssetReferences(db *gorm.DB, asset *Asset) error {
+	if exists, err := registryRowExists(db, &AssetProxy{}, "id = ?", asset.ProxyID); err != nil {
+		return err
+	} else if !exists {
+		return &RegistryReferenceError{fmt.Sprintf("Unknown asset proxy %#x", asset.ProxyID)}
+	}
+	return nil
+}
+
+// CreateAsset adds a new asset for an existing asset proxy
+func CreateAsset(db *gorm.DB, asset *Asset) error {
+	if err := checkAssetReferences(db, asset); err != nil {
+		return err
+	}
+	if exists, err := registryRowExists(db, &Asset{}, "symbol = ?", asset.Symbol); err != nil {
+		return err
+	} else if exists {
+		return &RegistryConflictError{fmt.Sprintf("Asset %v already exists", asset.Symbol)}
+	}
+	return db.Create(asset).Error
+}
+
+// UpdateAsset replaces the asset with asset's symbol, keeping its creation
+// time
+func UpdateAsset(db *gorm.DB, asset *Asset) error {
+	existing := &Asset{}
+	if err := db.Where("symbol = ?", asset.Symbol).First(existing).Error; err != nil {
+		return err
+	}
+	asset.CreatedAt = existing.CreatedAt
+	if err := checkAssetReferences(db, asset); err != nil {
+		return err
+	}
+	return db.Save(asset).Error
+}
+
+// DeleteAsset removes the asset with the given symbol, provided no asset
+// pairs refer to it
+func DeleteAsset(db *gorm.DB, symbol string) error {
+	if inUse, err := registryRowExists(db, &AssetPair{}, "asset_symbol_a = ? OR asset_symbol_b = ?", symbol, symbol); err != nil {
+		return err
+	} else if inUse {
+		return &RegistryConflictError{fmt.Sprintf("Asset %v has asset pairs", symbol)}
+	}
+	result := db.Where("symbol = ?", symbol).Delete(&Asset{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// ListAssetPairs returns every asset pair
+func ListAssetPairs(db *gorm.DB) ([]*AssetPair, error) {
+	assetPairs := []*AssetPair{}
+	err := db.Model(&AssetPair{}).Order("id").Find(&assetPairs).Error
+	return assetPairs, err
+}
+
+func checkAssetPairReferences(db *gorm.DB, assetPair *AssetPair) error {
+	for _, symbol := range []string{assetPair.AssetSymbolA, assetPair.AssetSymbolB} {
+		if exists, err := registryRowExists(db, &Asset{}, "symbol = ?", symbol); err != nil {
+			return err
+		} else if !exists {
+			return &RegistryReferenceError{fmt.Sprintf("Unknown asset %v", symbol)}
+		}
+	}
+	if exists, err := registryRowExists(
+		db, &AssetPair{},
+		"id <> ? AND ((asset_symbol_a = ? AND asset_symbol_b = ?) OR (asset_symbol_a = ? AND asset_symbol_b = ?))",
+		assetPair.ID,
+		assetPair.AssetSymbolA, assetPair.AssetSymbolB, assetPair.AssetSymbolB, assetPair.AssetSymbolA,
+	); err != nil {
+		return err
+	} else if exists {
+		return &RegistryConflictError{fmt.Sprintf("Asset pair %v-%v already exists", assetPair.AssetSymbolA, assetPair.AssetSymbolB)}
+	}
+	return nil
+}
+
+// CreateAssetPair adds a new pair of existing assets. The pair's ID is
+// assigned by the database.
+func CreateAssetPair(db *gorm.DB, assetPair *AssetPair) error {
+	assetPair.ID = 0
+	if err := checkAssetPairReferences(db, assetPair); err != nil {
+		return err
+	}
+	return db.Create(assetPair).Error
+}
+
+// UpdateAssetPair replaces the asset pair with assetPair's ID. As the
+// symbols are part of the table's primary key, the row is updated by ID
+// rather than saved.
+func UpdateAssetPair(db *gorm.DB, assetPair *AssetPair) error {
+	if exists, err := registryRowExists(db, &AssetPair{}, "id = ?", assetPair.ID); err != nil {
+		return err
+	} else if !exists {
+		return gorm.ErrRecordNotFound
+	}
+	if err := checkAssetPairReferences(db, assetPair); err != nil {
+		return err
+	}
+	return db.Model(&AssetPair{}).Where("id = ?", assetPair.ID).Updates(map[string]interface{}{
+		"asset_symbol_a": assetPair.AssetSymbolA,
+		"asset_symbol_b": assetPair.AssetSymbolB,
+		"active":         assetPair.Active,
+	}).Error
+}
+
+// DeleteAssetPair removes the asset pair with the given ID
+func DeleteAssetPair(db *gorm.DB, id uint64) error {
+	result := db.Where("id = ?", id).Delete(&AssetPair{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
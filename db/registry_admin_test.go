@@ -0,0 +1,182 @@
+package db_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+)
+
+var registryProxyID = []byte{0xf4, 0x72, 0x61, 0xb0}
+
+func registryAsset(symbol string, token string) *dbModule.Asset {
+	address, _ := common.HexToAddress(token)
+	data := types.AssetData(append(append([]byte{}, registryProxyID...), append(make([]byte, 12), address[:]...)...))
+	return &dbModule.Asset{
+		Symbol:         symbol,
+		Address:        address,
+		Decimals:       18,
+		ProxyID:        registryProxyID,
+		Data:           &data,
+		MinTradeAmount: common.BigToUint256(big.NewInt(0)),
+		MaxTradeAmount: common.BigToUint256(big.NewInt(1000)),
+		Active:         true,
+	}
+}
+
+// migrateRegistry creates the registry tables in tx, with an exchange, an
+// ERC20 proxy, two assets and a pair of them
+func migrateRegistry(t *testing.T, tx *gorm.DB) (*dbModule.Exchange, *dbModule.AssetPair) {
+	if err := tx.AutoMigrate(&dbModule.Exchange{}, &dbModule.AssetProxy{}, &dbModule.Asset{}, &dbModule.AssetPair{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	exchangeAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	exchange := &dbModule.Exchange{Address: exchangeAddress, Network: 1, ProtocolVersion: types.ProtocolV2}
+	if err := dbModule.CreateExchange(tx, exchange); err != nil {
+		t.Fatalf(err.Error())
+	}
+	proxyAddress, _ := common.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	if err := dbModule.CreateAssetProxy(tx, &dbModule.AssetProxy{ID: registryProxyID, Name: "erc20", Address: proxyAddress, ExchangeAddress: exchangeAddress, ZeroEx: true}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, asset := range []*dbModule.Asset{
+		registryAsset("ZRX", "0x1dad4783cf3fe3085c1426157ab175a6119a04ba"),
+		registryAsset("WETH", "0x05d090b51c40b020eab3bfcb6a2dff130df22e9c"),
+	} {
+		if err := dbModule.CreateAsset(tx, asset); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	assetPair := &dbModule.AssetPair{AssetSymbolA: "ZRX", AssetSymbolB: "WETH", Active: true}
+	if err := dbModule.CreateAssetPair(tx, assetPair); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return exchange, assetPair
+}
+
+func TestRegistryAdminCreate(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	exchange, assetPair := migrateRegistry(t, tx)
+	if assetPair.ID == 0 {
+		t.Errorf("Expected the asset pair to be assigned an ID")
+	}
+	if err := dbModule.CreateExchange(tx, exchange); err == nil {
+		t.Errorf("Expected a duplicate exchange to be refused")
+	} else if _, ok := err.(*dbModule.RegistryConflictError); !ok {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	unknownAddress, _ := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	if err := dbModule.CreateAssetProxy(tx, &dbModule.AssetProxy{ID: []byte{1, 2, 3, 4}, Address: unknownAddress, ExchangeAddress: unknownAddress}); err == nil {
+		t.Errorf("Expected a proxy for an unknown exchange to be refused")
+	} else if _, ok := err.(*dbModule.RegistryReferenceError); !ok {
+		t.Errorf("Expected a reference error, got %v", err)
+	}
+	if err := dbModule.CreateAssetPair(tx, &dbModule.AssetPair{AssetSymbolA: "WETH", AssetSymbolB: "ZRX"}); err == nil {
+		t.Errorf("Expected the reversed pair to be refused")
+	} else if _, ok := err.(*dbModule.RegistryConflictError); !ok {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	if err := dbModule.CreateAssetPair(tx, &dbModule.AssetPair{AssetSymbolA: "ZRX", AssetSymbolB: "DAI"}); err == nil {
+		t.Errorf("Expected a pair with an unknown asset to be refused")
+	} else if _, ok := err.(*dbModule.RegistryReferenceError); !ok {
+		t.Errorf("Expected a reference error, got %v", err)
+	}
+}
+
+func TestRegistryAdminUpdate(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	exchange, assetPair := migrateRegistry(t, tx)
+	updated := *exchange
+	updated.ProtocolVersion = types.ProtocolV3
+	updated.ChainID = 1
+	if err := dbModule.UpdateExchange(tx, &updated); err != nil {
+		t.Fatalf(err.Error())
+	}
+	exchanges, err := dbModule.ListExchanges(tx)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(exchanges) != 1 || !exchanges[0].IsV3() || exchanges[0].ChainID != 1 {
+		t.Errorf("Expected the exchange to be updated, got %v", exchanges)
+	}
+	assetPair.Active = false
+	if err := dbModule.UpdateAssetPair(tx, assetPair); err != nil {
+		t.Fatalf(err.Error())
+	}
+	assetPairs, err := dbModule.ListAssetPairs(tx)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(assetPairs) != 1 || assetPairs[0].Active {
+		t.Errorf("Expected the asset pair to be deactivated, got %v", assetPairs)
+	}
+	if err := dbModule.UpdateAssetPair(tx, &dbModule.AssetPair{ID: assetPair.ID + 1, AssetSymbolA: "ZRX", AssetSymbolB: "WETH"}); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an unknown pair not to be found, got %v", err)
+	}
+	if err := dbModule.UpdateAsset(tx, registryAsset("DAI", "0x89d24a6b4ccb1b6faa2625fe562bdd9a23260359")); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an unknown asset not to be found, got %v", err)
+	}
+}
+
+func TestRegistryAdminDelete(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	exchange, assetPair := migrateRegistry(t, tx)
+	// Rows can't be removed while other rows refer to them
+	if err := dbModule.DeleteAsset(tx, "ZRX"); err == nil {
+		t.Errorf("Expected an asset in a pair to be kept")
+	} else if _, ok := err.(*dbModule.RegistryConflictError); !ok {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	if err := dbModule.DeleteAssetProxy(tx, registryProxyID); err == nil {
+		t.Errorf("Expected a proxy with assets to be kept")
+	}
+	if err := dbModule.DeleteExchange(tx, exchange.Address); err == nil {
+		t.Errorf("Expected an exchange with proxies to be kept")
+	}
+	// Removed in dependency order, they can all go
+	if err := dbModule.DeleteAssetPair(tx, assetPair.ID); err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, symbol := range []string{"ZRX", "WETH"} {
+		if err := dbModule.DeleteAsset(tx, symbol); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	if err := dbModule.DeleteAssetProxy(tx, registryProxyID); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := dbModule.DeleteExchange(tx, exchange.Address); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := dbModule.DeleteAssetPair(tx, assetPair.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected a deleted pair not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/notegio/openrelay/channels"
+)
+
+// RegistryChange announces that a row of the exchanges, asset_proxies,
+// assets or asset_pairs tables was created, updated or deleted. Key is the
+// row's primary key as the admin API formats it.
+type RegistryChange struct {
+	Table  string `json:"table"`
+	Key    string `json:"key"`
+	Action string `json:"action"`
+}
+
+// PublishRegistryChange announces change on publisher, so services caching
+// the registry can refresh
+func PublishRegistryChange(publisher channels.Publisher, change *RegistryChange) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("Failed to encode registry change: %v", err.Error())
+		return
+	}
+	if !publisher.Publish(string(data)) {
+		log.Printf("Failed to publish registry change to %v %v", change.Table, change.Key)
+	}
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+)
+
+// SoftCancellation is the record of a maker's signed request to remove
+// orders from the order book. Request holds the signed message as submitted.
+// Salt based cancels keep their terms, so orders they cover stay cancelled if
+// they're submitted again or only indexed later.
+type SoftCancellation struct {
+	Hash            []byte         `gorm:"primary_key"`
+	Maker           *types.Address `gorm:"index"`
+	ExchangeAddress *types.Address
+	SaltUpTo        *types.Uint256
+	MakerAssetData  types.AssetData
+	TakerAssetData  types.AssetData
+	PoolID          []byte
+	Request         string `gorm:"type:text"`
+	OrdersCancelled int64
+	CreatedAt       time.Time
+}
+
+// SoftCancelledOrder records an order hash a maker listed in a soft cancel.
+// The maker is part of the key, so a cancel can't affect another maker's
+// order that happens to be listed.
+type SoftCancelledOrder struct {
+	OrderHash        []byte         `gorm:"primary_key"`
+	Maker            *types.Address `gorm:"primary_key"`
+	CancellationHash []byte         `gorm:"index"`
+}
+
+// RecordSoftCancel marks the open and unfunded orders covered by a signed soft
+// cancel as cancelled and records the request. Orders remain fillable
+// on-chain; this only removes them from the order book.
+func (indexer *Indexer) RecordSoftCancel(cancel *types.SoftCancel) ([][]byte, error) {
+	request, err := json.Marshal(cancel)
+	if err != nil {
+		return nil, err
+	}
+	tx := indexer.db.Begin()
+	query := tx.Model(&Order{}).Where(
+		"status IN (?) AND maker = ? AND exchange_address = ?", []int64{StatusOpen, StatusUnfunded}, cancel.Maker, cancel.ExchangeAddress,
+	)
+	if len(cancel.OrderHashes) > 0 {
+		orderHashes := make([][]byte, len(cancel.OrderHashes))
+		for i := range cancel.OrderHashes {
+			orderHashes[i] = cancel.OrderHashes[i][:]
+			// Listed orders the relay hasn't indexed yet are recorded too
+			cancelledOrder := &SoftCancelledOrder{orderHashes[i], cancel.Maker, cancel.Hash()}
+			if err := tx.Where("order_hash = ? AND maker = ?", orderHashes[i], cancel.Maker).FirstOrCreate(cancelledOrder).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		query = query.Where("order_hash IN (?)", orderHashes)
+	} else {
+		query = query.Where("salt < ?", cancel.SaltUpTo)
+		if cancel.HasPair() {
+			query = query.Where("maker_asset_data = ? AND taker_asset_data = ?", cancel.MakerAssetData, cancel.TakerAssetData)
+		}
+		if cancel.HasPool() {
+			query = query.Where("pool_id = ?", cancel.PoolID[:])
+		}
+	}
+	cancelledHashes := [][]byte{}
+	if err := query.Pluck("order_hash", &cancelledHashes).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if len(cancelledHashes) > 0 {
+		if err := tx.Model(&Order{}).Where("order_hash IN (?)", cancelledHashes).Update("status", StatusCancelled).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	record := &SoftCancellation{
+		Hash:            cancel.Hash(),
+		Maker:           cancel.Maker,
+		ExchangeAddress: cancel.ExchangeAddress,
+		SaltUpTo:        cancel.SaltUpTo,
+		MakerAssetData:  cancel.MakerAssetData,
+		TakerAssetData:  cancel.TakerAssetData,
+		Request:         string(request),
+		OrdersCancelled: int64(len(cancelledHashes)),
+	}
+	if cancel.HasPool() {
+		record.PoolID = cancel.PoolID[:]
+	}
+	// A resubmitted request replaces the earlier audit record
+	if err := tx.Where("hash = ?", record.Hash).Assign(record).FirstOrCreate(record).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	indexer.publishStatusChanges(cancelledHashes, StatusCancelled)
+	return cancelledHashes, nil
+}
+
+// SoftCancelled reports whether a recorded soft cancel covers the order,
+// either by listing its hash or by its salt.
+func SoftCancelled(db *gorm.DB, order *types.Order) (bool, error) {
+	var count int
+	if err := db.Model(&SoftCancelledOrder{}).Where(
+		"order_hash = ? AND maker = ?", order.Hash(), order.Maker,
+	).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	poolID := order.PoolID
+	if len(poolID) == 0 {
+		poolID = DefaultSha3()
+	}
+	// Cancels without a pair or pool leave those columns empty, and match any
+	err := db.Model(&SoftCancellation{}).Where(
+		"maker = ? AND exchange_address = ? AND salt_up_to > ?", order.Maker, order.ExchangeAddress, order.Salt,
+	).Where(
+		"(maker_asset_data IS NULL OR length(maker_asset_data) = 0 OR (maker_asset_data = ? AND taker_asset_data = ?))",
+		order.MakerAssetData, order.TakerAssetData,
+	).Where(
+		"(pool_id IS NULL OR length(pool_id) = 0 OR pool_id = ?)", poolID,
+	).Count(&count).Error
+	return count > 0, err
+}
+
+// SoftCancelLookup checks orders against the recorded soft cancels
+type SoftCancelLookup struct {
+	db *gorm.DB
+}
+
+// IsSoftCancelled reports whether a recorded soft cancel covers the order
+func (lookup *SoftCancelLookup) IsSoftCancelled(order *types.Order) (bool, error) {
+	return SoftCancelled(lookup.db, order)
+}
+
+func NewSoftCancelLookup(db *gorm.DB) *SoftCancelLookup {
+	return &SoftCancelLookup{db}
+}
+
+type SoftCancellationConsumer struct {
+	idx *Indexer
+	s   common.Semaphore
+}
+
+func (consumer *SoftCancellationConsumer) Consume(msg channels.Delivery) {
+	consumer.s.Acquire()
+	go func() {
+		defer consumer.s.Release()
+		cancel := &types.SoftCancel{}
+		if err := json.Unmarshal([]byte(msg.Payload()), cancel); err != nil {
+			log.Printf("Failed to parse JSON: %v", err.Error())
+			msg.Reject()
+			return
+		}
+		// The API checked the signature, but the queue isn't authenticated
+		if !cancel.Signature.Verify(cancel.Maker, cancel.Hash()) {
+			log.Printf("Invalid soft cancel signature: %v", msg.Payload())
+			msg.Reject()
+			return
+		}
+		cancelled, err := consumer.idx.RecordSoftCancel(cancel)
+		if err != nil {
+			log.Printf("Failed to record soft cancel: '%v', '%v'", msg.Payload(), err.Error())
+			msg.Reject()
+			return
+		}
+		log.Printf("Soft cancel %#x removed %v orders", cancel.Hash(), len(cancelled))
+		msg.Ack()
+	}()
+}
+
+// NewSoftCancellationConsumer creates a consumer that records signed soft
+// cancels, publishing a StatusChange for each order cancelled to publisher.
+func NewSoftCancellationConsumer(db *gorm.DB, publisher channels.Publisher, concurrency int) *SoftCancellationConsumer {
+	indexer := NewIndexer(db, StatusCancelled)
+	indexer.SetStatusPublisher(publisher)
+	return &SoftCancellationConsumer{indexer, make(common.Semaphore, concurrency)}
+}
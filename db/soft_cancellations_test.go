@@ -0,0 +1,113 @@
+package db_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+)
+
+func orderStatus(t *testing.T, db *gorm.DB, order *types.Order) int64 {
+	dbOrder := &dbModule.Order{}
+	dbOrder.Initialize()
+	if err := db.Model(&dbModule.Order{}).Where("order_hash = ?", order.Hash()).First(dbOrder).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	return dbOrder.Status
+}
+
+func TestSoftCancelledOrderStaysCancelled(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	// Unfunded orders are in the book as far as the maker is concerned, so
+	// they're cancelled too
+	indexer := dbModule.NewIndexer(tx, dbModule.StatusUnfunded)
+	order := sampleOrder(t)
+	if err := indexer.Index(order); err != nil {
+		t.Fatalf(err.Error())
+	}
+	orderHash := [32]byte{}
+	copy(orderHash[:], order.Hash())
+	cancel := &types.SoftCancel{
+		Maker:           order.Maker,
+		ExchangeAddress: order.ExchangeAddress,
+		OrderHashes:     [][32]byte{orderHash},
+		SaltUpTo:        &types.Uint256{},
+		Expiration:      &types.Uint256{},
+	}
+	cancelled, err := indexer.RecordSoftCancel(cancel)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(cancelled) != 1 {
+		t.Fatalf("Expected 1 order cancelled, got %v", len(cancelled))
+	}
+	// Submitting the order again doesn't return it to the book
+	if err := dbModule.NewIndexer(tx, dbModule.StatusOpen).Index(order); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status := orderStatus(t, tx, order); status != dbModule.StatusCancelled {
+		t.Errorf("Expected order to stay cancelled, got status %v", status)
+	}
+}
+
+func TestSoftCancelCoversLaterOrders(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}, &dbModule.SoftCancellation{}, &dbModule.SoftCancelledOrder{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	order := sampleOrder(t)
+	indexer := dbModule.NewIndexer(tx, dbModule.StatusOpen)
+	cancel := &types.SoftCancel{
+		Maker:           order.Maker,
+		ExchangeAddress: order.ExchangeAddress,
+		MakerAssetData:  order.MakerAssetData,
+		TakerAssetData:  order.TakerAssetData,
+		SaltUpTo:        order.Salt,
+		Expiration:      &types.Uint256{},
+	}
+	if _, err := indexer.RecordSoftCancel(cancel); err != nil {
+		t.Fatalf(err.Error())
+	}
+	// The order's salt isn't below saltUpTo, so it isn't covered
+	if cancelled, err := dbModule.SoftCancelled(tx, order); err != nil || cancelled {
+		t.Fatalf("Expected order not to be cancelled: %v", err)
+	}
+	cancel.SaltUpTo = common.BigToUint256(new(big.Int).Add(order.Salt.Big(), big.NewInt(1)))
+	if _, err := indexer.RecordSoftCancel(cancel); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if cancelled, err := dbModule.SoftCancelled(tx, order); err != nil || !cancelled {
+		t.Fatalf("Expected order to be cancelled: %v", err)
+	}
+	// The cancel was made before the relay saw the order, which is indexed as
+	// cancelled
+	if err := indexer.Index(order); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status := orderStatus(t, tx, order); status != dbModule.StatusCancelled {
+		t.Errorf("Expected order to be cancelled, got status %v", status)
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/notegio/openrelay/channels"
+)
+
+// StatusChange announces that an order's status changed in the database
+type StatusChange struct {
+	OrderHash string `json:"orderHash"`
+	Status    int64  `json:"status"`
+}
+
+// SetStatusPublisher makes the indexer publish a StatusChange whenever it
+// changes the status of existing orders.
+func (indexer *Indexer) SetStatusPublisher(publisher channels.Publisher) {
+	indexer.statusPublisher = publisher
+}
+
+func (indexer *Indexer) publishStatusChanges(orderHashes [][]byte, status int64) {
+	PublishStatusChanges(indexer.statusPublisher, orderHashes, status)
+}
+
+// PublishStatusChanges publishes a StatusChange to publisher for each of
+// orderHashes. A nil publisher publishes nothing.
+func PublishStatusChanges(publisher channels.Publisher, orderHashes [][]byte, status int64) {
+	if publisher == nil || len(orderHashes) == 0 {
+		return
+	}
+	payloads := make([]string, len(orderHashes))
+	for i, orderHash := range orderHashes {
+		data, err := json.Marshal(&StatusChange{fmt.Sprintf("%#x", orderHash), status})
+		if err != nil {
+			log.Printf("Failed to encode status change: %v", err.Error())
+			return
+		}
+		payloads[i] = string(data)
+	}
+	if !channels.PublishBatch(publisher, payloads) {
+		log.Printf("Failed to publish %v status changes", len(payloads))
+	}
+}
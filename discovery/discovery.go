@@ -0,0 +1,225 @@
+package discovery
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/token"
+	"github.com/notegio/openrelay/types"
+)
+
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// TokenMetadata is what a token contract reports about itself
+type TokenMetadata struct {
+	Name     string
+	Symbol   string
+	Decimals uint8
+}
+
+// MetadataReader reads token metadata from the chain
+type MetadataReader interface {
+	GetMetadata(address *types.Address, fungible bool) (*TokenMetadata, error)
+}
+
+type rpcMetadataReader struct {
+	conn bind.ContractCaller
+}
+
+func bytes32String(value [32]byte) string {
+	return string(bytes.TrimRight(value[:], "\x00"))
+}
+
+// GetMetadata calls the token's name() and symbol() methods, falling back to
+// the bytes32 versions some early tokens implemented. Decimals are only read
+// for fungible tokens. Tokens implementing neither version return empty
+// strings rather than an error, as the methods are optional.
+func (reader *rpcMetadataReader) GetMetadata(address *types.Address, fungible bool) (*TokenMetadata, error) {
+	gethAddress := orCommon.ToGethAddress(address)
+	caller, err := token.NewTokenMetadataCaller(gethAddress, reader.conn)
+	if err != nil {
+		return nil, err
+	}
+	bytes32Caller, err := token.NewTokenMetadataBytes32Caller(gethAddress, reader.conn)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &TokenMetadata{}
+	// A bytes32 result can't be decoded as a string, which shows up either as
+	// an error or an empty string
+	if metadata.Name, err = caller.Name(nil); err != nil || metadata.Name == "" {
+		if name, err := bytes32Caller.Name(nil); err == nil {
+			metadata.Name = bytes32String(name)
+		}
+	}
+	if metadata.Symbol, err = caller.Symbol(nil); err != nil || metadata.Symbol == "" {
+		if symbol, err := bytes32Caller.Symbol(nil); err == nil {
+			metadata.Symbol = bytes32String(symbol)
+		}
+	}
+	if fungible {
+		if metadata.Decimals, err = caller.Decimals(nil); err != nil {
+			return nil, fmt.Errorf("Unable to read decimals of %v: %v", address, err.Error())
+		}
+	}
+	return metadata, nil
+}
+
+// NewRpcMetadataReader creates a MetadataReader calling tokens through conn
+func NewRpcMetadataReader(conn bind.ContractCaller) MetadataReader {
+	return &rpcMetadataReader{conn}
+}
+
+// Discoverer records ERC20 and ERC721 tokens traded by orders but missing from
+// the assets table. New tokens are added as inactive assets, giving operators
+// a list of candidates to review and list.
+type Discoverer struct {
+	db     *gorm.DB
+	reader MetadataReader
+	known  map[types.Address]bool
+	mutex  sync.Mutex
+}
+
+// tokenAssets returns the ERC20 and ERC721 asset datas traded by order,
+// including those within MultiAssetProxy bundles
+func tokenAssets(order *types.Order) []types.AssetData {
+	assets := []types.AssetData{}
+	for _, assetData := range []types.AssetData{order.MakerAssetData, order.TakerAssetData} {
+		candidates := []types.AssetData{assetData}
+		if assetData.IsType(types.MultiAssetProxyID) {
+			components, err := assetData.LeafComponents()
+			if err != nil {
+				continue
+			}
+			candidates = []types.AssetData{}
+			for _, component := range components {
+				candidates = append(candidates, component.AssetData)
+			}
+		}
+		for _, candidate := range candidates {
+			if candidate.IsType(types.ERC20ProxyID) || candidate.IsType(types.ERC721ProxyID) {
+				assets = append(assets, candidate)
+			}
+		}
+	}
+	return assets
+}
+
+// Discover adds any tokens order trades that aren't yet assets
+func (discoverer *Discoverer) Discover(order *types.Order) error {
+	for _, assetData := range tokenAssets(order) {
+		if err := discoverer.discoverToken(assetData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (discoverer *Discoverer) discoverToken(assetData types.AssetData) error {
+	address := assetData.Address()
+	discoverer.mutex.Lock()
+	known := discoverer.known[*address]
+	discoverer.mutex.Unlock()
+	if known {
+		return nil
+	}
+	proxyID := assetData.ProxyId()
+	var count uint64
+	if err := discoverer.db.Model(&dbModule.Asset{}).Where("address = ? AND proxy_id = ?", address, proxyID[:]).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		// Assets must belong to a registered asset proxy
+		if err := discoverer.db.Model(&dbModule.AssetProxy{}).Where("id = ?", proxyID[:]).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			log.Printf("Not discovering %v, as asset proxy %#x is not registered", address, proxyID[:])
+			return nil
+		}
+		asset, err := discoverer.newAsset(assetData)
+		if err != nil {
+			return err
+		}
+		if err := discoverer.db.Create(asset).Error; err != nil {
+			return err
+		}
+		log.Printf("Discovered %v (%v) at %v", asset.Symbol, asset.Name, address)
+	}
+	discoverer.mutex.Lock()
+	discoverer.known[*address] = true
+	discoverer.mutex.Unlock()
+	return nil
+}
+
+// newAsset creates an inactive asset for a token, with a unique symbol. ERC721
+// assets are recorded with token id 0, standing for the whole contract.
+func (discoverer *Discoverer) newAsset(assetData types.AssetData) (*dbModule.Asset, error) {
+	address := assetData.Address()
+	proxyID := assetData.ProxyId()
+	fungible := assetData.IsType(types.ERC20ProxyID)
+	metadata, err := discoverer.reader.GetMetadata(address, fungible)
+	if err != nil {
+		return nil, err
+	}
+	symbol := strings.TrimSpace(metadata.Symbol)
+	if symbol == "" {
+		symbol = address.String()
+	} else {
+		var count uint64
+		if err := discoverer.db.Model(&dbModule.Asset{}).Where("symbol = ?", symbol).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			symbol = fmt.Sprintf("%v-%x", symbol, address[:3])
+		}
+	}
+	data := make(types.AssetData, 36)
+	if !fungible {
+		data = make(types.AssetData, 68)
+	}
+	copy(data[:4], proxyID[:])
+	copy(data[16:36], address[:])
+	return &dbModule.Asset{
+		Symbol:         symbol,
+		Name:           strings.TrimSpace(metadata.Name),
+		Address:        address,
+		Decimals:       uint16(metadata.Decimals),
+		ProxyID:        proxyID[:],
+		Data:           &data,
+		MinTradeAmount: orCommon.BigToUint256(big.NewInt(0)),
+		MaxTradeAmount: orCommon.BigToUint256(maxUint256),
+		Active:         false,
+	}, nil
+}
+
+// Consume discovers the tokens of each order published on the channel
+func (discoverer *Discoverer) Consume(delivery channels.Delivery) {
+	order, err := types.OrderFromBytes([]byte(delivery.Payload()))
+	if err != nil {
+		log.Printf("Error parsing order: %v", err.Error())
+		delivery.Reject()
+		return
+	}
+	if err := discoverer.Discover(order); err != nil {
+		log.Printf("Error discovering tokens of order %#x: %v", order.Hash(), err.Error())
+		delivery.Reject()
+		return
+	}
+	delivery.Ack()
+}
+
+// NewDiscoverer creates a Discoverer adding assets to db
+func NewDiscoverer(db *gorm.DB, reader MetadataReader) *Discoverer {
+	return &Discoverer{db, reader, make(map[types.Address]bool), sync.Mutex{}}
+}
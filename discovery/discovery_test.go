@@ -0,0 +1,105 @@
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/discovery"
+)
+
+var nameSelector = []byte{6, 253, 222, 3}
+var symbolSelector = []byte{149, 216, 155, 65}
+var decimalsSelector = []byte{49, 60, 229, 103}
+
+// testTokenCaller answers name(), symbol() and decimals() calls with the
+// results configured for each selector, and reverts anything else.
+type testTokenCaller struct {
+	results map[string][]byte
+}
+
+func (conn *testTokenCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (conn *testTokenCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if result, ok := conn.results[string(call.Data[:4])]; ok {
+		return result, nil
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func word(value int64) []byte {
+	return orCommon.BigToUint256(big.NewInt(value))[:]
+}
+
+func encodeString(value string) []byte {
+	result := append(word(32), word(int64(len(value)))...)
+	padded := make([]byte, ((len(value)+31)/32)*32)
+	copy(padded, value)
+	return append(result, padded...)
+}
+
+func encodeBytes32(value string) []byte {
+	result := make([]byte, 32)
+	copy(result, value)
+	return result
+}
+
+func getMetadata(t *testing.T, results map[string][]byte, fungible bool) (*discovery.TokenMetadata, error) {
+	address, _ := orCommon.HexToAddress("0x1dad4783cf3fe3085c1426157ab175a6119a04ba")
+	reader := discovery.NewRpcMetadataReader(&testTokenCaller{results})
+	return reader.GetMetadata(address, fungible)
+}
+
+func TestStringMetadata(t *testing.T) {
+	metadata, err := getMetadata(t, map[string][]byte{
+		string(nameSelector):     encodeString("0x Protocol Token"),
+		string(symbolSelector):   encodeString("ZRX"),
+		string(decimalsSelector): word(18),
+	}, true)
+	if err != nil {
+		t.Fatalf("Error reading metadata: %v", err.Error())
+	}
+	if metadata.Name != "0x Protocol Token" || metadata.Symbol != "ZRX" || metadata.Decimals != 18 {
+		t.Errorf("Unexpected metadata: %#v", metadata)
+	}
+}
+
+func TestBytes32Metadata(t *testing.T) {
+	metadata, err := getMetadata(t, map[string][]byte{
+		string(nameSelector):     encodeBytes32("Maker"),
+		string(symbolSelector):   encodeBytes32("MKR"),
+		string(decimalsSelector): word(18),
+	}, true)
+	if err != nil {
+		t.Fatalf("Error reading metadata: %v", err.Error())
+	}
+	if metadata.Name != "Maker" || metadata.Symbol != "MKR" || metadata.Decimals != 18 {
+		t.Errorf("Unexpected metadata: %#v", metadata)
+	}
+}
+
+func TestMissingMetadata(t *testing.T) {
+	metadata, err := getMetadata(t, map[string][]byte{}, false)
+	if err != nil {
+		t.Fatalf("Error reading metadata: %v", err.Error())
+	}
+	if metadata.Name != "" || metadata.Symbol != "" || metadata.Decimals != 0 {
+		t.Errorf("Unexpected metadata: %#v", metadata)
+	}
+}
+
+func TestMissingDecimals(t *testing.T) {
+	_, err := getMetadata(t, map[string][]byte{
+		string(nameSelector):   encodeString("Token"),
+		string(symbolSelector): encodeString("TKN"),
+	}, true)
+	if err == nil {
+		t.Errorf("Expected an error for a fungible token without decimals")
+	}
+}
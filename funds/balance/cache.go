@@ -0,0 +1,227 @@
+package balance
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+	"gopkg.in/redis.v3"
+)
+
+// BalanceCache stores balance and allowance lookups so that repeated checks
+// for the same maker don't each need an RPC call.
+type BalanceCache interface {
+	Get(key string) (*big.Int, bool)
+	Set(key string, value *big.Int)
+	Delete(keys ...string)
+	Clear()
+}
+
+// cacheAsset normalizes asset data for use in a cache key. RoboDex assets are
+// ERC20 tokens, so they share balance entries with the plain ERC20 asset.
+func cacheAsset(asset types.AssetData) types.AssetData {
+	if asset.IsType(types.RoboDexProxyID) {
+		return orCommon.ToERC20AssetData(asset.Address())
+	}
+	return asset
+}
+
+// BalanceKey is the cache key for the balance of owner in asset
+func BalanceKey(asset types.AssetData, owner *types.Address) string {
+	return fmt.Sprintf("b-%#x-%#x", cacheAsset(asset)[:], owner[:])
+}
+
+// AllowanceKey is the cache key for the allowance owner has granted spender
+// for asset
+func AllowanceKey(asset types.AssetData, owner, spender *types.Address) string {
+	return fmt.Sprintf("a-%#x-%#x-%#x", cacheAsset(asset)[:], owner[:], spender[:])
+}
+
+// OperatorKey is the cache key for whether owner has approved operator for
+// every token of the contract at token, as ERC721's setApprovalForAll does
+func OperatorKey(token, owner, operator *types.Address) string {
+	return fmt.Sprintf("o-%#x-%#x-%#x", token[:], owner[:], operator[:])
+}
+
+// CacheInvalidation lists the cache entries that were changed by a block.
+// Monitors publish one per block to the invalidation channel consumed by
+// CachedBalanceCheckers.
+type CacheInvalidation struct {
+	Keys []string `json:"keys"`
+}
+
+func (invalidation *CacheInvalidation) AddBalance(asset types.AssetData, owner *types.Address) {
+	invalidation.Keys = append(invalidation.Keys, BalanceKey(asset, owner))
+}
+
+func (invalidation *CacheInvalidation) AddAllowance(asset types.AssetData, owner, spender *types.Address) {
+	invalidation.Keys = append(invalidation.Keys, AllowanceKey(asset, owner, spender))
+}
+
+func (invalidation *CacheInvalidation) AddOperator(token, owner, operator *types.Address) {
+	invalidation.Keys = append(invalidation.Keys, OperatorKey(token, owner, operator))
+}
+
+// Publish sends the invalidation to publisher. Nothing is sent if the
+// publisher is nil or no keys were added.
+func (invalidation *CacheInvalidation) Publish(publisher channels.Publisher) error {
+	if publisher == nil || len(invalidation.Keys) == 0 {
+		return nil
+	}
+	msg, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+	if !publisher.Publish(string(msg)) {
+		return fmt.Errorf("Failed to publish cache invalidation")
+	}
+	return nil
+}
+
+type lruEntry struct {
+	key     string
+	value   *big.Int
+	expires time.Time
+}
+
+type lruBalanceCache struct {
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	mutex   *sync.Mutex
+}
+
+func (cache *lruBalanceCache) Get(key string) (*big.Int, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		if cache.ttl > 0 && time.Now().After(entry.expires) {
+			cache.order.Remove(element)
+			delete(cache.entries, key)
+			return nil, false
+		}
+		cache.order.MoveToFront(element)
+		return entry.value, true
+	}
+	return nil, false
+}
+
+func (cache *lruBalanceCache) Set(key string, value *big.Int) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	expires := time.Now().Add(cache.ttl)
+	if element, ok := cache.entries[key]; ok {
+		element.Value.(*lruEntry).value = value
+		element.Value.(*lruEntry).expires = expires
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&lruEntry{key, value, expires})
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (cache *lruBalanceCache) Delete(keys ...string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, key := range keys {
+		if element, ok := cache.entries[key]; ok {
+			cache.order.Remove(element)
+			delete(cache.entries, key)
+		}
+	}
+}
+
+func (cache *lruBalanceCache) Clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries = make(map[string]*list.Element)
+	cache.order.Init()
+}
+
+// NewLRUBalanceCache creates an in-process BalanceCache holding at most size
+// entries, evicting the least recently used entry when full. Entries expire
+// after ttl, which bounds how stale a value can get if an invalidation is
+// missed. A ttl of 0 means entries never expire.
+func NewLRUBalanceCache(size int, ttl time.Duration) BalanceCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &lruBalanceCache{size, ttl, make(map[string]*list.Element), list.New(), &sync.Mutex{}}
+}
+
+type redisBalanceCache struct {
+	redisClient *redis.Client
+	prefix      string
+	ttl         time.Duration
+}
+
+func (cache *redisBalanceCache) Get(key string) (*big.Int, bool) {
+	result, err := cache.redisClient.Get(cache.prefix + key).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error reading balance cache: %v", err.Error())
+		}
+		return nil, false
+	}
+	value, ok := new(big.Int).SetString(result, 10)
+	return value, ok
+}
+
+func (cache *redisBalanceCache) Set(key string, value *big.Int) {
+	if err := cache.redisClient.Set(cache.prefix+key, value.String(), cache.ttl).Err(); err != nil {
+		log.Printf("Error writing balance cache: %v", err.Error())
+	}
+}
+
+func (cache *redisBalanceCache) Delete(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = cache.prefix + key
+	}
+	if err := cache.redisClient.Del(prefixed...).Err(); err != nil {
+		log.Printf("Error invalidating balance cache: %v", err.Error())
+	}
+}
+
+func (cache *redisBalanceCache) Clear() {
+	cursor := int64(0)
+	for {
+		var keys []string
+		var err error
+		cursor, keys, err = cache.redisClient.Scan(cursor, cache.prefix+"*", 1000).Result()
+		if err != nil {
+			log.Printf("Error clearing balance cache: %v", err.Error())
+			return
+		}
+		if len(keys) > 0 {
+			cache.redisClient.Del(keys...)
+		}
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+// NewRedisBalanceCache creates a BalanceCache shared by every process using
+// the same redis server and prefix. Entries expire after ttl, which bounds how
+// stale a value can get if an invalidation is missed. A ttl of 0 means
+// entries never expire.
+func NewRedisBalanceCache(redisClient *redis.Client, prefix string, ttl time.Duration) BalanceCache {
+	return &redisBalanceCache{redisClient, prefix, ttl}
+}
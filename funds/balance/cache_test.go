@@ -0,0 +1,190 @@
+package balance_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/funds/balance"
+	"github.com/notegio/openrelay/types"
+)
+
+func TestLRUBalanceCacheEviction(t *testing.T) {
+	cache := balance.NewLRUBalanceCache(2, 0)
+	cache.Set("a", big.NewInt(1))
+	cache.Set("b", big.NewInt(2))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+	// b is now the least recently used
+	cache.Set("c", big.NewInt(3))
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if value, ok := cache.Get("a"); !ok || value.Int64() != 1 {
+		t.Errorf("Expected a to be retained, got %v", value)
+	}
+	if value, ok := cache.Get("c"); !ok || value.Int64() != 3 {
+		t.Errorf("Expected c to be retained, got %v", value)
+	}
+}
+
+func TestLRUBalanceCacheDelete(t *testing.T) {
+	cache := balance.NewLRUBalanceCache(10, 0)
+	cache.Set("a", big.NewInt(1))
+	cache.Set("b", big.NewInt(2))
+	cache.Delete("a", "missing")
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to be deleted")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Errorf("Expected b to be retained")
+	}
+	cache.Clear()
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected b to be cleared")
+	}
+}
+
+func TestLRUBalanceCacheExpiry(t *testing.T) {
+	cache := balance.NewLRUBalanceCache(10, 50*time.Millisecond)
+	cache.Set("a", big.NewInt(1))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("Expected a to expire")
+	}
+	// Setting a value again restarts its ttl
+	cache.Set("a", big.NewInt(2))
+	if value, ok := cache.Get("a"); !ok || value.Int64() != 2 {
+		t.Errorf("Expected a to be cached again, got %v", value)
+	}
+}
+
+type countingBalanceChecker struct {
+	calls int
+}
+
+func (checker *countingBalanceChecker) GetBalance(asset types.AssetData, userAddress *types.Address) (*big.Int, error) {
+	checker.calls++
+	return big.NewInt(int64(checker.calls)), nil
+}
+
+func (checker *countingBalanceChecker) GetAllowance(asset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	checker.calls++
+	return big.NewInt(int64(checker.calls)), nil
+}
+
+func TestRoutingBalanceCheckerInvalidation(t *testing.T) {
+	underlying := &countingBalanceChecker{}
+	checker := balance.NewRoutingBalanceChecker(
+		map[string]balance.BalanceChecker{"0xf47261b0": underlying},
+		balance.NewLRUBalanceCache(10, 0),
+	)
+	publisher, consumerChannel := channels.MockChannel()
+	consumerChannel.AddConsumer(checker)
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	tokenAddress, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	owner, _ := orCommon.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	other, _ := orCommon.HexToAddress("0x6ecbe1db9ef729cbe972c83fb886247691fb6beb")
+	asset := orCommon.ToERC20AssetData(tokenAddress)
+
+	// Any message enables the cache
+	publisher.Publish("{}")
+	channels.MockFinish(consumerChannel, 1)
+	checker.GetBalance(asset, owner)
+	checker.GetBalance(asset, other)
+	checker.GetBalance(asset, owner)
+	if underlying.calls != 2 {
+		t.Fatalf("Expected 2 lookups, got %v", underlying.calls)
+	}
+
+	invalidation := &balance.CacheInvalidation{}
+	invalidation.AddBalance(asset, owner)
+	if err := invalidation.Publish(publisher); err != nil {
+		t.Fatalf(err.Error())
+	}
+	channels.MockFinish(consumerChannel, 2)
+	checker.GetBalance(asset, owner)
+	checker.GetBalance(asset, other)
+	if underlying.calls != 3 {
+		t.Errorf("Expected only the invalidated balance to be looked up again, got %v lookups", underlying.calls)
+	}
+
+	msg, _ := json.Marshal(map[string]string{"hash": "0x00"})
+	publisher.Publish(string(msg))
+	channels.MockFinish(consumerChannel, 3)
+	checker.GetBalance(asset, owner)
+	checker.GetBalance(asset, other)
+	if underlying.calls != 3 {
+		t.Errorf("Expected a block message to leave the cache alone, got %v lookups", underlying.calls)
+	}
+}
+
+type operatorBalanceChecker struct {
+	countingBalanceChecker
+	approvedForAll bool
+	operatorCalls  int
+	tokenCalls     int
+}
+
+func (checker *operatorBalanceChecker) IsApprovedForAll(asset types.AssetData, ownerAddress, operatorAddress *types.Address) (bool, error) {
+	checker.operatorCalls++
+	return checker.approvedForAll, nil
+}
+
+func (checker *operatorBalanceChecker) GetTokenAllowance(asset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	checker.tokenCalls++
+	return big.NewInt(0), nil
+}
+
+func TestRoutingBalanceCheckerOperatorApprovals(t *testing.T) {
+	underlying := &operatorBalanceChecker{}
+	checker := balance.NewRoutingBalanceChecker(
+		map[string]balance.BalanceChecker{"0x02571792": underlying},
+		balance.NewLRUBalanceCache(10, 0),
+	)
+	publisher, consumerChannel := channels.MockChannel()
+	consumerChannel.AddConsumer(checker)
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	publisher.Publish("{}")
+	channels.MockFinish(consumerChannel, 1)
+	tokenAddress, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	owner, _ := orCommon.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	proxy, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	tokenA := orCommon.ToERC721AssetData(tokenAddress, orCommon.BigToUint256(big.NewInt(1)))
+	tokenB := orCommon.ToERC721AssetData(tokenAddress, orCommon.BigToUint256(big.NewInt(2)))
+
+	// The operator approval is shared by every token of the contract
+	checker.GetAllowance(tokenA, owner, proxy)
+	checker.GetAllowance(tokenB, owner, proxy)
+	checker.GetAllowance(tokenA, owner, proxy)
+	if underlying.operatorCalls != 1 || underlying.tokenCalls != 2 {
+		t.Fatalf("Expected 1 operator and 2 token lookups, got %v and %v", underlying.operatorCalls, underlying.tokenCalls)
+	}
+
+	// An ApprovalForAll log invalidates the operator approval for every token,
+	// leaving the approvals of individual tokens cached
+	underlying.approvedForAll = true
+	invalidation := &balance.CacheInvalidation{}
+	invalidation.AddOperator(tokenAddress, owner, proxy)
+	if err := invalidation.Publish(publisher); err != nil {
+		t.Fatalf(err.Error())
+	}
+	channels.MockFinish(consumerChannel, 2)
+	for _, asset := range []types.AssetData{tokenA, tokenB} {
+		if allowance, err := checker.GetAllowance(asset, owner, proxy); err != nil || allowance.Int64() != 1 {
+			t.Errorf("Expected %#x to be approved, got %v (%v)", asset[:], allowance, err)
+		}
+	}
+	if underlying.operatorCalls != 2 || underlying.tokenCalls != 2 {
+		t.Errorf("Expected 2 operator and 2 token lookups, got %v and %v", underlying.operatorCalls, underlying.tokenCalls)
+	}
+}
@@ -0,0 +1,84 @@
+package balance
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+)
+
+// keccak256("balanceOf(address,uint256)")[:4]
+var erc1155BalanceOfSelector = []byte{0, 253, 213, 142}
+
+// keccak256("isApprovedForAll(address,address)")[:4]
+var isApprovedForAllSelector = []byte{233, 133, 233, 197}
+
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+type rpcERC1155BalanceChecker struct {
+	conn bind.ContractCaller
+}
+
+func (funds *rpcERC1155BalanceChecker) call(tokenAddress *types.Address, data []byte) (*big.Int, error) {
+	target := orCommon.ToGethAddress(tokenAddress)
+	result, err := funds.conn.CallContract(context.Background(), ethereum.CallMsg{To: &target, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) < 32 {
+		return nil, fmt.Errorf("Unexpected result from %#x: %#x", tokenAddress[:], result)
+	}
+	return new(big.Int).SetBytes(result[:32]), nil
+}
+
+// GetBalance returns the number of units of the asset the user holds. An
+// ERC1155 asset may consist of several token ids, so this is limited by
+// whichever id the user holds the least of relative to the asset's values.
+func (funds *rpcERC1155BalanceChecker) GetBalance(tokenAsset types.AssetData, userAddrBytes *types.Address) (*big.Int, error) {
+	components, err := tokenAsset.Components()
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(components))
+	for i, component := range components {
+		ids, _, _, err := component.AssetData.ERC1155Items()
+		if err != nil {
+			return nil, err
+		}
+		data := append([]byte{}, erc1155BalanceOfSelector...)
+		data = append(data, make([]byte, 12)...)
+		data = append(data, userAddrBytes[:]...)
+		data = append(data, orCommon.BigToUint256(ids[0])[:]...)
+		if balances[i], err = funds.call(tokenAsset.Address(), data); err != nil {
+			return nil, err
+		}
+	}
+	return bundleUnits(components, balances), nil
+}
+
+// GetAllowance returns an unlimited allowance if the spender is an approved
+// operator for the owner, and 0 otherwise. ERC1155 has no per-token or
+// per-amount approvals.
+func (funds *rpcERC1155BalanceChecker) GetAllowance(tokenAsset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	data := append([]byte{}, isApprovedForAllSelector...)
+	data = append(data, make([]byte, 12)...)
+	data = append(data, ownerAddress[:]...)
+	data = append(data, make([]byte, 12)...)
+	data = append(data, spenderAddress[:]...)
+	approved, err := funds.call(tokenAsset.Address(), data)
+	if err != nil {
+		return nil, err
+	}
+	if approved.Sign() == 0 {
+		return big.NewInt(0), nil
+	}
+	return new(big.Int).Set(maxUint256), nil
+}
+
+func NewRpcERC1155BalanceChecker(conn bind.ContractCaller) BalanceChecker {
+	return &rpcERC1155BalanceChecker{conn}
+}
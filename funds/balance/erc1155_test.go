@@ -0,0 +1,77 @@
+package balance_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/funds/balance"
+)
+
+// erc1155Caller answers balanceOf(address,uint256) by token id and
+// isApprovedForAll(address,address) with a fixed value
+type erc1155Caller struct {
+	balances map[int64]int64
+	approved bool
+}
+
+func (caller *erc1155Caller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (caller *erc1155Caller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if bytes.Equal(call.Data[:4], []byte{0, 253, 213, 142}) {
+		id := new(big.Int).SetBytes(call.Data[36:68]).Int64()
+		return abi.U256(big.NewInt(caller.balances[id])), nil
+	}
+	if bytes.Equal(call.Data[:4], []byte{233, 133, 233, 197}) {
+		if caller.approved {
+			return abi.U256(big.NewInt(1)), nil
+		}
+		return abi.U256(big.NewInt(0)), nil
+	}
+	return nil, fmt.Errorf("Unexpected call %#x", call.Data)
+}
+
+func TestERC1155BalanceChecker(t *testing.T) {
+	token, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	owner, _ := orCommon.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	proxy, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c49")
+	caller := &erc1155Caller{balances: map[int64]int64{1: 10, 2: 12}}
+	checker := balance.NewRpcERC1155BalanceChecker(caller)
+	// Each unit is 2 of id 1 and 3 of id 2; 10 covers 5 units, 12 covers 4
+	asset := orCommon.ToERC1155AssetData(
+		token,
+		[]*big.Int{big.NewInt(1), big.NewInt(2)},
+		[]*big.Int{big.NewInt(2), big.NewInt(3)},
+		[]byte{},
+	)
+	units, err := checker.GetBalance(asset, owner)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if units.Int64() != 4 {
+		t.Errorf("Expected balance of 4 units, got %v", units)
+	}
+	allowance, err := checker.GetAllowance(asset, owner, proxy)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if allowance.Sign() != 0 {
+		t.Errorf("Expected no allowance without approval, got %v", allowance)
+	}
+	caller.approved = true
+	allowance, err = checker.GetAllowance(asset, owner, proxy)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if allowance.BitLen() != 256 {
+		t.Errorf("Expected unlimited allowance with approval, got %v", allowance)
+	}
+}
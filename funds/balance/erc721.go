@@ -28,19 +28,39 @@ func (funds *rpcERC721BalanceChecker) GetBalance(tokenAsset types.AssetData, use
 }
 
 func (funds *rpcERC721BalanceChecker) GetAllowance(tokenAsset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	if approved, err := funds.IsApprovedForAll(tokenAsset, ownerAddress, spenderAddress); err != nil {
+		return nil, err
+	} else if approved {
+		return big.NewInt(1), nil
+	}
+	return funds.GetTokenAllowance(tokenAsset, ownerAddress, spenderAddress)
+}
+
+// IsApprovedForAll indicates whether ownerAddress has approved
+// operatorAddress for every token of the asset's contract
+func (funds *rpcERC721BalanceChecker) IsApprovedForAll(tokenAsset types.AssetData, ownerAddress, operatorAddress *types.Address) (bool, error) {
 	token, err := tokenModule.NewERC721Token(orCommon.ToGethAddress(tokenAsset.Address()), funds.conn)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	if approved, err := token.IsApprovedForAll(nil, orCommon.ToGethAddress(ownerAddress), orCommon.ToGethAddress(spenderAddress)); err != nil {
+	approved, err := token.IsApprovedForAll(nil, orCommon.ToGethAddress(ownerAddress), orCommon.ToGethAddress(operatorAddress))
+	if err != nil {
 		if err.Error() != "VM Exception while processing transaction: revert" {
-			return nil, err
-		} else {
-			// Some early ERC721 tokens don't implement this
-			log.Printf("Token %#x does not provide isApprovedForAll. Testing getApproved.", tokenAsset.Address())
+			return false, err
 		}
-	} else if approved {
-		return big.NewInt(1), nil
+		// Some early ERC721 tokens don't implement this
+		log.Printf("Token %#x does not provide isApprovedForAll. Testing getApproved.", tokenAsset.Address())
+		return false, nil
+	}
+	return approved, nil
+}
+
+// GetTokenAllowance returns 1 if spenderAddress is approved for the asset's
+// token in particular, and 0 otherwise
+func (funds *rpcERC721BalanceChecker) GetTokenAllowance(tokenAsset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	token, err := tokenModule.NewERC721Token(orCommon.ToGethAddress(tokenAsset.Address()), funds.conn)
+	if err != nil {
+		return nil, err
 	}
 	if operator, err := token.GetApproved(nil, tokenAsset.TokenID().Big()); err != nil {
 		return nil, err
@@ -50,7 +70,6 @@ func (funds *rpcERC721BalanceChecker) GetAllowance(tokenAsset types.AssetData, o
 	return big.NewInt(0), nil
 }
 
-
 func NewRpcERC721BalanceChecker(conn bind.ContractBackend) (BalanceChecker) {
 	return &rpcERC721BalanceChecker{conn}
 }
@@ -0,0 +1,147 @@
+package balance
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/notegio/openrelay/types"
+)
+
+// keccak256("getAssetProxy(bytes4)")[:4]
+var getAssetProxySelector = []byte{96, 112, 65, 8}
+
+// ProxyLookup finds the asset proxy a MultiAssetProxy uses for a given proxy
+// id
+type ProxyLookup interface {
+	GetAssetProxy(multiAssetProxy *types.Address, proxyID [4]byte) (*types.Address, error)
+}
+
+type rpcProxyLookup struct {
+	conn    bind.ContractCaller
+	proxies map[types.Address]map[[4]byte]*types.Address
+	mutex   *sync.Mutex
+}
+
+func (lookup *rpcProxyLookup) GetAssetProxy(multiAssetProxy *types.Address, proxyID [4]byte) (*types.Address, error) {
+	lookup.mutex.Lock()
+	if proxy, ok := lookup.proxies[*multiAssetProxy][proxyID]; ok {
+		lookup.mutex.Unlock()
+		return proxy, nil
+	}
+	lookup.mutex.Unlock()
+	target := multiAssetProxy.ToGethAddress()
+	data := make([]byte, 36)
+	copy(data[:4], getAssetProxySelector)
+	copy(data[4:8], proxyID[:])
+	result, err := lookup.conn.CallContract(context.Background(), ethereum.CallMsg{To: &target, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) < 32 {
+		return nil, fmt.Errorf("Unexpected getAssetProxy result: %#x", result)
+	}
+	proxy := &types.Address{}
+	copy(proxy[:], result[12:32])
+	lookup.mutex.Lock()
+	defer lookup.mutex.Unlock()
+	if _, ok := lookup.proxies[*multiAssetProxy]; !ok {
+		lookup.proxies[*multiAssetProxy] = make(map[[4]byte]*types.Address)
+	}
+	lookup.proxies[*multiAssetProxy][proxyID] = proxy
+	return proxy, nil
+}
+
+// NewRpcProxyLookup creates a ProxyLookup that asks the MultiAssetProxy
+// contract for its registered asset proxies, caching the results.
+func NewRpcProxyLookup(conn bind.ContractCaller) ProxyLookup {
+	return &rpcProxyLookup{conn, make(map[types.Address]map[[4]byte]*types.Address), &sync.Mutex{}}
+}
+
+type multiAssetBalanceChecker struct {
+	componentChecker BalanceChecker
+	proxyLookup      ProxyLookup
+}
+
+// bundleUnits returns how many whole bundles the per-component amounts
+// available can cover
+func bundleUnits(components []types.AssetComponent, available []*big.Int) *big.Int {
+	var units *big.Int
+	for i, component := range components {
+		if component.Amount.Sign() == 0 {
+			continue
+		}
+		componentUnits := new(big.Int).Div(available[i], component.Amount)
+		if units == nil || componentUnits.Cmp(units) < 0 {
+			units = componentUnits
+		}
+	}
+	if units == nil {
+		return big.NewInt(0)
+	}
+	return units
+}
+
+// GetBalance returns the number of units of the bundle the user holds, which
+// is limited by whichever component they hold the least of.
+func (funds *multiAssetBalanceChecker) GetBalance(asset types.AssetData, userAddress *types.Address) (*big.Int, error) {
+	components, err := asset.Components()
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(components))
+	for i, component := range components {
+		if balances[i], err = funds.componentChecker.GetBalance(component.AssetData, userAddress); err != nil {
+			return nil, err
+		}
+	}
+	return bundleUnits(components, balances), nil
+}
+
+// GetAllowance returns the number of units of the bundle the spender (the
+// MultiAssetProxy) can transfer. The MultiAssetProxy transfers each component
+// through the proxy registered for that component, so those are the
+// allowances that matter.
+func (funds *multiAssetBalanceChecker) GetAllowance(asset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	components, err := asset.Components()
+	if err != nil {
+		return nil, err
+	}
+	allowances := make([]*big.Int, len(components))
+	for i, component := range components {
+		proxy, err := funds.proxyLookup.GetAssetProxy(spenderAddress, component.AssetData.ProxyId())
+		if err != nil {
+			return nil, err
+		}
+		if allowances[i], err = funds.componentChecker.GetAllowance(component.AssetData, ownerAddress, proxy); err != nil {
+			return nil, err
+		}
+	}
+	return bundleUnits(components, allowances), nil
+}
+
+// NewMultiAssetBalanceChecker creates a BalanceChecker for MultiAssetProxy
+// bundles, which looks up each component with componentChecker.
+func NewMultiAssetBalanceChecker(componentChecker BalanceChecker, proxyLookup ProxyLookup) BalanceChecker {
+	return &multiAssetBalanceChecker{componentChecker, proxyLookup}
+}
+
+type staticProxyLookup struct {
+	proxies map[[4]byte]*types.Address
+}
+
+func (lookup *staticProxyLookup) GetAssetProxy(multiAssetProxy *types.Address, proxyID [4]byte) (*types.Address, error) {
+	if proxy, ok := lookup.proxies[proxyID]; ok {
+		return proxy, nil
+	}
+	return nil, fmt.Errorf("No proxy registered for '%#x'", proxyID)
+}
+
+// NewStaticProxyLookup creates a ProxyLookup with a fixed set of proxies,
+// regardless of the MultiAssetProxy address.
+func NewStaticProxyLookup(proxies map[[4]byte]*types.Address) ProxyLookup {
+	return &staticProxyLookup{proxies}
+}
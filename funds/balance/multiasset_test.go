@@ -0,0 +1,63 @@
+package balance_test
+
+import (
+	"math/big"
+	"testing"
+
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/funds/balance"
+	"github.com/notegio/openrelay/types"
+)
+
+func TestMultiAssetBalanceChecker(t *testing.T) {
+	tokenA, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	tokenB, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	owner, _ := orCommon.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	erc20Proxy, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c49")
+	multiAssetProxy, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c4a")
+	assetA := orCommon.ToERC20AssetData(tokenA)
+	assetB := orCommon.ToERC20AssetData(tokenB)
+	balanceMap := map[string]map[types.Address]*big.Int{
+		string(assetA): map[types.Address]*big.Int{*owner: big.NewInt(10)},
+		string(assetB): map[types.Address]*big.Int{*owner: big.NewInt(12)},
+	}
+	checker := balance.NewMultiAssetBalanceChecker(
+		balance.NewMockBalanceChecker(balanceMap),
+		balance.NewStaticProxyLookup(map[[4]byte]*types.Address{types.ERC20ProxyID: erc20Proxy}),
+	)
+	// Each bundle is 2 of A and 3 of B; 10 A covers 5 bundles, 12 B covers 4
+	bundle := orCommon.ToMultiAssetData(
+		[]*big.Int{big.NewInt(2), big.NewInt(3)},
+		[]types.AssetData{assetA, assetB},
+	)
+	units, err := checker.GetBalance(bundle, owner)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if units.Int64() != 4 {
+		t.Errorf("Expected balance of 4 bundles, got %v", units)
+	}
+	units, err = checker.GetAllowance(bundle, owner, multiAssetProxy)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if units.Int64() != 4 {
+		t.Errorf("Expected allowance of 4 bundles, got %v", units)
+	}
+}
+
+func TestMultiAssetBalanceCheckerMissingProxy(t *testing.T) {
+	token, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	owner, _ := orCommon.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	asset := orCommon.ToERC20AssetData(token)
+	checker := balance.NewMultiAssetBalanceChecker(
+		balance.NewMockBalanceChecker(map[string]map[types.Address]*big.Int{
+			string(asset): map[types.Address]*big.Int{*owner: big.NewInt(10)},
+		}),
+		balance.NewStaticProxyLookup(map[[4]byte]*types.Address{}),
+	)
+	bundle := orCommon.ToMultiAssetData([]*big.Int{big.NewInt(1)}, []types.AssetData{asset})
+	if _, err := checker.GetAllowance(bundle, owner, owner); err == nil {
+		t.Errorf("Expected error for unregistered component proxy")
+	}
+}
@@ -2,16 +2,25 @@ package balance
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/types"
 )
 
+// DefaultCacheSize is the number of entries kept by the in-process cache
+const DefaultCacheSize = 100000
+
+// DefaultCacheTTL is how long cached balances and allowances are trusted
+// without an invalidation
+const DefaultCacheTTL = 10 * time.Minute
+
 type BalanceChecker interface {
 	GetBalance(asset types.AssetData, userAddress *types.Address) (*big.Int, error)
 	GetAllowance(asset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error)
@@ -22,76 +31,145 @@ type CachedBalanceChecker interface {
 	Consume(msg channels.Delivery)
 }
 
+// OperatorBalanceChecker is implemented by checkers for tokens whose owners
+// can approve an operator for all of their tokens at once, as with ERC721's
+// setApprovalForAll. An ApprovalForAll log names no token, so operator
+// approvals are cached apart from the approvals of individual tokens.
+type OperatorBalanceChecker interface {
+	BalanceChecker
+	IsApprovedForAll(asset types.AssetData, ownerAddress, operatorAddress *types.Address) (bool, error)
+	GetTokenAllowance(asset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error)
+}
+
 type routingBalanceChecker struct {
-	lookupCache          map[string]*big.Int
+	cache                BalanceCache
+	cacheEnabled         bool
 	cacheMutex           *sync.Mutex
+	enableCache          *sync.Once
 	assetBalanceCheckers map[string]BalanceChecker
 }
 
-func (funds *routingBalanceChecker) GetBalance(tokenAsset types.AssetData, userAddrBytes *types.Address) (*big.Int, error) {
-	cacheKey := fmt.Sprintf("b-%#x-%#x", tokenAsset[:], userAddrBytes[:])
-	if funds.lookupCache != nil {
-		funds.cacheMutex.Lock()
-		if balance, ok := funds.lookupCache[cacheKey]; ok {
-			funds.cacheMutex.Unlock()
+// cached returns the cache if it is in use. The cache is only used once an
+// invalidation message has been received, as until then we have no way of
+// knowing cached values are still current.
+//
+// Bundles are never cached themselves, as invalidations are published for
+// their components; the component lookups are cached instead. ERC1155
+// transfers name individual token ids rather than the asset data orders use,
+// so ERC1155 lookups aren't cached either.
+func (funds *routingBalanceChecker) cached(asset types.AssetData) BalanceCache {
+	if asset.IsType(types.MultiAssetProxyID) || asset.IsType(types.ERC1155ProxyID) {
+		return nil
+	}
+	funds.cacheMutex.Lock()
+	defer funds.cacheMutex.Unlock()
+	if funds.cacheEnabled {
+		return funds.cache
+	}
+	return nil
+}
 
-			return balance, nil
+// lookup returns the value cached under key, or gets it with fetch and caches
+// it. A nil cache means the value is always fetched.
+func lookup(cache BalanceCache, key string, fetch func() (*big.Int, error)) (*big.Int, error) {
+	if cache != nil {
+		if value, ok := cache.Get(key); ok {
+			return value, nil
 		}
-		funds.cacheMutex.Unlock()
-	}
-	balanceChecker, ok := funds.assetBalanceCheckers[fmt.Sprintf("%#x", tokenAsset.ProxyId())]
-	if !ok {
-		return nil, fmt.Errorf("Could not find balance checker for asset type '%#x'", tokenAsset.ProxyId())
 	}
-	balance, err := balanceChecker.GetBalance(tokenAsset, userAddrBytes)
+	value, err := fetch()
 	if err != nil {
 		return nil, err
 	}
-	if funds.lookupCache != nil {
-		funds.cacheMutex.Lock()
-		defer funds.cacheMutex.Unlock()
-		funds.lookupCache[cacheKey] = balance
+	if cache != nil {
+		cache.Set(key, value)
 	}
-	return balance, nil
+	return value, nil
 }
-func (funds *routingBalanceChecker) GetAllowance(tokenAsset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
-	cacheKey := fmt.Sprintf("a-%#x-%#x-%#x", tokenAsset[:], ownerAddress[:], spenderAddress[:])
-	if funds.lookupCache != nil {
-		funds.cacheMutex.Lock()
-		if balance, ok := funds.lookupCache[cacheKey]; ok {
-			funds.cacheMutex.Unlock()
 
-			return balance, nil
-		}
-		funds.cacheMutex.Unlock()
-	}
+func (funds *routingBalanceChecker) checker(tokenAsset types.AssetData) (BalanceChecker, error) {
 	balanceChecker, ok := funds.assetBalanceCheckers[fmt.Sprintf("%#x", tokenAsset.ProxyId())]
 	if !ok {
 		return nil, fmt.Errorf("Could not find balance checker for asset type '%#x'", tokenAsset.ProxyId())
 	}
-	allowance, err := balanceChecker.GetAllowance(tokenAsset, ownerAddress, spenderAddress)
+	return balanceChecker, nil
+}
+
+func (funds *routingBalanceChecker) GetBalance(tokenAsset types.AssetData, userAddrBytes *types.Address) (*big.Int, error) {
+	balanceChecker, err := funds.checker(tokenAsset)
+	if err != nil {
+		return nil, err
+	}
+	return lookup(funds.cached(tokenAsset), BalanceKey(tokenAsset, userAddrBytes), func() (*big.Int, error) {
+		return balanceChecker.GetBalance(tokenAsset, userAddrBytes)
+	})
+}
+
+func (funds *routingBalanceChecker) GetAllowance(tokenAsset types.AssetData, ownerAddress, spenderAddress *types.Address) (*big.Int, error) {
+	balanceChecker, err := funds.checker(tokenAsset)
 	if err != nil {
 		return nil, err
 	}
-	if funds.lookupCache != nil {
-		funds.cacheMutex.Lock()
-		defer funds.cacheMutex.Unlock()
-		funds.lookupCache[cacheKey] = allowance
+	cache := funds.cached(tokenAsset)
+	if operatorChecker, ok := balanceChecker.(OperatorBalanceChecker); ok {
+		approved, err := lookup(cache, OperatorKey(tokenAsset.Address(), ownerAddress, spenderAddress), func() (*big.Int, error) {
+			if approved, err := operatorChecker.IsApprovedForAll(tokenAsset, ownerAddress, spenderAddress); err != nil {
+				return nil, err
+			} else if approved {
+				return big.NewInt(1), nil
+			}
+			return big.NewInt(0), nil
+		})
+		if err != nil || approved.Sign() != 0 {
+			return approved, err
+		}
+		return lookup(cache, AllowanceKey(tokenAsset, ownerAddress, spenderAddress), func() (*big.Int, error) {
+			return operatorChecker.GetTokenAllowance(tokenAsset, ownerAddress, spenderAddress)
+		})
 	}
-	return allowance, nil
+	return lookup(cache, AllowanceKey(tokenAsset, ownerAddress, spenderAddress), func() (*big.Int, error) {
+		return balanceChecker.GetAllowance(tokenAsset, ownerAddress, spenderAddress)
+	})
 }
 
+// Consume handles invalidation messages, removing the entries a
+// CacheInvalidation lists. The first message of any kind switches the cache
+// on, after clearing out anything left from before invalidations were being
+// received. Other messages are otherwise ignored.
 func (funds *routingBalanceChecker) Consume(msg channels.Delivery) {
-	if funds.lookupCache == nil {
-		log.Printf("Inititalizing lookup cache")
-	}
 	defer msg.Ack()
+	if funds.cache == nil {
+		return
+	}
 	funds.cacheMutex.Lock()
-	defer funds.cacheMutex.Unlock()
-	funds.lookupCache = make(map[string]*big.Int)
+	cacheEnabled := funds.cacheEnabled
+	funds.cacheMutex.Unlock()
+	if !cacheEnabled {
+		// Lookups don't use the cache until it is enabled, so clearing it
+		// doesn't hold them up
+		funds.enableCache.Do(func() {
+			log.Printf("Inititalizing lookup cache")
+			funds.cache.Clear()
+			funds.cacheMutex.Lock()
+			funds.cacheEnabled = true
+			funds.cacheMutex.Unlock()
+		})
+		return
+	}
+	invalidation := &CacheInvalidation{}
+	if err := json.Unmarshal([]byte(msg.Payload()), invalidation); err != nil || len(invalidation.Keys) == 0 {
+		return
+	}
+	funds.cache.Delete(invalidation.Keys...)
 }
 
 func NewRpcRoutingBalanceChecker(rpcURL string) (CachedBalanceChecker, error) {
+	return NewRpcCachedRoutingBalanceChecker(rpcURL, NewLRUBalanceCache(DefaultCacheSize, DefaultCacheTTL))
+}
+
+// NewRpcCachedRoutingBalanceChecker creates a CachedBalanceChecker that
+// stores lookups in cache.
+func NewRpcCachedRoutingBalanceChecker(rpcURL string, cache BalanceCache) (CachedBalanceChecker, error) {
 	conn, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, err
@@ -106,5 +184,16 @@ func NewRpcRoutingBalanceChecker(rpcURL string) (CachedBalanceChecker, error) {
 	checkers["0xf47261b0"] = NewRpcERC20BalanceChecker(conn)
 	checkers["0x02571792"] = NewRpcERC721BalanceChecker(conn)
 	checkers["0x0e2042d8"] = NewRpcERC20BalanceChecker(conn)
-	return &routingBalanceChecker{nil, &sync.Mutex{}, checkers}, nil
+	checkers["0xa7cb5fb7"] = NewRpcERC1155BalanceChecker(conn)
+	routing := NewRoutingBalanceChecker(checkers, cache)
+	// Bundle components are looked up through the routing checker so that
+	// they share its cache
+	checkers["0x94cfcdd7"] = NewMultiAssetBalanceChecker(routing, NewRpcProxyLookup(conn))
+	return routing, nil
+}
+
+// NewRoutingBalanceChecker creates a CachedBalanceChecker that dispatches to
+// checkers by asset proxy id (formatted as "0x" followed by 8 hex digits).
+func NewRoutingBalanceChecker(checkers map[string]BalanceChecker, cache BalanceCache) CachedBalanceChecker {
+	return &routingBalanceChecker{cache, false, &sync.Mutex{}, &sync.Once{}, checkers}
 }
@@ -0,0 +1,190 @@
+package funds
+
+import (
+	"bytes"
+	"log"
+	"math/big"
+
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/config"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds/balance"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+)
+
+// OpenOrderLookup finds the other open orders a maker has on the same
+// exchange as the given order.
+type OpenOrderLookup interface {
+	GetOpenOrders(order *types.Order) ([]dbModule.Order, error)
+}
+
+type dbOpenOrderLookup struct {
+	db *gorm.DB
+}
+
+func (lookup *dbOpenOrderLookup) GetOpenOrders(order *types.Order) ([]dbModule.Order, error) {
+	orders := []dbModule.Order{}
+	err := lookup.db.Model(&dbModule.Order{}).Select(
+		"maker_asset_data, maker_asset_remaining, maker_fee_asset_data, maker_fee_remaining",
+	).Where(
+		"status = ? AND maker = ? AND exchange_address = ? AND order_hash <> ?",
+		dbModule.StatusOpen, order.Maker, order.ExchangeAddress, order.Hash(),
+	).Find(&orders).Error
+	return orders, err
+}
+
+func NewDBOpenOrderLookup(db *gorm.DB) OpenOrderLookup {
+	return &dbOpenOrderLookup{db}
+}
+
+type MockOpenOrderLookup struct {
+	orders []dbModule.Order
+	err    error
+}
+
+func (lookup *MockOpenOrderLookup) GetOpenOrders(order *types.Order) ([]dbModule.Order, error) {
+	return lookup.orders, lookup.err
+}
+
+func NewMockOpenOrderLookup(orders []dbModule.Order, err error) OpenOrderLookup {
+	return &MockOpenOrderLookup{orders, err}
+}
+
+// CommitmentChecker determines whether a maker has committed more of an asset
+// across their open orders than their balance and allowance can cover. Each
+// order may be individually fundable while the set of them is not.
+type CommitmentChecker interface {
+	OverCommitted(order *types.Order) (bool, error)
+}
+
+type commitmentChecker struct {
+	lookup    OpenOrderLookup
+	validator *orderValidator
+}
+
+// OverCommitted sums MakerAssetRemaining and MakerFeeRemaining across the
+// maker's open orders, including this one, and compares the totals for the
+// order's maker asset and the fee token to what the maker has available.
+func (checker *commitmentChecker) OverCommitted(order *types.Order) (bool, error) {
+	feeToken, err := checker.validator.feeToken.Get(order)
+	if err != nil {
+		log.Printf("Error getting fee token '%v'", err.Error())
+		return false, err
+	}
+	makerProxyAddress, err := checker.validator.tokenProxy.Get(order)
+	if err != nil {
+		log.Printf("Error getting token proxy address '%v'", err.Error())
+		return false, err
+	}
+	feeProxyAddress, err := checker.validator.feeProxy(order, feeToken)
+	if err != nil {
+		log.Printf("Error getting fee token proxy address '%v'", err.Error())
+		return false, err
+	}
+	openOrders, err := checker.lookup.GetOpenOrders(order)
+	if err != nil {
+		return false, err
+	}
+	newOrder := dbModule.Order{}
+	newOrder.Order = *order
+	newOrder.Populate()
+	openOrders = append(openOrders, newOrder)
+
+	makerAssetIsFee := bytes.Equal(order.MakerAssetData, feeToken)
+	makerCommitted := new(big.Int)
+	feeCommitted := new(big.Int)
+	for _, openOrder := range openOrders {
+		if openOrder.MakerAssetRemaining != nil && bytes.Equal(openOrder.MakerAssetData, order.MakerAssetData) {
+			makerCommitted.Add(makerCommitted, openOrder.MakerAssetRemaining.Big())
+		} else if openOrder.MakerAssetRemaining != nil && bytes.Equal(openOrder.MakerAssetData, feeToken) {
+			feeCommitted.Add(feeCommitted, openOrder.MakerAssetRemaining.Big())
+		}
+		if openOrder.MakerFeeRemaining != nil {
+			openFeeToken := feeToken
+			if order.IsV3() {
+				// v3 orders on the same exchange may pay fees in different assets
+				openFeeToken = openOrder.MakerFeeAssetData
+			}
+			if bytes.Equal(openFeeToken, feeToken) {
+				feeCommitted.Add(feeCommitted, openOrder.MakerFeeRemaining.Big())
+			} else if bytes.Equal(openFeeToken, order.MakerAssetData) {
+				makerCommitted.Add(makerCommitted, openOrder.MakerFeeRemaining.Big())
+			}
+		}
+	}
+	if makerAssetIsFee {
+		makerCommitted.Add(makerCommitted, feeCommitted)
+		feeCommitted.SetInt64(0)
+	}
+
+	var makerChan, feeChan chan bigOrErr
+	if makerCommitted.Sign() != 0 {
+		makerChan = make(chan bigOrErr, 1)
+		go checker.validator.checkAvailable(order.MakerAssetData, order.Maker, makerProxyAddress, makerChan)
+	}
+	if feeCommitted.Sign() != 0 {
+		feeChan = make(chan bigOrErr, 1)
+		go checker.validator.checkAvailable(feeToken, order.Maker, feeProxyAddress, feeChan)
+	}
+	makerAvailable, err := awaitAvailable(makerChan)
+	if err != nil {
+		return false, err
+	}
+	feeAvailable, err := awaitAvailable(feeChan)
+	if err != nil {
+		return false, err
+	}
+	if makerAvailable != nil && makerCommitted.Cmp(makerAvailable) > 0 {
+		log.Printf("Maker %#x has committed %v of %#x with %v available", order.Maker[:], makerCommitted, order.MakerAssetData[:], makerAvailable)
+		return true, nil
+	}
+	if feeAvailable != nil && feeCommitted.Cmp(feeAvailable) > 0 {
+		log.Printf("Maker %#x has committed %v in fees with %v available", order.Maker[:], feeCommitted, feeAvailable)
+		return true, nil
+	}
+	return false, nil
+}
+
+func NewCommitmentChecker(lookup OpenOrderLookup, checker balance.BalanceChecker, feeToken config.FeeToken, tokenProxy config.TokenProxy) CommitmentChecker {
+	return &commitmentChecker{lookup, &orderValidator{checker, feeToken, tokenProxy}}
+}
+
+func NewRpcCommitmentChecker(db *gorm.DB, rpcUrl string, feeToken config.FeeToken, tokenProxy config.TokenProxy) (CommitmentChecker, error) {
+	checker, err := balance.NewRpcRoutingBalanceChecker(rpcUrl)
+	if err != nil {
+		return nil, err
+	}
+	return NewCommitmentChecker(NewDBOpenOrderLookup(db), checker, feeToken, tokenProxy), nil
+}
+
+type poolCommitmentScreen struct {
+	db      *gorm.DB
+	checker CommitmentChecker
+}
+
+// Screen applies the commitment policy of the order's pool. Orders in pools
+// without a policy aren't checked at all.
+func (screen *poolCommitmentScreen) Screen(order *types.Order) (bool, bool, error) {
+	pool := &poolModule.Pool{}
+	if err := screen.db.Model(&poolModule.Pool{}).Where("id = ?", order.PoolID).First(pool).Error; err != nil {
+		if err.Error() == "record not found" {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	if pool.CommitmentPolicy != poolModule.CommitmentPolicyReject && pool.CommitmentPolicy != poolModule.CommitmentPolicyFlag {
+		return false, false, nil
+	}
+	overCommitted, err := screen.checker.OverCommitted(order)
+	if err != nil || !overCommitted {
+		return false, false, err
+	}
+	return pool.CommitmentPolicy == poolModule.CommitmentPolicyReject, pool.CommitmentPolicy == poolModule.CommitmentPolicyFlag, nil
+}
+
+// NewPoolCommitmentScreen creates a db.CommitmentScreen that rejects or flags
+// over-committed orders according to their pool's CommitmentPolicy.
+func NewPoolCommitmentScreen(db *gorm.DB, checker CommitmentChecker) dbModule.CommitmentScreen {
+	return &poolCommitmentScreen{db, checker}
+}
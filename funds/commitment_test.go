@@ -0,0 +1,72 @@
+package funds_test
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/notegio/openrelay/config"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+)
+
+func getCommitmentChecker(t *testing.T, makerBalance string, openOrders []dbModule.Order) funds.CommitmentChecker {
+	balanceChecker := createMockBalanceChecker("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba", "627306090abab3a6e1400e9345bc60c78a8bef57", makerBalance, "0", t)
+	feeTokenAsset, _ := hexToAssetData("f47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	tokenProxyAddress, _ := hexToAddress("d4fd252d7d2c9479a8d616f510eac6243b5dddf9")
+	return funds.NewCommitmentChecker(
+		funds.NewMockOpenOrderLookup(openOrders, nil),
+		balanceChecker,
+		config.StaticFeeToken(feeTokenAsset),
+		config.StaticTokenProxy(tokenProxyAddress),
+	)
+}
+
+func openOrder(assetDataHex string, makerRemaining int64) dbModule.Order {
+	assetData, _ := hexToAssetData(assetDataHex)
+	order := dbModule.Order{}
+	order.MakerAssetData = assetData
+	order.MakerAssetRemaining = &types.Uint256{}
+	copy(order.MakerAssetRemaining[:], abi.U256(new(big.Int).Mul(big.NewInt(makerRemaining), big.NewInt(1000000000000000000))))
+	order.MakerFeeRemaining = &types.Uint256{}
+	return order
+}
+
+func TestCommitmentWithinBalance(t *testing.T) {
+	// The sample order offers 50 of its maker token
+	checker := getCommitmentChecker(t, "80000000000000000000", []dbModule.Order{
+		openOrder("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba", 30),
+		// Orders for other assets don't count against this one
+		openOrder("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04bb", 100),
+	})
+	order, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	overCommitted, err := checker.OverCommitted(order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if overCommitted {
+		t.Errorf("Expected 80 to cover 30 + 50")
+	}
+}
+
+func TestCommitmentExceedsBalance(t *testing.T) {
+	checker := getCommitmentChecker(t, "80000000000000000000", []dbModule.Order{
+		openOrder("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba", 20),
+		openOrder("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba", 20),
+	})
+	order, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	overCommitted, err := checker.OverCommitted(order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !overCommitted {
+		t.Errorf("Expected 80 not to cover 20 + 20 + 50")
+	}
+}
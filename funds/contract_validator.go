@@ -0,0 +1,411 @@
+package funds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+)
+
+// Order statuses as reported by the 0x OrderValidator contract
+const (
+	OrderStatusInvalid                 = uint8(0)
+	OrderStatusInvalidMakerAssetAmount = uint8(1)
+	OrderStatusInvalidTakerAssetAmount = uint8(2)
+	OrderStatusFillable                = uint8(3)
+	OrderStatusExpired                 = uint8(4)
+	OrderStatusFullyFilled             = uint8(5)
+	OrderStatusCancelled               = uint8(6)
+)
+
+// keccak256("getOrdersAndTradersInfo((address,address,address,address,uint256,uint256,uint256,uint256,uint256,uint256,bytes,bytes)[],address[])")[:4]
+var getOrdersAndTradersInfoSelector = []byte{75, 149, 222, 19}
+
+// OrderFundInfo holds everything the OrderValidator contract reports about
+// an order and its maker in a single call.
+type OrderFundInfo struct {
+	OrderStatus            uint8
+	TakerAssetFilledAmount *big.Int
+	MakerBalance           *big.Int
+	MakerAllowance         *big.Int
+	MakerFeeBalance        *big.Int
+	MakerFeeAllowance      *big.Int
+}
+
+// Funded indicates whether the maker can cover the unfilled portion of the
+// order, including the maker fee.
+func (info *OrderFundInfo) Funded(order *types.Order) bool {
+	if info.OrderStatus != OrderStatusFillable {
+		return false
+	}
+	filled := info.TakerAssetFilledAmount.Bytes()
+	makerRequired := new(big.Int).SetBytes(getRemainingAmount(filled, order.TakerAssetAmount[:], order.MakerAssetAmount[:]))
+	feeRequired := new(big.Int).SetBytes(getRemainingAmount(filled, order.TakerAssetAmount[:], order.MakerFee[:]))
+	return makerRequired.Cmp(info.MakerBalance) <= 0 &&
+		makerRequired.Cmp(info.MakerAllowance) <= 0 &&
+		feeRequired.Cmp(info.MakerFeeBalance) <= 0 &&
+		feeRequired.Cmp(info.MakerFeeAllowance) <= 0
+}
+
+// FillableTakerAssetAmount returns how much of the unfilled portion of the
+// order the maker's balances and allowances can cover. Orders the contract
+// doesn't consider fillable have nothing fillable.
+func (info *OrderFundInfo) FillableTakerAssetAmount(order *types.Order) *big.Int {
+	if info.OrderStatus != OrderStatusFillable {
+		return big.NewInt(0)
+	}
+	filledOrder := *order
+	filledOrder.TakerAssetAmountFilled = common.BigToUint256(info.TakerAssetFilledAmount)
+	return filledOrder.FillableTakerAssetAmount(
+		minInt(info.MakerBalance, info.MakerAllowance),
+		minInt(info.MakerFeeBalance, info.MakerFeeAllowance),
+		false,
+	)
+}
+
+func minInt(a, b *big.Int) *big.Int {
+	if a.Cmp(b) < 0 {
+		return a
+	}
+	return b
+}
+
+// BatchOrderValidator can check the funding of many orders at once
+type BatchOrderValidator interface {
+	FillableOrderValidator
+	ValidateOrders(orders []*types.Order) ([]bool, error)
+	GetOrdersInfo(orders []*types.Order) ([]*OrderFundInfo, error)
+	Checks(order *types.Order) bool
+}
+
+type contractOrderValidator struct {
+	address  *types.Address
+	exchange *types.Address
+	conn     bind.ContractCaller
+}
+
+// Checks indicates whether the OrderValidator contract can check order. The
+// contract works out order status against the exchange it was deployed for,
+// takes v2 orders with ZRX fees, and reports balances only for plain ERC20
+// and ERC721 assets. Anything else would be reported as unfunded.
+func (validator *contractOrderValidator) Checks(order *types.Order) bool {
+	if order.IsV3() || *order.ExchangeAddress != *validator.exchange {
+		return false
+	}
+	for _, assetData := range []types.AssetData{order.MakerAssetData, order.TakerAssetData} {
+		if !assetData.IsType(types.ERC20ProxyID) && !assetData.IsType(types.ERC721ProxyID) {
+			return false
+		}
+	}
+	return true
+}
+
+func abiWord(value []byte) []byte {
+	word := make([]byte, 32)
+	copy(word[32-len(value):], value)
+	return word
+}
+
+func abiInt(value int) []byte {
+	return common.BigToUint256(big.NewInt(int64(value)))[:]
+}
+
+func abiBytes(value []byte) []byte {
+	encoding := abiInt(len(value))
+	padded := make([]byte, ((len(value)+31)/32)*32)
+	copy(padded, value)
+	return append(encoding, padded...)
+}
+
+func encodeOrderTuple(order *types.Order) []byte {
+	encoding := []byte{}
+	encoding = append(encoding, abiWord(order.Maker[:])...)
+	encoding = append(encoding, abiWord(order.Taker[:])...)
+	encoding = append(encoding, abiWord(order.FeeRecipient[:])...)
+	encoding = append(encoding, abiWord(order.SenderAddress[:])...)
+	encoding = append(encoding, order.MakerAssetAmount[:]...)
+	encoding = append(encoding, order.TakerAssetAmount[:]...)
+	encoding = append(encoding, order.MakerFee[:]...)
+	encoding = append(encoding, order.TakerFee[:]...)
+	encoding = append(encoding, order.ExpirationTimestampInSec[:]...)
+	encoding = append(encoding, order.Salt[:]...)
+	makerAssetData := abiBytes(order.MakerAssetData)
+	takerAssetData := abiBytes(order.TakerAssetData)
+	// The static part of the tuple is 12 words, followed by the asset data
+	encoding = append(encoding, abiInt(12*32)...)
+	encoding = append(encoding, abiInt(12*32+len(makerAssetData))...)
+	encoding = append(encoding, makerAssetData...)
+	return append(encoding, takerAssetData...)
+}
+
+// encodeGetOrdersAndTradersInfo ABIv2 encodes a call to
+// getOrdersAndTradersInfo(LibOrder.Order[] orders, address[] takerAddresses)
+func encodeGetOrdersAndTradersInfo(orders []*types.Order) []byte {
+	heads := []byte{}
+	tails := []byte{}
+	for _, order := range orders {
+		heads = append(heads, abiInt(len(orders)*32+len(tails))...)
+		tails = append(tails, encodeOrderTuple(order)...)
+	}
+	orderArray := append(abiInt(len(orders)), append(heads, tails...)...)
+	takerArray := abiInt(len(orders))
+	for _, order := range orders {
+		takerArray = append(takerArray, abiWord(order.Taker[:])...)
+	}
+	encoding := append([]byte{}, getOrdersAndTradersInfoSelector...)
+	encoding = append(encoding, abiInt(64)...)
+	encoding = append(encoding, abiInt(64+len(orderArray))...)
+	encoding = append(encoding, orderArray...)
+	return append(encoding, takerArray...)
+}
+
+func readWord(data []byte, offset int) (*big.Int, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return nil, fmt.Errorf("Result too short: needed %v bytes, got %v", offset+32, len(data))
+	}
+	return new(big.Int).SetBytes(data[offset : offset+32]), nil
+}
+
+// readStaticTupleArray reads an array of tuples made up of `width` words
+// each, returning the words of each tuple.
+func readStaticTupleArray(data []byte, headOffset int, width int) ([][]*big.Int, error) {
+	arrayOffset, err := readWord(data, headOffset)
+	if err != nil {
+		return nil, err
+	}
+	if !arrayOffset.IsInt64() || arrayOffset.Int64() > int64(len(data)) {
+		return nil, fmt.Errorf("Invalid array offset %v", arrayOffset)
+	}
+	length, err := readWord(data, int(arrayOffset.Int64()))
+	if err != nil {
+		return nil, err
+	}
+	if !length.IsInt64() || length.Int64() > int64(len(data)/32) {
+		return nil, fmt.Errorf("Invalid array length %v", length)
+	}
+	result := make([][]*big.Int, int(length.Int64()))
+	position := int(arrayOffset.Int64()) + 32
+	for i := range result {
+		result[i] = make([]*big.Int, width)
+		for j := 0; j < width; j++ {
+			if result[i][j], err = readWord(data, position); err != nil {
+				return nil, err
+			}
+			position += 32
+		}
+	}
+	return result, nil
+}
+
+// decodeGetOrdersAndTradersInfo decodes the (OrderInfo[], TraderInfo[])
+// returned by getOrdersAndTradersInfo
+func decodeGetOrdersAndTradersInfo(data []byte, count int) ([]*OrderFundInfo, error) {
+	// OrderInfo is (uint8 orderStatus, bytes32 orderHash, uint256 orderTakerAssetFilledAmount)
+	orderInfos, err := readStaticTupleArray(data, 0, 3)
+	if err != nil {
+		return nil, err
+	}
+	// TraderInfo is (makerBalance, makerAllowance, takerBalance,
+	// takerAllowance, makerZrxBalance, makerZrxAllowance, takerZrxBalance,
+	// takerZrxAllowance). ZRX is the fee token for every v2 order.
+	traderInfos, err := readStaticTupleArray(data, 32, 8)
+	if err != nil {
+		return nil, err
+	}
+	if len(orderInfos) != count || len(traderInfos) != count {
+		return nil, fmt.Errorf("Expected info for %v orders, got %v and %v", count, len(orderInfos), len(traderInfos))
+	}
+	result := make([]*OrderFundInfo, count)
+	for i := range result {
+		result[i] = &OrderFundInfo{
+			OrderStatus:            uint8(orderInfos[i][0].Uint64()),
+			TakerAssetFilledAmount: orderInfos[i][2],
+			MakerBalance:           traderInfos[i][0],
+			MakerAllowance:         traderInfos[i][1],
+			MakerFeeBalance:        traderInfos[i][4],
+			MakerFeeAllowance:      traderInfos[i][5],
+		}
+	}
+	return result, nil
+}
+
+// GetOrdersInfo retrieves the status, filled amount, and maker balances and
+// allowances for a list of orders in a single eth_call. Orders the contract
+// can't check are refused rather than misreported.
+func (validator *contractOrderValidator) GetOrdersInfo(orders []*types.Order) ([]*OrderFundInfo, error) {
+	if len(orders) == 0 {
+		return []*OrderFundInfo{}, nil
+	}
+	for _, order := range orders {
+		if !validator.Checks(order) {
+			return nil, fmt.Errorf("OrderValidator contract can't check order %#x", order.Hash())
+		}
+	}
+	target := validator.address.ToGethAddress()
+	callMsg := ethereum.CallMsg{
+		To:   &target,
+		Data: encodeGetOrdersAndTradersInfo(orders),
+	}
+	result, err := validator.conn.CallContract(context.Background(), callMsg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGetOrdersAndTradersInfo(result, len(orders))
+}
+
+func (validator *contractOrderValidator) ValidateOrders(orders []*types.Order) ([]bool, error) {
+	infos, err := validator.GetOrdersInfo(orders)
+	if err != nil {
+		log.Printf("Error getting order info from %v: %v", validator.address, err.Error())
+		return nil, err
+	}
+	result := make([]bool, len(orders))
+	for i, info := range infos {
+		result[i] = info.Funded(orders[i])
+	}
+	return result, nil
+}
+
+func (validator *contractOrderValidator) ValidateOrder(order *types.Order) (bool, error) {
+	result, err := validator.ValidateOrders([]*types.Order{order})
+	if err != nil {
+		return false, err
+	}
+	return result[0], nil
+}
+
+func (validator *contractOrderValidator) FillableTakerAssetAmount(order *types.Order) (*big.Int, error) {
+	infos, err := validator.GetOrdersInfo([]*types.Order{order})
+	if err != nil {
+		return nil, err
+	}
+	return infos[0].FillableTakerAssetAmount(order), nil
+}
+
+// NewContractOrderValidator creates a BatchOrderValidator backed by a 0x
+// OrderValidator contract (or a compatible multicall contract) deployed at
+// address for the exchange at exchange.
+func NewContractOrderValidator(address, exchange *types.Address, conn bind.ContractCaller) BatchOrderValidator {
+	return &contractOrderValidator{address, exchange, conn}
+}
+
+func NewRpcContractOrderValidator(rpcURL string, address, exchange *types.Address) (BatchOrderValidator, error) {
+	conn, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.SyncProgress(context.Background()); err != nil {
+		// This is just here so that a contract validator can't be instantiated
+		// successfully if the RPC server isn't responding properly.
+		return nil, err
+	}
+	return NewContractOrderValidator(address, exchange, conn), nil
+}
+
+type validationRequest struct {
+	order  *types.Order
+	result chan validationResult
+}
+
+type validationResult struct {
+	info *OrderFundInfo
+	err  error
+}
+
+type batchingOrderValidator struct {
+	validator BatchOrderValidator
+	fallback  FillableOrderValidator
+	requests  chan *validationRequest
+	maxBatch  int
+	maxWait   time.Duration
+}
+
+func (validator *batchingOrderValidator) getOrderInfo(order *types.Order) (*OrderFundInfo, error) {
+	request := &validationRequest{order, make(chan validationResult, 1)}
+	validator.requests <- request
+	result := <-request.result
+	return result.info, result.err
+}
+
+func (validator *batchingOrderValidator) ValidateOrder(order *types.Order) (bool, error) {
+	if !validator.validator.Checks(order) {
+		return validator.fallback.ValidateOrder(order)
+	}
+	info, err := validator.getOrderInfo(order)
+	if err != nil {
+		return false, err
+	}
+	return info.Funded(order), nil
+}
+
+func (validator *batchingOrderValidator) FillableTakerAssetAmount(order *types.Order) (*big.Int, error) {
+	if !validator.validator.Checks(order) {
+		return validator.fallback.FillableTakerAssetAmount(order)
+	}
+	info, err := validator.getOrderInfo(order)
+	if err != nil {
+		return nil, err
+	}
+	return info.FillableTakerAssetAmount(order), nil
+}
+
+func (validator *batchingOrderValidator) flush(batch []*validationRequest) {
+	orders := make([]*types.Order, len(batch))
+	for i, request := range batch {
+		orders[i] = request.order
+	}
+	infos, err := validator.validator.GetOrdersInfo(orders)
+	for i, request := range batch {
+		if err != nil {
+			request.result <- validationResult{nil, err}
+		} else {
+			request.result <- validationResult{infos[i], nil}
+		}
+	}
+}
+
+func (validator *batchingOrderValidator) run() {
+	for request := range validator.requests {
+		batch := []*validationRequest{request}
+		timer := time.NewTimer(validator.maxWait)
+	collect:
+		for len(batch) < validator.maxBatch {
+			select {
+			case request := <-validator.requests:
+				batch = append(batch, request)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		go validator.flush(batch)
+	}
+}
+
+// NewBatchingOrderValidator groups concurrent ValidateOrder and
+// FillableTakerAssetAmount calls into batches of up to maxBatch orders,
+// waiting at most maxWait after the first order of a batch arrives before
+// sending the batch to the contract. Orders the contract can't check, such as
+// v3 orders, bundles, or orders for other exchanges, are checked one at a time
+// by fallback.
+func NewBatchingOrderValidator(validator BatchOrderValidator, fallback FillableOrderValidator, maxBatch int, maxWait time.Duration) FillableOrderValidator {
+	if maxBatch <= 0 {
+		maxBatch = 1
+	}
+	batching := &batchingOrderValidator{
+		validator,
+		fallback,
+		make(chan *validationRequest, maxBatch),
+		maxBatch,
+		maxWait,
+	}
+	go batching.run()
+	return batching
+}
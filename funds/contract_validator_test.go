@@ -0,0 +1,366 @@
+package funds_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+)
+
+// testValidatorCaller answers getOrdersAndTradersInfo calls with the same
+// order status and trader balances for every order in the batch, keeping the
+// data of the last call.
+type testValidatorCaller struct {
+	status  int64
+	balance *big.Int
+	err     error
+	calls   int
+	data    []byte
+	mutex   sync.Mutex
+}
+
+func (conn *testValidatorCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{}, conn.err
+}
+
+func word(value *big.Int) []byte {
+	return orCommon.BigToUint256(value)[:]
+}
+
+func (conn *testValidatorCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	conn.mutex.Lock()
+	conn.calls++
+	conn.data = call.Data
+	conn.mutex.Unlock()
+	if conn.err != nil {
+		return nil, conn.err
+	}
+	// The order count is the first word of the order array, which begins
+	// after the selector and the two offsets
+	count := new(big.Int).SetBytes(call.Data[4+64 : 4+96])
+	n := int(count.Int64())
+	result := word(big.NewInt(64))
+	result = append(result, word(big.NewInt(int64(64+32+n*96)))...)
+	result = append(result, word(count)...)
+	for i := 0; i < n; i++ {
+		result = append(result, word(big.NewInt(conn.status))...)
+		result = append(result, make([]byte, 32)...)
+		result = append(result, word(big.NewInt(0))...)
+	}
+	result = append(result, word(count)...)
+	for i := 0; i < n; i++ {
+		for j := 0; j < 8; j++ {
+			result = append(result, word(conn.balance)...)
+		}
+	}
+	return result, nil
+}
+
+func getContractValidatorTestOrder(t *testing.T) *types.Order {
+	order, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf("Error parsing order: %v", err.Error())
+	}
+	return order
+}
+
+func newTestContractValidator(conn *testValidatorCaller) funds.BatchOrderValidator {
+	address, _ := orCommon.HexToAddress("0x9463e518dea6810309563c81d5266c1b1d149138")
+	// The exchange the sample order was made for
+	exchange, _ := orCommon.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	return funds.NewContractOrderValidator(address, exchange, conn)
+}
+
+// unsupportedOrders returns variants of the sample order the OrderValidator
+// contract can't check
+func unsupportedOrders(t *testing.T) map[string]*types.Order {
+	orders := map[string]*types.Order{}
+	for name, amend := range map[string]func(order *types.Order){
+		"v3": func(order *types.Order) {
+			order.ProtocolVersion = types.ProtocolV3
+		},
+		"bundle": func(order *types.Order) {
+			order.MakerAssetData = append(types.AssetData{0x94, 0xcf, 0xcd, 0xd7}, order.MakerAssetData[4:]...)
+		},
+		"ERC1155": func(order *types.Order) {
+			order.TakerAssetData = append(types.AssetData{0xa7, 0xcb, 0x5f, 0xb7}, order.TakerAssetData[4:]...)
+		},
+		"RoboDex": func(order *types.Order) {
+			order.MakerAssetData = append(types.AssetData{0x0e, 0x20, 0x42, 0xd8}, order.MakerAssetData[4:]...)
+		},
+		"other exchange": func(order *types.Order) {
+			order.ExchangeAddress, _ = orCommon.HexToAddress("0x4f833a24e1f95d70f028921e27040ca56e09ab0b")
+		},
+	} {
+		order := getContractValidatorTestOrder(t)
+		amend(order)
+		orders[name] = order
+	}
+	return orders
+}
+
+func TestContractValidatorFunded(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	validator := newTestContractValidator(&testValidatorCaller{status: 3, balance: balance})
+	valid, err := validator.ValidateOrder(getContractValidatorTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !valid {
+		t.Errorf("Expected order to be funded")
+	}
+}
+
+func TestContractValidatorUnfunded(t *testing.T) {
+	validator := newTestContractValidator(&testValidatorCaller{status: 3, balance: big.NewInt(1)})
+	valid, err := validator.ValidateOrder(getContractValidatorTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if valid {
+		t.Errorf("Expected order to be unfunded")
+	}
+}
+
+func TestContractValidatorFillable(t *testing.T) {
+	// 30000000000000000000 is 60% of the makerAssetAmount for the sample order
+	balance, _ := new(big.Int).SetString("30000000000000000000", 10)
+	validator := newTestContractValidator(&testValidatorCaller{status: 3, balance: balance})
+	fillable, err := validator.FillableTakerAssetAmount(getContractValidatorTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if fillable.String() != "600000000000000000" {
+		t.Errorf("Expected 60%% of the taker asset amount to be fillable, got %v", fillable)
+	}
+}
+
+func TestContractValidatorCancelled(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	validator := newTestContractValidator(&testValidatorCaller{status: 6, balance: balance})
+	valid, err := validator.ValidateOrder(getContractValidatorTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if valid {
+		t.Errorf("Expected cancelled order to be invalid")
+	}
+}
+
+func TestContractValidatorBatch(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	conn := &testValidatorCaller{status: 3, balance: balance}
+	validator := newTestContractValidator(conn)
+	order := getContractValidatorTestOrder(t)
+	results, err := validator.ValidateOrders([]*types.Order{order, order, order})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %v", len(results))
+	}
+	for i, valid := range results {
+		if !valid {
+			t.Errorf("Expected order %v to be funded", i)
+		}
+	}
+	if conn.calls != 1 {
+		t.Errorf("Expected a single contract call, got %v", conn.calls)
+	}
+}
+
+func TestContractValidatorError(t *testing.T) {
+	validator := newTestContractValidator(&testValidatorCaller{err: errors.New("connection refused")})
+	if _, err := validator.ValidateOrder(getContractValidatorTestOrder(t)); err == nil {
+		t.Errorf("Expected error to be returned")
+	}
+}
+
+func TestBatchingOrderValidator(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	conn := &testValidatorCaller{status: 3, balance: balance}
+	validator := funds.NewBatchingOrderValidator(newTestContractValidator(conn), &fallbackValidator{}, 5, 100*time.Millisecond)
+	order := getContractValidatorTestOrder(t)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			valid, err := validator.ValidateOrder(order)
+			if err != nil {
+				t.Errorf(err.Error())
+			} else if !valid {
+				t.Errorf("Expected order to be funded")
+			}
+		}()
+	}
+	wg.Wait()
+	if conn.calls != 1 {
+		t.Errorf("Expected orders to be validated in a single call, got %v", conn.calls)
+	}
+}
+
+func TestContractValidatorRefusesV3(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	conn := &testValidatorCaller{status: 3, balance: balance}
+	order := getContractValidatorTestOrder(t)
+	order.ProtocolVersion = types.ProtocolV3
+	if _, err := newTestContractValidator(conn).ValidateOrder(order); err == nil {
+		t.Errorf("Expected v3 order to be refused")
+	}
+	if conn.calls != 0 {
+		t.Errorf("Expected no contract calls, got %v", conn.calls)
+	}
+}
+
+func TestContractValidatorRefusesUnsupported(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	conn := &testValidatorCaller{status: 3, balance: balance}
+	validator := newTestContractValidator(conn)
+	if !validator.Checks(getContractValidatorTestOrder(t)) {
+		t.Errorf("Expected the sample order to be checked")
+	}
+	for name, order := range unsupportedOrders(t) {
+		if validator.Checks(order) {
+			t.Errorf("%v: expected the order not to be checked", name)
+		}
+		if _, err := validator.ValidateOrder(order); err == nil {
+			t.Errorf("%v: expected the order to be refused", name)
+		}
+	}
+	if conn.calls != 0 {
+		t.Errorf("Expected no contract calls, got %v", conn.calls)
+	}
+}
+
+// getOrdersAndTradersInfoGolden is the call for the sample order, and a copy
+// taken by 0x5409ed021d9299bf6814279a6a1411a7e866a631 for ERC721 token 7 of
+// 0x1d7022f5b17d2f8b695918fb48fa1089c9f85401 with a 1 ZRX maker fee, as
+// packed by go-ethereum's abi.Pack with the contract's ABI
+const getOrdersAndTradersInfoGolden = "4b95de13" +
+	"0000000000000000000000000000000000000000000000000000000000000040" +
+	"0000000000000000000000000000000000000000000000000000000000000540" +
+	// orders
+	"0000000000000000000000000000000000000000000000000000000000000002" +
+	"0000000000000000000000000000000000000000000000000000000000000040" +
+	"0000000000000000000000000000000000000000000000000000000000000280" +
+	// orders[0]
+	"000000000000000000000000627306090abab3a6e1400e9345bc60c78a8bef57" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"000000000000000000000000000000000000000000000002b5e3af16b1880000" +
+	"0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000159938ac4" +
+	"000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222b" +
+	"0000000000000000000000000000000000000000000000000000000000000180" +
+	"00000000000000000000000000000000000000000000000000000000000001e0" +
+	"0000000000000000000000000000000000000000000000000000000000000024" +
+	"f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6" +
+	"119a04ba00000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000000000024" +
+	"f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff13" +
+	"0df22e9c00000000000000000000000000000000000000000000000000000000" +
+	// orders[1]
+	"000000000000000000000000627306090abab3a6e1400e9345bc60c78a8bef57" +
+	"0000000000000000000000005409ed021d9299bf6814279a6a1411a7e866a631" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"000000000000000000000000000000000000000000000002b5e3af16b1880000" +
+	"0000000000000000000000000000000000000000000000000000000000000001" +
+	"0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000159938ac4" +
+	"000643508ff7019bfb134363a86e98746f6c33262e68daf992b8df064217222b" +
+	"0000000000000000000000000000000000000000000000000000000000000180" +
+	"00000000000000000000000000000000000000000000000000000000000001e0" +
+	"0000000000000000000000000000000000000000000000000000000000000024" +
+	"f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6" +
+	"119a04ba00000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000000000000000000000000000000000000000000044" +
+	"025717920000000000000000000000001d7022f5b17d2f8b695918fb48fa1089" +
+	"c9f8540100000000000000000000000000000000000000000000000000000000" +
+	"0000000700000000000000000000000000000000000000000000000000000000" +
+	// takerAddresses
+	"0000000000000000000000000000000000000000000000000000000000000002" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"0000000000000000000000005409ed021d9299bf6814279a6a1411a7e866a631"
+
+func TestContractValidatorEncoding(t *testing.T) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	conn := &testValidatorCaller{status: 3, balance: balance}
+	first := getContractValidatorTestOrder(t)
+	second := getContractValidatorTestOrder(t)
+	second.Taker, _ = orCommon.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631")
+	second.TakerAssetAmount = orCommon.BigToUint256(big.NewInt(1))
+	second.MakerFee = orCommon.BigToUint256(balance.Div(balance, big.NewInt(100)))
+	token, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	second.TakerAssetData = orCommon.ToERC721AssetData(token, orCommon.BigToUint256(big.NewInt(7)))
+	if _, err := newTestContractValidator(conn).ValidateOrders([]*types.Order{first, second}); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if encoded := hex.EncodeToString(conn.data); encoded != getOrdersAndTradersInfoGolden {
+		t.Errorf("Unexpected encoding:\n%v\nexpected:\n%v", encoded, getOrdersAndTradersInfoGolden)
+	}
+}
+
+// fallbackValidator reports every order funded, counting the orders it's
+// asked about
+type fallbackValidator struct {
+	calls int
+}
+
+func (validator *fallbackValidator) ValidateOrder(order *types.Order) (bool, error) {
+	validator.calls++
+	return true, nil
+}
+
+func (validator *fallbackValidator) FillableTakerAssetAmount(order *types.Order) (*big.Int, error) {
+	validator.calls++
+	return order.TakerAssetAmount.Big(), nil
+}
+
+func TestBatchingOrderValidatorV3(t *testing.T) {
+	conn := &testValidatorCaller{status: 3, balance: big.NewInt(0)}
+	fallback := &fallbackValidator{}
+	validator := funds.NewBatchingOrderValidator(newTestContractValidator(conn), fallback, 5, 100*time.Millisecond)
+	order := getContractValidatorTestOrder(t)
+	order.ProtocolVersion = types.ProtocolV3
+	fillable, err := validator.FillableTakerAssetAmount(order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if fillable.Cmp(order.TakerAssetAmount.Big()) != 0 {
+		t.Errorf("Expected the fallback's fillable amount, got %v", fillable)
+	}
+	if fallback.calls != 1 || conn.calls != 0 {
+		t.Errorf("Expected v3 order checked by the fallback, got %v fallback and %v contract calls", fallback.calls, conn.calls)
+	}
+}
+
+func TestBatchingOrderValidatorUnsupported(t *testing.T) {
+	for name, order := range unsupportedOrders(t) {
+		// The contract would report no balance for these orders
+		conn := &testValidatorCaller{status: 3, balance: big.NewInt(0)}
+		fallback := &fallbackValidator{}
+		validator := funds.NewBatchingOrderValidator(newTestContractValidator(conn), fallback, 5, 100*time.Millisecond)
+		valid, err := validator.ValidateOrder(order)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err.Error())
+		}
+		if !valid || fallback.calls != 1 || conn.calls != 0 {
+			t.Errorf("%v: expected the order checked by the fallback, got %v fallback and %v contract calls", name, fallback.calls, conn.calls)
+		}
+	}
+}
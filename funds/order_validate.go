@@ -1,6 +1,7 @@
 package funds
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -16,12 +17,30 @@ type OrderValidator interface {
 	ValidateOrder(order *types.Order) (bool, error)
 }
 
+// FillableOrderValidator reports how much of an order's remaining taker asset
+// amount the maker's balances and allowances can cover, rather than only
+// whether they cover all of it.
+type FillableOrderValidator interface {
+	OrderValidator
+	FillableTakerAssetAmount(order *types.Order) (*big.Int, error)
+}
+
 type orderValidator struct {
 	balanceChecker balance.BalanceChecker
 	feeToken       config.FeeToken
 	tokenProxy     config.TokenProxy
 }
 
+// feeProxy looks up the proxy the maker's fee is paid through. v2 fees are
+// always paid in ZRX through the ERC20 proxy, while v3 fees may be any asset.
+func (funds *orderValidator) feeProxy(order *types.Order, feeToken types.AssetData) (*types.Address, error) {
+	proxyID := types.ERC20ProxyID
+	if order.IsV3() && len(feeToken) >= 4 {
+		proxyID = feeToken.ProxyId()
+	}
+	return funds.tokenProxy.GetById(order, proxyID)
+}
+
 type boolOrErr struct {
 	success bool
 	err     error
@@ -61,6 +80,32 @@ func (funds *orderValidator) checkAllowance(assetData types.AssetData, userAddre
 	respond <- boolOrErr{(requiredInt.Cmp(balance) <= 0), nil}
 }
 
+type bigOrErr struct {
+	value *big.Int
+	err   error
+}
+
+// checkAvailable looks up the lesser of the user's balance and allowance
+func (funds *orderValidator) checkAvailable(assetData types.AssetData, userAddress, proxyAddress *types.Address, respond chan bigOrErr) {
+	balance, err := funds.balanceChecker.GetBalance(assetData, userAddress)
+	if err != nil {
+		log.Printf("'%v': '%v', '%v'", err.Error(), hex.EncodeToString(assetData[:]), hex.EncodeToString(userAddress[:]))
+		respond <- bigOrErr{nil, err}
+		return
+	}
+	allowance, err := funds.balanceChecker.GetAllowance(assetData, userAddress, proxyAddress)
+	if err != nil {
+		log.Printf("'%v': '%v', '%v'", err.Error(), hex.EncodeToString(assetData[:]), hex.EncodeToString(userAddress[:]))
+		respond <- bigOrErr{nil, err}
+		return
+	}
+	if allowance.Cmp(balance) < 0 {
+		respond <- bigOrErr{allowance, nil}
+	} else {
+		respond <- bigOrErr{balance, nil}
+	}
+}
+
 func getRemainingAmount(numerator, denominator, target []byte) []byte {
 	numInt := new(big.Int)
 	denomInt := new(big.Int)
@@ -89,7 +134,7 @@ func (funds *orderValidator) ValidateOrder(order *types.Order) (bool, error) {
 		log.Printf("Error getting token proxy address '%v'", err.Error())
 		return false, err
 	}
-	feeProxyAddress, err := funds.tokenProxy.GetById(order, types.ERC20ProxyID)
+	feeProxyAddress, err := funds.feeProxy(order, feeToken)
 	if err != nil {
 		log.Printf("Error getting fee token proxy address '%v'", err.Error())
 		return false, err
@@ -169,9 +214,77 @@ func (funds *orderValidator) ValidateOrder(order *types.Order) (bool, error) {
 	return result, nil
 }
 
-func NewRpcOrderValidator(rpcUrl string, feeToken config.FeeToken, tokenProxy config.TokenProxy, invalidationChannel channels.ConsumerChannel) (OrderValidator, error) {
-	if checker, err := balance.NewRpcRoutingBalanceChecker(rpcUrl); err == nil {
-		if invalidationChannel != nil {
+// awaitAvailable waits for a checkAvailable lookup, returning nil if no lookup
+// was started. Like ValidateOrder, it panics if the RPC server fails.
+func awaitAvailable(respond chan bigOrErr) (*big.Int, error) {
+	if respond == nil {
+		return nil, nil
+	}
+	chanResult := <-respond
+	if chanResult.err != nil {
+		if chanResult.err.Error() == "no contract code at given address" {
+			return nil, chanResult.err
+		}
+		panic(fmt.Sprintf("RPC Communication Failed: '%v'", chanResult.err.Error()))
+	}
+	return chanResult.value, nil
+}
+
+// FillableTakerAssetAmount returns how much of the order's remaining taker
+// asset amount the maker's balance and allowance of the maker asset and fee
+// token can cover.
+func (funds *orderValidator) FillableTakerAssetAmount(order *types.Order) (*big.Int, error) {
+	feeToken, err := funds.feeToken.Get(order)
+	if err != nil {
+		log.Printf("Error getting fee token '%v'", err.Error())
+		return nil, err
+	}
+	makerProxyAddress, err := funds.tokenProxy.Get(order)
+	if err != nil {
+		log.Printf("Error getting token proxy address '%v'", err.Error())
+		return nil, err
+	}
+	feeProxyAddress, err := funds.feeProxy(order, feeToken)
+	if err != nil {
+		log.Printf("Error getting fee token proxy address '%v'", err.Error())
+		return nil, err
+	}
+	makerAssetIsFee := bytes.Equal(order.MakerAssetData, feeToken)
+	// Buffered so the lookups don't block if we return early on an error
+	var makerChan, feeChan chan bigOrErr
+	if order.MakerAssetAmount.Big().Sign() != 0 || (makerAssetIsFee && order.MakerFee.Big().Sign() != 0) {
+		makerChan = make(chan bigOrErr, 1)
+		go funds.checkAvailable(order.MakerAssetData, order.Maker, makerProxyAddress, makerChan)
+	}
+	if !makerAssetIsFee && order.MakerFee.Big().Sign() != 0 {
+		feeChan = make(chan bigOrErr, 1)
+		go funds.checkAvailable(feeToken, order.Maker, feeProxyAddress, feeChan)
+	}
+	makerAvailable, err := awaitAvailable(makerChan)
+	if err != nil {
+		return nil, err
+	}
+	feeAvailable, err := awaitAvailable(feeChan)
+	if err != nil {
+		return nil, err
+	}
+	return order.FillableTakerAssetAmount(makerAvailable, feeAvailable, makerAssetIsFee), nil
+}
+
+func NewRpcOrderValidator(rpcUrl string, feeToken config.FeeToken, tokenProxy config.TokenProxy, invalidationChannel channels.ConsumerChannel) (FillableOrderValidator, error) {
+	invalidationChannels := []channels.ConsumerChannel{}
+	if invalidationChannel != nil {
+		invalidationChannels = append(invalidationChannels, invalidationChannel)
+	}
+	return NewRpcCachedOrderValidator(rpcUrl, feeToken, tokenProxy, invalidationChannels, balance.NewLRUBalanceCache(balance.DefaultCacheSize, balance.DefaultCacheTTL))
+}
+
+// NewRpcCachedOrderValidator creates an OrderValidator that caches balance
+// lookups in cache, which may be shared between processes. Entries are
+// invalidated by messages on any of the invalidationChannels.
+func NewRpcCachedOrderValidator(rpcUrl string, feeToken config.FeeToken, tokenProxy config.TokenProxy, invalidationChannels []channels.ConsumerChannel, cache balance.BalanceCache) (FillableOrderValidator, error) {
+	if checker, err := balance.NewRpcCachedRoutingBalanceChecker(rpcUrl, cache); err == nil {
+		for _, invalidationChannel := range invalidationChannels {
 			invalidationChannel.AddConsumer(checker)
 			invalidationChannel.StartConsuming()
 		}
@@ -180,6 +293,6 @@ func NewRpcOrderValidator(rpcUrl string, feeToken config.FeeToken, tokenProxy co
 		return nil, err
 	}
 }
-func NewOrderValidator(checker balance.BalanceChecker, feeToken config.FeeToken, tokenProxy config.TokenProxy) OrderValidator {
+func NewOrderValidator(checker balance.BalanceChecker, feeToken config.FeeToken, tokenProxy config.TokenProxy) FillableOrderValidator {
 	return &orderValidator{checker, feeToken, tokenProxy}
 }
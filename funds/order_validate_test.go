@@ -158,3 +158,25 @@ func TestErrorNoContract(t *testing.T) {
 
 	validator.ValidateOrder(newOrder)
 }
+
+func TestOrderFillablePartial(t *testing.T) {
+	// 30000000000000000000 is 60% of the makerAssetAmount for the sample order
+	balanceChecker := createMockBalanceChecker("f47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba", "627306090abab3a6e1400e9345bc60c78a8bef57", "30000000000000000000", "0", t)
+	feeTokenAsset, _ := hexToAssetData("f47261b0000000000000000000000000e41d2489571d322189246dafa5ebde1f4699f498")
+	tokenProxyAddress, _ := hexToAddress("d4fd252d7d2c9479a8d616f510eac6243b5dddf9")
+	validator := funds.NewOrderValidator(balanceChecker, config.StaticFeeToken(feeTokenAsset), config.StaticTokenProxy(tokenProxyAddress))
+	newOrder, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Errorf("Error parsing order: %v", err.Error())
+	}
+	if result, _ := validator.ValidateOrder(newOrder); result {
+		t.Errorf("Expected insufficient funds")
+	}
+	fillable, err := validator.FillableTakerAssetAmount(newOrder)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if fillable.String() != "600000000000000000" {
+		t.Errorf("Expected 60%% of the taker asset amount to be fillable, got %v", fillable)
+	}
+}
@@ -0,0 +1,269 @@
+package funds
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/common"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+)
+
+// DriftSummary counts the open orders whose recorded state no longer matched
+// the chain during a reconciliation pass.
+type DriftSummary struct {
+	Checked   int `json:"checked"`
+	Filled    int `json:"filled"`
+	Cancelled int `json:"cancelled"`
+	Unfunded  int `json:"unfunded"`
+	// Fillable counts open orders whose fillable amount changed
+	Fillable int `json:"fillable"`
+	Updated  int `json:"updated"`
+	Errors   int `json:"errors"`
+}
+
+func (summary *DriftSummary) String() string {
+	return fmt.Sprintf(
+		"checked=%v filled=%v cancelled=%v unfunded=%v fillable=%v updated=%v errors=%v",
+		summary.Checked,
+		summary.Filled,
+		summary.Cancelled,
+		summary.Unfunded,
+		summary.Fillable,
+		summary.Updated,
+		summary.Errors,
+	)
+}
+
+// Reconciler walks the open orders in the database and re-checks their
+// filled, cancelled and funded state against the chain. It exists to catch
+// orders whose events were missed by the monitors (dropped blocks, downtime,
+// bloom filter false negatives).
+type Reconciler struct {
+	db                 *gorm.DB
+	filledLookup       FilledLookup
+	cancellationLookup CancellationLookup
+	orderValidator     OrderValidator
+	pageSize           int
+	orderInterval      time.Duration
+	statusPublisher    channels.Publisher
+}
+
+// SetStatusPublisher makes the reconciler publish a db.StatusChange for each
+// order it updates.
+func (reconciler *Reconciler) SetStatusPublisher(publisher channels.Publisher) {
+	reconciler.statusPublisher = publisher
+}
+
+// Check looks up the current on-chain state of an order and returns the
+// status the order should have. order.TakerAssetAmountFilled and
+// order.Cancelled are updated in place, as is
+// order.RemainingFillableTakerAssetAmount if the OrderValidator is a
+// FillableOrderValidator.
+func (reconciler *Reconciler) Check(order *dbModule.Order) (status int64, err error) {
+	defer func() {
+		// OrderValidator panics on RPC failures, which is the right thing for
+		// the fund check relay but would kill a long running reconciliation.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	amountFilled, err := reconciler.filledLookup.GetAmountFilled(&order.Order)
+	if err != nil {
+		return order.Status, err
+	}
+	if amountFilled.Big().Cmp(order.TakerAssetAmountFilled.Big()) > 0 {
+		order.TakerAssetAmountFilled = amountFilled
+	}
+	cancelled, err := reconciler.filledLookup.GetCancelled(&order.Order)
+	if err != nil {
+		return order.Status, err
+	}
+	if !cancelled {
+		cancelled, err = reconciler.cancellationLookup.GetCancelled(&order.Order)
+		if err != nil {
+			return order.Status, err
+		}
+	}
+	order.Cancelled = order.Cancelled || cancelled
+	order.Status = dbModule.StatusOpen
+	order.Populate()
+	if order.Status != dbModule.StatusOpen {
+		return order.Status, nil
+	}
+	if fillableValidator, ok := reconciler.orderValidator.(FillableOrderValidator); ok {
+		fillable, err := fillableValidator.FillableTakerAssetAmount(&order.Order)
+		if err != nil {
+			return order.Status, err
+		}
+		order.RemainingFillableTakerAssetAmount = common.BigToUint256(fillable)
+		if fillable.Sign() == 0 {
+			return dbModule.StatusUnfunded, nil
+		}
+		return dbModule.StatusOpen, nil
+	}
+	funded, err := reconciler.orderValidator.ValidateOrder(&order.Order)
+	if err != nil {
+		return order.Status, err
+	}
+	if !funded {
+		return dbModule.StatusUnfunded, nil
+	}
+	return dbModule.StatusOpen, nil
+}
+
+func (reconciler *Reconciler) reconcile(order *dbModule.Order, summary *DriftSummary) {
+	summary.Checked++
+	previousFillable := ""
+	if order.RemainingFillableTakerAssetAmount != nil {
+		previousFillable = order.RemainingFillableTakerAssetAmount.String()
+	}
+	previousFilled := order.TakerAssetAmountFilled.String()
+	status, err := reconciler.Check(order)
+	if err != nil {
+		log.Printf("Error reconciling order %#x: %v", order.OrderHash, err.Error())
+		summary.Errors++
+		return
+	}
+	switch status {
+	case dbModule.StatusFilled:
+		summary.Filled++
+	case dbModule.StatusCancelled:
+		summary.Cancelled++
+	case dbModule.StatusUnfunded:
+		summary.Unfunded++
+	case dbModule.StatusOpen:
+		fillableChanged := order.RemainingFillableTakerAssetAmount != nil && order.RemainingFillableTakerAssetAmount.String() != previousFillable
+		// A partial fill the monitors missed leaves the order open, but its
+		// filled amount still needs recording
+		if !fillableChanged && order.TakerAssetAmountFilled.String() == previousFilled {
+			return
+		}
+		if fillableChanged {
+			summary.Fillable++
+		}
+	}
+	log.Printf("Order %#x drifted to status %v", order.OrderHash, status)
+	if err := order.Save(reconciler.db, status).Error; err != nil {
+		log.Printf("Error updating order %#x: %v", order.OrderHash, err.Error())
+		summary.Errors++
+		return
+	}
+	dbModule.PublishStatusChanges(reconciler.statusPublisher, [][]byte{order.OrderHash}, order.Status)
+	summary.Updated++
+}
+
+// RunOnce makes a single pass over the open orders, updating any that have
+// drifted from the on-chain state. Orders are paged by hash rather than offset
+// so that orders leaving the open state don't shift the pages.
+func (reconciler *Reconciler) RunOnce(stop <-chan struct{}) *DriftSummary {
+	summary := &DriftSummary{}
+	ticker := time.NewTicker(reconciler.orderInterval)
+	defer ticker.Stop()
+	lastHash := []byte{}
+	for {
+		orders := []dbModule.Order{}
+		query := reconciler.db.Model(&dbModule.Order{}).
+			Where("status = ? AND order_hash > ?", dbModule.StatusOpen, lastHash).
+			Order("order_hash").
+			Limit(reconciler.pageSize).
+			Find(&orders)
+		if query.Error != nil {
+			log.Printf("Error reading open orders: %v", query.Error.Error())
+			summary.Errors++
+			return summary
+		}
+		for i := range orders {
+			select {
+			case <-stop:
+				return summary
+			case <-ticker.C:
+			}
+			reconciler.reconcile(&orders[i], summary)
+		}
+		if len(orders) < reconciler.pageSize {
+			return summary
+		}
+		lastHash = orders[len(orders)-1].OrderHash
+	}
+}
+
+// Run reconciles the order book repeatedly, waiting passInterval between
+// passes, until stop is closed.
+func (reconciler *Reconciler) Run(passInterval time.Duration, stop <-chan struct{}) {
+	for {
+		summary := reconciler.RunOnce(stop)
+		log.Printf("Reconciliation pass complete: %v", summary)
+		select {
+		case <-stop:
+			return
+		case <-time.After(passInterval):
+		}
+	}
+}
+
+// NewReconciler creates a Reconciler that reads pageSize orders at a time and
+// checks at most ordersPerSecond orders per second, to avoid overloading the
+// RPC node.
+func NewReconciler(db *gorm.DB, filledLookup FilledLookup, cancellationLookup CancellationLookup, orderValidator OrderValidator, pageSize int, ordersPerSecond float64) *Reconciler {
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	if ordersPerSecond <= 0 {
+		ordersPerSecond = 1
+	}
+	return &Reconciler{
+		db,
+		filledLookup,
+		cancellationLookup,
+		orderValidator,
+		pageSize,
+		time.Duration(float64(time.Second) / ordersPerSecond),
+		nil,
+	}
+}
+
+// MockOrderValidator returns a fixed result for every order.
+type MockOrderValidator struct {
+	valid bool
+	err   error
+}
+
+func (validator *MockOrderValidator) ValidateOrder(order *types.Order) (bool, error) {
+	return validator.valid, validator.err
+}
+
+func NewMockOrderValidator(valid bool, err error) OrderValidator {
+	return &MockOrderValidator{valid, err}
+}
+
+// MockFillableOrderValidator reports a fixed fillable amount for every order,
+// capped at the order's remaining amount.
+type MockFillableOrderValidator struct {
+	fillable *big.Int
+	err      error
+}
+
+func (validator *MockFillableOrderValidator) FillableTakerAssetAmount(order *types.Order) (*big.Int, error) {
+	if validator.err != nil {
+		return nil, validator.err
+	}
+	remaining := order.FillableTakerAssetAmount(nil, nil, false)
+	return new(big.Int).Set(minInt(remaining, validator.fillable)), nil
+}
+
+func (validator *MockFillableOrderValidator) ValidateOrder(order *types.Order) (bool, error) {
+	fillable, err := validator.FillableTakerAssetAmount(order)
+	if err != nil {
+		return false, err
+	}
+	return fillable.Cmp(order.FillableTakerAssetAmount(nil, nil, false)) == 0, nil
+}
+
+func NewMockFillableOrderValidator(fillable *big.Int, err error) FillableOrderValidator {
+	return &MockFillableOrderValidator{fillable, err}
+}
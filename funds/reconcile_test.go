@@ -0,0 +1,210 @@
+package funds_test
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+)
+
+func getReconcileTestOrder(t *testing.T) *dbModule.Order {
+	order, err := types.OrderFromBytes(getTestOrderBytes())
+	if err != nil {
+		t.Fatalf("Error parsing order: %v", err.Error())
+	}
+	dbOrder := &dbModule.Order{}
+	dbOrder.Order = *order
+	dbOrder.Populate()
+	return dbOrder
+}
+
+func TestReconcileUnchanged(t *testing.T) {
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "0", nil),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockOrderValidator(true, nil),
+		10,
+		1,
+	)
+	status, err := reconciler.Check(getReconcileTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status != dbModule.StatusOpen {
+		t.Errorf("Expected open status, got %v", status)
+	}
+}
+
+func TestReconcileFilled(t *testing.T) {
+	// The sample order has a takerAssetAmount of 1000000000000000000
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "1000000000000000000", nil),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockOrderValidator(true, nil),
+		10,
+		1,
+	)
+	order := getReconcileTestOrder(t)
+	status, err := reconciler.Check(order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status != dbModule.StatusFilled {
+		t.Errorf("Expected filled status, got %v", status)
+	}
+	if order.TakerAssetAmountFilled.String() != "1000000000000000000" {
+		t.Errorf("Expected filled amount to be updated, got %v", order.TakerAssetAmountFilled.String())
+	}
+}
+
+func TestReconcileCancelUpTo(t *testing.T) {
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "0", nil),
+		funds.NewMockCancellationLookup(true),
+		funds.NewMockOrderValidator(true, nil),
+		10,
+		1,
+	)
+	status, err := reconciler.Check(getReconcileTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status != dbModule.StatusCancelled {
+		t.Errorf("Expected cancelled status, got %v", status)
+	}
+}
+
+func TestReconcileUnfunded(t *testing.T) {
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "0", nil),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockOrderValidator(false, nil),
+		10,
+		1,
+	)
+	status, err := reconciler.Check(getReconcileTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status != dbModule.StatusUnfunded {
+		t.Errorf("Expected unfunded status, got %v", status)
+	}
+}
+
+func TestReconcileLookupError(t *testing.T) {
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "0", errors.New("connection refused")),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockOrderValidator(true, nil),
+		10,
+		1,
+	)
+	if _, err := reconciler.Check(getReconcileTestOrder(t)); err == nil {
+		t.Errorf("Expected lookup error to be returned")
+	}
+}
+
+func TestReconcilePartiallyFunded(t *testing.T) {
+	// The sample order has a takerAssetAmount of 1000000000000000000
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "0", nil),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockFillableOrderValidator(big.NewInt(600000000000000000), nil),
+		10,
+		1,
+	)
+	order := getReconcileTestOrder(t)
+	status, err := reconciler.Check(order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status != dbModule.StatusOpen {
+		t.Errorf("Expected partially funded order to stay open, got %v", status)
+	}
+	if order.RemainingFillableTakerAssetAmount.String() != "600000000000000000" {
+		t.Errorf("Expected fillable amount to be updated, got %v", order.RemainingFillableTakerAssetAmount.String())
+	}
+}
+
+func TestReconcileFillableUnfunded(t *testing.T) {
+	reconciler := funds.NewReconciler(
+		nil,
+		funds.NewMockFilledLookup(false, "0", nil),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockFillableOrderValidator(big.NewInt(0), nil),
+		10,
+		1,
+	)
+	status, err := reconciler.Check(getReconcileTestOrder(t))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if status != dbModule.StatusUnfunded {
+		t.Errorf("Expected unfunded status, got %v", status)
+	}
+}
+
+func getReconcileDb(t *testing.T) *gorm.DB {
+	connectionString := fmt.Sprintf(
+		"postgres://%v@%v",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_HOST"),
+	)
+	db, err := dbModule.GetDB(connectionString, os.Getenv("POSTGRES_PASSWORD"))
+	if err != nil {
+		t.Fatalf("Could not get db: %v", err.Error())
+	}
+	return db
+}
+
+func TestReconcileSavesPartialFill(t *testing.T) {
+	db := getReconcileDb(t)
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	order := getReconcileTestOrder(t)
+	if err := order.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	// The order stays open and funded, so only the filled amount drifts
+	reconciler := funds.NewReconciler(
+		tx,
+		funds.NewMockFilledLookup(false, "400000000000000000", nil),
+		funds.NewMockCancellationLookup(false),
+		funds.NewMockOrderValidator(true, nil),
+		10,
+		1000,
+	)
+	summary := reconciler.RunOnce(make(chan struct{}))
+	if summary.Updated != 1 {
+		t.Errorf("Expected the order to be updated, got %v", summary)
+	}
+	saved := &dbModule.Order{}
+	if err := tx.Model(&dbModule.Order{}).Where("order_hash = ?", order.OrderHash).First(saved).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	if saved.Status != dbModule.StatusOpen {
+		t.Errorf("Expected order to stay open, got %v", saved.Status)
+	}
+	if saved.TakerAssetAmountFilled.String() != "400000000000000000" {
+		t.Errorf("Expected filled amount to be saved, got %v", saved.TakerAssetAmountFilled.String())
+	}
+}
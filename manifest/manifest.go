@@ -0,0 +1,197 @@
+package manifest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+
+	"github.com/notegio/openrelay/types"
+)
+
+// HexBytes is a byte slice written in manifests as a 0x prefixed hex string
+type HexBytes []byte
+
+func (data HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%#x", []byte(data)))
+}
+
+func (data *HexBytes) UnmarshalJSON(jsonData []byte) error {
+	hexString := ""
+	if err := json.Unmarshal(jsonData, &hexString); err != nil {
+		return err
+	}
+	value, err := hex.DecodeString(strings.TrimPrefix(hexString, "0x"))
+	if err != nil {
+		return err
+	}
+	*data = value
+	return nil
+}
+
+// Exchange describes an exchange contract. ProtocolVersion defaults to 2.
+type Exchange struct {
+	Address         *types.Address `json:"address"`
+	NetworkID       uint64         `json:"networkId"`
+	ProtocolVersion uint8          `json:"protocolVersion"`
+	ChainID         uint64         `json:"chainId"`
+	DomainName      string         `json:"domainName"`
+	DomainVersion   string         `json:"domainVersion"`
+	DomainSalt      HexBytes       `json:"domainSalt"`
+}
+
+// AssetProxy describes an asset proxy, identified by its 4 byte ID
+type AssetProxy struct {
+	ID              HexBytes       `json:"id"`
+	Name            string         `json:"name"`
+	Address         *types.Address `json:"address"`
+	ExchangeAddress *types.Address `json:"exchangeAddress"`
+	ZeroEx          bool           `json:"zeroEx"`
+}
+
+// Asset describes an asset, identified by its symbol
+type Asset struct {
+	Symbol         string         `json:"symbol"`
+	Name           string         `json:"name"`
+	Address        *types.Address `json:"address"`
+	Decimals       uint16         `json:"decimals"`
+	ProxyID        HexBytes       `json:"proxyId"`
+	AssetData      HexBytes       `json:"assetData"`
+	Precision      uint16         `json:"precision"`
+	MinTradeAmount *types.Uint256 `json:"minTradeAmount"`
+	MaxTradeAmount *types.Uint256 `json:"maxTradeAmount"`
+	ZeroEx         bool           `json:"zeroEx"`
+	Active         bool           `json:"active"`
+	Quote          bool           `json:"quote"`
+}
+
+// AssetPair describes a pair of assets, identified by their symbols
+type AssetPair struct {
+	AssetSymbolA string `json:"assetSymbolA"`
+	AssetSymbolB string `json:"assetSymbolB"`
+	Active       bool   `json:"active"`
+}
+
+// Pool describes an order pool. Pools are identified by the keccak256 hash of
+// their name, where the default pool's name is "".
+type Pool struct {
+	Name                 string                  `json:"name"`
+	SearchTerms          string                  `json:"searchTerms"`
+	Expiration           uint64                  `json:"expiration"`
+	Nonce                uint                    `json:"nonce"`
+	FeeShare             string                  `json:"feeShare"`
+	Limit                uint                    `json:"limit"`
+	SenderAddresses      types.NetworkAddressMap `json:"senderAddresses"`
+	FilterAddresses      types.NetworkAddressMap `json:"filterAddresses"`
+	CommitmentPolicy     string                  `json:"commitmentPolicy"`
+	EnforceAssetRegistry bool                    `json:"enforceAssetRegistry"`
+}
+
+// ID returns the pool's ID, the hash of its name
+func (pool *Pool) ID() []byte {
+	poolHash := sha3.NewKeccak256()
+	poolHash.Write([]byte(pool.Name))
+	return poolHash.Sum(nil)
+}
+
+// Terms gives the terms of use text for a language. The text is read from
+// File, relative to the manifest, when Text is empty.
+type Terms struct {
+	Lang string `json:"lang"`
+	Text string `json:"text"`
+	File string `json:"file"`
+}
+
+// Manifest describes the exchanges, asset proxies, assets, asset pairs,
+// pools and terms an environment should have. Applying a manifest creates
+// and updates rows to match it, but leaves rows it doesn't mention alone.
+type Manifest struct {
+	Exchanges    []*Exchange   `json:"exchanges"`
+	AssetProxies []*AssetProxy `json:"assetProxies"`
+	Assets       []*Asset      `json:"assets"`
+	AssetPairs   []*AssetPair  `json:"assetPairs"`
+	Pools        []*Pool       `json:"pools"`
+	Terms        []*Terms      `json:"terms"`
+}
+
+// Validate checks that the manifest's entries are complete
+func (manifest *Manifest) Validate() error {
+	for _, exchange := range manifest.Exchanges {
+		if exchange.Address == nil {
+			return fmt.Errorf("Exchange on network %v has no address", exchange.NetworkID)
+		}
+		if exchange.ProtocolVersion != types.ProtocolV2 && exchange.ProtocolVersion != types.ProtocolV3 {
+			return fmt.Errorf("Exchange %v has unsupported protocol version %v", exchange.Address, exchange.ProtocolVersion)
+		}
+		if len(exchange.DomainSalt) != 0 && len(exchange.DomainSalt) != 32 {
+			return fmt.Errorf("Exchange %v domain salt must be 32 bytes", exchange.Address)
+		}
+	}
+	for _, assetProxy := range manifest.AssetProxies {
+		if len(assetProxy.ID) != 4 {
+			return fmt.Errorf("Asset proxy %v ID must be 4 bytes", assetProxy.Name)
+		}
+		if assetProxy.Address == nil || assetProxy.ExchangeAddress == nil {
+			return fmt.Errorf("Asset proxy %v needs an address and exchange address", assetProxy.Name)
+		}
+	}
+	for _, asset := range manifest.Assets {
+		if asset.Symbol == "" {
+			return fmt.Errorf("Asset %v has no symbol", asset.Name)
+		}
+		if len(asset.ProxyID) != 4 || len(asset.AssetData) < 4 {
+			return fmt.Errorf("Asset %v needs a proxy ID and asset data", asset.Symbol)
+		}
+		if !strings.HasPrefix(string(asset.AssetData), string(asset.ProxyID)) {
+			return fmt.Errorf("Asset %v asset data doesn't match its proxy ID", asset.Symbol)
+		}
+		if asset.MinTradeAmount == nil || asset.MaxTradeAmount == nil {
+			return fmt.Errorf("Asset %v needs trade limits", asset.Symbol)
+		}
+	}
+	for _, assetPair := range manifest.AssetPairs {
+		if assetPair.AssetSymbolA == "" || assetPair.AssetSymbolB == "" || assetPair.AssetSymbolA == assetPair.AssetSymbolB {
+			return fmt.Errorf("Asset pair %v-%v needs two different assets", assetPair.AssetSymbolA, assetPair.AssetSymbolB)
+		}
+	}
+	for _, terms := range manifest.Terms {
+		if terms.Lang == "" || terms.Text == "" {
+			return fmt.Errorf("Terms need a language and text")
+		}
+	}
+	return nil
+}
+
+// Load reads a JSON manifest from path, reading any terms files relative to
+// it
+func Load(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("Malformed manifest %v: %v", path, err.Error())
+	}
+	// Unmarshaling into a zero value can't tell an omitted protocol version
+	// from an invalid one, so default it afterwards
+	for _, exchange := range manifest.Exchanges {
+		if exchange.ProtocolVersion == 0 {
+			exchange.ProtocolVersion = types.ProtocolV2
+		}
+	}
+	for _, terms := range manifest.Terms {
+		if terms.Text == "" && terms.File != "" {
+			text, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), terms.File))
+			if err != nil {
+				return nil, err
+			}
+			terms.Text = strings.TrimRight(string(text), "\n")
+		}
+	}
+	return manifest, manifest.Validate()
+}
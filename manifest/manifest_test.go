@@ -0,0 +1,112 @@
+package manifest_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notegio/openrelay/manifest"
+	"github.com/notegio/openrelay/types"
+)
+
+func writeManifest(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("%v", err.Error())
+		}
+	}
+	return filepath.Join(dir, "manifest.json")
+}
+
+func TestLoadDevManifest(t *testing.T) {
+	bootstrap, err := manifest.Load("../manifests/dev.json")
+	if err != nil {
+		t.Fatalf("Error loading manifest: %v", err.Error())
+	}
+	if len(bootstrap.Exchanges) != 9 {
+		t.Errorf("Expected 9 exchanges, got %v", len(bootstrap.Exchanges))
+	}
+	v2, v3 := 0, 0
+	for _, exchange := range bootstrap.Exchanges {
+		switch exchange.ProtocolVersion {
+		case types.ProtocolV2:
+			v2++
+		case types.ProtocolV3:
+			v3++
+			if exchange.ChainID != exchange.NetworkID {
+				t.Errorf("Exchange %v has chain ID %v", exchange.Address, exchange.ChainID)
+			}
+		}
+	}
+	if v2 != 5 || v3 != 4 {
+		t.Errorf("Expected 5 v2 and 4 v3 exchanges, got %v and %v", v2, v3)
+	}
+	if len(bootstrap.AssetProxies) != 3 || len(bootstrap.Assets) != 4 || len(bootstrap.AssetPairs) != 4 {
+		t.Errorf("Unexpected registry sizes: %v proxies, %v assets, %v pairs", len(bootstrap.AssetProxies), len(bootstrap.Assets), len(bootstrap.AssetPairs))
+	}
+	if len(bootstrap.Pools) != 1 || bootstrap.Pools[0].Name != "" {
+		t.Fatalf("Expected only the default pool")
+	}
+	if poolID := bootstrap.Pools[0].ID(); !bytes.Equal(poolID[:4], []byte{0xc5, 0xd2, 0x46, 0x01}) {
+		t.Errorf("Unexpected default pool ID %#x", poolID)
+	}
+	if len(bootstrap.Terms) != 1 || !strings.HasPrefix(bootstrap.Terms[0].Text, "In signing this statement") {
+		t.Errorf("Terms not read from file")
+	}
+	if strings.HasSuffix(bootstrap.Terms[0].Text, "\n") {
+		t.Errorf("Terms should not end with a newline")
+	}
+}
+
+func TestLoadHexFields(t *testing.T) {
+	path := writeManifest(t, map[string]string{"manifest.json": `{
+		"exchanges": [{
+			"address": "0x61935cbdd02287b511119ddb11aeb42f1593b7ef",
+			"networkId": 1,
+			"protocolVersion": 3,
+			"chainId": 1,
+			"domainSalt": "0x0000000000000000000000000000000000000000000000000000000000000001"
+		}],
+		"assetProxies": [{
+			"id": "0xf47261b0",
+			"name": "ERC20",
+			"address": "0x0a667983bb3edd6d50a9d42dfed998667e1ef1c9",
+			"exchangeAddress": "0x61935cbdd02287b511119ddb11aeb42f1593b7ef"
+		}]
+	}`})
+	bootstrap, err := manifest.Load(path)
+	if err != nil {
+		t.Fatalf("Error loading manifest: %v", err.Error())
+	}
+	if salt := bootstrap.Exchanges[0].DomainSalt; len(salt) != 32 || salt[31] != 1 {
+		t.Errorf("Unexpected domain salt %#x", []byte(salt))
+	}
+	if id := bootstrap.AssetProxies[0].ID; !bytes.Equal(id, []byte{0xf4, 0x72, 0x61, 0xb0}) {
+		t.Errorf("Unexpected asset proxy ID %#x", []byte(id))
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"malformed":        `{"exchanges": [`,
+		"protocol version": `{"exchanges": [{"address": "0x61935cbdd02287b511119ddb11aeb42f1593b7ef", "protocolVersion": 4}]}`,
+		"proxy id":         `{"assetProxies": [{"id": "0xf47261", "name": "ERC20", "address": "0x0a667983bb3edd6d50a9d42dfed998667e1ef1c9", "exchangeAddress": "0x0a667983bb3edd6d50a9d42dfed998667e1ef1c9"}]}`,
+		"asset data":       `{"assets": [{"symbol": "WETH", "proxyId": "0xf47261b0", "assetData": "0x02571792", "minTradeAmount": "0", "maxTradeAmount": "1"}]}`,
+		"trade limits":     `{"assets": [{"symbol": "WETH", "proxyId": "0xf47261b0", "assetData": "0xf47261b0"}]}`,
+		"pair":             `{"assetPairs": [{"assetSymbolA": "WETH", "assetSymbolB": "WETH"}]}`,
+		"terms":            `{"terms": [{"lang": "en"}]}`,
+	} {
+		path := writeManifest(t, map[string]string{"manifest.json": content})
+		if _, err := manifest.Load(path); err == nil {
+			t.Errorf("Expected %v error", name)
+		}
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
@@ -0,0 +1,332 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/jinzhu/gorm"
+
+	dbModule "github.com/notegio/openrelay/db"
+	poolModule "github.com/notegio/openrelay/pool"
+	"github.com/notegio/openrelay/types"
+)
+
+// Change is a row that applying a manifest would create or update. Fields
+// describes how an updated row's columns change.
+type Change struct {
+	Table  string
+	Key    string
+	Action string
+	Fields []string
+	apply  func(db *gorm.DB) error
+}
+
+func (change *Change) String() string {
+	if change.Action == "create" {
+		return fmt.Sprintf("+ %v %v", change.Table, change.Key)
+	}
+	return fmt.Sprintf("~ %v %v: %v", change.Table, change.Key, strings.Join(change.Fields, ", "))
+}
+
+// fieldDiff collects the differences between a row and its manifest entry
+type fieldDiff []string
+
+func (diff *fieldDiff) compare(name string, old, new interface{}) {
+	oldString, newString := fmt.Sprint(old), fmt.Sprint(new)
+	if oldString != newString {
+		*diff = append(*diff, fmt.Sprintf("%v %v -> %v", name, oldString, newString))
+	}
+}
+
+func hexString(data []byte) string {
+	return fmt.Sprintf("%#x", data)
+}
+
+func planRow(table, key string, diff fieldDiff, found bool, create, update func(db *gorm.DB) error) *Change {
+	if !found {
+		return &Change{table, key, "create", nil, create}
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+	return &Change{table, key, "update", diff, update}
+}
+
+func planExchange(db *gorm.DB, exchange *Exchange) (*Change, error) {
+	row := &dbModule.Exchange{
+		Address:         exchange.Address,
+		Network:         exchange.NetworkID,
+		ProtocolVersion: exchange.ProtocolVersion,
+		ChainID:         exchange.ChainID,
+		DomainName:      exchange.DomainName,
+		DomainVersion:   exchange.DomainVersion,
+		DomainSalt:      exchange.DomainSalt,
+	}
+	existing := &dbModule.Exchange{}
+	result := db.Where("address = ?", exchange.Address).First(existing)
+	if result.Error != nil && !result.RecordNotFound() {
+		return nil, result.Error
+	}
+	diff := fieldDiff{}
+	if !result.RecordNotFound() {
+		diff.compare("networkId", existing.Network, row.Network)
+		diff.compare("protocolVersion", existing.ProtocolVersion, row.ProtocolVersion)
+		diff.compare("chainId", existing.ChainID, row.ChainID)
+		diff.compare("domainName", existing.DomainName, row.DomainName)
+		diff.compare("domainVersion", existing.DomainVersion, row.DomainVersion)
+		diff.compare("domainSalt", hexString(existing.DomainSalt), hexString(row.DomainSalt))
+	}
+	return planRow("exchanges", exchange.Address.String(), diff, !result.RecordNotFound(),
+		func(db *gorm.DB) error { return dbModule.CreateExchange(db, row) },
+		func(db *gorm.DB) error { return dbModule.UpdateExchange(db, row) },
+	), nil
+}
+
+func planAssetProxy(db *gorm.DB, assetProxy *AssetProxy) (*Change, error) {
+	row := &dbModule.AssetProxy{
+		ID:              assetProxy.ID,
+		Name:            assetProxy.Name,
+		Address:         assetProxy.Address,
+		ExchangeAddress: assetProxy.ExchangeAddress,
+		ZeroEx:          assetProxy.ZeroEx,
+	}
+	existing := &dbModule.AssetProxy{}
+	result := db.Where("id = ?", []byte(assetProxy.ID)).First(existing)
+	if result.Error != nil && !result.RecordNotFound() {
+		return nil, result.Error
+	}
+	diff := fieldDiff{}
+	if !result.RecordNotFound() {
+		diff.compare("name", existing.Name, row.Name)
+		diff.compare("address", existing.Address, row.Address)
+		diff.compare("exchangeAddress", existing.ExchangeAddress, row.ExchangeAddress)
+		diff.compare("zeroEx", existing.ZeroEx, row.ZeroEx)
+	}
+	return planRow("asset_proxies", hexString(assetProxy.ID), diff, !result.RecordNotFound(),
+		func(db *gorm.DB) error { return dbModule.CreateAssetProxy(db, row) },
+		func(db *gorm.DB) error { return dbModule.UpdateAssetProxy(db, row) },
+	), nil
+}
+
+func planAsset(db *gorm.DB, asset *Asset) (*Change, error) {
+	data := types.AssetData(asset.AssetData)
+	row := &dbModule.Asset{
+		Symbol:         asset.Symbol,
+		Name:           asset.Name,
+		Address:        asset.Address,
+		Decimals:       asset.Decimals,
+		ProxyID:        asset.ProxyID,
+		Data:           &data,
+		Precision:      asset.Precision,
+		MinTradeAmount: asset.MinTradeAmount,
+		MaxTradeAmount: asset.MaxTradeAmount,
+		ZeroEx:         asset.ZeroEx,
+		Active:         asset.Active,
+		Quote:          asset.Quote,
+	}
+	existing := &dbModule.Asset{}
+	result := db.Where("symbol = ?", asset.Symbol).First(existing)
+	if result.Error != nil && !result.RecordNotFound() {
+		return nil, result.Error
+	}
+	diff := fieldDiff{}
+	if !result.RecordNotFound() {
+		existingData := []byte{}
+		if existing.Data != nil {
+			existingData = *existing.Data
+		}
+		diff.compare("name", existing.Name, row.Name)
+		diff.compare("address", existing.Address, row.Address)
+		diff.compare("decimals", existing.Decimals, row.Decimals)
+		diff.compare("proxyId", hexString(existing.ProxyID), hexString(row.ProxyID))
+		diff.compare("assetData", hexString(existingData), hexString(data))
+		diff.compare("precision", existing.Precision, row.Precision)
+		diff.compare("minTradeAmount", existing.MinTradeAmount, row.MinTradeAmount)
+		diff.compare("maxTradeAmount", existing.MaxTradeAmount, row.MaxTradeAmount)
+		diff.compare("zeroEx", existing.ZeroEx, row.ZeroEx)
+		diff.compare("active", existing.Active, row.Active)
+		diff.compare("quote", existing.Quote, row.Quote)
+	}
+	return planRow("assets", asset.Symbol, diff, !result.RecordNotFound(),
+		func(db *gorm.DB) error { return dbModule.CreateAsset(db, row) },
+		func(db *gorm.DB) error { return dbModule.UpdateAsset(db, row) },
+	), nil
+}
+
+func planAssetPair(db *gorm.DB, assetPair *AssetPair) (*Change, error) {
+	row := &dbModule.AssetPair{
+		AssetSymbolA: assetPair.AssetSymbolA,
+		AssetSymbolB: assetPair.AssetSymbolB,
+		Active:       assetPair.Active,
+	}
+	existing := &dbModule.AssetPair{}
+	result := db.Where("asset_symbol_a = ? AND asset_symbol_b = ?", assetPair.AssetSymbolA, assetPair.AssetSymbolB).First(existing)
+	if result.Error != nil && !result.RecordNotFound() {
+		return nil, result.Error
+	}
+	diff := fieldDiff{}
+	if !result.RecordNotFound() {
+		row.ID = existing.ID
+		diff.compare("active", existing.Active, row.Active)
+	}
+	return planRow("asset_pairs", assetPair.AssetSymbolA+"-"+assetPair.AssetSymbolB, diff, !result.RecordNotFound(),
+		func(db *gorm.DB) error { return dbModule.CreateAssetPair(db, row) },
+		func(db *gorm.DB) error { return dbModule.UpdateAssetPair(db, row) },
+	), nil
+}
+
+// decodeNetworkAddresses reads a pool's address map column, which
+// NetworkAddressMap can't scan back
+func decodeNetworkAddresses(data []byte) types.NetworkAddressMap {
+	items := []struct {
+		Net     uint64
+		Address types.Address
+	}{}
+	result := types.NetworkAddressMap{}
+	if err := rlp.DecodeBytes(data, &items); err != nil {
+		return result
+	}
+	for i := range items {
+		result[items[i].Net] = &items[i].Address
+	}
+	return result
+}
+
+func formatNetworkAddresses(addresses types.NetworkAddressMap) string {
+	networks := []string{}
+	for network, address := range addresses {
+		networks = append(networks, fmt.Sprintf("%v:%v", network, address))
+	}
+	// Map order is random, so sort for a stable comparison
+	for i := 1; i < len(networks); i++ {
+		for j := i; j > 0 && networks[j] < networks[j-1]; j-- {
+			networks[j], networks[j-1] = networks[j-1], networks[j]
+		}
+	}
+	return "{" + strings.Join(networks, " ") + "}"
+}
+
+func planPool(db *gorm.DB, pool *Pool) (*Change, error) {
+	row := &poolModule.Pool{
+		SearchTerms:          pool.SearchTerms,
+		Expiration:           pool.Expiration,
+		Nonce:                pool.Nonce,
+		FeeShare:             pool.FeeShare,
+		ID:                   pool.ID(),
+		Limit:                pool.Limit,
+		SenderAddresses:      pool.SenderAddresses,
+		FilterAddresses:      pool.FilterAddresses,
+		CommitmentPolicy:     pool.CommitmentPolicy,
+		EnforceAssetRegistry: pool.EnforceAssetRegistry,
+	}
+	if row.SenderAddresses == nil {
+		row.SenderAddresses = types.NetworkAddressMap{}
+	}
+	if row.FilterAddresses == nil {
+		row.FilterAddresses = types.NetworkAddressMap{}
+	}
+	existing := &poolModule.Pool{}
+	result := db.Model(&poolModule.Pool{}).Where("id = ?", row.ID).First(existing)
+	if result.Error != nil && !result.RecordNotFound() {
+		return nil, result.Error
+	}
+	diff := fieldDiff{}
+	if !result.RecordNotFound() {
+		var senderAddresses, filterAddresses []byte
+		if err := db.Model(&poolModule.Pool{}).Where("id = ?", row.ID).Select("sender_addresses, filter_addresses").Row().Scan(&senderAddresses, &filterAddresses); err != nil {
+			return nil, err
+		}
+		diff.compare("searchTerms", existing.SearchTerms, row.SearchTerms)
+		diff.compare("expiration", existing.Expiration, row.Expiration)
+		diff.compare("nonce", existing.Nonce, row.Nonce)
+		diff.compare("feeShare", existing.FeeShare, row.FeeShare)
+		diff.compare("limit", existing.Limit, row.Limit)
+		diff.compare("senderAddresses", formatNetworkAddresses(decodeNetworkAddresses(senderAddresses)), formatNetworkAddresses(row.SenderAddresses))
+		diff.compare("filterAddresses", formatNetworkAddresses(decodeNetworkAddresses(filterAddresses)), formatNetworkAddresses(row.FilterAddresses))
+		diff.compare("commitmentPolicy", existing.CommitmentPolicy, row.CommitmentPolicy)
+		diff.compare("enforceAssetRegistry", existing.EnforceAssetRegistry, row.EnforceAssetRegistry)
+	}
+	save := func(db *gorm.DB) error {
+		return db.Model(&poolModule.Pool{}).Where("id = ?", row.ID).Assign(row).FirstOrCreate(row).Error
+	}
+	return planRow("pools", fmt.Sprintf("%q", pool.Name), diff, !result.RecordNotFound(), save, save), nil
+}
+
+func planTerms(db *gorm.DB, terms *Terms) (*Change, error) {
+	current, err := dbModule.NewTermsManager(db).GetTerms(terms.Lang)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	diff := fieldDiff{}
+	if err == nil && current.Text != terms.Text {
+		diff = append(diff, "text changed")
+	}
+	save := func(db *gorm.DB) error {
+		return dbModule.NewTxTermsManager(db).UpdateTerms(terms.Lang, terms.Text)
+	}
+	return planRow("terms", terms.Lang, diff, err == nil, save, save), nil
+}
+
+// Plan lists the changes applying the manifest to db would make, in the order
+// they must be applied. Rows the manifest doesn't mention are left alone, so
+// a manifest never deletes anything.
+func Plan(db *gorm.DB, manifest *Manifest) ([]*Change, error) {
+	changes := []*Change{}
+	add := func(change *Change, err error) error {
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+		return nil
+	}
+	for _, exchange := range manifest.Exchanges {
+		if err := add(planExchange(db, exchange)); err != nil {
+			return nil, err
+		}
+	}
+	for _, assetProxy := range manifest.AssetProxies {
+		if err := add(planAssetProxy(db, assetProxy)); err != nil {
+			return nil, err
+		}
+	}
+	for _, asset := range manifest.Assets {
+		if err := add(planAsset(db, asset)); err != nil {
+			return nil, err
+		}
+	}
+	for _, assetPair := range manifest.AssetPairs {
+		if err := add(planAssetPair(db, assetPair)); err != nil {
+			return nil, err
+		}
+	}
+	for _, pool := range manifest.Pools {
+		if err := add(planPool(db, pool)); err != nil {
+			return nil, err
+		}
+	}
+	for _, terms := range manifest.Terms {
+		if err := add(planTerms(db, terms)); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// Apply makes the planned changes in a single transaction, so either all of
+// them or none are applied
+func Apply(db *gorm.DB, changes []*Change) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, change := range changes {
+		if err := change.apply(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error applying %v: %v", change, err.Error())
+		}
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,279 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// The baseline's tables are snapshots of the models as they stood when
+// migrations were versioned, so the baseline creates the same schema however
+// the models change later. Changes to the models need migrations of their
+// own. Columns the models store through custom types are declared with the
+// plain types gorm maps them to.
+
+// blob matches the column type of types.NetworkAddressMap
+type blob []byte
+
+func (data blob) GormDataType(dialect gorm.Dialect) string {
+	if dialect.GetName() == "postgres" {
+		return "bytea"
+	}
+	return "blob"
+}
+
+type baselineExchange struct {
+	Address         []byte `gorm:"primary_key"`
+	Network         uint64 `gorm:"index"`
+	ProtocolVersion uint8  `gorm:"default:2"`
+	ChainID         uint64
+	DomainName      string
+	DomainVersion   string
+	DomainSalt      []byte
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (baselineExchange) TableName() string { return "exchanges" }
+
+type baselineAssetProxy struct {
+	ID              []byte `gorm:"primary_key"`
+	Name            string `gorm:"index"`
+	Address         []byte `gorm:"index"`
+	ExchangeAddress []byte `gorm:"index"`
+	ZeroEx          bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (baselineAssetProxy) TableName() string { return "asset_proxies" }
+
+type baselineAsset struct {
+	Symbol         string `gorm:"primary_key"`
+	Name           string `gorm:"index"`
+	Address        []byte `gorm:"index"`
+	Decimals       uint16 `gorm:"index"`
+	ProxyID        []byte `gorm:"index"`
+	Data           []byte `gorm:"index"`
+	Precision      uint16
+	MinTradeAmount []byte
+	MaxTradeAmount []byte
+	ZeroEx         bool
+	Active         bool
+	Quote          bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (baselineAsset) TableName() string { return "assets" }
+
+type baselineAssetPair struct {
+	ID           uint64 `gorm:"primary_key;AUTO_INCREMENT"`
+	AssetSymbolA string `gorm:"primary_key"`
+	AssetSymbolB string `gorm:"primary_key"`
+	Active       bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineAssetPair) TableName() string { return "asset_pairs" }
+
+type baselineOrder struct {
+	Maker                             []byte `gorm:"index"`
+	Taker                             []byte `gorm:"index"`
+	MakerAssetAddress                 []byte `gorm:"index"`
+	TakerAssetAddress                 []byte `gorm:"index"`
+	MakerAssetData                    []byte `gorm:"index"`
+	TakerAssetData                    []byte `gorm:"index"`
+	FeeRecipient                      []byte `gorm:"index"`
+	ExchangeAddress                   []byte `gorm:"index"`
+	SenderAddress                     []byte `gorm:"index"`
+	MakerAssetAmount                  []byte
+	TakerAssetAmount                  []byte
+	MakerFee                          []byte
+	TakerFee                          []byte `gorm:"index"`
+	ExpirationTimestampInSec          []byte `gorm:"index"`
+	Salt                              []byte
+	Signature                         []byte
+	TakerAssetAmountFilled            []byte
+	Cancelled                         bool
+	PoolID                            []byte `gorm:"index"`
+	ProtocolVersion                   uint8  `gorm:"index;default:2"`
+	ChainID                           uint64
+	MakerFeeAssetData                 []byte
+	TakerFeeAssetData                 []byte
+	DomainName                        string
+	DomainVersion                     string
+	DomainSalt                        []byte
+	CreatedAt                         time.Time
+	UpdatedAt                         time.Time
+	OrderHash                         []byte  `gorm:"primary_key"`
+	Status                            int64   `gorm:"index"`
+	Price                             float64 `gorm:"index:price"`
+	FeeRate                           float64 `gorm:"index:price"`
+	MakerAssetRemaining               []byte
+	MakerFeeRemaining                 []byte
+	RemainingFillableTakerAssetAmount []byte
+	OverCommitted                     bool
+}
+
+func (baselineOrder) TableName() string { return "orders" }
+
+type baselineAssetComponent struct {
+	OrderHash    []byte `gorm:"primary_key"`
+	Side         string `gorm:"primary_key"`
+	Position     int    `gorm:"primary_key;auto_increment:false"`
+	AssetData    []byte `gorm:"index"`
+	AssetAddress []byte `gorm:"index"`
+	Amount       []byte
+}
+
+func (baselineAssetComponent) TableName() string { return "asset_components" }
+
+type baselineCancellation struct {
+	Maker  []byte `gorm:"primary_key"`
+	Sender []byte `gorm:"primary_key"`
+	Epoch  []byte
+}
+
+func (baselineCancellation) TableName() string { return "cancellations" }
+
+type baselineSoftCancellation struct {
+	Hash            []byte `gorm:"primary_key"`
+	Maker           []byte `gorm:"index"`
+	Request         string `gorm:"type:text"`
+	OrdersCancelled int64
+	CreatedAt       time.Time
+}
+
+func (baselineSoftCancellation) TableName() string { return "soft_cancellations" }
+
+type baselineTerms struct {
+	gorm.Model
+	Current    bool
+	Lang       string
+	Text       string `sql:"type:text;"`
+	Valid      bool
+	Difficulty int
+}
+
+func (baselineTerms) TableName() string { return "terms" }
+
+type baselineTermsSig struct {
+	gorm.Model
+	Signer    []byte `gorm:"index"`
+	Timestamp string
+	IP        string
+	Nonce     []byte
+	Banned    bool
+	Signature []byte
+	TermsID   uint
+}
+
+func (baselineTermsSig) TableName() string { return "terms_sigs" }
+
+type baselineHashMask struct {
+	gorm.Model
+	Mask       []byte
+	Expiration time.Time
+}
+
+func (baselineHashMask) TableName() string { return "hash_masks" }
+
+type baselinePool struct {
+	SearchTerms          string
+	Expiration           uint64
+	Nonce                uint
+	FeeShare             string
+	ID                   []byte
+	Limit                uint
+	SenderAddresses      blob
+	FilterAddresses      blob
+	CommitmentPolicy     string
+	EnforceAssetRegistry bool
+}
+
+func (baselinePool) TableName() string { return "pools" }
+
+type baselineAPIKey struct {
+	KeyHash       []byte `gorm:"primary_key"`
+	Prefix        string `gorm:"unique_index"`
+	Owner         string `gorm:"index"`
+	RateLimit     string
+	Pools         string `gorm:"type:text"`
+	PrivateOrders bool
+	Admin         bool
+	CreatedAt     time.Time
+	RevokedAt     *time.Time
+}
+
+func (baselineAPIKey) TableName() string { return "api_keys" }
+
+// baseline creates the schema automigrate built with AutoMigrate before
+// migrations were versioned. AutoMigrate leaves existing tables alone, so
+// applying it to a database automigrate already set up just records it.
+var baseline = &Migration{
+	Version: 1,
+	Name:    "baseline",
+	Up: func(db *gorm.DB) error {
+		if err := db.AutoMigrate(
+			&baselineExchange{},
+			&baselineAssetProxy{},
+			&baselineAsset{},
+			&baselineAssetPair{},
+			&baselineOrder{},
+			&baselineAssetComponent{},
+			&baselineCancellation{},
+			&baselineSoftCancellation{},
+			&baselineTerms{},
+			&baselineTermsSig{},
+			&baselineHashMask{},
+			&baselinePool{},
+			&baselineAPIKey{},
+		).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&baselineOrder{}).AddIndex("idx_order_maker_asset_taker_asset_data", "maker_asset_data", "taker_asset_data").Error; err != nil {
+			return err
+		}
+		if err := db.Model(&baselineAssetProxy{}).AddForeignKey("exchange_address", "exchanges(address)", "RESTRICT", "CASCADE").Error; err != nil {
+			return err
+		}
+		if err := db.Model(&baselineAsset{}).AddForeignKey("proxy_id", "asset_proxies(id)", "RESTRICT", "CASCADE").Error; err != nil {
+			return err
+		}
+		if err := db.Model(&baselineAssetPair{}).AddForeignKey("asset_symbol_a", "assets(symbol)", "RESTRICT", "CASCADE").Error; err != nil {
+			return err
+		}
+		return db.Model(&baselineAssetPair{}).AddForeignKey("asset_symbol_b", "assets(symbol)", "RESTRICT", "CASCADE").Error
+	},
+	Down: func(db *gorm.DB) error {
+		// Reverting the baseline drops every table, so it's refused while any
+		// orders remain rather than risk discarding the order book by mistake
+		var count int
+		if err := db.Model(&baselineOrder{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("Refusing to drop the schema while the orders table holds %v orders", count)
+		}
+		// Tables are dropped in reverse dependency order, to satisfy the foreign
+		// keys
+		return db.DropTableIfExists(
+			&baselineAPIKey{},
+			&baselinePool{},
+			&baselineHashMask{},
+			&baselineTermsSig{},
+			&baselineTerms{},
+			&baselineSoftCancellation{},
+			&baselineCancellation{},
+			&baselineAssetComponent{},
+			&baselineOrder{},
+			&baselineAssetPair{},
+			&baselineAsset{},
+			&baselineAssetProxy{},
+			&baselineExchange{},
+		).Error
+	},
+}
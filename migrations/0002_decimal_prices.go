@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/types"
+)
+
+const backfillBatchSize = 1000
+
+// alterPriceColumns changes the type of the price and fee rate columns. On
+// Postgres, postgresUsing converts existing values, with %v standing for the
+// column.
+func alterPriceColumns(db *gorm.DB, postgresType, postgresUsing, mysqlType string) error {
+	for _, column := range []string{"price", "fee_rate"} {
+		using := postgresUsing
+		if strings.Contains(using, "%v") {
+			using = fmt.Sprintf(using, column)
+		}
+		query := fmt.Sprintf("ALTER TABLE orders ALTER COLUMN %v TYPE %v USING %v", column, postgresType, using)
+		if db.Dialect().GetName() == "mysql" {
+			query = fmt.Sprintf("ALTER TABLE orders MODIFY COLUMN %v %v NULL", column, mysqlType)
+		}
+		if err := db.Exec(query).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillPrices recomputes every order's price and fee rate. Orders are read
+// in batches by hash, as Postgres can't run the updates while a result set
+// is open on the transaction's connection.
+func backfillPrices(db *gorm.DB) error {
+	lastHash := []byte{}
+	for {
+		orders := []*dbModule.Order{}
+		if err := db.Model(&dbModule.Order{}).Select(
+			"order_hash, maker_asset_amount, taker_asset_amount, taker_fee",
+		).Where("order_hash > ?", lastHash).Order("order_hash").Limit(backfillBatchSize).Find(&orders).Error; err != nil {
+			return err
+		}
+		for _, order := range orders {
+			order.PopulatePrice()
+			if err := db.Model(&dbModule.Order{}).Where("order_hash = ?", order.OrderHash).UpdateColumns(map[string]interface{}{
+				"price":    order.Price,
+				"fee_rate": order.FeeRate,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if len(orders) < backfillBatchSize {
+			return nil
+		}
+		lastHash = orders[len(orders)-1].OrderHash
+	}
+}
+
+// decimalPrices stores order prices and fee rates as exact decimals instead
+// of floats, so orders at equal prices compare equal
+var decimalPrices = &Migration{
+	Version: 2,
+	Name:    "decimal prices",
+	Up: func(db *gorm.DB) error {
+		// Existing values are discarded rather than cast, as Postgres can't cast
+		// infinite floats to numeric, and the backfill replaces them anyway
+		if err := alterPriceColumns(db, "numeric", "NULL", fmt.Sprintf("decimal(65,%v)", types.DecimalScale)); err != nil {
+			return err
+		}
+		return backfillPrices(db)
+	},
+	Down: func(db *gorm.DB) error {
+		return alterPriceColumns(db, "double precision", "%v::double precision", "double")
+	},
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+// searchIndexes are the indexes behind the order search range filters
+// and sort options, named as gorm names the model's indexes
+var searchIndexes = []struct {
+	name   string
+	column string
+}{
+	{"idx_orders_maker_asset_amount", "maker_asset_amount"},
+	{"idx_orders_taker_asset_amount", "taker_asset_amount"},
+	{"idx_orders_fee_rate", "fee_rate"},
+	{"idx_orders_created_at", "created_at"},
+}
+
+// orderSearchIndexes indexes the columns order search filters and
+// sorts on that weren't indexed already
+var orderSearchIndexes = &Migration{
+	Version: 3,
+	Name:    "order search indexes",
+	Up: func(db *gorm.DB) error {
+		for _, index := range searchIndexes {
+			// AddIndex skips indexes that already exist
+			if err := db.Model(&dbModule.Order{}).AddIndex(index.name, index.column).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(db *gorm.DB) error {
+		for _, index := range searchIndexes {
+			if err := db.Model(&dbModule.Order{}).RemoveIndex(index.name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/notegio/openrelay/types"
+)
+
+type softCancelRecord struct {
+	Hash            []byte `gorm:"primary_key"`
+	ExchangeAddress []byte
+	SaltUpTo        []byte
+	MakerAssetData  []byte
+	TakerAssetData  []byte
+	PoolID          []byte
+	Request         string `gorm:"type:text"`
+}
+
+func (softCancelRecord) TableName() string { return "soft_cancellations" }
+
+type softCancelledOrder struct {
+	OrderHash        []byte `gorm:"primary_key"`
+	Maker            []byte `gorm:"primary_key"`
+	CancellationHash []byte `gorm:"index"`
+}
+
+func (softCancelledOrder) TableName() string { return "soft_cancelled_orders" }
+
+var softCancelTermsColumns = []string{"exchange_address", "salt_up_to", "maker_asset_data", "taker_asset_data", "pool_id"}
+
+// backfillSoftCancelTerms fills in the terms of soft cancels recorded before
+// they were kept, from the signed requests
+func backfillSoftCancelTerms(db *gorm.DB) error {
+	records := []*softCancelRecord{}
+	if err := db.Model(&softCancelRecord{}).Find(&records).Error; err != nil {
+		return err
+	}
+	for _, record := range records {
+		cancel := &types.SoftCancel{}
+		if err := json.Unmarshal([]byte(record.Request), cancel); err != nil {
+			return err
+		}
+		for _, orderHash := range cancel.OrderHashes {
+			if err := db.Where(&softCancelledOrder{OrderHash: orderHash[:], Maker: cancel.Maker[:]}).FirstOrCreate(&softCancelledOrder{
+				OrderHash:        orderHash[:],
+				Maker:            cancel.Maker[:],
+				CancellationHash: record.Hash,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		updates := map[string]interface{}{
+			"exchange_address": cancel.ExchangeAddress[:],
+			"salt_up_to":       cancel.SaltUpTo[:],
+			"maker_asset_data": []byte(cancel.MakerAssetData),
+			"taker_asset_data": []byte(cancel.TakerAssetData),
+		}
+		if cancel.HasPool() {
+			updates["pool_id"] = cancel.PoolID[:]
+		}
+		if err := db.Model(&softCancelRecord{}).Where("hash = ?", record.Hash).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// softCancelTerms keeps the terms of soft cancels and the order hashes they
+// list, so cancelled orders can't return to the order book
+var softCancelTerms = &Migration{
+	Version: 5,
+	Name:    "soft cancel terms",
+	Up: func(db *gorm.DB) error {
+		if err := db.AutoMigrate(&softCancelRecord{}, &softCancelledOrder{}).Error; err != nil {
+			return err
+		}
+		return backfillSoftCancelTerms(db)
+	},
+	Down: func(db *gorm.DB) error {
+		for _, column := range softCancelTermsColumns {
+			if err := db.Model(&softCancelRecord{}).DropColumn(column).Error; err != nil {
+				return err
+			}
+		}
+		return db.DropTableIfExists(&softCancelledOrder{}).Error
+	},
+}
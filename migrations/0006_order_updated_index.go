@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+// orderUpdatedIndex indexes when orders last changed, which cursor pages
+// over every order are ordered by
+var orderUpdatedIndex = &Migration{
+	Version: 6,
+	Name:    "order updated index",
+	Up: func(db *gorm.DB) error {
+		// AddIndex skips indexes that already exist
+		return db.Model(&dbModule.Order{}).AddIndex("idx_orders_updated_at", "updated_at").Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&dbModule.Order{}).RemoveIndex("idx_orders_updated_at").Error
+	},
+}
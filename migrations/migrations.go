@@ -0,0 +1,176 @@
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is a numbered change to the database schema. Down reverses Up.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(db *gorm.DB) error
+	Down    func(db *gorm.DB) error
+}
+
+// SchemaMigration records a migration that has been applied
+type SchemaMigration struct {
+	Version   uint `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status reports whether a migration has been applied. Migrations applied to
+// the database but unknown to this build have a nil Migration.
+type Status struct {
+	Version   uint
+	Name      string
+	Migration *Migration
+	AppliedAt *time.Time
+}
+
+func (status *Status) String() string {
+	state := "pending"
+	if status.AppliedAt != nil {
+		state = "applied " + status.AppliedAt.UTC().Format(time.RFC3339)
+	}
+	if status.Migration == nil {
+		state += " (unknown migration)"
+	}
+	return fmt.Sprintf("%04d %v: %v", status.Version, status.Name, state)
+}
+
+// All lists every migration, in version order
+var All = []*Migration{
+	baseline,
+	decimalPrices,
+	orderSearchIndexes,
+	softCancelTerms,
+	orderUpdatedIndex,
+}
+
+func sorted(migrations []*Migration) []*Migration {
+	result := append([]*Migration{}, migrations...)
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result
+}
+
+func applied(db *gorm.DB) (map[uint]*SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return nil, err
+	}
+	rows := []*SchemaMigration{}
+	if err := db.Model(&SchemaMigration{}).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]*SchemaMigration)
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// run applies fn along with its schema_migrations bookkeeping. Postgres
+// supports transactional DDL, so there a failed migration leaves no trace.
+// Other databases commit DDL implicitly, and a failure may need cleaning up
+// by hand.
+func run(db *gorm.DB, fn func(db *gorm.DB) error) error {
+	if db.Dialect().GetName() != "postgres" {
+		return fn(db)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// Up applies pending migrations with versions up to target, or every pending
+// migration if target is 0. It returns the migrations it applied.
+func Up(db *gorm.DB, migrations []*Migration, target uint) ([]*Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Migration{}
+	for _, migration := range sorted(migrations) {
+		if target != 0 && migration.Version > target {
+			break
+		}
+		if _, ok := done[migration.Version]; ok {
+			continue
+		}
+		err := run(db, func(db *gorm.DB) error {
+			if err := migration.Up(db); err != nil {
+				return err
+			}
+			return db.Create(&SchemaMigration{migration.Version, migration.Name, time.Now()}).Error
+		})
+		if err != nil {
+			return result, fmt.Errorf("Error applying migration %04d %v: %v", migration.Version, migration.Name, err.Error())
+		}
+		result = append(result, migration)
+	}
+	return result, nil
+}
+
+// Down reverts the most recently applied steps migrations. It returns the
+// migrations it reverted.
+func Down(db *gorm.DB, migrations []*Migration, steps uint) ([]*Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	ordered := sorted(migrations)
+	result := []*Migration{}
+	for i := len(ordered) - 1; i >= 0 && uint(len(result)) < steps; i-- {
+		migration := ordered[i]
+		if _, ok := done[migration.Version]; !ok {
+			continue
+		}
+		err := run(db, func(db *gorm.DB) error {
+			if err := migration.Down(db); err != nil {
+				return err
+			}
+			return db.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return result, fmt.Errorf("Error reverting migration %04d %v: %v", migration.Version, migration.Name, err.Error())
+		}
+		result = append(result, migration)
+	}
+	return result, nil
+}
+
+// GetStatus reports the state of every migration, whether known to this
+// build or recorded in the database
+func GetStatus(db *gorm.DB, migrations []*Migration) ([]*Status, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Status{}
+	known := make(map[uint]bool)
+	for _, migration := range sorted(migrations) {
+		status := &Status{Version: migration.Version, Name: migration.Name, Migration: migration}
+		if row, ok := done[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		known[migration.Version] = true
+		result = append(result, status)
+	}
+	for version, row := range done {
+		if !known[version] {
+			result = append(result, &Status{Version: version, Name: row.Name, AppliedAt: &row.AppliedAt})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package migrations_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	dbModule "github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/migrations"
+)
+
+// getDb connects to the test database with a schema of its own, so
+// migrations don't touch the tables other tests use
+func getDb(t *testing.T) (*gorm.DB, func()) {
+	connectionString := fmt.Sprintf(
+		"postgres://%v@%v",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_HOST"),
+	)
+	db, err := dbModule.GetDB(connectionString, os.Getenv("POSTGRES_PASSWORD"))
+	if err != nil {
+		t.Fatalf("Could not get db: %v", err.Error())
+	}
+	// search_path is per connection, so keep to a single one
+	db.DB().SetMaxOpenConns(1)
+	schema := fmt.Sprintf("migrations_test_%v", time.Now().UnixNano())
+	if err := db.Exec(fmt.Sprintf("CREATE SCHEMA %v", schema)).Error; err != nil {
+		t.Fatalf("Could not create schema: %v", err.Error())
+	}
+	if err := db.Exec(fmt.Sprintf("SET search_path TO %v", schema)).Error; err != nil {
+		t.Fatalf("Could not set search path: %v", err.Error())
+	}
+	return db, func() {
+		db.Exec(fmt.Sprintf("DROP SCHEMA %v CASCADE", schema))
+		db.Close()
+	}
+}
+
+type widget struct {
+	ID uint
+}
+
+var testMigrations = []*migrations.Migration{
+	&migrations.Migration{
+		Version: 2,
+		Name:    "widget colour",
+		Up: func(db *gorm.DB) error {
+			return db.Exec("ALTER TABLE widgets ADD COLUMN colour text").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Exec("ALTER TABLE widgets DROP COLUMN colour").Error
+		},
+	},
+	&migrations.Migration{
+		Version: 1,
+		Name:    "widgets",
+		Up: func(db *gorm.DB) error {
+			return db.CreateTable(&widget{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTable(&widget{}).Error
+		},
+	},
+}
+
+func hasColour(db *gorm.DB) bool {
+	return db.Exec("SELECT colour FROM widgets").Error == nil
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db, cleanup := getDb(t)
+	defer cleanup()
+	applied, err := migrations.Up(db, testMigrations, 1)
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Fatalf("Expected only migration 1 to apply, got %v", applied)
+	}
+	if !db.HasTable(&widget{}) || hasColour(db) {
+		t.Errorf("Expected widgets table without colour")
+	}
+	statuses, err := migrations.GetStatus(db, testMigrations)
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if len(statuses) != 2 || statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("Unexpected status %v", statuses)
+	}
+	if applied, err = migrations.Up(db, testMigrations, 0); err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if len(applied) != 1 || applied[0].Version != 2 || !hasColour(db) {
+		t.Errorf("Expected migration 2 to apply")
+	}
+	if applied, err = migrations.Up(db, testMigrations, 0); err != nil || len(applied) != 0 {
+		t.Errorf("Expected nothing left to apply, got %v, %v", applied, err)
+	}
+	reverted, err := migrations.Down(db, testMigrations, 1)
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 || hasColour(db) {
+		t.Errorf("Expected migration 2 to revert")
+	}
+	if reverted, err = migrations.Down(db, testMigrations, 5); err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if len(reverted) != 1 || db.HasTable(&widget{}) {
+		t.Errorf("Expected migration 1 to revert")
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db, cleanup := getDb(t)
+	defer cleanup()
+	failing := append(testMigrations, &migrations.Migration{
+		Version: 3,
+		Name:    "broken",
+		Up: func(db *gorm.DB) error {
+			if err := db.Exec("ALTER TABLE widgets ADD COLUMN size integer").Error; err != nil {
+				return err
+			}
+			return errors.New("broken")
+		},
+		Down: func(db *gorm.DB) error { return nil },
+	})
+	applied, err := migrations.Up(db, failing, 0)
+	if err == nil {
+		t.Fatalf("Expected migration 3 to fail")
+	}
+	if len(applied) != 2 {
+		t.Errorf("Expected migrations 1 and 2 to apply, got %v", applied)
+	}
+	if db.Exec("SELECT size FROM widgets").Error == nil {
+		t.Errorf("Failed migration should have rolled back")
+	}
+	statuses, err := migrations.GetStatus(db, failing)
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if statuses[2].AppliedAt != nil {
+		t.Errorf("Failed migration should not be recorded")
+	}
+}
+
+func TestAll(t *testing.T) {
+	db, cleanup := getDb(t)
+	defer cleanup()
+	if _, err := migrations.Up(db, migrations.All, 0); err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	for _, table := range []string{"exchanges", "asset_proxies", "assets", "asset_pairs", "orders", "pools", "api_keys"} {
+		if !db.HasTable(table) {
+			t.Errorf("Expected table %v", table)
+		}
+	}
+	if err := db.Exec("INSERT INTO orders (order_hash) VALUES (?)", []byte{1}).Error; err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if _, err := migrations.Down(db, migrations.All, uint(len(migrations.All))); err == nil {
+		t.Fatalf("Expected baseline to refuse to revert with orders present")
+	}
+	if !db.HasTable("orders") {
+		t.Fatalf("Expected orders table to remain")
+	}
+	if err := db.Exec("DELETE FROM orders").Error; err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if _, err := migrations.Down(db, migrations.All, uint(len(migrations.All))); err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if db.HasTable("orders") {
+		t.Errorf("Expected baseline to revert")
+	}
+}
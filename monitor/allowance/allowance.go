@@ -16,8 +16,10 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/notegio/openrelay/channels"
+	orCommon "github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/db"
 	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/funds/balance"
 	"github.com/notegio/openrelay/monitor/blocks"
 	"github.com/notegio/openrelay/types"
 )
@@ -29,6 +31,8 @@ type allowanceBlockConsumer struct {
 	feeTokenAddress     string // Needed for the SpendRecord,
 	logFilter           ethereum.LogFilterer
 	publisher           channels.Publisher
+	invalidationPublisher channels.Publisher
+	approvalForAllTopic *big.Int
 }
 
 func (consumer *allowanceBlockConsumer) Consume(delivery channels.Delivery) {
@@ -37,6 +41,7 @@ func (consumer *allowanceBlockConsumer) Consume(delivery channels.Delivery) {
 	if err != nil {
 		log.Printf("Error parsing payload: %v\n", err.Error())
 	}
+	invalidation := &balance.CacheInvalidation{}
 	if coreTypes.BloomLookup(block.Bloom, consumer.approvalTopic) && coreTypes.BloomLookup(block.Bloom, common.BigToHash(consumer.tokenProxyAddress)) {
 		log.Printf("Block %#x bloom filter indicates approval event for %#x", block.Hash, consumer.tokenProxyAddress)
 		query := ethereum.FilterQuery{
@@ -60,6 +65,7 @@ func (consumer *allowanceBlockConsumer) Consume(delivery channels.Delivery) {
 				log.Printf("Unexpected log data. Skipping.")
 				continue
 			}
+			consumer.invalidate(invalidation, approvalLog)
 			balance := big.NewInt(0)
 			balance.SetBytes(approvalLog.Data)
 			sr := &db.SpendRecord{
@@ -98,6 +104,7 @@ func (consumer *allowanceBlockConsumer) Consume(delivery channels.Delivery) {
 				log.Printf("Unexpected log data. Skipping.")
 				continue
 			}
+			consumer.invalidate(invalidation, approvalLog)
 			balance := big.NewInt(0)
 			balance.SetBytes(approvalLog.Data)
 			sr := &db.SpendRecord{
@@ -116,16 +123,69 @@ func (consumer *allowanceBlockConsumer) Consume(delivery channels.Delivery) {
 	} else {
 		log.Printf("Block 0x%x shows no approval events", block.Hash)
 	}
+	if consumer.invalidationPublisher != nil && coreTypes.BloomLookup(block.Bloom, consumer.approvalForAllTopic) {
+		// Operator approvals of ERC721 tokens don't need SpendRecords, which the
+		// ERC721 approval monitor takes care of, but cached approvals must go
+		query := ethereum.FilterQuery{
+			FromBlock: block.Number,
+			ToBlock:   block.Number,
+			Addresses: nil,
+			Topics: [][]common.Hash{
+				[]common.Hash{common.BigToHash(consumer.approvalForAllTopic)},
+			},
+		}
+		logs, err := consumer.logFilter.FilterLogs(context.Background(), query)
+		if err != nil {
+			delivery.Return()
+			log.Fatalf("Failed to filter logs on block %v - aborting: %v", block.Number, err.Error())
+		}
+		for _, approvalLog := range logs {
+			if len(approvalLog.Topics) != 3 {
+				continue
+			}
+			tokenAddress := &types.Address{}
+			ownerAddress := &types.Address{}
+			operatorAddress := &types.Address{}
+			copy(tokenAddress[:], approvalLog.Address[:])
+			copy(ownerAddress[:], approvalLog.Topics[1][12:])
+			copy(operatorAddress[:], approvalLog.Topics[2][12:])
+			invalidation.AddOperator(tokenAddress, ownerAddress, operatorAddress)
+		}
+	}
+	if err := invalidation.Publish(consumer.invalidationPublisher); err != nil {
+		log.Printf("Error publishing cache invalidation on block %v: %v", block.Number, err.Error())
+	}
 	delivery.Ack()
 }
 
-func NewAllowanceBlockConsumer(bdp *big.Int, tp *big.Int, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher) channels.Consumer {
+// invalidate records the allowance changed by an Approval(owner, spender,
+// value) log
+func (consumer *allowanceBlockConsumer) invalidate(invalidation *balance.CacheInvalidation, approvalLog coreTypes.Log) {
+	if len(approvalLog.Topics) < 3 {
+		return
+	}
+	tokenAddress := &types.Address{}
+	ownerAddress := &types.Address{}
+	spenderAddress := &types.Address{}
+	copy(tokenAddress[:], approvalLog.Address[:])
+	copy(ownerAddress[:], approvalLog.Topics[1][12:])
+	copy(spenderAddress[:], approvalLog.Topics[2][12:])
+	invalidation.AddAllowance(orCommon.ToERC20AssetData(tokenAddress), ownerAddress, spenderAddress)
+}
+
+// NewAllowanceBlockConsumer creates a consumer that publishes SpendRecords
+// for proxy approvals to publisher. If invalidationPublisher is not nil, the
+// allowance cache entries affected by each block's approvals, including ERC721
+// operator approvals, are published to it.
+func NewAllowanceBlockConsumer(bdp *big.Int, tp *big.Int, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher, invalidationPublisher channels.Publisher) channels.Consumer {
 	approvalTopic := &big.Int{}
 	approvalTopic.SetString("8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925", 16)
-	return &allowanceBlockConsumer{bdp, tp, approvalTopic, feeToken, lf, publisher}
+	approvalForAllTopic := &big.Int{}
+	approvalForAllTopic.SetString("17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31", 16)
+	return &allowanceBlockConsumer{bdp, tp, approvalTopic, feeToken, lf, publisher, invalidationPublisher, approvalForAllTopic}
 }
 
-func NewRPCAllowanceBlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher) (channels.Consumer, error) {
+func NewRPCAllowanceBlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher, invalidationPublisher channels.Publisher) (channels.Consumer, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, err
@@ -153,5 +213,5 @@ func NewRPCAllowanceBlockConsumer(rpcURL string, exchangeAddress string, publish
 		log.Printf("error getting tokenProxyAddress")
 		return nil, err
 	}
-	return NewAllowanceBlockConsumer(roboDexProxyAddress.Big(), tokenProxyAddress.Big(), feeTokenAddress.String(), client, publisher), nil
+	return NewAllowanceBlockConsumer(roboDexProxyAddress.Big(), tokenProxyAddress.Big(), feeTokenAddress.String(), client, publisher, invalidationPublisher), nil
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/notegio/openrelay/monitor/blocks/mock"
 	"github.com/notegio/openrelay/channels"
 	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds/balance"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/common"
 	// "log"
@@ -92,6 +93,7 @@ func TestAllowanceFromBlock(t *testing.T) {
 		"0x4444444444444444444444444444444444444444",
 		mock.NewMockLogFilterer([]types.Log{*testLog}),
 		destPublisher,
+		nil,
 	))
 	consumerChannel.StartConsuming()
 	defer consumerChannel.StopConsuming()
@@ -138,6 +140,7 @@ func TestNoAllowanceInBlock(t *testing.T) {
 		"0x4444444444444444444444444444444444444444",
 		mock.NewMockLogFilterer([]types.Log{}),
 		destPublisher,
+		nil,
 	))
 	consumerChannel.StartConsuming()
 	defer consumerChannel.StopConsuming()
@@ -148,3 +151,103 @@ func TestNoAllowanceInBlock(t *testing.T) {
 		default:
 	}
 }
+
+func TestAllowanceInvalidation(t *testing.T) {
+	testLog := allowanceLog()
+	bloom := types.BytesToBloom(types.LogsBloom([]*types.Log{testLog}).Bytes())
+	mb := &blocks.MiniBlock{
+		common.Hash{},
+		big.NewInt(0),
+		bloom,
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, _ := channels.MockChannel()
+	invalidationPublisher, invalidationConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	tc := newTestConsumer()
+	invalidationConsumerChannel.AddConsumer(tc)
+	invalidationConsumerChannel.StartConsuming()
+	defer invalidationConsumerChannel.StopConsuming()
+	consumerChannel.AddConsumer(allowance.NewAllowanceBlockConsumer(
+		common.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48").Big(),
+		common.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48").Big(),
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer([]types.Log{*testLog}),
+		destPublisher,
+		invalidationPublisher,
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	payload := <-tc.channel
+	invalidation := &balance.CacheInvalidation{}
+	if err := json.Unmarshal([]byte(payload), invalidation); err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := "a-0xf47261b00000000000000000000000001d7022f5b17d2f8b695918fb48fa1089c9f85401-0x5409ed021d9299bf6814279a6a1411a7e866a631-0x1dc4c1cefef38a777b15aa20260a54e584b16c48"
+	if len(invalidation.Keys) != 1 || invalidation.Keys[0] != expected {
+		t.Errorf("Unexpected invalidation keys: %v", invalidation.Keys)
+	}
+}
+
+func TestOperatorApprovalInvalidation(t *testing.T) {
+	approvalForAllTopic := &big.Int{}
+	approvalForAllTopic.SetString("17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31", 16)
+	testLog := buildLog(
+		common.HexToAddress("0x06012c8cf97bead5deae237070f9587f8e7a266d"),
+		[]common.Hash{
+			common.BigToHash(approvalForAllTopic),
+			common.BigToHash(common.HexToAddress("0x5409ed021d9299bf6814279a6a1411a7e866a631").Big()),
+			common.BigToHash(common.HexToAddress("0x2a9127c745688a165106c11cd4d647d2220af821").Big()),
+		},
+		common.BigToHash(big.NewInt(1)).Bytes(),
+	)
+	mb := &blocks.MiniBlock{
+		common.Hash{},
+		big.NewInt(0),
+		types.BytesToBloom(types.LogsBloom([]*types.Log{testLog}).Bytes()),
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, destConsumerChannel := channels.MockChannel()
+	invalidationPublisher, invalidationConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	records := newTestConsumer()
+	destConsumerChannel.AddConsumer(records)
+	destConsumerChannel.StartConsuming()
+	defer destConsumerChannel.StopConsuming()
+	tc := newTestConsumer()
+	invalidationConsumerChannel.AddConsumer(tc)
+	invalidationConsumerChannel.StartConsuming()
+	defer invalidationConsumerChannel.StopConsuming()
+	consumerChannel.AddConsumer(allowance.NewAllowanceBlockConsumer(
+		common.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48").Big(),
+		common.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48").Big(),
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer([]types.Log{*testLog}),
+		destPublisher,
+		invalidationPublisher,
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	payload := <-tc.channel
+	invalidation := &balance.CacheInvalidation{}
+	if err := json.Unmarshal([]byte(payload), invalidation); err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := "o-0x06012c8cf97bead5deae237070f9587f8e7a266d-0x5409ed021d9299bf6814279a6a1411a7e866a631-0x2a9127c745688a165106c11cd4d647d2220af821"
+	if len(invalidation.Keys) != 1 || invalidation.Keys[0] != expected {
+		t.Errorf("Unexpected invalidation keys: %v", invalidation.Keys)
+	}
+	select {
+	case payload := <-records.channel:
+		t.Errorf("Unexpected spend record: %v", payload)
+	default:
+	}
+}
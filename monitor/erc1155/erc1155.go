@@ -0,0 +1,235 @@
+package erc1155
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coreTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/funds/balance"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/types"
+)
+
+type erc1155BlockConsumer struct {
+	tokenProxyAddress   *types.Address
+	transferSingleTopic *big.Int
+	transferBatchTopic  *big.Int
+	approveAllTopic     *big.Int
+	feeTokenAddress     string // Needed for the SpendRecord,
+	logFilter           ethereum.LogFilterer
+	publisher           channels.Publisher
+	balanceChecker      balance.BalanceChecker
+}
+
+type erc1155Transfer struct {
+	token *types.Address
+	from  *types.Address
+	id    *big.Int
+}
+
+// decodeTransfers extracts the sender, token address and token ids from
+// TransferSingle(operator, from, to, id, value) and
+// TransferBatch(operator, from, to, ids, values) logs.
+func (consumer *erc1155BlockConsumer) decodeTransfers(transferLog coreTypes.Log) ([]erc1155Transfer, error) {
+	if len(transferLog.Topics) != 4 {
+		return nil, fmt.Errorf("Expected 4 topics, got %v", len(transferLog.Topics))
+	}
+	token := &types.Address{}
+	from := &types.Address{}
+	copy(token[:], transferLog.Address[:])
+	copy(from[:], transferLog.Topics[2][12:])
+	topic := new(big.Int).SetBytes(transferLog.Topics[0][:])
+	if topic.Cmp(consumer.transferSingleTopic) == 0 {
+		if len(transferLog.Data) < 64 {
+			return nil, fmt.Errorf("Unexpected TransferSingle data length %v", len(transferLog.Data))
+		}
+		return []erc1155Transfer{erc1155Transfer{token, from, new(big.Int).SetBytes(transferLog.Data[:32])}}, nil
+	}
+	// TransferBatch data is (uint256[] ids, uint256[] values). Only the ids
+	// matter to us.
+	if len(transferLog.Data) < 64 {
+		return nil, fmt.Errorf("Unexpected TransferBatch data length %v", len(transferLog.Data))
+	}
+	offset := new(big.Int).SetBytes(transferLog.Data[:32])
+	if !offset.IsInt64() || offset.Int64()+32 > int64(len(transferLog.Data)) {
+		return nil, fmt.Errorf("Invalid TransferBatch ids offset %v", offset)
+	}
+	start := int(offset.Int64())
+	count := new(big.Int).SetBytes(transferLog.Data[start : start+32])
+	if !count.IsInt64() || int64(start)+32+32*count.Int64() > int64(len(transferLog.Data)) {
+		return nil, fmt.Errorf("Invalid TransferBatch ids length %v", count)
+	}
+	transfers := make([]erc1155Transfer, int(count.Int64()))
+	for i := range transfers {
+		idStart := start + 32 + 32*i
+		transfers[i] = erc1155Transfer{token, from, new(big.Int).SetBytes(transferLog.Data[idStart : idStart+32])}
+	}
+	return transfers, nil
+}
+
+func (consumer *erc1155BlockConsumer) publish(sr *db.SpendRecord) error {
+	msg, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	consumer.publisher.Publish(string(msg))
+	return nil
+}
+
+func (consumer *erc1155BlockConsumer) Consume(delivery channels.Delivery) {
+	block := &blocks.MiniBlock{}
+	err := json.Unmarshal([]byte(delivery.Payload()), block)
+	if err != nil {
+		log.Printf("Error parsing payload: %v\n", err.Error())
+	}
+	if !coreTypes.BloomLookup(block.Bloom, consumer.transferSingleTopic) &&
+		!coreTypes.BloomLookup(block.Bloom, consumer.transferBatchTopic) &&
+		!(coreTypes.BloomLookup(block.Bloom, consumer.approveAllTopic) && coreTypes.BloomLookup(block.Bloom, common.BytesToHash(consumer.tokenProxyAddress[:]))) {
+		log.Printf("Block %#x shows no ERC1155 events", block.Hash)
+		delivery.Ack()
+		return
+	}
+	log.Printf("Block %#x bloom filter indicates ERC1155 events", block.Hash)
+	query := ethereum.FilterQuery{
+		FromBlock: block.Number,
+		ToBlock:   block.Number,
+		Addresses: nil,
+		Topics: [][]common.Hash{
+			[]common.Hash{
+				common.BigToHash(consumer.transferSingleTopic),
+				common.BigToHash(consumer.transferBatchTopic),
+				common.BigToHash(consumer.approveAllTopic),
+			},
+		},
+	}
+	logs, err := consumer.logFilter.FilterLogs(context.Background(), query)
+	if err != nil {
+		delivery.Return()
+		log.Fatalf("Failed to filter logs on block %v - aborting: %v", block.Number, err.Error())
+	}
+	log.Printf("Found %v ERC1155 logs", len(logs))
+	checked := make(map[string]struct{})
+	for _, eventLog := range logs {
+		if new(big.Int).SetBytes(eventLog.Topics[0][:]).Cmp(consumer.approveAllTopic) == 0 {
+			// ApprovalForAll(owner, operator, approved)
+			if len(eventLog.Topics) != 3 || len(eventLog.Data) < 32 {
+				continue
+			}
+			if !bytes32IsAddress(eventLog.Topics[2], consumer.tokenProxyAddress) || new(big.Int).SetBytes(eventLog.Data[:32]).Sign() != 0 {
+				// Only revoking the proxy's approval can make an order unfillable
+				continue
+			}
+			sr := &db.SpendRecord{
+				AssetData:      "",
+				TokenAddress:   strings.ToLower(eventLog.Address.String()),
+				SpenderAddress: hexutil.Encode(eventLog.Topics[1][12:]),
+				ZrxToken:       consumer.feeTokenAddress,
+				Balance:        "0",
+			}
+			if err := consumer.publish(sr); err != nil {
+				delivery.Return()
+				log.Fatalf("Failed to encode SpendRecord on block %v", block.Number)
+			}
+			continue
+		}
+		transfers, err := consumer.decodeTransfers(eventLog)
+		if err != nil {
+			log.Printf("Unexpected log data: %v. Skipping.", err.Error())
+			continue
+		}
+		for _, transfer := range transfers {
+			if *transfer.from == (types.Address{}) {
+				// Mints can't reduce anyone's balance
+				continue
+			}
+			assetData := types.ERC1155ItemAssetData(transfer.token, transfer.id)
+			checkKey := fmt.Sprintf("%#x:%#x", transfer.from[:], assetData[:])
+			if _, ok := checked[checkKey]; ok {
+				// We already checked this balance as of the end of the block
+				continue
+			}
+			checked[checkKey] = struct{}{}
+			balance, err := consumer.balanceChecker.GetBalance(assetData, transfer.from)
+			if err != nil {
+				delivery.Return()
+				log.Fatalf("Failed to get balance for '%#x' - '%#x': %v", assetData[:], transfer.from[:], err.Error())
+			}
+			allowance, err := consumer.balanceChecker.GetAllowance(assetData, transfer.from, consumer.tokenProxyAddress)
+			if err != nil {
+				delivery.Return()
+				log.Fatalf("Failed to get allowance for '%#x' - '%#x': %v", assetData[:], transfer.from[:], err.Error())
+			}
+			if allowance.Cmp(balance) < 0 {
+				balance = allowance
+			}
+			sr := &db.SpendRecord{
+				TokenAddress:   strings.ToLower(eventLog.Address.String()),
+				AssetData:      hexutil.Encode(assetData[:]),
+				SpenderAddress: hexutil.Encode(transfer.from[:]),
+				ZrxToken:       consumer.feeTokenAddress,
+				Balance:        balance.String(),
+			}
+			if err := consumer.publish(sr); err != nil {
+				delivery.Return()
+				log.Fatalf("Failed to encode SpendRecord on block %v", block.Number)
+			}
+		}
+	}
+	delivery.Ack()
+}
+
+func bytes32IsAddress(data common.Hash, address *types.Address) bool {
+	return common.BytesToAddress(data[12:]) == common.BytesToAddress(address[:])
+}
+
+// NewERC1155BlockConsumer creates a consumer that publishes SpendRecords when
+// ERC1155 tokens are transferred out of an account, or when an account revokes
+// the ERC1155 proxy's approval.
+func NewERC1155BlockConsumer(tp *types.Address, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher, bc balance.BalanceChecker) channels.Consumer {
+	transferSingleTopic := &big.Int{}
+	transferBatchTopic := &big.Int{}
+	approveAllTopic := &big.Int{}
+	transferSingleTopic.SetString("c3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62", 16)
+	transferBatchTopic.SetString("4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb", 16)
+	approveAllTopic.SetString("17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31", 16)
+	return &erc1155BlockConsumer{tp, transferSingleTopic, transferBatchTopic, approveAllTopic, feeToken, lf, publisher, bc}
+}
+
+func NewRPCERC1155BlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher) (channels.Consumer, error) {
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	exchange, err := exchangecontract.NewExchange(common.HexToAddress(exchangeAddress), client)
+	if err != nil {
+		log.Printf("Error intializing exchange contract '%v': '%v'", exchangeAddress, err.Error())
+		return nil, err
+	}
+	feeTokenAssetData, err := exchange.ZRX_ASSET_DATA(nil)
+	if err != nil {
+		log.Printf("Error getting fee token address for exchange %v", exchangeAddress)
+		return nil, err
+	}
+	feeTokenAsset := make(types.AssetData, len(feeTokenAssetData))
+	copy(feeTokenAsset[:], feeTokenAssetData[:])
+	feeTokenAddress := feeTokenAsset.Address()
+	tokenProxyAddress, err := exchange.GetAssetProxy(nil, types.ERC1155ProxyID)
+	if err != nil {
+		log.Printf("error getting tokenProxyAddress")
+		return nil, err
+	}
+	tokenProxyAddressOr := &types.Address{}
+	copy(tokenProxyAddressOr[:], tokenProxyAddress[:])
+	return NewERC1155BlockConsumer(tokenProxyAddressOr, feeTokenAddress.String(), client, publisher, balance.NewRpcERC1155BalanceChecker(client)), nil
+}
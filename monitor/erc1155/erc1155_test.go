@@ -0,0 +1,175 @@
+package erc1155_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/notegio/openrelay/channels"
+	"github.com/notegio/openrelay/db"
+	"github.com/notegio/openrelay/funds/balance"
+	"github.com/notegio/openrelay/monitor/blocks"
+	"github.com/notegio/openrelay/monitor/blocks/mock"
+	"github.com/notegio/openrelay/monitor/erc1155"
+	orTypes "github.com/notegio/openrelay/types"
+)
+
+type testConsumer struct {
+	channel chan string
+}
+
+func (consumer *testConsumer) Consume(msg channels.Delivery) {
+	consumer.channel <- msg.Payload()
+}
+
+func newTestConsumer() *testConsumer {
+	return &testConsumer{make(chan string, 5)}
+}
+
+var tokenAddress = common.HexToAddress("0x3495ffcee09012ab7d827abf3e3b3ae428a38443")
+var senderAddress = common.HexToAddress("0x34ab4a96678c4de8eb34597dbbcf09c27d9bc79d")
+var receiverAddress = common.HexToAddress("0x12459c951127e0c374ff9105dda097662a027093")
+var proxyAddress = common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+func topic(hexTopic string) common.Hash {
+	value, _ := new(big.Int).SetString(hexTopic, 16)
+	return common.BigToHash(value)
+}
+
+func transferBatchLog(ids []int64) *types.Log {
+	data := append([]byte{}, abi.U256(big.NewInt(64))...)
+	data = append(data, abi.U256(big.NewInt(int64(96+32*len(ids))))...)
+	data = append(data, abi.U256(big.NewInt(int64(len(ids))))...)
+	for _, id := range ids {
+		data = append(data, abi.U256(big.NewInt(id))...)
+	}
+	data = append(data, abi.U256(big.NewInt(int64(len(ids))))...)
+	for range ids {
+		data = append(data, abi.U256(big.NewInt(5))...)
+	}
+	return &types.Log{
+		Address: tokenAddress,
+		Topics: []common.Hash{
+			topic("4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"),
+			common.BigToHash(receiverAddress.Big()),
+			common.BigToHash(senderAddress.Big()),
+			common.BigToHash(receiverAddress.Big()),
+		},
+		Data: data,
+	}
+}
+
+func approvalForAllLog(operator common.Address, approved int64) *types.Log {
+	return &types.Log{
+		Address: tokenAddress,
+		Topics: []common.Hash{
+			topic("17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c31"),
+			common.BigToHash(senderAddress.Big()),
+			common.BigToHash(operator.Big()),
+		},
+		Data: abi.U256(big.NewInt(approved)),
+	}
+}
+
+func runConsumer(t *testing.T, logs []types.Log, balanceMap map[string]map[orTypes.Address]*big.Int, expected uint) []*db.SpendRecord {
+	logPointers := []*types.Log{}
+	for i := range logs {
+		logPointers = append(logPointers, &logs[i])
+	}
+	mb := &blocks.MiniBlock{
+		common.Hash{},
+		big.NewInt(0),
+		types.BytesToBloom(types.LogsBloom(logPointers).Bytes()),
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, destConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tc := newTestConsumer()
+	destConsumerChannel.AddConsumer(tc)
+	destConsumerChannel.StartConsuming()
+	defer destConsumerChannel.StopConsuming()
+	tokenProxyAddress := &orTypes.Address{}
+	copy(tokenProxyAddress[:], proxyAddress[:])
+	consumerChannel.AddConsumer(erc1155.NewERC1155BlockConsumer(
+		tokenProxyAddress,
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer(logs),
+		destPublisher,
+		balance.NewMockBalanceChecker(balanceMap),
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	channels.MockFinish(consumerChannel, 1)
+	channels.MockFinish(destConsumerChannel, expected)
+	records := []*db.SpendRecord{}
+	for {
+		select {
+		case payload := <-tc.channel:
+			sr := &db.SpendRecord{}
+			if err := json.Unmarshal([]byte(payload), sr); err != nil {
+				t.Fatalf(err.Error())
+			}
+			records = append(records, sr)
+		default:
+			return records
+		}
+	}
+}
+
+func TestTransferBatch(t *testing.T) {
+	token := &orTypes.Address{}
+	sender := &orTypes.Address{}
+	copy(token[:], tokenAddress[:])
+	copy(sender[:], senderAddress[:])
+	balanceMap := make(map[string]map[orTypes.Address]*big.Int)
+	for id, amount := range map[int64]int64{1: 7, 2: 3} {
+		assetData := orTypes.ERC1155ItemAssetData(token, big.NewInt(id))
+		balanceMap[string(assetData)] = map[orTypes.Address]*big.Int{*sender: big.NewInt(amount)}
+	}
+	records := runConsumer(t, []types.Log{*transferBatchLog([]int64{1, 2, 1})}, balanceMap, 2)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 spend records, got %v", len(records))
+	}
+	for i, id := range []int64{1, 2} {
+		expectedAssetData := hexutil.Encode(orTypes.ERC1155ItemAssetData(token, big.NewInt(id)))
+		if records[i].AssetData != expectedAssetData {
+			t.Errorf("Unexpected asset data, got '%v'", records[i].AssetData)
+		}
+		if records[i].SpenderAddress != "0x34ab4a96678c4de8eb34597dbbcf09c27d9bc79d" {
+			t.Errorf("Unexpected spender address, got '%v'", records[i].SpenderAddress)
+		}
+		if records[i].TokenAddress != "0x3495ffcee09012ab7d827abf3e3b3ae428a38443" {
+			t.Errorf("Unexpected token address, got '%v'", records[i].TokenAddress)
+		}
+	}
+	if records[0].Balance != "7" || records[1].Balance != "3" {
+		t.Errorf("Unexpected balances '%v', '%v'", records[0].Balance, records[1].Balance)
+	}
+}
+
+func TestApprovalForAllRevoked(t *testing.T) {
+	otherOperator := common.HexToAddress("0x5555555555555555555555555555555555555555")
+	logs := []types.Log{
+		*approvalForAllLog(proxyAddress, 1),
+		*approvalForAllLog(otherOperator, 0),
+		*approvalForAllLog(proxyAddress, 0),
+	}
+	records := runConsumer(t, logs, map[string]map[orTypes.Address]*big.Int{}, 1)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 spend record, got %v", len(records))
+	}
+	if records[0].AssetData != "" || records[0].Balance != "0" {
+		t.Errorf("Unexpected spend record %v", records[0])
+	}
+	if records[0].SpenderAddress != "0x34ab4a96678c4de8eb34597dbbcf09c27d9bc79d" {
+		t.Errorf("Unexpected spender address, got '%v'", records[0].SpenderAddress)
+	}
+}
@@ -28,6 +28,9 @@ type spendBlockConsumer struct {
 	logFilter          ethereum.LogFilterer
 	publisher          channels.Publisher
 	balanceChecker     balance.BalanceChecker
+	invalidationPublisher channels.Publisher
+	depositTopic       *big.Int
+	withdrawalTopic    *big.Int
 }
 
 func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
@@ -36,6 +39,7 @@ func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
 	if err != nil {
 		log.Printf("Error parsing payload: %v\n", err.Error())
 	}
+	invalidation := &balance.CacheInvalidation{}
 	if coreTypes.BloomLookup(block.Bloom, consumer.spendTopic) {
 		log.Printf("Block %#x bloom filter indicates spend event", block.Hash)
 		query := ethereum.FilterQuery{
@@ -61,6 +65,7 @@ func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
 			// 	continue
 			// }
 			senderAddress := &types.Address{}
+			recipientAddress := &types.Address{}
 			tokenAddress := &types.Address{}
 			var tokenAssetData types.AssetData
 
@@ -69,16 +74,19 @@ func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
 			if len(spendLog.Topics) == 0  && len(spendLog.Data) >= 96{
 				// CryptoKitties Style ERC721
 				copy(senderAddress[:], spendLog.Data[12:32])
+				copy(recipientAddress[:], spendLog.Data[44:64])
 				tokenID := &types.Uint256{}
 				copy(tokenID[:], spendLog.Data[len(spendLog.Data)-32:])
 				tokenAssetData = orCommon.ToERC721AssetData(tokenAddress, tokenID)
 			} else if len(spendLog.Topics) == 3 {
 				// ERC20
 				copy(senderAddress[:], spendLog.Topics[1][12:])
+				copy(recipientAddress[:], spendLog.Topics[2][12:])
 				tokenAssetData = orCommon.ToERC20AssetData(tokenAddress)
 			} else if len(spendLog.Topics) == 4 {
 				// ERC721
 				copy(senderAddress[:], spendLog.Topics[1][12:])
+				copy(recipientAddress[:], spendLog.Topics[2][12:])
 				tokenID := &types.Uint256{}
 				copy(tokenID[:], spendLog.Topics[3][:])
 				tokenAssetData = orCommon.ToERC721AssetData(tokenAddress, tokenID)
@@ -87,6 +95,12 @@ func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
 				continue
 			}
 
+			// Both parties' balances changed, and the proxy may have used up some
+			// of the sender's allowance.
+			invalidation.AddBalance(tokenAssetData, senderAddress)
+			invalidation.AddBalance(tokenAssetData, recipientAddress)
+			invalidation.AddAllowance(tokenAssetData, senderAddress, consumer.tokenProxyAddress)
+
 			pairKey := fmt.Sprintf("%#x:%#x", senderAddress, tokenAddress)
 			if _, ok := tradedTokens[pairKey]; ok {
 				// If the same account sent the same token multiple times in a single
@@ -154,16 +168,54 @@ func (consumer *spendBlockConsumer) Consume(delivery channels.Delivery) {
 	} else {
 		log.Printf("Block %v shows no spend events", block.Hash)
 	}
+	if consumer.invalidationPublisher != nil && (coreTypes.BloomLookup(block.Bloom, consumer.depositTopic) || coreTypes.BloomLookup(block.Bloom, consumer.withdrawalTopic)) {
+		// Wrapping and unwrapping WETH changes balances without a Transfer log.
+		// Other contracts logging the same events only cost an extra lookup.
+		query := ethereum.FilterQuery{
+			FromBlock: block.Number,
+			ToBlock: block.Number,
+			Addresses: nil,
+			Topics: [][]common.Hash{
+				[]common.Hash{common.BigToHash(consumer.depositTopic), common.BigToHash(consumer.withdrawalTopic)},
+			},
+		}
+		logs, err := consumer.logFilter.FilterLogs(context.Background(), query)
+		if err != nil {
+			delivery.Return()
+			log.Fatalf("Failed to filter logs on block %v - aborting: %v", block.Number, err.Error())
+		}
+		for _, wrapLog := range logs {
+			if len(wrapLog.Topics) != 2 {
+				continue
+			}
+			tokenAddress := &types.Address{}
+			ownerAddress := &types.Address{}
+			copy(tokenAddress[:], wrapLog.Address[:])
+			copy(ownerAddress[:], wrapLog.Topics[1][12:])
+			invalidation.AddBalance(orCommon.ToERC20AssetData(tokenAddress), ownerAddress)
+		}
+	}
+	if err := invalidation.Publish(consumer.invalidationPublisher); err != nil {
+		log.Printf("Error publishing cache invalidation on block %v: %v", block.Number, err.Error())
+	}
 	delivery.Ack()
 }
 
-func NewSpendBlockConsumer(tp *types.Address, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher, bc balance.BalanceChecker) (channels.Consumer) {
+// NewSpendBlockConsumer creates a consumer that publishes SpendRecords for
+// token transfers to publisher. If invalidationPublisher is not nil, the
+// balance cache entries affected by each block's transfers, deposits and
+// withdrawals are published to it.
+func NewSpendBlockConsumer(tp *types.Address, feeToken string, lf ethereum.LogFilterer, publisher channels.Publisher, bc balance.BalanceChecker, invalidationPublisher channels.Publisher) (channels.Consumer) {
 	spendTopic := &big.Int{}
 	spendTopic.SetString("ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", 16)
-	return &spendBlockConsumer{tp, spendTopic, feeToken, lf, publisher, bc}
+	depositTopic := &big.Int{}
+	depositTopic.SetString("e1fffcc4923d04b559f4d29a8bfc6cda04eb5b0d3c460751c2402c5c5cc9109c", 16)
+	withdrawalTopic := &big.Int{}
+	withdrawalTopic.SetString("7fcf532c15f0a6db0bd6d0e038bea71d30d808c7d98cb3bf7268a95bf5081b65", 16)
+	return &spendBlockConsumer{tp, spendTopic, feeToken, lf, publisher, bc, invalidationPublisher, depositTopic, withdrawalTopic}
 }
 
-func NewRPCSpendBlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher) (channels.Consumer, error) {
+func NewRPCSpendBlockConsumer(rpcURL string, exchangeAddress string, publisher channels.Publisher, invalidationPublisher channels.Publisher) (channels.Consumer, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, err
@@ -193,5 +245,5 @@ func NewRPCSpendBlockConsumer(rpcURL string, exchangeAddress string, publisher c
 		log.Printf("Error getting balance checker")
 		return nil, err
 	}
-	return NewSpendBlockConsumer(tokenProxyAddressOr, feeTokenAddress.String(), client, publisher, balanceChecker), nil
+	return NewSpendBlockConsumer(tokenProxyAddressOr, feeTokenAddress.String(), client, publisher, balanceChecker, invalidationPublisher), nil
 }
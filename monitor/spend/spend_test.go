@@ -103,6 +103,7 @@ func TestSpendFromBlock(t *testing.T) {
 		mock.NewMockLogFilterer([]types.Log{*testLog}),
 		destPublisher,
 		balance.NewMockBalanceChecker(balanceMap),
+		nil,
 	))
 	consumerChannel.StartConsuming()
 	defer consumerChannel.StopConsuming()
@@ -152,6 +153,7 @@ func TestNoSpendInBlock(t *testing.T) {
 		mock.NewMockLogFilterer([]types.Log{*testLog}),
 		destPublisher,
 		balance.NewMockBalanceChecker(make(map[string]map[orTypes.Address]*big.Int)),
+		nil,
 	))
 	consumerChannel.StartConsuming()
 	defer consumerChannel.StopConsuming()
@@ -162,3 +164,137 @@ func TestNoSpendInBlock(t *testing.T) {
 		default:
 	}
 }
+
+func TestSpendInvalidation(t *testing.T) {
+	testLog := spendLog()
+	bloom := types.BytesToBloom(types.LogsBloom([]*types.Log{testLog}).Bytes())
+	mb := &blocks.MiniBlock{
+		common.Hash{},
+		big.NewInt(0),
+		bloom,
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, _ := channels.MockChannel()
+	invalidationPublisher, invalidationConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	tc := newTestConsumer()
+	invalidationConsumerChannel.AddConsumer(tc)
+	invalidationConsumerChannel.StartConsuming()
+	defer invalidationConsumerChannel.StopConsuming()
+	tokenProxyAddress, _ := orCommon.HexToAddress("0x3333333333333333333333333333333333333333")
+	tokenAddress, _ := orCommon.HexToAddress("0x3495ffcee09012ab7d827abf3e3b3ae428a38443")
+	spenderAddress, _ := orCommon.HexToAddress("0x34ab4a96678c4de8eb34597dbbcf09c27d9bc79d")
+	receiverAddress, _ := orCommon.HexToAddress("0x12459c951127e0c374ff9105dda097662a027093")
+	assetData := orCommon.ToERC20AssetData(tokenAddress)
+	balanceMap := make(map[string]map[orTypes.Address]*big.Int)
+	balanceMap[string(assetData)] = make(map[orTypes.Address]*big.Int)
+	balanceMap[string(assetData)][*spenderAddress] = big.NewInt(0)
+	consumerChannel.AddConsumer(spend.NewSpendBlockConsumer(tokenProxyAddress,
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer([]types.Log{*testLog}),
+		destPublisher,
+		balance.NewMockBalanceChecker(balanceMap),
+		invalidationPublisher,
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	payload := <-tc.channel
+	invalidation := &balance.CacheInvalidation{}
+	if err := json.Unmarshal([]byte(payload), invalidation); err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := []string{
+		balance.BalanceKey(assetData, spenderAddress),
+		balance.BalanceKey(assetData, receiverAddress),
+		balance.AllowanceKey(assetData, spenderAddress, tokenProxyAddress),
+	}
+	if len(invalidation.Keys) != len(expected) {
+		t.Fatalf("Expected %v keys, got %v", len(expected), invalidation.Keys)
+	}
+	for i, key := range expected {
+		if invalidation.Keys[i] != key {
+			t.Errorf("Expected key %v, got %v", key, invalidation.Keys[i])
+		}
+	}
+}
+
+func wrapLog(topic string, owner common.Address) *types.Log {
+	wrapTopic, _ := new(big.Int).SetString(topic, 16)
+	topics := []common.Hash{
+		common.BigToHash(wrapTopic),
+		common.BigToHash(owner.Big()),
+	}
+	data := common.HexToHash("0x0000000000000000000000000000000000000000000000006f05b59d3b200000")
+	return buildLog(common.HexToAddress("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"), topics, data[:])
+}
+
+func TestWrapInvalidation(t *testing.T) {
+	depositor := common.HexToAddress("0x34ab4a96678c4de8eb34597dbbcf09c27d9bc79d")
+	withdrawer := common.HexToAddress("0x12459c951127e0c374ff9105dda097662a027093")
+	logs := []*types.Log{
+		wrapLog("e1fffcc4923d04b559f4d29a8bfc6cda04eb5b0d3c460751c2402c5c5cc9109c", depositor),
+		wrapLog("7fcf532c15f0a6db0bd6d0e038bea71d30d808c7d98cb3bf7268a95bf5081b65", withdrawer),
+	}
+	mb := &blocks.MiniBlock{
+		common.Hash{},
+		big.NewInt(0),
+		types.BytesToBloom(types.LogsBloom(logs).Bytes()),
+	}
+	srcPublisher, consumerChannel := channels.MockChannel()
+	destPublisher, destConsumerChannel := channels.MockChannel()
+	invalidationPublisher, invalidationConsumerChannel := channels.MockChannel()
+	data, err := json.Marshal(mb)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	records := newTestConsumer()
+	destConsumerChannel.AddConsumer(records)
+	destConsumerChannel.StartConsuming()
+	defer destConsumerChannel.StopConsuming()
+	tc := newTestConsumer()
+	invalidationConsumerChannel.AddConsumer(tc)
+	invalidationConsumerChannel.StartConsuming()
+	defer invalidationConsumerChannel.StopConsuming()
+	tokenProxyAddress, _ := orCommon.HexToAddress("0x3333333333333333333333333333333333333333")
+	consumerChannel.AddConsumer(spend.NewSpendBlockConsumer(tokenProxyAddress,
+		"0x4444444444444444444444444444444444444444",
+		mock.NewMockLogFilterer([]types.Log{*logs[0], *logs[1]}),
+		destPublisher,
+		balance.NewMockBalanceChecker(make(map[string]map[orTypes.Address]*big.Int)),
+		invalidationPublisher,
+	))
+	consumerChannel.StartConsuming()
+	defer consumerChannel.StopConsuming()
+	srcPublisher.Publish(string(data))
+	payload := <-tc.channel
+	invalidation := &balance.CacheInvalidation{}
+	if err := json.Unmarshal([]byte(payload), invalidation); err != nil {
+		t.Fatalf(err.Error())
+	}
+	wethAddress, _ := orCommon.HexToAddress("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	depositorAddress, _ := orCommon.HexToAddress(depositor.Hex())
+	withdrawerAddress, _ := orCommon.HexToAddress(withdrawer.Hex())
+	weth := orCommon.ToERC20AssetData(wethAddress)
+	expected := []string{
+		balance.BalanceKey(weth, depositorAddress),
+		balance.BalanceKey(weth, withdrawerAddress),
+	}
+	if len(invalidation.Keys) != len(expected) {
+		t.Fatalf("Expected %v keys, got %v", len(expected), invalidation.Keys)
+	}
+	for i, key := range expected {
+		if invalidation.Keys[i] != key {
+			t.Errorf("Expected key %v, got %v", key, invalidation.Keys[i])
+		}
+	}
+	// Wrapping only invalidates cached balances; it doesn't spend anything
+	select {
+	case payload := <-records.channel:
+		t.Errorf("Unexpected spend record: %v", payload)
+	default:
+	}
+}
@@ -21,17 +21,29 @@ import (
 
 var feeBaseUnits = big.NewInt(1000000000000000000)
 
+// CommitmentPolicy values determine what happens to an order whose maker has
+// committed more across their open orders than their balance and allowance
+// can cover. Pools with no policy don't check.
+const (
+	CommitmentPolicyReject = "reject"
+	CommitmentPolicyFlag   = "flag"
+)
+
 type Pool struct {
-	SearchTerms     string
-	Expiration      uint64
-	Nonce           uint
-	FeeShare        string
-	ID              []byte
-	Limit           uint
-	SenderAddresses types.NetworkAddressMap
-	FilterAddresses types.NetworkAddressMap
-	conn            bind.ContractCaller
-	baseFee         config.BaseFee
+	SearchTerms      string
+	Expiration       uint64
+	Nonce            uint
+	FeeShare         string
+	ID               []byte
+	Limit            uint
+	SenderAddresses  types.NetworkAddressMap
+	FilterAddresses  types.NetworkAddressMap
+	CommitmentPolicy string
+	// EnforceAssetRegistry restricts the pool to orders for active asset pairs,
+	// within the assets' trade limits and price precision
+	EnforceAssetRegistry bool
+	conn                 bind.ContractCaller
+	baseFee              config.BaseFee
 }
 
 func (pool *Pool) SetConn(conn bind.ContractCaller) {
@@ -61,12 +73,14 @@ func (pool *Pool) CheckFilter(order *types.Order, networkid uint64) (bool, error
 	return NewFilterContract(filterAddress, pool.conn).Filter(pool.ID, order)
 }
 
+// Filter limits query to the orders matching the pool's search terms. Which
+// states of order to include is left to the caller.
 func (pool Pool) Filter(query *gorm.DB) (*gorm.DB, error) {
 	queryObject, err := urlModule.ParseQuery(pool.SearchTerms)
 	if err != nil {
 		return nil, err
 	}
-	query, errs := search.QueryFilter(query, queryObject)
+	query, errs := search.OrderFilter(query, queryObject)
 	if errCount := len(errs); errCount > 0 {
 		return nil, fmt.Errorf("Found %v errors in pool query string", errCount)
 	}
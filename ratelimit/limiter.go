@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/redis.v3"
+)
+
+// Limit allows Count requests per Period. Unused requests accumulate up to
+// Count, so a client that has been quiet may burst up to Count at once.
+type Limit struct {
+	Count  int64
+	Period time.Duration
+}
+
+// ParseLimit parses limits of the form "<count>/<period>", such as "100/1m"
+func ParseLimit(value string) (Limit, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("Limit should be <count>/<period>: %v", value)
+	}
+	count, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("Invalid limit count: %v", parts[0])
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("Invalid limit period: %v", parts[1])
+	}
+	return Limit{count, period}, nil
+}
+
+type Limiter interface {
+	// Allow takes a token from the bucket for key. If the bucket is empty it
+	// returns false, along with how long until a token is available.
+	Allow(key string, limit Limit) (bool, time.Duration, error)
+}
+
+// tokenBucketScript refills the bucket for the time elapsed since it was last
+// used, then takes a token if one is available. Running as a script keeps
+// the read and update atomic across API servers.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or capacity
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate))
+return {allowed, wait}
+`)
+
+type redisLimiter struct {
+	redisClient *redis.Client
+}
+
+func (limiter *redisLimiter) Allow(key string, limit Limit) (bool, time.Duration, error) {
+	// Tokens added per millisecond
+	rate := float64(limit.Count) / float64(limit.Period/time.Millisecond)
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	result, err := tokenBucketScript.Run(
+		limiter.redisClient,
+		[]string{fmt.Sprintf("ratelimit::%v", key)},
+		[]string{
+			strconv.FormatInt(limit.Count, 10),
+			strconv.FormatFloat(rate, 'f', -1, 64),
+			strconv.FormatInt(now, 10),
+		},
+	).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("Unexpected rate limit result: %v", result)
+	}
+	allowed, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Millisecond, nil
+}
+
+// NewRedisLimiter creates a token bucket Limiter shared through redis
+func NewRedisLimiter(redisClient *redis.Client) Limiter {
+	return &redisLimiter{redisClient}
+}
+
+type MockLimiter struct {
+	allowed bool
+	wait    time.Duration
+	err     error
+}
+
+func (limiter *MockLimiter) Allow(key string, limit Limit) (bool, time.Duration, error) {
+	return limiter.allowed, limiter.wait, limiter.err
+}
+
+func NewMockLimiter(allowed bool, wait time.Duration, err error) Limiter {
+	return &MockLimiter{allowed, wait, err}
+}
@@ -0,0 +1,62 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/notegio/openrelay/ratelimit"
+	"gopkg.in/redis.v3"
+)
+
+func getRedisClient(t *testing.T) *redis.Client {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Errorf("Please set the REDIS_URL environment variable")
+		return nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr: redisURL,
+	})
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("100/1m")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if limit.Count != 100 || limit.Period != time.Minute {
+		t.Errorf("Unexpected limit: %v", limit)
+	}
+	for _, value := range []string{"100", "0/1m", "-1/1m", "10/forever", "10/0s"} {
+		if _, err := ratelimit.ParseLimit(value); err == nil {
+			t.Errorf("Expected error parsing %v", value)
+		}
+	}
+}
+
+func TestRedisLimiter(t *testing.T) {
+	redisClient := getRedisClient(t)
+	if redisClient == nil {
+		return
+	}
+	limiter := ratelimit.NewRedisLimiter(redisClient)
+	key := fmt.Sprintf("test::%v", time.Now().UnixNano())
+	limit := ratelimit.Limit{3, time.Hour}
+	for i := 0; i < 3; i++ {
+		if allowed, _, err := limiter.Allow(key, limit); err != nil || !allowed {
+			t.Fatalf("Request %v should be allowed: %v", i, err)
+		}
+	}
+	allowed, wait, err := limiter.Allow(key, limit)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if allowed {
+		t.Errorf("Request should have been limited")
+	}
+	if wait <= 0 || wait > 21*time.Minute {
+		t.Errorf("Unexpected wait: %v", wait)
+	}
+}
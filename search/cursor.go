@@ -0,0 +1,307 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	urlModule "net/url"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	dbModule "github.com/notegio/openrelay/db"
+)
+
+// keyColumn is a column of a keyset, with how to write its value into a
+// cursor and read it back as a query parameter. Descending columns run from
+// highest to lowest when the keyset is in ascending order.
+type keyColumn struct {
+	name       string
+	encode     func(order *dbModule.Order) string
+	decode     func(value string) (interface{}, error)
+	descending bool
+}
+
+// keyset is an ordering on columns that together identify an order. Rather
+// than counting past an offset, each page picks up after the last order of
+// the page before, which stays fast and consistent however deep the page.
+type keyset struct {
+	name       string
+	filter     string
+	columns    []keyColumn
+	descending bool
+}
+
+// cursorPosition is where a page of a keyset ordering ends. Done indicates
+// there are no more orders.
+type cursorPosition struct {
+	Keyset string   `json:"k"`
+	Values []string `json:"v,omitempty"`
+	Done   bool     `json:"d,omitempty"`
+}
+
+func decodeDecimal(value string) (interface{}, error) {
+	if strings.Trim(value, "0123456789.-") != "" {
+		return nil, fmt.Errorf("Invalid decimal '%v'", value)
+	}
+	return value, nil
+}
+
+func decodeHex(value string) (interface{}, error) {
+	return hex.DecodeString(value)
+}
+
+func decodeTime(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+var orderHashColumn = keyColumn{
+	name:   "order_hash",
+	encode: func(order *dbModule.Order) string { return hex.EncodeToString(order.OrderHash) },
+	decode: decodeHex,
+}
+
+var priceColumn = keyColumn{
+	name:   "price",
+	encode: func(order *dbModule.Order) string { return order.Price.String() },
+	decode: decodeDecimal,
+}
+
+var feeRateColumn = keyColumn{
+	name:   "fee_rate",
+	encode: func(order *dbModule.Order) string { return order.FeeRate.String() },
+	decode: decodeDecimal,
+}
+
+var expirationColumn = keyColumn{
+	name:   "expiration_timestamp_in_sec",
+	encode: func(order *dbModule.Order) string { return hex.EncodeToString(order.ExpirationTimestampInSec[:]) },
+	decode: decodeHex,
+}
+
+// priceKeyset orders by price. Orders without a price can't be compared, so
+// are left out.
+var priceKeyset = &keyset{
+	name:    "price",
+	filter:  "price IS NOT NULL AND fee_rate IS NOT NULL",
+	columns: []keyColumn{priceColumn, feeRateColumn, orderHashColumn},
+}
+
+// feeRateKeyset orders by fee rate
+var feeRateKeyset = &keyset{
+	name:    "feeRate",
+	filter:  "fee_rate IS NOT NULL",
+	columns: []keyColumn{feeRateColumn, orderHashColumn},
+}
+
+// expirationKeyset orders by expiration time
+var expirationKeyset = &keyset{
+	name:    "expiration",
+	columns: []keyColumn{expirationColumn, orderHashColumn},
+}
+
+// createdKeyset orders by the time orders were first seen
+var createdKeyset = &keyset{
+	name: "createdAt",
+	columns: []keyColumn{
+		{
+			name:   "created_at",
+			encode: func(order *dbModule.Order) string { return order.CreatedAt.UTC().Format(time.RFC3339Nano) },
+			decode: decodeTime,
+		},
+		orderHashColumn,
+	},
+}
+
+// updatedKeyset orders by the time orders last changed
+var updatedKeyset = &keyset{
+	name: "updated",
+	columns: []keyColumn{
+		{
+			name:   "updated_at",
+			encode: func(order *dbModule.Order) string { return order.UpdatedAt.UTC().Format(time.RFC3339Nano) },
+			decode: decodeTime,
+		},
+		orderHashColumn,
+	},
+}
+
+// sortKeysets are the orderings the sort parameter can choose
+var sortKeysets = map[string]*keyset{
+	"price":      priceKeyset,
+	"feeRate":    feeRateKeyset,
+	"expiration": expirationKeyset,
+	"createdAt":  createdKeyset,
+}
+
+// reversed returns the keyset in descending order
+func (ks *keyset) reversed() *keyset {
+	return &keyset{ks.name + "-desc", ks.filter, ks.columns, true}
+}
+
+// getSort returns the keyset chosen by the sort parameter, a sort key
+// optionally prefixed with "-" for descending order, or nil if no sort is
+// given
+func getSort(queryObject urlModule.Values) (*keyset, error) {
+	sort := queryObject.Get("sort")
+	if sort == "" {
+		return nil, nil
+	}
+	ks, ok := sortKeysets[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported sort '%v'", sort)
+	}
+	if strings.HasPrefix(sort, "-") {
+		return ks.reversed(), nil
+	}
+	return ks, nil
+}
+
+// ordering returns the keyset's ORDER BY clause
+func (ks *keyset) ordering() string {
+	columns := []string{}
+	for _, column := range ks.columns {
+		if column.descending != ks.descending {
+			columns = append(columns, column.name+" DESC")
+		} else {
+			columns = append(columns, column.name)
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+func (ks *keyset) columnNames() []string {
+	names := []string{}
+	for _, column := range ks.columns {
+		names = append(names, column.name)
+	}
+	return names
+}
+
+// following returns the condition for orders coming after the values after
+// returned, along with its query parameters
+func (ks *keyset) following(after []interface{}) (string, []interface{}) {
+	comparison := func(descending bool) string {
+		if descending {
+			return "<"
+		}
+		return ">"
+	}
+	mixed := false
+	for _, column := range ks.columns {
+		mixed = mixed || column.descending
+	}
+	if !mixed {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(after)), ", ")
+		return fmt.Sprintf("(%v) %v (%v)", strings.Join(ks.columnNames(), ", "), comparison(ks.descending), placeholders), after
+	}
+	// A row comparison runs every column the same way, so otherwise spell
+	// out each column the ordering can first differ on
+	clauses := []string{}
+	values := []interface{}{}
+	for i, column := range ks.columns {
+		terms := []string{}
+		for j := 0; j < i; j++ {
+			terms = append(terms, ks.columns[j].name+" = ?")
+			values = append(values, after[j])
+		}
+		terms = append(terms, fmt.Sprintf("%v %v ?", column.name, comparison(column.descending != ks.descending)))
+		values = append(values, after[i])
+		clauses = append(clauses, "("+strings.Join(terms, " AND ")+")")
+	}
+	return strings.Join(clauses, " OR "), values
+}
+
+// after returns the query parameters for the orders following position,
+// which must belong to this keyset. A nil position starts from the first
+// order.
+func (ks *keyset) after(position *cursorPosition) ([]interface{}, error) {
+	if position == nil || position.Done {
+		return nil, nil
+	}
+	if position.Keyset != ks.name || len(position.Values) != len(ks.columns) {
+		return nil, errors.New("Cursor does not match the requested ordering")
+	}
+	values := []interface{}{}
+	for i, column := range ks.columns {
+		value, err := column.decode(position.Values[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cursor: %v", err.Error())
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// page returns up to limit orders from query following the position after
+// was read from, along with the position of the last of them
+func (ks *keyset) page(query *gorm.DB, after []interface{}, limit int) ([]dbModule.Order, *cursorPosition, error) {
+	orders := []dbModule.Order{}
+	if ks.filter != "" {
+		query = query.Where(ks.filter)
+	}
+	if after != nil {
+		condition, values := ks.following(after)
+		query = query.Where(condition, values...)
+	}
+	// Fetching one more order than needed tells us whether there's another
+	// page, without counting
+	if err := query.Order(ks.ordering()).Limit(limit + 1).Find(&orders).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(orders) <= limit {
+		return orders, &cursorPosition{Keyset: ks.name, Done: true}, nil
+	}
+	orders = orders[:limit]
+	next := &cursorPosition{Keyset: ks.name}
+	for _, column := range ks.columns {
+		next.Values = append(next.Values, column.encode(&orders[limit-1]))
+	}
+	return orders, next, nil
+}
+
+// encodeCursor writes value as an opaque, URL safe cursor
+func encodeCursor(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor reads a cursor written by encodeCursor into value. An empty
+// cursor leaves value alone, starting from the beginning.
+func decodeCursor(cursor string, value interface{}) error {
+	if cursor == "" {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return errors.New("Malformed cursor")
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return errors.New("Malformed cursor")
+	}
+	return nil
+}
+
+// cursorRequested indicates whether the request pages by cursor rather than
+// by page number. Passing an empty cursor requests the first page.
+func cursorRequested(queryObject urlModule.Values) bool {
+	_, ok := queryObject["cursor"]
+	return ok
+}
+
+// setNextLink points the Link header at the next page, if there is one
+func setNextLink(w http.ResponseWriter, r *http.Request, queryObject urlModule.Values, next string) {
+	if next == "" {
+		return
+	}
+	url := *r.URL
+	queryObject.Set("cursor", next)
+	url.RawQuery = queryObject.Encode()
+	w.Header().Set("Link", fmt.Sprintf("<%v>; rel=\"next\"", (&url).RequestURI()))
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/notegio/openrelay/types"
 	"math/big"
 	"fmt"
+	"time"
 )
 
 type FormattedOrder struct {
@@ -17,6 +18,21 @@ type OrderMetadata struct {
 	FeeRate float64               `json:"feeRate"`
 	Status int64                  `json:"status"`
 	TakerAssetAmountRemaining string `json:"takerAssetAmountRemaining"`
+	RemainingFillableTakerAssetAmount string `json:"remainingFillableTakerAssetAmount"`
+	OverCommitted bool            `json:"overCommitted,omitempty"`
+	TakerAssetAmountFilled string `json:"takerAssetAmountFilled"`
+	CreatedAt *time.Time          `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time          `json:"updatedAt,omitempty"`
+}
+
+// timestamp returns nil for the zero time, so orders that haven't been
+// saved leave their timestamps out
+func timestamp(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	value = value.UTC()
+	return &value
 }
 
 func GetFormattedOrder(order dbModule.Order) (*FormattedOrder) {
@@ -24,9 +40,14 @@ func GetFormattedOrder(order dbModule.Order) (*FormattedOrder) {
 		&order.Order,
 		&OrderMetadata{
 			fmt.Sprintf("%#x", order.OrderHash[:]),
-			order.FeeRate,
+			order.FeeRate.Float64(),
 			order.Status,
 			new(big.Int).Sub(order.TakerAssetAmount.Big(), order.TakerAssetAmountFilled.Big()).String(),
+			order.RemainingFillable().String(),
+			order.OverCommitted,
+			order.TakerAssetAmountFilled.Big().String(),
+			timestamp(order.CreatedAt),
+			timestamp(order.UpdatedAt),
 		},
 	}
 }
@@ -45,6 +66,15 @@ func GetPagedResult(total, page, per_page int, records interface{}) (*PagedResul
 }
 
 
+// CursorResult is a page of results fetched by cursor. Next is the cursor
+// for the following page, and is empty on the last page.
+type CursorResult struct {
+	PerPage int         `json:"perPage"`
+	Records interface{} `json:"records"`
+	Next string         `json:"next,omitempty"`
+}
+
+
 type PagedOrders struct {
 	Total int           `json:"total"`
 	Page int            `json:"page"`
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"github.com/jinzhu/gorm"
@@ -8,12 +9,51 @@ import (
 	"github.com/notegio/openrelay/types"
 	dbModule "github.com/notegio/openrelay/db"
 	"net/http"
+	urlModule "net/url"
+	"strings"
 	// "log"
 )
 
+// unknownFillable stands in for the fillable amount of orders recorded before
+// fillable amounts were tracked, putting them ahead of the rest at their price
+// as the database would with NULLs
+var unknownFillable = strings.Repeat("ff", 32)
+
+var remainingFillableColumn = keyColumn{
+	name: "COALESCE(remaining_fillable_taker_asset_amount, '\\x" + unknownFillable + "'::bytea)",
+	encode: func(order *dbModule.Order) string {
+		if order.RemainingFillableTakerAssetAmount == nil {
+			return unknownFillable
+		}
+		return hex.EncodeToString(order.RemainingFillableTakerAssetAmount[:])
+	},
+	decode:     decodeHex,
+	descending: true,
+}
+
+// orderBookKeyset puts the best price first. Among orders at the same price,
+// those the makers can fund the most of come first, since they contribute the
+// most depth. Orders without a price can't be placed in the book, so are left
+// out. Paging by number and by cursor both use this ordering.
+var orderBookKeyset = &keyset{
+	name:    "book",
+	filter:  "price IS NOT NULL AND fee_rate IS NOT NULL",
+	columns: []keyColumn{priceColumn, feeRateColumn, remainingFillableColumn, expirationColumn, orderHashColumn},
+}
+
 type OrderBook struct {
-	Asks *PagedResult `json:"asks"`
-	Bids *PagedResult `json:"bids"`
+	Asks interface{} `json:"asks"`
+	Bids interface{} `json:"bids"`
+	// Next is the cursor for the following page of both sides, when paging
+	// by cursor
+	Next string `json:"next,omitempty"`
+}
+
+// orderBookCursor tracks each side of the order book separately, as one side
+// may run out before the other
+type orderBookCursor struct {
+	Asks *cursorPosition `json:"a,omitempty"`
+	Bids *cursorPosition `json:"b,omitempty"`
 }
 
 func OrderBookHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, types.Pool) {
@@ -39,29 +79,40 @@ func OrderBookHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, type
 			errs = append(errs, ValidationError{err.Error(), 1001, "quoteAssetData"})
 		}
 		currentTime := getExpTime(queryObject)
-		baseQuery, err := pool.Filter(db.Model(&dbModule.Order{}).Where("status = ?", dbModule.StatusOpen).Where("expiration_timestamp_in_sec > ?", currentTime))
+		baseQuery, err := pool.Filter(db.Model(&dbModule.Order{}).Where("status = ?", dbModule.StatusOpen).Where("expiration_timestamp_in_sec > ?", currentTime).Where("remaining_fillable_taker_asset_amount IS NULL OR remaining_fillable_taker_asset_amount > ?", &types.Uint256{}))
 		if err != nil {
 			errs = append(errs, ValidationError{err.Error(), 1001, "pool"})
+		} else if baseQuery, err = applyVersionFilter(baseQuery, "protocolVersion", "protocol_version", queryObject); err != nil {
+			// The order book mixes v2 and v3 orders unless a version is requested
+			errs = append(errs, ValidationError{err.Error(), 1006, "protocolVersion"})
 		}
 		if len(errs) > 0 {
 			returnErrorList(w, errs)
 			return
 		}
+		bidsQuery := baseQuery.Where("taker_asset_data = ? AND maker_asset_data = ?", []byte(baseAssetData[:]), []byte(quoteAssetData[:]))
+		asksQuery := baseQuery.Where("maker_asset_data = ? AND taker_asset_data = ?", []byte(baseAssetData[:]), []byte(quoteAssetData[:]))
+		if cursorRequested(queryObject) {
+			orderBookByCursor(w, r, asksQuery, bidsQuery, queryObject, perPageInt)
+			return
+		}
+		bidsQuery = bidsQuery.Where(orderBookKeyset.filter).Order(orderBookKeyset.ordering())
+		asksQuery = asksQuery.Where(orderBookKeyset.filter).Order(orderBookKeyset.ordering())
 		bids := []dbModule.Order{}
 		asks := []dbModule.Order{}
 		var bidCount int
 		var askCount int
 
 		// orderBook := &OrderBook{[]dbModule.Order{}, []dbModule.Order{}}
-		baseQuery.Where("taker_asset_data = ? AND maker_asset_data = ?", []byte(baseAssetData[:]), []byte(quoteAssetData[:])).Order("price, fee_rate, expiration_timestamp_in_sec").Count(&bidCount)
-		baseQuery.Where("maker_asset_data = ? AND taker_asset_data = ?", []byte(baseAssetData[:]), []byte(quoteAssetData[:])).Order("price, fee_rate, expiration_timestamp_in_sec").Count(&askCount)
+		bidsQuery.Count(&bidCount)
+		asksQuery.Count(&askCount)
 		if bidCount > (pageInt - 1) * perPageInt {
 			// We don't need to bother with this query if te total is less than the
 			// offset
-			baseQuery.Where("taker_asset_data = ? AND maker_asset_data = ?", []byte(baseAssetData[:]), []byte(quoteAssetData[:])).Order("price, fee_rate, expiration_timestamp_in_sec").Offset((pageInt - 1) * perPageInt).Limit(perPageInt).Find(&bids)
+			bidsQuery.Offset((pageInt - 1) * perPageInt).Limit(perPageInt).Find(&bids)
 		}
 		if askCount > (pageInt - 1) * perPageInt {
-			baseQuery.Where("maker_asset_data = ? AND taker_asset_data = ?", []byte(baseAssetData[:]), []byte(quoteAssetData[:])).Order("price, fee_rate, expiration_timestamp_in_sec").Offset((pageInt - 1) * perPageInt).Limit(perPageInt).Find(&asks)
+			asksQuery.Offset((pageInt - 1) * perPageInt).Limit(perPageInt).Find(&asks)
 		}
 		formattedAsks := []FormattedOrder{}
 		formattedBids := []FormattedOrder{}
@@ -72,8 +123,8 @@ func OrderBookHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, type
 			formattedBids = append(formattedBids, *GetFormattedOrder(order))
 		}
 		orderBook := &OrderBook{
-			GetPagedResult(askCount, pageInt, perPageInt, formattedAsks),
-			GetPagedResult(bidCount, pageInt, perPageInt, formattedBids),
+			Asks: GetPagedResult(askCount, pageInt, perPageInt, formattedAsks),
+			Bids: GetPagedResult(bidCount, pageInt, perPageInt, formattedBids),
 		}
 		response, err := json.Marshal(orderBook)
 		if err != nil {
@@ -85,3 +136,65 @@ func OrderBookHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, type
 		}
 	}
 }
+
+// orderBookByCursor responds with the page of each side of the order book
+// following the request's cursor, in the order of orderBookKeyset
+func orderBookByCursor(w http.ResponseWriter, r *http.Request, asksQuery, bidsQuery *gorm.DB, queryObject urlModule.Values, perPage int) {
+	position := &orderBookCursor{}
+	if err := decodeCursor(queryObject.Get("cursor"), position); err != nil {
+		returnErrorList(w, []ValidationError{{err.Error(), 1001, "cursor"}})
+		return
+	}
+	next := &orderBookCursor{}
+	sides := []struct {
+		query    *gorm.DB
+		position *cursorPosition
+		next     **cursorPosition
+		records  []FormattedOrder
+	}{
+		{asksQuery, position.Asks, &next.Asks, []FormattedOrder{}},
+		{bidsQuery, position.Bids, &next.Bids, []FormattedOrder{}},
+	}
+	for i := range sides {
+		side := &sides[i]
+		after, err := orderBookKeyset.after(side.position)
+		if err != nil {
+			returnErrorList(w, []ValidationError{{err.Error(), 1001, "cursor"}})
+			return
+		}
+		if side.position != nil && side.position.Done {
+			*side.next = side.position
+			continue
+		}
+		orders, sideNext, err := orderBookKeyset.page(side.query, after, perPage)
+		if err != nil {
+			returnError(w, err, 500)
+			return
+		}
+		*side.next = sideNext
+		for _, order := range orders {
+			side.records = append(side.records, *GetFormattedOrder(order))
+		}
+	}
+	nextCursor := ""
+	if !next.Asks.Done || !next.Bids.Done {
+		var err error
+		if nextCursor, err = encodeCursor(next); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+	response, err := json.Marshal(&OrderBook{
+		Asks: &CursorResult{PerPage: perPage, Records: sides[0].records},
+		Bids: &CursorResult{PerPage: perPage, Records: sides[1].records},
+		Next: nextCursor,
+	})
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	setNextLink(w, r, queryObject, nextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(response)
+}
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
 	urlModule "net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/jinzhu/gorm"
+	"github.com/notegio/openrelay/apikeys"
 	"github.com/notegio/openrelay/blockhash"
 	"github.com/notegio/openrelay/common"
 	dbModule "github.com/notegio/openrelay/db"
@@ -84,6 +87,38 @@ func applyAssetDataOrFilter(query *gorm.DB, queryField, dbField1, dbField2 strin
 	return query, nil
 }
 
+// applyComponentFilter matches orders trading the asset on the given side,
+// either directly or as a component of a MultiAssetProxy bundle.
+func applyComponentFilter(query *gorm.DB, queryField, dbField, side string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if assetData := queryObject.Get(queryField); assetData != "" {
+		assetDataBytes, err := common.HexToAssetData(assetData)
+		if err != nil {
+			return query, err
+		}
+		whereClause := fmt.Sprintf("%v = ? or order_hash IN (%v)", dbField, dbModule.ComponentSubquery(side, "asset_data"))
+		filteredQuery := query.Where(whereClause, &assetDataBytes, &assetDataBytes)
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
+func applyComponentOrFilter(query *gorm.DB, queryField string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if assetData := queryObject.Get(queryField); assetData != "" {
+		assetDataBytes, err := common.HexToAssetData(assetData)
+		if err != nil {
+			return query, err
+		}
+		whereClause := fmt.Sprintf(
+			"maker_asset_data = ? or taker_asset_data = ? or order_hash IN (%v) or order_hash IN (%v)",
+			dbModule.ComponentSubquery(dbModule.SideMaker, "asset_data"),
+			dbModule.ComponentSubquery(dbModule.SideTaker, "asset_data"),
+		)
+		filteredQuery := query.Where(whereClause, &assetDataBytes, &assetDataBytes, &assetDataBytes, &assetDataBytes)
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
 func escape(queryBytes []byte) []byte {
 	return bytes.Replace(bytes.Replace(queryBytes, []byte("?"), []byte("\\?"), -1), []byte("_"), []byte("\\_"), -1)
 }
@@ -113,17 +148,83 @@ func applyOrFilter(query *gorm.DB, queryField, dbField1, dbField2 string, queryO
 	return query, nil
 }
 
-func applyBytesFilter(query *gorm.DB, queryField, dbField string, queryObject urlModule.Values) (*gorm.DB, error) {
-	if data := queryObject.Get(queryField); data != "" {
-		dataBytes, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+// maxFilterValues caps how many values a multi-value filter accepts, to keep
+// the queries they build to a reasonable size
+const maxFilterValues = 50
+
+// getFilterValues returns the values given for queryField, which may be
+// comma separated, repeated, or both
+func getFilterValues(queryObject urlModule.Values, queryField string) []string {
+	values := []string{}
+	for _, value := range queryObject[queryField] {
+		for _, item := range strings.Split(value, ",") {
+			if item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+func parseAddress(value string) (interface{}, error) {
+	addressBytes, err := common.HexToBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	return common.BytesToOrAddress(addressBytes), nil
+}
+
+func parseAssetData(value string) (interface{}, error) {
+	assetDataBytes, err := common.HexToAssetData(value)
+	if err != nil {
+		return nil, err
+	}
+	return &assetDataBytes, nil
+}
+
+func parseBytes(value string) (interface{}, error) {
+	return hex.DecodeString(strings.TrimPrefix(value, "0x"))
+}
+
+// applyMultiFilter matches orders whose dbField is any of the values given
+// for queryField, each converted to a query parameter by parse. When several
+// values are given, errors point at the offending one by its position in the
+// list, as in makerAddress[2].
+func applyMultiFilter(query *gorm.DB, queryField, dbField string, code int64, parse func(string) (interface{}, error), queryObject urlModule.Values) (*gorm.DB, []ValidationError) {
+	if _, ok := queryObject[queryField]; !ok {
+		return query, nil
+	}
+	values := getFilterValues(queryObject, queryField)
+	if len(values) == 0 {
+		// An empty filter would otherwise match every order
+		return query, []ValidationError{{"No values given", 1001, queryField}}
+	}
+	if len(values) > maxFilterValues {
+		return query, []ValidationError{{fmt.Sprintf("At most %v values are allowed", maxFilterValues), 1001, queryField}}
+	}
+	errs := []ValidationError{}
+	params := []interface{}{}
+	for i, value := range values {
+		param, err := parse(value)
 		if err != nil {
-			return query, err
+			field := queryField
+			if len(values) > 1 {
+				field = fmt.Sprintf("%v[%v]", queryField, i)
+			}
+			errs = append(errs, ValidationError{err.Error(), code, field})
+			continue
 		}
-		whereClause := fmt.Sprintf("%v = ?", dbField)
-		filteredQuery := query.Where(whereClause, dataBytes[:])
-		return filteredQuery, filteredQuery.Error
+		params = append(params, param)
 	}
-	return query, nil
+	if len(errs) > 0 {
+		return query, errs
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(params)), ", ")
+	filteredQuery := query.Where(fmt.Sprintf("%v IN (%v)", dbField, placeholders), params...)
+	if filteredQuery.Error != nil {
+		return filteredQuery, []ValidationError{{filteredQuery.Error.Error(), code, queryField}}
+	}
+	return filteredQuery, nil
 }
 
 func applyHashFilter(query *gorm.DB, queryField, dbField string, queryObject urlModule.Values) (*gorm.DB, error) {
@@ -151,6 +252,70 @@ func applyUint256Filter(query *gorm.DB, queryField, dbField string, queryObject
 	return query, nil
 }
 
+// applyUint256RangeFilter matches orders whose dbField compares to the
+// number given by queryField with op, one of <, <=, > or >=. Uint256 columns
+// hold big endian bytes, so they compare in numeric order.
+func applyUint256RangeFilter(query *gorm.DB, queryField, dbField, op string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if value := queryObject.Get(queryField); value != "" {
+		intValue, ok := new(big.Int).SetString(value, 10)
+		if !ok || intValue.Sign() < 0 || intValue.BitLen() > 256 {
+			return query, fmt.Errorf("Invalid number: %v", value)
+		}
+		whereClause := fmt.Sprintf("%v %v ?", dbField, op)
+		filteredQuery := query.Where(whereClause, common.BigToUint256(intValue))
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
+// applyDecimalRangeFilter matches orders whose dbField compares to the
+// decimal given by queryField with op
+func applyDecimalRangeFilter(query *gorm.DB, queryField, dbField, op string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if value := queryObject.Get(queryField); value != "" {
+		ratValue, ok := new(big.Rat).SetString(value)
+		if !ok {
+			return query, fmt.Errorf("Invalid decimal: %v", value)
+		}
+		whereClause := fmt.Sprintf("%v %v ?", dbField, op)
+		filteredQuery := query.Where(whereClause, (*types.Decimal)(ratValue))
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
+// applyTimeRangeFilter matches orders whose dbField compares to the time
+// given by queryField with op. Times may be RFC 3339 or Unix timestamps.
+func applyTimeRangeFilter(query *gorm.DB, queryField, dbField, op string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if value := queryObject.Get(queryField); value != "" {
+		timeValue, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return query, fmt.Errorf("Invalid time: %v", value)
+			}
+			timeValue = time.Unix(seconds, 0)
+		}
+		whereClause := fmt.Sprintf("%v %v ?", dbField, op)
+		filteredQuery := query.Where(whereClause, timeValue)
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
+// applyVersionFilter matches orders for a version of the 0x protocol
+func applyVersionFilter(query *gorm.DB, queryField, dbField string, queryObject urlModule.Values) (*gorm.DB, error) {
+	if value := queryObject.Get(queryField); value != "" {
+		version, err := strconv.ParseUint(value, 10, 8)
+		if err != nil || (uint8(version) != types.ProtocolV2 && uint8(version) != types.ProtocolV3) {
+			return query, fmt.Errorf("Unsupported protocol version: %v", value)
+		}
+		whereClause := fmt.Sprintf("%v = ?", dbField)
+		filteredQuery := query.Where(whereClause, uint8(version))
+		return filteredQuery, filteredQuery.Error
+	}
+	return query, nil
+}
+
 func returnError(w http.ResponseWriter, err error, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -234,9 +399,90 @@ func filterByNetworkId(query *gorm.DB, queryObject urlModule.Values, exchangeLoo
 	return query, nil
 }
 
-func QueryFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []ValidationError) {
-	query = query.Where("status = ?", dbModule.StatusOpen)
+// orderStatus is the condition matching orders in a state of the status
+// filter, with its parameters
+type orderStatus struct {
+	condition string
+	values    func(expTime *types.Uint256) []interface{}
+}
+
+// orderStatuses maps the values of the status filter to the orders in that
+// state. Expiration isn't recorded as a status, so open and unfunded orders
+// count as expired once past their expiration time, rather than as open or
+// unfunded.
+var orderStatuses = map[string]orderStatus{
+	"open": {
+		"status = ? AND expiration_timestamp_in_sec > ?",
+		func(expTime *types.Uint256) []interface{} { return []interface{}{dbModule.StatusOpen, expTime} },
+	},
+	"unfunded": {
+		"status = ? AND expiration_timestamp_in_sec > ?",
+		func(expTime *types.Uint256) []interface{} { return []interface{}{dbModule.StatusUnfunded, expTime} },
+	},
+	"expired": {
+		"status IN (?, ?) AND expiration_timestamp_in_sec <= ?",
+		func(expTime *types.Uint256) []interface{} {
+			return []interface{}{dbModule.StatusOpen, dbModule.StatusUnfunded, expTime}
+		},
+	},
+	"filled": {
+		"status = ?",
+		func(expTime *types.Uint256) []interface{} { return []interface{}{dbModule.StatusFilled} },
+	},
+	"cancelled": {
+		"status = ?",
+		func(expTime *types.Uint256) []interface{} { return []interface{}{dbModule.StatusCancelled} },
+	},
+}
+
+// applyStatusFilter limits the results to orders in any of the states listed
+// by the status parameter, comma separated or repeated, or to open orders if
+// none are listed. Other states are only searchable for a specific maker, as
+// an order history, unless privateOrders allows searching the whole book.
+func applyStatusFilter(query *gorm.DB, queryObject urlModule.Values, privateOrders bool) (*gorm.DB, error) {
+	statuses := []string{}
+	seen := make(map[string]bool)
+	for _, value := range queryObject["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if _, ok := orderStatuses[status]; !ok {
+				return query, fmt.Errorf("Unsupported status '%v'", status)
+			}
+			if !seen[status] {
+				seen[status] = true
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	if len(statuses) == 0 {
+		statuses = []string{"open"}
+	} else if !privateOrders && len(getFilterValues(queryObject, "makerAddress")) == 0 && queryObject.Get("traderAddress") == "" {
+		return query, errors.New("Filtering by status requires makerAddress or traderAddress")
+	}
+	expTime := getExpTime(queryObject)
+	conditions := []string{}
+	values := []interface{}{}
+	for _, status := range statuses {
+		conditions = append(conditions, fmt.Sprintf("(%v)", orderStatuses[status].condition))
+		values = append(values, orderStatuses[status].values(expTime)...)
+	}
+	// As with the network filter, only the fixed conditions above are built
+	// into the query string, and the values stay parameterized
+	query = query.Where(fmt.Sprintf("(%v)", strings.Join(conditions, " OR ")), values...)
+	return query, query.Error
+}
+
+func QueryFilter(query *gorm.DB, queryObject urlModule.Values, privateOrders bool) (*gorm.DB, []ValidationError) {
+	query, errs := OrderFilter(query, queryObject)
+	query, err := applyStatusFilter(query, queryObject, privateOrders)
+	if err != nil {
+		errs = append(errs, ValidationError{err.Error(), 1001, "status"})
+	}
+	return query, errs
+}
 
+// OrderFilter applies the order field filters from QueryFilter, without
+// limiting the results to open, unexpired orders.
+func OrderFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []ValidationError) {
 	errs := []ValidationError{}
 
 	query, err := applyAddressFilter(query, "exchangeContractAddress", "exchange_address", queryObject)
@@ -251,26 +497,30 @@ func QueryFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []Vali
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "takerAssetAddress"})
 	}
-	query, err = applyAssetDataFilter(query, "makerAssetData", "maker_asset_data", queryObject)
+	query, multiErrs := applyMultiFilter(query, "makerAssetData", "maker_asset_data", 1003, parseAssetData, queryObject)
+	errs = append(errs, multiErrs...)
+	query, multiErrs = applyMultiFilter(query, "takerAssetData", "taker_asset_data", 1003, parseAssetData, queryObject)
+	errs = append(errs, multiErrs...)
+	query, err = applyAssetDataFilter(query, "makerFeeAssetData", "maker_fee_asset_data", queryObject)
 	if err != nil {
-		errs = append(errs, ValidationError{err.Error(), 1003, "makerAssetData"})
+		errs = append(errs, ValidationError{err.Error(), 1003, "makerFeeAssetData"})
 	}
-	query, err = applyAssetDataFilter(query, "takerAssetData", "taker_asset_data", queryObject)
+	query, err = applyAssetDataFilter(query, "takerFeeAssetData", "taker_fee_asset_data", queryObject)
 	if err != nil {
-		errs = append(errs, ValidationError{err.Error(), 1003, "takerAssetData"})
+		errs = append(errs, ValidationError{err.Error(), 1003, "takerFeeAssetData"})
 	}
-	query, err = applyAddressFilter(query, "makerAddress", "maker", queryObject)
+	query, err = applyVersionFilter(query, "protocolVersion", "protocol_version", queryObject)
 	if err != nil {
-		errs = append(errs, ValidationError{err.Error(), 1003, "maker"})
+		errs = append(errs, ValidationError{err.Error(), 1006, "protocolVersion"})
 	}
+	query, multiErrs = applyMultiFilter(query, "makerAddress", "maker", 1003, parseAddress, queryObject)
+	errs = append(errs, multiErrs...)
 	query, err = applyAddressFilter(query, "takerAddress", "taker", queryObject)
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "taker"})
 	}
-	query, err = applyAddressFilter(query, "feeRecipient", "fee_recipient", queryObject)
-	if err != nil {
-		errs = append(errs, ValidationError{err.Error(), 1003, "feeRecipient"})
-	}
+	query, multiErrs = applyMultiFilter(query, "feeRecipient", "fee_recipient", 1003, parseAddress, queryObject)
+	errs = append(errs, multiErrs...)
 	query, err = applyStartsWithFilter(query, "makerAssetProxyId", "maker_asset_data", queryObject)
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "makerAssetProxyId"})
@@ -287,14 +537,24 @@ func QueryFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []Vali
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1001, "assetData"})
 	}
-	query, err = applyOrFilter(query, "traderAddress", "maker", "taker", queryObject)
+	query, err = applyComponentFilter(query, "makerAssetComponent", "maker_asset_data", dbModule.SideMaker, queryObject)
 	if err != nil {
-		errs = append(errs, ValidationError{err.Error(), 1003, "traderAddress"})
+		errs = append(errs, ValidationError{err.Error(), 1001, "makerAssetComponent"})
+	}
+	query, err = applyComponentFilter(query, "takerAssetComponent", "taker_asset_data", dbModule.SideTaker, queryObject)
+	if err != nil {
+		errs = append(errs, ValidationError{err.Error(), 1001, "takerAssetComponent"})
 	}
-	query, err = applyBytesFilter(query, "_poolId", "pool_id", queryObject)
+	query, err = applyComponentOrFilter(query, "assetComponent", queryObject)
 	if err != nil {
-		errs = append(errs, ValidationError{err.Error(), 1003, "_poolId"})
+		errs = append(errs, ValidationError{err.Error(), 1001, "assetComponent"})
 	}
+	query, err = applyOrFilter(query, "traderAddress", "maker", "taker", queryObject)
+	if err != nil {
+		errs = append(errs, ValidationError{err.Error(), 1003, "traderAddress"})
+	}
+	query, multiErrs = applyMultiFilter(query, "_poolId", "pool_id", 1003, parseBytes, queryObject)
+	errs = append(errs, multiErrs...)
 	query, err = applyHashFilter(query, "_poolName", "pool_id", queryObject)
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "_poolName"})
@@ -303,8 +563,29 @@ func QueryFilter(query *gorm.DB, queryObject urlModule.Values) (*gorm.DB, []Vali
 	if err != nil {
 		errs = append(errs, ValidationError{err.Error(), 1003, "_takerFee"})
 	}
-
-	query = query.Where("expiration_timestamp_in_sec > ?", getExpTime(queryObject))
+	for _, rangeFilter := range []struct {
+		apply      func(*gorm.DB, string, string, string, urlModule.Values) (*gorm.DB, error)
+		queryField string
+		dbField    string
+		op         string
+	}{
+		{applyDecimalRangeFilter, "minPrice", "price", ">="},
+		{applyDecimalRangeFilter, "maxPrice", "price", "<="},
+		{applyDecimalRangeFilter, "maxFeeRate", "fee_rate", "<="},
+		{applyUint256RangeFilter, "expiresAfter", "expiration_timestamp_in_sec", ">"},
+		{applyUint256RangeFilter, "expiresBefore", "expiration_timestamp_in_sec", "<"},
+		{applyUint256RangeFilter, "minMakerAssetAmount", "maker_asset_amount", ">="},
+		{applyUint256RangeFilter, "maxMakerAssetAmount", "maker_asset_amount", "<="},
+		{applyUint256RangeFilter, "minTakerAssetAmount", "taker_asset_amount", ">="},
+		{applyUint256RangeFilter, "maxTakerAssetAmount", "taker_asset_amount", "<="},
+		{applyTimeRangeFilter, "createdSince", "created_at", ">="},
+		{applyTimeRangeFilter, "updatedSince", "updated_at", ">="},
+	} {
+		query, err = rangeFilter.apply(query, rangeFilter.queryField, rangeFilter.dbField, rangeFilter.op, queryObject)
+		if err != nil {
+			errs = append(errs, ValidationError{err.Error(), 1001, rangeFilter.queryField})
+		}
+	}
 	return query, errs
 }
 
@@ -312,7 +593,8 @@ func SearchHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, types.P
 	exchangeLookup := dbModule.NewExchangeLookup(db)
 	return func(w http.ResponseWriter, r *http.Request, pool types.Pool) {
 		queryObject := r.URL.Query()
-		query, errs := QueryFilter(db.Model(&dbModule.Order{}), queryObject)
+		key := apikeys.FromRequest(r)
+		query, errs := QueryFilter(db.Model(&dbModule.Order{}), queryObject, key != nil && key.PrivateOrders)
 		query, err := pool.Filter(query)
 		if err != nil {
 			returnError(w, fmt.Errorf("Pool filter error: %v", err.Error()), 404)
@@ -328,6 +610,19 @@ func SearchHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, types.P
 		if err != nil {
 			errs = append(errs, ValidationError{err.Error(), 1001, "page"})
 		}
+		sort, err := getSort(queryObject)
+		if err != nil {
+			errs = append(errs, ValidationError{err.Error(), 1001, "sort"})
+		}
+
+		if cursorRequested(queryObject) {
+			if len(errs) > 0 {
+				returnErrorList(w, errs)
+				return
+			}
+			searchByCursor(w, r, query, queryObject, sort, perPageInt)
+			return
+		}
 
 		var count int
 		query.Count(&count)
@@ -340,18 +635,16 @@ func SearchHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, types.P
 			return
 		}
 
-		if queryObject.Get("makerAssetAddress") != "" && queryObject.Get("takerAssetAddress") != "" {
-			query := query.Order("price asc, fee_rate asc")
-			if query.Error != nil {
-				returnError(w, query.Error, 500)
-				return
-			}
+		if sort != nil {
+			query = query.Order(sort.ordering())
+		} else if queryObject.Get("makerAssetAddress") != "" && queryObject.Get("takerAssetAddress") != "" {
+			query = query.Order("price asc, fee_rate asc")
 		} else {
-			query := query.Order("updated_at")
-			if query.Error != nil {
-				returnError(w, query.Error, 500)
-				return
-			}
+			query = query.Order("updated_at")
+		}
+		if query.Error != nil {
+			returnError(w, query.Error, 500)
+			return
 		}
 
 		orders := []dbModule.Order{}
@@ -383,3 +676,61 @@ func SearchHandler(db *gorm.DB) func(http.ResponseWriter, *http.Request, types.P
 		}
 	}
 }
+
+// searchByCursor responds with the page of orders following the request's
+// cursor, in the order sort chooses. Without a sort, orders for a specific
+// pair are ordered by price, like the page based search, and others by when
+// they last changed.
+func searchByCursor(w http.ResponseWriter, r *http.Request, query *gorm.DB, queryObject urlModule.Values, sort *keyset, perPage int) {
+	ordering := sort
+	if ordering == nil {
+		ordering = updatedKeyset
+		if queryObject.Get("makerAssetAddress") != "" && queryObject.Get("takerAssetAddress") != "" {
+			ordering = priceKeyset
+		}
+	}
+	var position *cursorPosition
+	if err := decodeCursor(queryObject.Get("cursor"), &position); err != nil {
+		returnErrorList(w, []ValidationError{{err.Error(), 1001, "cursor"}})
+		return
+	}
+	after, err := ordering.after(position)
+	if err != nil {
+		returnErrorList(w, []ValidationError{{err.Error(), 1001, "cursor"}})
+		return
+	}
+	orders := []dbModule.Order{}
+	next := position
+	if position == nil || !position.Done {
+		if orders, next, err = ordering.page(query, after, perPage); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+	nextCursor := ""
+	if !next.Done {
+		if nextCursor, err = encodeCursor(next); err != nil {
+			returnError(w, err, 500)
+			return
+		}
+	}
+	var response []byte
+	contentType := "application/json"
+	if acceptVal, ok := r.Header["Accept"]; ok && strings.Split(acceptVal[0], ";")[0] == "application/octet-stream" {
+		response, contentType, err = FormatResponse(orders, "application/octet-stream", 0, 0, perPage)
+	} else {
+		records := []FormattedOrder{}
+		for _, order := range orders {
+			records = append(records, *GetFormattedOrder(order))
+		}
+		response, err = json.Marshal(&CursorResult{perPage, records, nextCursor})
+	}
+	if err != nil {
+		returnError(w, err, 500)
+		return
+	}
+	setNextLink(w, r, queryObject, nextCursor)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(200)
+	w.Write(response)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/notegio/openrelay/apikeys"
 	"github.com/notegio/openrelay/blockhash"
 	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/channels"
@@ -20,8 +21,11 @@ import (
 	"net/http/httptest"
 	"io/ioutil"
 	"encoding/json"
+	"math/big"
 	"os"
-	// "reflect"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -40,12 +44,18 @@ func sampleOrder(t *testing.T) *dbModule.Order {
 
 
 func saltedSampleOrder(t *testing.T) *dbModule.Order {
-	// TODO: Sign this order
+	return amendedSampleOrder(t, func(order *dbModule.Order) {})
+}
+
+// amendedSampleOrder returns a sample order with a maker and salt of its own,
+// changed by amend before it is signed
+func amendedSampleOrder(t *testing.T, amend func(order *dbModule.Order)) *dbModule.Order {
 	key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
 	order := sampleOrder(t)
 	address := crypto.PubkeyToAddress(key.PublicKey)
 	copy(order.Maker[:], address[:])
 	rand.Read(order.Salt[:])
+	amend(order)
 
 	hashedBytes := append([]byte("\x19Ethereum Signed Message:\n32"), order.Hash()...)
 	signedBytes := crypto.Keccak256(hashedBytes)
@@ -95,6 +105,14 @@ func getTestOrderBookHandler(db *gorm.DB) func(http.ResponseWriter, *http.Reques
 	return search.BlockHashDecorator(blockHash, mockPoolDecorator(search.OrderBookHandler(db)))
 }
 
+var timestampPattern = regexp.MustCompile(`,"(createdAt|updatedAt)":"[^"]*"`)
+
+// withoutTimestamps strips the order timestamps, which depend on when the
+// test runs, from a response
+func withoutTimestamps(response string) string {
+	return timestampPattern.ReplaceAllString(response, "")
+}
+
 func getDb() (*gorm.DB, error) {
 	connectionString := fmt.Sprintf(
 		"postgres://%v@%v",
@@ -116,7 +134,7 @@ func TestFormatResponseJson(t *testing.T) {
 	if contentType != "application/json" {
 		t.Errorf("Expected content type application/json, got '%v'", contentType)
 	}
-	if string(response) != "{\"total\":2,\"page\":1,\"perPage\":100,\"records\":[{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\"}},{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\"}}]}" {
+	if string(response) != "{\"total\":2,\"page\":1,\"perPage\":100,\"records\":[{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\",\"remainingFillableTakerAssetAmount\":\"1000000000000000000\",\"takerAssetAmountFilled\":\"0\"}},{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\",\"remainingFillableTakerAssetAmount\":\"1000000000000000000\",\"takerAssetAmountFilled\":\"0\"}}]}" {
 		t.Errorf("Got '%v'", string(response))
 	}
 }
@@ -148,7 +166,7 @@ func TestFormatSingleResponseJson(t *testing.T) {
 	if contentType != "application/json" {
 		t.Errorf("Expected content type application/json, got '%v'", contentType)
 	}
-	if string(response) != "{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\"}}" {
+	if string(response) != "{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\",\"remainingFillableTakerAssetAmount\":\"1000000000000000000\",\"takerAssetAmountFilled\":\"0\"}}" {
 		t.Errorf("Got '%v'", string(response))
 	}
 }
@@ -219,8 +237,8 @@ func filterContractRequest(queryString, emptyQueryString string, t *testing.T) {
 	if recorder.Code != 200 {
 		t.Errorf("Unexpected response code '%v'", recorder.Code)
 	}
-	response := recorder.Body.String()
-	if string(response) != "{\"total\":1,\"page\":1,\"perPage\":20,\"records\":[{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\"}}]}" {
+	response := withoutTimestamps(recorder.Body.String())
+	if string(response) != "{\"total\":1,\"page\":1,\"perPage\":20,\"records\":[{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\",\"remainingFillableTakerAssetAmount\":\"1000000000000000000\",\"takerAssetAmountFilled\":\"0\"}}]}" {
 		t.Errorf("Got '%v'", string(response))
 	}
 	request, _ = http.NewRequest("GET", "/v0/orders?"+emptyQueryString+"&blockhash=x", nil)
@@ -295,6 +313,83 @@ func TestFilterPoolId(t *testing.T) {
 func TestFilterTakerFee(t *testing.T) {
 	filterContractRequest("_takerFee=0", "_takerFee=1000", t)
 }
+func TestFilterMultipleMakers(t *testing.T) {
+	filterContractRequest("makerAddress=0x90fe2af704b34e0224bf2299c838e04d4dcf1300,0x627306090abab3a6e1400e9345bc60c78a8bef57", "makerAddress=0x90fe2af704b34e0224bf2299c838e04d4dcf1300,0x90fe2af704b34e0224bf2299c838e04d4dcf1301", t)
+}
+func TestFilterRepeatedMakerAssetData(t *testing.T) {
+	filterContractRequest("makerAssetData=0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba&makerAssetData=0xf47261b00000000000000000000000002dad4783cf3fe3085c1426157ab175a6119a04ba", "makerAssetData=0xf47261b00000000000000000000000002dad4783cf3fe3085c1426157ab175a6119a04ba&makerAssetData=0xf47261b00000000000000000000000003dad4783cf3fe3085c1426157ab175a6119a04ba", t)
+}
+func TestFilterMultiplePoolIds(t *testing.T) {
+	filterContractRequest("_poolId=0x0000000000000000000000000000000000000000000000000000000000000000,0xa7d8eff4026f252db5b90c78e43dd191dfe6e55fcb98548a5f38faf0d4e3eb39", "_poolId=0x0000000000000000000000000000000000000000000000000000000000000000,0x0000000000000000000000000000000000000000000000000000000000000001", t)
+}
+
+func TestMultiValueFilterErrors(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	defer db.Close()
+	handler := getTestSearchHandler(db)
+	tooMany := strings.TrimSuffix(strings.Repeat("0x627306090abab3a6e1400e9345bc60c78a8bef57,", 51), ",")
+	for _, testCase := range []struct {
+		queryString string
+		field       string
+	}{
+		{"makerAddress=0x627306090abab3a6e1400e9345bc60c78a8bef57,nothex", "makerAddress[1]"},
+		{"feeRecipient=nothex&feeRecipient=0x627306090abab3a6e1400e9345bc60c78a8bef57", "feeRecipient[0]"},
+		{"takerAssetData=nothex", "takerAssetData"},
+		{"makerAddress=" + tooMany, "makerAddress"},
+		{"makerAddress=,", "makerAddress"},
+		{"feeRecipient=", "feeRecipient"},
+	} {
+		request, _ := http.NewRequest("GET", "/v0/orders?"+testCase.queryString+"&blockhash=x", nil)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != 400 {
+			t.Errorf("Expected '%v' to be rejected, got '%v'", testCase.queryString, recorder.Code)
+			continue
+		}
+		apiError := &search.ApiError{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), apiError); err != nil {
+			t.Fatalf(err.Error())
+		}
+		if len(apiError.Errors) != 1 || apiError.Errors[0].Field != testCase.field {
+			t.Errorf("Expected an error on '%v', got %v", testCase.field, apiError.Errors)
+		}
+	}
+}
+
+func TestFilterMakerAssetAmountRange(t *testing.T) {
+	filterContractRequest("minMakerAssetAmount=50000000000000000000&maxMakerAssetAmount=50000000000000000000", "minMakerAssetAmount=50000000000000000001", t)
+}
+func TestFilterTakerAssetAmountRange(t *testing.T) {
+	filterContractRequest("maxTakerAssetAmount=2000000000000000000", "maxTakerAssetAmount=999999999999999999", t)
+}
+func TestFilterExpiration(t *testing.T) {
+	filterContractRequest("expiresAfter=5797808835&expiresBefore=5797808837", "expiresBefore=5797808836", t)
+}
+func TestFilterCreatedSince(t *testing.T) {
+	filterContractRequest("createdSince=0", "createdSince=2999-01-01T00:00:00Z", t)
+}
+
+func TestInvalidRangeAndSort(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	defer db.Close()
+	handler := getTestSearchHandler(db)
+	for _, queryString := range []string{"minMakerAssetAmount=-1", "minPrice=abc", "updatedSince=yesterday", "sort=colour"} {
+		request, _ := http.NewRequest("GET", "/v0/orders?"+queryString+"&blockhash=x", nil)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != 400 {
+			t.Errorf("Expected '%v' to be rejected, got '%v'", queryString, recorder.Code)
+		}
+	}
+}
 
 func TestPagination(t *testing.T) {
 	db, err := getDb()
@@ -366,6 +461,270 @@ func TestPagination(t *testing.T) {
 	}
 }
 
+func TestCursorPagination(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	for i := 0; i < 21; i++ {
+		saltedSampleOrder(t).Save(tx, 0)
+	}
+	handler := getTestSearchHandler(tx)
+	seen := make(map[string]bool)
+	url := "/v0/orders?blockhash=x&_expTime=0&cursor="
+	for _, expected := range []int{20, 1} {
+		request, _ := http.NewRequest("GET", url, nil)
+		request.Header.Set("Accept", "application/json")
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != 200 {
+			t.Fatalf("Unexpected response code '%v'", recorder.Code)
+		}
+		result := &struct {
+			PerPage int                     `json:"perPage"`
+			Records []search.FormattedOrder `json:"records"`
+			Next    string                  `json:"next"`
+		}{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf(err.Error())
+		}
+		if length := len(result.Records); length != expected {
+			t.Errorf("Expected %v items, got '%v'", expected, length)
+		}
+		for _, order := range result.Records {
+			if seen[order.Metadata.Hash] {
+				t.Errorf("Order %v returned twice", order.Metadata.Hash)
+			}
+			seen[order.Metadata.Hash] = true
+		}
+		link := recorder.Header().Get("Link")
+		if expected == 20 && (result.Next == "" || link == "") {
+			t.Fatalf("Expected a next cursor")
+		}
+		if expected == 1 && (result.Next != "" || link != "") {
+			t.Errorf("Expected no next cursor on the last page")
+		}
+		url = "/v0/orders?blockhash=x&_expTime=0&cursor=" + result.Next
+	}
+	request, _ := http.NewRequest("GET", "/v0/orders?blockhash=x&_expTime=0&cursor=garbage", nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 400 {
+		t.Errorf("Expected malformed cursor to be rejected, got '%v'", recorder.Code)
+	}
+}
+
+// pricedOrders saves orders priced at 0.04, 0.02 and 0.06, in that order.
+// The last has a fee rate of 0.1, and the others no fee.
+func pricedOrders(t *testing.T, tx *gorm.DB) (*dbModule.Order, *dbModule.Order, *dbModule.Order) {
+	orders := []*dbModule.Order{}
+	for _, takerAmount := range []int64{2, 1, 3} {
+		amount := new(big.Int).Mul(big.NewInt(takerAmount), big.NewInt(1000000000000000000))
+		order := amendedSampleOrder(t, func(order *dbModule.Order) {
+			order.TakerAssetAmount = common.BigToUint256(amount)
+			if takerAmount == 3 {
+				order.TakerFee = common.BigToUint256(new(big.Int).Div(amount, big.NewInt(10)))
+			}
+		})
+		if err := order.Save(tx, dbModule.StatusOpen).Error; err != nil {
+			t.Fatalf(err.Error())
+		}
+		orders = append(orders, order)
+	}
+	return orders[1], orders[0], orders[2]
+}
+
+// searchHashes returns the hashes of the orders in a search response, along
+// with its next cursor
+func searchHashes(t *testing.T, handler func(http.ResponseWriter, *http.Request), url string) ([]string, string) {
+	request, _ := http.NewRequest("GET", url, nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 200 {
+		t.Fatalf("Unexpected response code '%v' for '%v'", recorder.Code, url)
+	}
+	result := &struct {
+		Records []search.FormattedOrder `json:"records"`
+		Next    string                  `json:"next"`
+	}{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+		t.Fatalf(err.Error())
+	}
+	hashes := []string{}
+	for _, record := range result.Records {
+		hashes = append(hashes, record.Metadata.Hash)
+	}
+	return hashes, result.Next
+}
+
+// cursorHashes follows the cursors of a search two orders at a time,
+// returning the hashes of every order
+func cursorHashes(t *testing.T, handler func(http.ResponseWriter, *http.Request), url string) []string {
+	hashes := []string{}
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		page, next := searchHashes(t, handler, url+"&per_page=2&cursor="+cursor)
+		hashes = append(hashes, page...)
+		if next == "" {
+			return hashes
+		}
+		cursor = next
+	}
+	t.Fatalf("Too many pages for '%v'", url)
+	return nil
+}
+
+func hashList(orders ...*dbModule.Order) []string {
+	hashes := []string{}
+	for _, order := range orders {
+		hashes = append(hashes, fmt.Sprintf("%#x", order.OrderHash))
+	}
+	return hashes
+}
+
+func TestSortAndPriceFilters(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	cheap, middle, dear := pricedOrders(t, tx)
+	handler := getTestSearchHandler(tx)
+	for _, testCase := range []struct {
+		queryString string
+		expected    []string
+	}{
+		{"sort=price", hashList(cheap, middle, dear)},
+		{"sort=-price", hashList(dear, middle, cheap)},
+		{"sort=createdAt", hashList(middle, cheap, dear)},
+		{"sort=-createdAt", hashList(dear, cheap, middle)},
+		{"sort=price&minPrice=0.03", hashList(middle, dear)},
+		{"sort=price&maxPrice=0.04", hashList(cheap, middle)},
+		{"sort=price&minPrice=0.02&maxPrice=0.02", hashList(cheap)},
+		{"sort=price&maxFeeRate=0.05", hashList(cheap, middle)},
+		{"sort=-price&maxFeeRate=0.1", hashList(dear, middle, cheap)},
+	} {
+		url := "/v0/orders?" + testCase.queryString + "&blockhash=x&_expTime=0"
+		if hashes, _ := searchHashes(t, handler, url); !reflect.DeepEqual(hashes, testCase.expected) {
+			t.Errorf("Expected %v for '%v', got %v", testCase.expected, testCase.queryString, hashes)
+		}
+		if hashes := cursorHashes(t, handler, url); !reflect.DeepEqual(hashes, testCase.expected) {
+			t.Errorf("Expected %v for '%v' by cursor, got %v", testCase.expected, testCase.queryString, hashes)
+		}
+	}
+}
+
+func TestStatusFilter(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	filled := saltedSampleOrder(t)
+	filled.TakerAssetAmountFilled = filled.TakerAssetAmount
+	if err := filled.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	cancelled := saltedSampleOrder(t)
+	cancelled.Status = dbModule.StatusCancelled
+	if err := cancelled.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	handler := getTestSearchHandler(tx)
+	for _, testCase := range []struct {
+		queryString string
+		key         *apikeys.APIKey
+		code        int
+		hashes      []string
+	}{
+		{"makerAddress=" + filled.Maker.String(), nil, 200, []string{}},
+		{"makerAddress=" + filled.Maker.String() + "&status=filled", nil, 200, []string{fmt.Sprintf("%#x", filled.OrderHash)}},
+		{"makerAddress=" + filled.Maker.String() + "&status=open,cancelled", nil, 200, []string{}},
+		{"traderAddress=" + cancelled.Maker.String() + "&status=filled&status=cancelled", nil, 200, []string{fmt.Sprintf("%#x", cancelled.OrderHash)}},
+		{"status=filled", nil, 400, nil},
+		{"status=filled", &apikeys.APIKey{}, 400, nil},
+		{"status=filled", &apikeys.APIKey{PrivateOrders: true}, 200, []string{fmt.Sprintf("%#x", filled.OrderHash)}},
+		{"makerAddress=,&status=filled", nil, 400, nil},
+		{"makerAddress=" + filled.Maker.String() + "&status=archived", nil, 400, nil},
+	} {
+		request, _ := http.NewRequest("GET", "/v0/orders?"+testCase.queryString+"&blockhash=x&_expTime=0", nil)
+		if testCase.key != nil {
+			request = request.WithContext(apikeys.NewContext(request.Context(), testCase.key))
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != testCase.code {
+			t.Errorf("Unexpected response code '%v' for '%v'", recorder.Code, testCase.queryString)
+			continue
+		}
+		if testCase.code != 200 {
+			continue
+		}
+		result := &search.PagedOrders{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf(err.Error())
+		}
+		if len(result.Records) != len(testCase.hashes) {
+			t.Errorf("Expected %v orders for '%v', got %v", len(testCase.hashes), testCase.queryString, len(result.Records))
+			continue
+		}
+		for i, record := range result.Records {
+			if record.Metadata.Hash != testCase.hashes[i] {
+				t.Errorf("Expected order %v, got %v", testCase.hashes[i], record.Metadata.Hash)
+			}
+			if record.Metadata.CreatedAt == nil || record.Metadata.UpdatedAt == nil {
+				t.Errorf("Expected timestamps on order %v", record.Metadata.Hash)
+			}
+		}
+	}
+}
+
 func TestOrderLookup(t *testing.T) {
 	db, err := getDb()
 	if err != nil {
@@ -401,8 +760,8 @@ func TestOrderLookup(t *testing.T) {
 	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
 		t.Errorf("Unexpected Content-Type: '%v'", contentType)
 	}
-	response := recorder.Body.String()
-	if string(response) != "{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\"}}" {
+	response := withoutTimestamps(recorder.Body.String())
+	if string(response) != "{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\",\"remainingFillableTakerAssetAmount\":\"1000000000000000000\",\"takerAssetAmountFilled\":\"0\"}}" {
 		t.Errorf("Got '%v'", string(response))
 	}
 }
@@ -440,12 +799,201 @@ func TestPairLookup(t *testing.T) {
 	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
 		t.Errorf("Unexpected Content-Type: '%v'", contentType)
 	}
-	response := recorder.Body.String()
+	response := withoutTimestamps(recorder.Body.String())
 	if string(response) != "{\"total\":1,\"page\":1,\"perPage\":20,\"records\":[{\"assetDataA\":{\"assetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"minAmount\":\"1\",\"maxAmount\":\"115792089237316195423570985008687907853269984665640564039457584007913129639935\",\"precision\":5},\"assetDataB\":{\"assetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"minAmount\":\"1\",\"maxAmount\":\"115792089237316195423570985008687907853269984665640564039457584007913129639935\",\"precision\":5}}]}" {
 		t.Errorf("Got unexpected JSON response '%v'", string(response))
 	}
 }
 
+func TestPriceCursorPagination(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	cheap, middle, dear := pricedOrders(t, tx)
+	// A second order at the middle price, which the hash orders against the
+	// first
+	twin := amendedSampleOrder(t, func(order *dbModule.Order) {
+		order.TakerAssetAmount = middle.TakerAssetAmount
+	})
+	if err := twin.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := hashList(cheap, middle, twin, dear)
+	if bytes.Compare(twin.OrderHash, middle.OrderHash) < 0 {
+		expected = hashList(cheap, twin, middle, dear)
+	}
+	handler := getTestSearchHandler(tx)
+	url := "/v0/orders?makerAssetAddress=0x1dad4783cf3fe3085c1426157ab175a6119a04ba&takerAssetAddress=0x05d090b51c40b020eab3bfcb6a2dff130df22e9c&blockhash=x&_expTime=0"
+	if hashes := cursorHashes(t, handler, url); !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("Expected %v, got %v", expected, hashes)
+	}
+}
+
+// orderBookPage returns the hashes of each side of an order book response,
+// along with its next cursor
+func orderBookPage(t *testing.T, handler func(http.ResponseWriter, *http.Request), url string) ([]string, []string, string) {
+	request, _ := http.NewRequest("GET", url, nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 200 {
+		t.Fatalf("Unexpected response code '%v' for '%v'", recorder.Code, url)
+	}
+	type side struct {
+		Records []search.FormattedOrder `json:"records"`
+	}
+	result := &struct {
+		Asks side   `json:"asks"`
+		Bids side   `json:"bids"`
+		Next string `json:"next"`
+	}{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+		t.Fatalf(err.Error())
+	}
+	asks := []string{}
+	for _, record := range result.Asks.Records {
+		asks = append(asks, record.Metadata.Hash)
+	}
+	bids := []string{}
+	for _, record := range result.Bids.Records {
+		bids = append(bids, record.Metadata.Hash)
+	}
+	if link := recorder.Header().Get("Link"); (link == "") != (result.Next == "") {
+		t.Errorf("Expected the Link header to match the next cursor, got '%v'", link)
+	}
+	return asks, bids, result.Next
+}
+
+func TestOrderBookCursorPagination(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	cheap, middle, dear := pricedOrders(t, tx)
+	ask := amendedSampleOrder(t, func(order *dbModule.Order) {
+		order.MakerAssetData, order.TakerAssetData = order.TakerAssetData, order.MakerAssetData
+	})
+	if err := ask.Save(tx, dbModule.StatusOpen).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	handler := getTestOrderBookHandler(tx)
+	url := "/v0/orderbook?blockhash=x&quoteAssetData=0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba&baseAssetData=0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c&_expTime=0&per_page=2&cursor="
+	// The asks run out on the first page, while the bids take two
+	asks, bids, next := orderBookPage(t, handler, url)
+	if !reflect.DeepEqual(asks, hashList(ask)) || !reflect.DeepEqual(bids, hashList(cheap, middle)) {
+		t.Errorf("Unexpected first page: asks %v, bids %v", asks, bids)
+	}
+	if next == "" {
+		t.Fatalf("Expected a next cursor")
+	}
+	asks, bids, next = orderBookPage(t, handler, url+next)
+	if len(asks) != 0 || !reflect.DeepEqual(bids, hashList(dear)) {
+		t.Errorf("Unexpected second page: asks %v, bids %v", asks, bids)
+	}
+	if next != "" {
+		t.Errorf("Expected no next cursor on the last page")
+	}
+	request, _ := http.NewRequest("GET", url+"garbage", nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != 400 {
+		t.Errorf("Expected malformed cursor to be rejected, got '%v'", recorder.Code)
+	}
+}
+
+func TestOrderBookPagingModesAgree(t *testing.T) {
+	db, err := getDb()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	tx := db.Begin()
+	defer func() {
+		tx.Rollback()
+		db.Close()
+	}()
+	if err := tx.AutoMigrate(&dbModule.Order{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := tx.AutoMigrate(&dbModule.Exchange{}).Error; err != nil {
+		t.Errorf(err.Error())
+	}
+	sampleAddress, _ := common.HexToAddress("0x90fe2af704b34e0224bf2299c838e04d4dcf1364")
+	tx.Where(
+		&dbModule.Exchange{Network: 1},
+	).FirstOrCreate(&dbModule.Exchange{Network: 1, Address: sampleAddress })
+	// Every order is at the same price, so the fillable amount and the
+	// expiration decide the order
+	full := saltedSampleOrder(t)
+	later := amendedSampleOrder(t, func(order *dbModule.Order) {
+		order.ExpirationTimestampInSec = common.BigToUint256(new(big.Int).Add(order.ExpirationTimestampInSec.Big(), big.NewInt(1)))
+	})
+	partial := amendedSampleOrder(t, func(order *dbModule.Order) {
+		order.TakerAssetAmountFilled = common.BigToUint256(new(big.Int).Div(order.TakerAssetAmount.Big(), big.NewInt(2)))
+	})
+	legacy := saltedSampleOrder(t)
+	unpriced := saltedSampleOrder(t)
+	for _, order := range []*dbModule.Order{full, later, partial, legacy, unpriced} {
+		if err := order.Save(tx, dbModule.StatusOpen).Error; err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	// Orders recorded before fillable amounts or prices were tracked
+	if err := tx.Model(&dbModule.Order{}).Where("order_hash = ?", legacy.OrderHash).UpdateColumn("remaining_fillable_taker_asset_amount", gorm.Expr("NULL")).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := tx.Model(&dbModule.Order{}).Where("order_hash = ?", unpriced.OrderHash).UpdateColumn("price", gorm.Expr("NULL")).Error; err != nil {
+		t.Fatalf(err.Error())
+	}
+	handler := getTestOrderBookHandler(tx)
+	url := "/v0/orderbook?blockhash=x&quoteAssetData=0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba&baseAssetData=0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c&_expTime=0&per_page=3"
+	_, pageBids, _ := orderBookPage(t, handler, url)
+	_, cursorBids, next := orderBookPage(t, handler, url+"&cursor=")
+	expected := hashList(legacy, full, later)
+	if !reflect.DeepEqual(pageBids, expected) {
+		t.Errorf("Expected first page %v, got %v", expected, pageBids)
+	}
+	if !reflect.DeepEqual(cursorBids, pageBids) {
+		t.Errorf("Expected the first cursor page to match the first numbered page %v, got %v", pageBids, cursorBids)
+	}
+	_, cursorBids, _ = orderBookPage(t, handler, url+"&cursor="+next)
+	if !reflect.DeepEqual(cursorBids, hashList(partial)) {
+		t.Errorf("Unexpected second cursor page %v", cursorBids)
+	}
+}
+
 func TestOrderBookLookup(t *testing.T) {
 	db, err := getDb()
 	if err != nil {
@@ -481,8 +1029,8 @@ func TestOrderBookLookup(t *testing.T) {
 	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
 		t.Errorf("Unexpected Content-Type: '%v'", contentType)
 	}
-	response := recorder.Body.String()
-	if string(response) != "{\"asks\":{\"total\":0,\"page\":1,\"perPage\":20,\"records\":[]},\"bids\":{\"total\":1,\"page\":1,\"perPage\":20,\"records\":[{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\"}}]}}" {
+	response := withoutTimestamps(recorder.Body.String())
+	if string(response) != "{\"asks\":{\"total\":0,\"page\":1,\"perPage\":20,\"records\":[]},\"bids\":{\"total\":1,\"page\":1,\"perPage\":20,\"records\":[{\"order\":{\"makerAddress\":\"0x627306090abab3a6e1400e9345bc60c78a8bef57\",\"takerAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetData\":\"0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba\",\"takerAssetData\":\"0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c\",\"feeRecipientAddress\":\"0x0000000000000000000000000000000000000000\",\"exchangeAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"senderAddress\":\"0x0000000000000000000000000000000000000000\",\"makerAssetAmount\":\"50000000000000000000\",\"takerAssetAmount\":\"1000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"expirationTimeSeconds\":\"5797808836\",\"salt\":\"11065671350908846865864045738088581419204014210814002044381812654087807531\",\"signature\":\"0x1ba0ebab93c67e7cdf45e50c83b3a47681918c3f47f220935eb92b7338788024c82a0329105e2259b128ec811b69eb9eee253027089d544c37a1cc33b433ab9b8e03\"},\"metaData\":{\"hash\":\"0x0fa71adbd21643cbb4e87ab8e411655775b626b587e50d7b5303cf1a532e3be7\",\"feeRate\":0,\"status\":0,\"takerAssetAmountRemaining\":\"1000000000000000000\",\"remainingFillableTakerAssetAmount\":\"1000000000000000000\",\"takerAssetAmountFilled\":\"0\"}}]}}" {
 		t.Errorf("Got '%v'", string(response))
 	}
 }
@@ -0,0 +1,403 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package token
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TokenMetadataABI is the input ABI used to generate the binding from.
+const TokenMetadataABI = "[{\"constant\":true,\"inputs\":[],\"name\":\"name\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"symbol\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"decimals\",\"outputs\":[{\"name\":\"\",\"type\":\"uint8\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+// TokenMetadata is an auto generated Go binding around an Ethereum contract.
+type TokenMetadata struct {
+	TokenMetadataCaller     // Read-only binding to the contract
+	TokenMetadataTransactor // Write-only binding to the contract
+}
+
+// TokenMetadataCaller is an auto generated read-only Go binding around an Ethereum contract.
+type TokenMetadataCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// TokenMetadataTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type TokenMetadataTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// TokenMetadataSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type TokenMetadataSession struct {
+	Contract     *TokenMetadata    // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// TokenMetadataCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type TokenMetadataCallerSession struct {
+	Contract *TokenMetadataCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts        // Call options to use throughout this session
+}
+
+// TokenMetadataTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type TokenMetadataTransactorSession struct {
+	Contract     *TokenMetadataTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts        // Transaction auth options to use throughout this session
+}
+
+// TokenMetadataRaw is an auto generated low-level Go binding around an Ethereum contract.
+type TokenMetadataRaw struct {
+	Contract *TokenMetadata // Generic contract binding to access the raw methods on
+}
+
+// TokenMetadataCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type TokenMetadataCallerRaw struct {
+	Contract *TokenMetadataCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// TokenMetadataTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type TokenMetadataTransactorRaw struct {
+	Contract *TokenMetadataTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewTokenMetadata creates a new instance of TokenMetadata, bound to a specific deployed contract.
+func NewTokenMetadata(address common.Address, backend bind.ContractBackend) (*TokenMetadata, error) {
+	contract, err := bindTokenMetadata(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenMetadata{TokenMetadataCaller: TokenMetadataCaller{contract: contract}, TokenMetadataTransactor: TokenMetadataTransactor{contract: contract}}, nil
+}
+
+// NewTokenMetadataCaller creates a new read-only instance of TokenMetadata, bound to a specific deployed contract.
+func NewTokenMetadataCaller(address common.Address, caller bind.ContractCaller) (*TokenMetadataCaller, error) {
+	contract, err := bindTokenMetadata(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenMetadataCaller{contract: contract}, nil
+}
+
+// NewTokenMetadataTransactor creates a new write-only instance of TokenMetadata, bound to a specific deployed contract.
+func NewTokenMetadataTransactor(address common.Address, transactor bind.ContractTransactor) (*TokenMetadataTransactor, error) {
+	contract, err := bindTokenMetadata(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenMetadataTransactor{contract: contract}, nil
+}
+
+// bindTokenMetadata binds a generic wrapper to an already deployed contract.
+func bindTokenMetadata(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(TokenMetadataABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_TokenMetadata *TokenMetadataRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _TokenMetadata.Contract.TokenMetadataCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_TokenMetadata *TokenMetadataRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _TokenMetadata.Contract.TokenMetadataTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_TokenMetadata *TokenMetadataRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _TokenMetadata.Contract.TokenMetadataTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_TokenMetadata *TokenMetadataCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _TokenMetadata.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_TokenMetadata *TokenMetadataTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _TokenMetadata.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_TokenMetadata *TokenMetadataTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _TokenMetadata.Contract.contract.Transact(opts, method, params...)
+}
+
+// Decimals is a free data retrieval call binding the contract method 0x313ce567.
+//
+// Solidity: function decimals() constant returns(uint8)
+func (_TokenMetadata *TokenMetadataCaller) Decimals(opts *bind.CallOpts) (uint8, error) {
+	var (
+		ret0 = new(uint8)
+	)
+	out := ret0
+	err := _TokenMetadata.contract.Call(opts, out, "decimals")
+	return *ret0, err
+}
+
+// Decimals is a free data retrieval call binding the contract method 0x313ce567.
+//
+// Solidity: function decimals() constant returns(uint8)
+func (_TokenMetadata *TokenMetadataSession) Decimals() (uint8, error) {
+	return _TokenMetadata.Contract.Decimals(&_TokenMetadata.CallOpts)
+}
+
+// Decimals is a free data retrieval call binding the contract method 0x313ce567.
+//
+// Solidity: function decimals() constant returns(uint8)
+func (_TokenMetadata *TokenMetadataCallerSession) Decimals() (uint8, error) {
+	return _TokenMetadata.Contract.Decimals(&_TokenMetadata.CallOpts)
+}
+
+// Name is a free data retrieval call binding the contract method 0x06fdde03.
+//
+// Solidity: function name() constant returns(string)
+func (_TokenMetadata *TokenMetadataCaller) Name(opts *bind.CallOpts) (string, error) {
+	var (
+		ret0 = new(string)
+	)
+	out := ret0
+	err := _TokenMetadata.contract.Call(opts, out, "name")
+	return *ret0, err
+}
+
+// Name is a free data retrieval call binding the contract method 0x06fdde03.
+//
+// Solidity: function name() constant returns(string)
+func (_TokenMetadata *TokenMetadataSession) Name() (string, error) {
+	return _TokenMetadata.Contract.Name(&_TokenMetadata.CallOpts)
+}
+
+// Name is a free data retrieval call binding the contract method 0x06fdde03.
+//
+// Solidity: function name() constant returns(string)
+func (_TokenMetadata *TokenMetadataCallerSession) Name() (string, error) {
+	return _TokenMetadata.Contract.Name(&_TokenMetadata.CallOpts)
+}
+
+// Symbol is a free data retrieval call binding the contract method 0x95d89b41.
+//
+// Solidity: function symbol() constant returns(string)
+func (_TokenMetadata *TokenMetadataCaller) Symbol(opts *bind.CallOpts) (string, error) {
+	var (
+		ret0 = new(string)
+	)
+	out := ret0
+	err := _TokenMetadata.contract.Call(opts, out, "symbol")
+	return *ret0, err
+}
+
+// Symbol is a free data retrieval call binding the contract method 0x95d89b41.
+//
+// Solidity: function symbol() constant returns(string)
+func (_TokenMetadata *TokenMetadataSession) Symbol() (string, error) {
+	return _TokenMetadata.Contract.Symbol(&_TokenMetadata.CallOpts)
+}
+
+// Symbol is a free data retrieval call binding the contract method 0x95d89b41.
+//
+// Solidity: function symbol() constant returns(string)
+func (_TokenMetadata *TokenMetadataCallerSession) Symbol() (string, error) {
+	return _TokenMetadata.Contract.Symbol(&_TokenMetadata.CallOpts)
+}
+
+// TokenMetadataBytes32ABI is the input ABI used to generate the binding from.
+const TokenMetadataBytes32ABI = "[{\"constant\":true,\"inputs\":[],\"name\":\"name\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"symbol\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+// TokenMetadataBytes32 is an auto generated Go binding around an Ethereum contract.
+type TokenMetadataBytes32 struct {
+	TokenMetadataBytes32Caller     // Read-only binding to the contract
+	TokenMetadataBytes32Transactor // Write-only binding to the contract
+}
+
+// TokenMetadataBytes32Caller is an auto generated read-only Go binding around an Ethereum contract.
+type TokenMetadataBytes32Caller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// TokenMetadataBytes32Transactor is an auto generated write-only Go binding around an Ethereum contract.
+type TokenMetadataBytes32Transactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// TokenMetadataBytes32Session is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type TokenMetadataBytes32Session struct {
+	Contract     *TokenMetadataBytes32 // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts         // Call options to use throughout this session
+	TransactOpts bind.TransactOpts     // Transaction auth options to use throughout this session
+}
+
+// TokenMetadataBytes32CallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type TokenMetadataBytes32CallerSession struct {
+	Contract *TokenMetadataBytes32Caller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts               // Call options to use throughout this session
+}
+
+// TokenMetadataBytes32TransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type TokenMetadataBytes32TransactorSession struct {
+	Contract     *TokenMetadataBytes32Transactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts               // Transaction auth options to use throughout this session
+}
+
+// TokenMetadataBytes32Raw is an auto generated low-level Go binding around an Ethereum contract.
+type TokenMetadataBytes32Raw struct {
+	Contract *TokenMetadataBytes32 // Generic contract binding to access the raw methods on
+}
+
+// TokenMetadataBytes32CallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type TokenMetadataBytes32CallerRaw struct {
+	Contract *TokenMetadataBytes32Caller // Generic read-only contract binding to access the raw methods on
+}
+
+// TokenMetadataBytes32TransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type TokenMetadataBytes32TransactorRaw struct {
+	Contract *TokenMetadataBytes32Transactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewTokenMetadataBytes32 creates a new instance of TokenMetadataBytes32, bound to a specific deployed contract.
+func NewTokenMetadataBytes32(address common.Address, backend bind.ContractBackend) (*TokenMetadataBytes32, error) {
+	contract, err := bindTokenMetadataBytes32(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenMetadataBytes32{TokenMetadataBytes32Caller: TokenMetadataBytes32Caller{contract: contract}, TokenMetadataBytes32Transactor: TokenMetadataBytes32Transactor{contract: contract}}, nil
+}
+
+// NewTokenMetadataBytes32Caller creates a new read-only instance of TokenMetadataBytes32, bound to a specific deployed contract.
+func NewTokenMetadataBytes32Caller(address common.Address, caller bind.ContractCaller) (*TokenMetadataBytes32Caller, error) {
+	contract, err := bindTokenMetadataBytes32(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenMetadataBytes32Caller{contract: contract}, nil
+}
+
+// NewTokenMetadataBytes32Transactor creates a new write-only instance of TokenMetadataBytes32, bound to a specific deployed contract.
+func NewTokenMetadataBytes32Transactor(address common.Address, transactor bind.ContractTransactor) (*TokenMetadataBytes32Transactor, error) {
+	contract, err := bindTokenMetadataBytes32(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenMetadataBytes32Transactor{contract: contract}, nil
+}
+
+// bindTokenMetadataBytes32 binds a generic wrapper to an already deployed contract.
+func bindTokenMetadataBytes32(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(TokenMetadataBytes32ABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Raw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _TokenMetadataBytes32.Contract.TokenMetadataBytes32Caller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Raw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _TokenMetadataBytes32.Contract.TokenMetadataBytes32Transactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Raw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _TokenMetadataBytes32.Contract.TokenMetadataBytes32Transactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_TokenMetadataBytes32 *TokenMetadataBytes32CallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _TokenMetadataBytes32.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_TokenMetadataBytes32 *TokenMetadataBytes32TransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _TokenMetadataBytes32.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_TokenMetadataBytes32 *TokenMetadataBytes32TransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _TokenMetadataBytes32.Contract.contract.Transact(opts, method, params...)
+}
+
+// Name is a free data retrieval call binding the contract method 0x06fdde03.
+//
+// Solidity: function name() constant returns(bytes32)
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Caller) Name(opts *bind.CallOpts) ([32]byte, error) {
+	var (
+		ret0 = new([32]byte)
+	)
+	out := ret0
+	err := _TokenMetadataBytes32.contract.Call(opts, out, "name")
+	return *ret0, err
+}
+
+// Name is a free data retrieval call binding the contract method 0x06fdde03.
+//
+// Solidity: function name() constant returns(bytes32)
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Session) Name() ([32]byte, error) {
+	return _TokenMetadataBytes32.Contract.Name(&_TokenMetadataBytes32.CallOpts)
+}
+
+// Name is a free data retrieval call binding the contract method 0x06fdde03.
+//
+// Solidity: function name() constant returns(bytes32)
+func (_TokenMetadataBytes32 *TokenMetadataBytes32CallerSession) Name() ([32]byte, error) {
+	return _TokenMetadataBytes32.Contract.Name(&_TokenMetadataBytes32.CallOpts)
+}
+
+// Symbol is a free data retrieval call binding the contract method 0x95d89b41.
+//
+// Solidity: function symbol() constant returns(bytes32)
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Caller) Symbol(opts *bind.CallOpts) ([32]byte, error) {
+	var (
+		ret0 = new([32]byte)
+	)
+	out := ret0
+	err := _TokenMetadataBytes32.contract.Call(opts, out, "symbol")
+	return *ret0, err
+}
+
+// Symbol is a free data retrieval call binding the contract method 0x95d89b41.
+//
+// Solidity: function symbol() constant returns(bytes32)
+func (_TokenMetadataBytes32 *TokenMetadataBytes32Session) Symbol() ([32]byte, error) {
+	return _TokenMetadataBytes32.Contract.Symbol(&_TokenMetadataBytes32.CallOpts)
+}
+
+// Symbol is a free data retrieval call binding the contract method 0x95d89b41.
+//
+// Solidity: function symbol() constant returns(bytes32)
+func (_TokenMetadataBytes32 *TokenMetadataBytes32CallerSession) Symbol() ([32]byte, error) {
+	return _TokenMetadataBytes32.Contract.Symbol(&_TokenMetadataBytes32.CallOpts)
+}
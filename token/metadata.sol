@@ -0,0 +1,15 @@
+pragma solidity 0.4.24;
+
+// Optional ERC20 and ERC721 metadata methods
+contract TokenMetadata {
+    function name() public view returns (string) {}
+    function symbol() public view returns (string) {}
+    function decimals() public view returns (uint8) {}
+}
+
+// Metadata methods of tokens deployed before string returns were
+// standardized, such as MKR
+contract TokenMetadataBytes32 {
+    function name() public view returns (bytes32) {}
+    function symbol() public view returns (bytes32) {}
+}
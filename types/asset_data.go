@@ -3,7 +3,11 @@ package types
 import (
 	"bytes"
 	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
 )
 
 type AssetData []byte
@@ -11,6 +15,16 @@ type AssetData []byte
 var ERC20ProxyID = [4]byte{244, 114, 97, 176}
 var ERC721ProxyID = [4]byte{2, 87, 23, 146}
 var RoboDexProxyID = [4]byte{14, 32, 66, 216}
+var MultiAssetProxyID = [4]byte{148, 207, 205, 215}
+var ERC1155ProxyID = [4]byte{167, 203, 95, 183}
+
+// AssetComponent is one of the assets making up a MultiAssetProxy bundle.
+// Amount is the number of units of AssetData transferred per unit of the
+// bundle.
+type AssetComponent struct {
+	Amount    *big.Int
+	AssetData AssetData
+}
 
 func (data AssetData) ProxyId() [4]byte {
 	result := [4]byte{}
@@ -18,25 +32,225 @@ func (data AssetData) ProxyId() [4]byte {
 	return result
 }
 
+// Address returns the token address of the asset. For MultiAssetProxy
+// bundles, this is the token address shared by every component (such as a
+// bundle of several ERC721 tokens from one contract), or the zero address if
+// the components involve more than one token.
 func (data AssetData) Address() *Address {
 	address := &Address{}
-	if data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID) || data.IsType(RoboDexProxyID) {
+	if len(data) < 36 {
+		return address
+	}
+	if data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID) || data.IsType(RoboDexProxyID) || data.IsType(ERC1155ProxyID) {
 		copy(address[:], data[16:36])
+	} else if data.IsType(MultiAssetProxyID) {
+		components, err := data.Components()
+		if err != nil || len(components) == 0 {
+			return address
+		}
+		first := components[0].AssetData.Address()
+		for _, component := range components[1:] {
+			if !bytes.Equal(first[:], component.AssetData.Address()[:]) {
+				return address
+			}
+		}
+		return first
 	}
 	return address
 }
 
 func (data AssetData) IsType(proxyId [4]byte) bool {
-	return bytes.Equal(data[0:4], proxyId[:])
+	return len(data) >= 4 && bytes.Equal(data[0:4], proxyId[:])
 }
 
+// SupportedType indicates whether the asset data is for a proxy we support.
+// MultiAssetProxy bundles are supported if they decode properly and all of
+// their components are supported. Bundles may not be nested.
 func (data AssetData) SupportedType() bool {
+	if data.IsType(MultiAssetProxyID) {
+		components, err := data.Components()
+		if err != nil || len(components) == 0 {
+			return false
+		}
+		for _, component := range components {
+			if component.AssetData.IsType(MultiAssetProxyID) || !component.AssetData.SupportedType() {
+				return false
+			}
+		}
+		return true
+	}
+	if data.IsType(ERC1155ProxyID) {
+		ids, _, _, err := data.ERC1155Items()
+		return err == nil && len(ids) > 0
+	}
 	return data.IsType(ERC20ProxyID) || data.IsType(ERC721ProxyID) || data.IsType(RoboDexProxyID)
 }
 
+func readAbiUint(data []byte, offset int) (int, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return 0, errors.New("Asset data too short")
+	}
+	value := new(big.Int).SetBytes(data[offset : offset+32])
+	if !value.IsInt64() || value.Int64() > int64(len(data)) {
+		return 0, errors.New("Asset data offset out of range")
+	}
+	return int(value.Int64()), nil
+}
+
+func readAbiUintArray(data []byte, offset int) ([]*big.Int, error) {
+	length, err := readAbiUint(data, offset)
+	if err != nil {
+		return nil, err
+	}
+	if offset+32+32*length > len(data) {
+		return nil, errors.New("Asset data too short")
+	}
+	result := make([]*big.Int, length)
+	for i := range result {
+		start := offset + 32 + 32*i
+		result[i] = new(big.Int).SetBytes(data[start : start+32])
+	}
+	return result, nil
+}
+
+// ERC1155Items decodes ERC1155 asset data, encoded as
+// ERC1155Assets(address tokenAddress, uint256[] tokenIds,
+// uint256[] tokenValues, bytes callbackData). One unit of the asset is
+// tokenValues[i] of each tokenIds[i].
+func (data AssetData) ERC1155Items() (ids []*big.Int, values []*big.Int, callbackData []byte, err error) {
+	if !data.IsType(ERC1155ProxyID) {
+		return nil, nil, nil, errors.New("Not ERC1155 asset data")
+	}
+	body := data[4:]
+	idsOffset, err := readAbiUint(body, 32)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	valuesOffset, err := readAbiUint(body, 64)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	callbackOffset, err := readAbiUint(body, 96)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ids, err = readAbiUintArray(body, idsOffset); err != nil {
+		return nil, nil, nil, err
+	}
+	if values, err = readAbiUintArray(body, valuesOffset); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(ids) != len(values) {
+		return nil, nil, nil, errors.New("ERC1155 tokenIds and tokenValues lengths differ")
+	}
+	callbackLength, err := readAbiUint(body, callbackOffset)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if callbackOffset+32+callbackLength > len(body) {
+		return nil, nil, nil, errors.New("Asset data too short")
+	}
+	callbackData = make([]byte, callbackLength)
+	copy(callbackData, body[callbackOffset+32:callbackOffset+32+callbackLength])
+	return ids, values, callbackData, nil
+}
+
+// ERC20AssetData returns the asset data for an ERC20 token
+func ERC20AssetData(tokenAddress *Address) AssetData {
+	assetData := append(AssetData{}, ERC20ProxyID[:]...)
+	assetData = append(assetData, make([]byte, 12)...)
+	return append(assetData, tokenAddress[:]...)
+}
+
+// ERC1155ItemAssetData returns the canonical asset data for a single ERC1155
+// token id: one of tokenID, with no callback data. ERC1155 assets are broken
+// down into these for tracking transfers of individual ids.
+func ERC1155ItemAssetData(tokenAddress *Address, tokenID *big.Int) AssetData {
+	word := func(value int64) []byte {
+		return abi.U256(big.NewInt(value))
+	}
+	assetData := append(AssetData{}, ERC1155ProxyID[:]...)
+	assetData = append(assetData, make([]byte, 12)...)
+	assetData = append(assetData, tokenAddress[:]...)
+	assetData = append(assetData, word(128)...)
+	assetData = append(assetData, word(192)...)
+	assetData = append(assetData, word(256)...)
+	assetData = append(assetData, word(1)...)
+	assetData = append(assetData, abi.U256(new(big.Int).Set(tokenID))...)
+	assetData = append(assetData, word(1)...)
+	assetData = append(assetData, word(1)...)
+	return append(assetData, word(0)...)
+}
+
+// Components decodes the assets making up a bundle. MultiAssetProxy data is
+// encoded as MultiAsset(uint256[] amounts, bytes[] nestedAssetData). ERC1155
+// data is broken down into one component per token id (see
+// ERC1155ItemAssetData). Other asset types are returned as a single
+// component with an amount of 1.
+func (data AssetData) Components() ([]AssetComponent, error) {
+	if data.IsType(ERC1155ProxyID) {
+		ids, values, _, err := data.ERC1155Items()
+		if err != nil {
+			return nil, err
+		}
+		components := make([]AssetComponent, len(ids))
+		for i := range ids {
+			components[i] = AssetComponent{values[i], ERC1155ItemAssetData(data.Address(), ids[i])}
+		}
+		return components, nil
+	}
+	if !data.IsType(MultiAssetProxyID) {
+		return []AssetComponent{AssetComponent{big.NewInt(1), data}}, nil
+	}
+	body := data[4:]
+	amountsOffset, err := readAbiUint(body, 0)
+	if err != nil {
+		return nil, err
+	}
+	nestedOffset, err := readAbiUint(body, 32)
+	if err != nil {
+		return nil, err
+	}
+	amountCount, err := readAbiUint(body, amountsOffset)
+	if err != nil {
+		return nil, err
+	}
+	nestedCount, err := readAbiUint(body, nestedOffset)
+	if err != nil {
+		return nil, err
+	}
+	if amountCount != nestedCount {
+		return nil, errors.New("MultiAsset amounts and nestedAssetData lengths differ")
+	}
+	components := make([]AssetComponent, amountCount)
+	for i := range components {
+		amountStart := amountsOffset + 32 + 32*i
+		if amountStart+32 > len(body) {
+			return nil, errors.New("Asset data too short")
+		}
+		components[i].Amount = new(big.Int).SetBytes(body[amountStart : amountStart+32])
+		// bytes[] offsets are relative to the start of the array contents
+		elementOffset, err := readAbiUint(body, nestedOffset+32+32*i)
+		if err != nil {
+			return nil, err
+		}
+		elementStart := nestedOffset + 32 + elementOffset
+		elementLength, err := readAbiUint(body, elementStart)
+		if err != nil {
+			return nil, err
+		}
+		if elementStart+32+elementLength > len(body) {
+			return nil, errors.New("Asset data too short")
+		}
+		components[i].AssetData = make(AssetData, elementLength)
+		copy(components[i].AssetData[:], body[elementStart+32:elementStart+32+elementLength])
+	}
+	return components, nil
+}
+
 func (data AssetData) TokenID() *Uint256 {
 	tokenID := &Uint256{}
-	if data.IsType(ERC721ProxyID) {
+	if data.IsType(ERC721ProxyID) && len(data) > 36 {
 		copy(tokenID[:], data[36:])
 	}
 	return tokenID
@@ -49,3 +263,37 @@ func (data AssetData) MarshalJSON() ([]byte, error) {
 func (data AssetData) Value() (driver.Value, error) {
 	return []byte(data[:]), nil
 }
+
+// HasComponents indicates whether the asset is made up of other assets, which
+// must be tracked individually.
+func (data AssetData) HasComponents() bool {
+	return data.IsType(MultiAssetProxyID) || data.IsType(ERC1155ProxyID)
+}
+
+// LeafComponents recursively expands the components of a bundle, so that
+// MultiAssetProxy bundles containing ERC1155 assets are broken down to
+// individual token ids. Amounts are per unit of the outer bundle.
+func (data AssetData) LeafComponents() ([]AssetComponent, error) {
+	components, err := data.Components()
+	if err != nil {
+		return nil, err
+	}
+	if !data.IsType(MultiAssetProxyID) {
+		return components, nil
+	}
+	result := []AssetComponent{}
+	for _, component := range components {
+		if !component.AssetData.HasComponents() {
+			result = append(result, component)
+			continue
+		}
+		leaves, err := component.AssetData.LeafComponents()
+		if err != nil {
+			return nil, err
+		}
+		for _, leaf := range leaves {
+			result = append(result, AssetComponent{new(big.Int).Mul(leaf.Amount, component.Amount), leaf.AssetData})
+		}
+	}
+	return result, nil
+}
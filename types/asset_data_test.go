@@ -3,6 +3,8 @@ package types_test
 import (
 	"encoding/hex"
 	"encoding/json"
+	"math/big"
+	orCommon "github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/types"
 	"testing"
 	"bytes"
@@ -41,3 +43,130 @@ func TestAssetDataJSONEncode(t *testing.T) {
 // 		t.Errorf("Unexpected ProxyId: %#x", proxyId)
 // 	}
 // }
+
+func getMultiAssetData() types.AssetData {
+	// MultiAsset([1, 2], [ERC20(0x1dc4c1cefef38a777b15aa20260a54e584b16c48), ERC721(0x1d7022f5b17d2f8b695918fb48fa1089c9f85401, 1)])
+	data, _ := hex.DecodeString("94cfcdd7" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"00000000000000000000000000000000000000000000000000000000000000a0" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"00000000000000000000000000000000000000000000000000000000000000a0" +
+		"0000000000000000000000000000000000000000000000000000000000000024" +
+		"f47261b00000000000000000000000001dc4c1cefef38a777b15aa20260a54e5" +
+		"84b16c4800000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000044" +
+		"025717920000000000000000000000001d7022f5b17d2f8b695918fb48fa1089" +
+		"c9f8540100000000000000000000000000000000000000000000000000000000" +
+		"0000000100000000000000000000000000000000000000000000000000000000")
+	return types.AssetData(data)
+}
+
+func TestMultiAssetComponents(t *testing.T) {
+	assetData := getMultiAssetData()
+	if !assetData.SupportedType() {
+		t.Fatalf("Expected MultiAsset data to be supported")
+	}
+	components, err := assetData.Components()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(components) != 2 {
+		t.Fatalf("Expected 2 components, got %v", len(components))
+	}
+	if components[0].Amount.Int64() != 1 || !components[0].AssetData.IsType(types.ERC20ProxyID) {
+		t.Errorf("Unexpected first component: %v %#x", components[0].Amount, components[0].AssetData[:])
+	}
+	if components[1].Amount.Int64() != 2 || !components[1].AssetData.IsType(types.ERC721ProxyID) {
+		t.Errorf("Unexpected second component: %v %#x", components[1].Amount, components[1].AssetData[:])
+	}
+	if components[1].AssetData.TokenID().Big().Int64() != 1 {
+		t.Errorf("Unexpected token id: %v", components[1].AssetData.TokenID().Big())
+	}
+	// The components are different tokens, so there's no single address
+	if address := assetData.Address(); !bytes.Equal(address[:], make([]byte, 20)) {
+		t.Errorf("Expected zero address for mixed bundle, got %#x", address[:])
+	}
+}
+
+func TestMultiAssetTruncated(t *testing.T) {
+	assetData := getMultiAssetData()
+	truncated := assetData[:len(assetData)-40]
+	if truncated.SupportedType() {
+		t.Errorf("Truncated MultiAsset data should not be supported")
+	}
+	if _, err := truncated.Components(); err == nil {
+		t.Errorf("Expected error decoding truncated MultiAsset data")
+	}
+}
+
+func TestMultiAssetEncoding(t *testing.T) {
+	erc20Address, _ := orCommon.HexToAddress("0x1dc4c1cefef38a777b15aa20260a54e584b16c48")
+	erc721Address, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	assetData := orCommon.ToMultiAssetData(
+		[]*big.Int{big.NewInt(1), big.NewInt(2)},
+		[]types.AssetData{
+			orCommon.ToERC20AssetData(erc20Address),
+			orCommon.ToERC721AssetData(erc721Address, orCommon.BigToUint256(big.NewInt(1))),
+		},
+	)
+	if !bytes.Equal(assetData, getMultiAssetData()) {
+		t.Errorf("Unexpected encoding: %#x", assetData[:])
+	}
+}
+
+func TestERC1155Items(t *testing.T) {
+	tokenAddress, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	assetData := orCommon.ToERC1155AssetData(
+		tokenAddress,
+		[]*big.Int{big.NewInt(5), big.NewInt(7)},
+		[]*big.Int{big.NewInt(10), big.NewInt(1)},
+		[]byte{1, 2, 3},
+	)
+	if !assetData.SupportedType() {
+		t.Fatalf("Expected ERC1155 data to be supported")
+	}
+	if address := assetData.Address(); !bytes.Equal(address[:], tokenAddress[:]) {
+		t.Errorf("Unexpected address %#x", address[:])
+	}
+	ids, values, callbackData, err := assetData.ERC1155Items()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(ids) != 2 || ids[0].Int64() != 5 || ids[1].Int64() != 7 {
+		t.Errorf("Unexpected ids: %v", ids)
+	}
+	if len(values) != 2 || values[0].Int64() != 10 || values[1].Int64() != 1 {
+		t.Errorf("Unexpected values: %v", values)
+	}
+	if !bytes.Equal(callbackData, []byte{1, 2, 3}) {
+		t.Errorf("Unexpected callback data: %#x", callbackData)
+	}
+	components, err := assetData.Components()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := orCommon.ToERC1155AssetData(tokenAddress, []*big.Int{big.NewInt(7)}, []*big.Int{big.NewInt(1)}, []byte{})
+	if components[1].Amount.Int64() != 1 || !bytes.Equal(components[1].AssetData, expected) {
+		t.Errorf("Unexpected component: %v %#x", components[1].Amount, components[1].AssetData[:])
+	}
+}
+
+func TestLeafComponents(t *testing.T) {
+	tokenAddress, _ := orCommon.HexToAddress("0x1d7022f5b17d2f8b695918fb48fa1089c9f85401")
+	erc1155 := orCommon.ToERC1155AssetData(tokenAddress, []*big.Int{big.NewInt(5)}, []*big.Int{big.NewInt(10)}, []byte{})
+	bundle := orCommon.ToMultiAssetData([]*big.Int{big.NewInt(3)}, []types.AssetData{erc1155})
+	leaves, err := bundle.LeafComponents()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(leaves) != 1 || leaves[0].Amount.Int64() != 30 {
+		t.Fatalf("Unexpected leaves: %v", leaves)
+	}
+	if !bytes.Equal(leaves[0].AssetData, types.ERC1155ItemAssetData(tokenAddress, big.NewInt(5))) {
+		t.Errorf("Unexpected leaf asset data: %#x", leaves[0].AssetData[:])
+	}
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// DecimalScale is the number of decimal places a Decimal keeps. Values are
+// rounded to this many places, so equal rationals always store equal values.
+const DecimalScale = 30
+
+// Decimal is a decimal number stored in a NUMERIC column, letting the
+// database compare and order values without floating point error
+type Decimal big.Rat
+
+// NewDecimal creates a Decimal of numerator / denominator, or nil if the
+// denominator is zero
+func NewDecimal(numerator, denominator *big.Int) *Decimal {
+	if denominator.Sign() == 0 {
+		return nil
+	}
+	return (*Decimal)(new(big.Rat).SetFrac(numerator, denominator))
+}
+
+// Rat returns the decimal's value, rounded to DecimalScale places
+func (data *Decimal) Rat() *big.Rat {
+	rat, _ := new(big.Rat).SetString(data.String())
+	return rat
+}
+
+func (data *Decimal) String() string {
+	value := (*big.Rat)(data).FloatString(DecimalScale)
+	value = strings.TrimRight(value, "0")
+	return strings.TrimSuffix(value, ".")
+}
+
+// Float64 returns the nearest float64 to the decimal, for APIs that report
+// numbers as floats
+func (data *Decimal) Float64() float64 {
+	if data == nil {
+		return 0
+	}
+	value, _ := data.Rat().Float64()
+	return value
+}
+
+func (data *Decimal) Value() (driver.Value, error) {
+	return data.String(), nil
+}
+
+func (data *Decimal) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case float64:
+		value = fmt.Sprint(v)
+	case int64:
+		value = fmt.Sprint(v)
+	default:
+		return fmt.Errorf("Decimal scanner src should be a number, got %T", src)
+	}
+	if _, ok := (*big.Rat)(data).SetString(value); !ok {
+		return fmt.Errorf("Invalid decimal '%v'", value)
+	}
+	return nil
+}
+
+// GormDataType tells gorm what data type to use for the column.
+func (data Decimal) GormDataType(dialect gorm.Dialect) string {
+	if dialect.GetName() == "mysql" {
+		// MySQL decimals are limited to 65 digits
+		return fmt.Sprintf("decimal(65,%v)", DecimalScale)
+	}
+	return "numeric"
+}
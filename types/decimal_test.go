@@ -0,0 +1,58 @@
+package types_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/notegio/openrelay/types"
+)
+
+func TestDecimalEqualPrices(t *testing.T) {
+	a := types.NewDecimal(big.NewInt(1), big.NewInt(3))
+	b := types.NewDecimal(big.NewInt(2000000000000000000), big.NewInt(6000000000000000000))
+	if a.String() != b.String() {
+		t.Errorf("Expected equal prices, got %v and %v", a, b)
+	}
+	if a.String() != "0.333333333333333333333333333333" {
+		t.Errorf("Unexpected price %v", a)
+	}
+}
+
+func TestDecimalExact(t *testing.T) {
+	// These differ by less than a float64 can represent
+	a := types.NewDecimal(new(big.Int).Add(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil), big.NewInt(1)), big.NewInt(1))
+	b := types.NewDecimal(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil), big.NewInt(1))
+	if a.Float64() != b.Float64() {
+		t.Fatalf("Expected prices to collide as floats")
+	}
+	if a.String() == b.String() {
+		t.Errorf("Expected distinct prices, got %v", a)
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	value := types.NewDecimal(big.NewInt(1), big.NewInt(50))
+	dbValue, err := value.Value()
+	if err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if dbValue != "0.02" {
+		t.Errorf("Unexpected value %v", dbValue)
+	}
+	scanned := &types.Decimal{}
+	if err := scanned.Scan([]byte("0.020000000000000000000000000000")); err != nil {
+		t.Fatalf("%v", err.Error())
+	}
+	if scanned.Rat().Cmp(value.Rat()) != 0 {
+		t.Errorf("Expected %v, got %v", value, scanned)
+	}
+	if err := scanned.Scan([]byte("not a number")); err == nil {
+		t.Errorf("Expected error scanning invalid decimal")
+	}
+}
+
+func TestDecimalZeroDenominator(t *testing.T) {
+	if value := types.NewDecimal(big.NewInt(1), big.NewInt(0)); value != nil {
+		t.Errorf("Expected nil, got %v", value)
+	}
+}
@@ -1,17 +1,25 @@
 package types
 
 import (
+	"encoding/hex"
 	"encoding/json"
-	// "encoding/hex"
 
 	"fmt"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/rlp"
-	// "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"io"
 	"math/big"
 	// "strconv"
 )
 
+// Versions of the 0x protocol an order can be for. Orders that predate v3
+// support have a ProtocolVersion of 0, and are treated as v2.
+const (
+	ProtocolV2 = uint8(2)
+	ProtocolV3 = uint8(3)
+)
+
 // Order represents an 0x order object
 type Order struct {
 	Maker                     *Address  `gorm:"index"`
@@ -23,8 +31,8 @@ type Order struct {
 	FeeRecipient              *Address  `gorm:"index"`
 	ExchangeAddress           *Address  `gorm:"index"`
 	SenderAddress             *Address  `gorm:"index"`
-	MakerAssetAmount          *Uint256
-	TakerAssetAmount          *Uint256
+	MakerAssetAmount          *Uint256  `gorm:"index"`
+	TakerAssetAmount          *Uint256  `gorm:"index"`
 	MakerFee                  *Uint256
 	TakerFee                  *Uint256  `gorm:"index"`
 	ExpirationTimestampInSec  *Uint256  `gorm:"index"`
@@ -33,6 +41,33 @@ type Order struct {
 	TakerAssetAmountFilled    *Uint256
 	Cancelled                 bool
 	PoolID                    []byte    `gorm:"index"`
+	ProtocolVersion           uint8     `gorm:"index;default:2"`
+	// ChainID, MakerFeeAssetData and TakerFeeAssetData are only set on v3
+	// orders
+	ChainID                   uint64
+	MakerFeeAssetData         AssetData
+	TakerFeeAssetData         AssetData
+	// DomainName, DomainVersion and DomainSalt override the EIP-712 domain
+	// the order is signed under, for exchanges forked from 0x. They're set
+	// from the exchange's record rather than submitted with the order.
+	DomainName                string
+	DomainVersion             string
+	DomainSalt                []byte
+	// FundedTakerAssetAmount is how much of the order's remaining taker asset
+	// amount the maker's funds covered when the order was fund checked. It's
+	// carried along with the order to the indexer, but not stored with it.
+	FundedTakerAssetAmount    *Uint256  `gorm:"-"`
+}
+
+// IsV3 indicates whether the order is for v3 of the 0x protocol
+func (order *Order) IsV3() bool {
+	return order.ProtocolVersion == ProtocolV3
+}
+
+// hasCustomDomain indicates whether the order is signed under a domain other
+// than the 0x Protocol domain for its version
+func (order *Order) hasCustomDomain() bool {
+	return order.DomainName != "" || order.DomainVersion != "" || len(order.DomainSalt) > 0
 }
 
 func (order *Order) Initialize() {
@@ -54,6 +89,13 @@ func (order *Order) Initialize() {
 	order.TakerAssetAmountFilled = &Uint256{}
 	order.Cancelled = false
 	order.Signature = Signature{}
+	order.ProtocolVersion = ProtocolV2
+	order.ChainID = 0
+	order.MakerFeeAssetData = nil
+	order.TakerFeeAssetData = nil
+	order.DomainName = ""
+	order.DomainVersion = ""
+	order.DomainSalt = nil
 }
 
 // NewOrder takes string representations of values and converts them into an Order object
@@ -156,23 +198,48 @@ func (order *Order) Hash() []byte {
 
 	eip191Header := []byte{25, 1}
 	twelveNullBytes := [12]byte{}  // Addresses are 20 bytes, but the hashes expect 32, so we'll just add twelveNullBytes before each address
+	domainName := "0x Protocol"
+	domainVersion := "2"
+	domainSchema := "EIP712Domain(string name,string version,address verifyingContract"
+	orderSchemaSha := sha3.NewKeccak256()
+	if order.IsV3() {
+		// v3 adds the chain ID to the domain, and fee asset data to the order
+		domainVersion = "3.0"
+		domainSchema = "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract"
+		orderSchemaSha.Write([]byte("Order(address makerAddress,address takerAddress,address feeRecipientAddress,address senderAddress,uint256 makerAssetAmount,uint256 takerAssetAmount,uint256 makerFee,uint256 takerFee,uint256 expirationTimeSeconds,uint256 salt,bytes makerAssetData,bytes takerAssetData,bytes makerFeeAssetData,bytes takerFeeAssetData)"))
+	} else {
+		orderSchemaSha.Write([]byte("Order(address makerAddress,address takerAddress,address feeRecipientAddress,address senderAddress,uint256 makerAssetAmount,uint256 takerAssetAmount,uint256 makerFee,uint256 takerFee,uint256 expirationTimeSeconds,uint256 salt,bytes makerAssetData,bytes takerAssetData)"))
+	}
+	if order.DomainName != "" {
+		domainName = order.DomainName
+	}
+	if order.DomainVersion != "" {
+		domainVersion = order.DomainVersion
+	}
+	if len(order.DomainSalt) > 0 {
+		domainSchema += ",bytes32 salt"
+	}
 	domainSchemaSha := sha3.NewKeccak256()
-	domainSchemaSha.Write([]byte("EIP712Domain(string name,string version,address verifyingContract)"))
-	domainSha := sha3.NewKeccak256()
+	domainSchemaSha.Write([]byte(domainSchema + ")"))
 	nameSha := sha3.NewKeccak256()
-	nameSha.Write([]byte("0x Protocol"))
+	nameSha.Write([]byte(domainName))
 	versionSha := sha3.NewKeccak256()
-	versionSha.Write([]byte("2"))
+	versionSha.Write([]byte(domainVersion))
+	domainSha := sha3.NewKeccak256()
 	domainSha.Write(domainSchemaSha.Sum(nil))
 	domainSha.Write(nameSha.Sum(nil))
 	domainSha.Write(versionSha.Sum(nil))
+	if order.IsV3() {
+		domainSha.Write(abi.U256(new(big.Int).SetUint64(order.ChainID)))
+	}
 	domainSha.Write(twelveNullBytes[:])
 	domainSha.Write(order.ExchangeAddress[:])
+	if len(order.DomainSalt) > 0 {
+		salt := [32]byte{}
+		copy(salt[:], order.DomainSalt)
+		domainSha.Write(salt[:])
+	}
 
-	orderSchemaSha := sha3.NewKeccak256()
-	orderSchemaSha.Write([]byte("Order(address makerAddress,address takerAddress,address feeRecipientAddress,address senderAddress,uint256 makerAssetAmount,uint256 takerAssetAmount,uint256 makerFee,uint256 takerFee,uint256 expirationTimeSeconds,uint256 salt,bytes makerAssetData,bytes takerAssetData)"))
-	exchangeSha := sha3.NewKeccak256()
-	exchangeSha.Write(order.ExchangeAddress[:])
 	makerAssetDataSha := sha3.NewKeccak256()
 	makerAssetDataSha.Write(order.MakerAssetData[:])
 	takerAssetDataSha := sha3.NewKeccak256()
@@ -195,6 +262,14 @@ func (order *Order) Hash() []byte {
 	orderSha.Write(order.Salt[:])
 	orderSha.Write(makerAssetDataSha.Sum(nil))
 	orderSha.Write(takerAssetDataSha.Sum(nil))
+	if order.IsV3() {
+		makerFeeAssetDataSha := sha3.NewKeccak256()
+		makerFeeAssetDataSha.Write(order.MakerFeeAssetData[:])
+		takerFeeAssetDataSha := sha3.NewKeccak256()
+		takerFeeAssetDataSha.Write(order.TakerFeeAssetData[:])
+		orderSha.Write(makerFeeAssetDataSha.Sum(nil))
+		orderSha.Write(takerFeeAssetDataSha.Sum(nil))
+	}
 
 	sha := sha3.NewKeccak256()
 	sha.Write(eip191Header)
@@ -204,6 +279,38 @@ func (order *Order) Hash() []byte {
 	return sha.Sum(nil)
 }
 
+// FillableTakerAssetAmount returns how much of the order's remaining taker
+// asset amount the maker can cover. makerAvailable and feeAvailable are the
+// lesser of the maker's balance and allowance for the maker asset and the fee
+// token; a nil value places no limit. If makerAssetIsFee, the maker asset is
+// the fee token, so makerAvailable must cover both the asset and the fee.
+func (order *Order) FillableTakerAssetAmount(makerAvailable, feeAvailable *big.Int, makerAssetIsFee bool) *big.Int {
+	takerAmount := order.TakerAssetAmount.Big()
+	fillable := new(big.Int).Sub(takerAmount, order.TakerAssetAmountFilled.Big())
+	if fillable.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	limit := func(available, required *big.Int) {
+		if available == nil || required.Sign() == 0 {
+			return
+		}
+		// The taker amount that available units of the maker's funds can
+		// cover, at the order's rate
+		covered := new(big.Int).Mul(available, takerAmount)
+		covered.Div(covered, required)
+		if covered.Cmp(fillable) < 0 {
+			fillable = covered
+		}
+	}
+	if makerAssetIsFee {
+		limit(makerAvailable, new(big.Int).Add(order.MakerAssetAmount.Big(), order.MakerFee.Big()))
+	} else {
+		limit(makerAvailable, order.MakerAssetAmount.Big())
+		limit(feeAvailable, order.MakerFee.Big())
+	}
+	return fillable
+}
+
 type jsonOrder struct {
 	Maker                     string  `json:"makerAddress"`
 	Taker                     string  `json:"takerAddress"`
@@ -221,6 +328,10 @@ type jsonOrder struct {
 	Signature                 string  `json:"signature"`
 	TakerAssetAmountFilled    string  `json:"-"`
 	Cancelled                 string  `json:"-"`
+	// v3 orders identify themselves with these fields
+	ChainID                   *uint64 `json:"chainId,omitempty"`
+	MakerFeeAssetData         string  `json:"makerFeeAssetData,omitempty"`
+	TakerFeeAssetData         string  `json:"takerFeeAssetData,omitempty"`
 }
 
 func (order *Order) UnmarshalJSON(b []byte) error {
@@ -234,7 +345,7 @@ func (order *Order) UnmarshalJSON(b []byte) error {
 	if jOrder.Cancelled == "" {
 		jOrder.Cancelled = "false"
 	}
-	return order.fromStrings(
+	if err := order.fromStrings(
 		jOrder.Maker,
 		jOrder.Taker,
 		jOrder.MakerAssetData,
@@ -251,7 +362,27 @@ func (order *Order) UnmarshalJSON(b []byte) error {
 		jOrder.Signature,
 		jOrder.TakerAssetAmountFilled,
 		jOrder.Cancelled,
-	)
+	); err != nil {
+		return err
+	}
+	if jOrder.ChainID == nil && jOrder.MakerFeeAssetData == "" && jOrder.TakerFeeAssetData == "" {
+		return nil
+	}
+	makerFeeAssetDataBytes, err := HexStringToBytes(jOrder.MakerFeeAssetData)
+	if err != nil {
+		return err
+	}
+	takerFeeAssetDataBytes, err := HexStringToBytes(jOrder.TakerFeeAssetData)
+	if err != nil {
+		return err
+	}
+	order.ProtocolVersion = ProtocolV3
+	if jOrder.ChainID != nil {
+		order.ChainID = *jOrder.ChainID
+	}
+	order.MakerFeeAssetData = AssetData(makerFeeAssetDataBytes)
+	order.TakerFeeAssetData = AssetData(takerFeeAssetDataBytes)
+	return nil
 }
 
 func (order *Order) MarshalJSON() ([]byte, error) {
@@ -276,9 +407,104 @@ func (order *Order) MarshalJSON() ([]byte, error) {
 	} else {
 		jsonOrder.Cancelled = "false"
 	}
+	if order.IsV3() {
+		chainID := order.ChainID
+		jsonOrder.ChainID = &chainID
+		// %#x formats empty asset data as "", but v3 orders without fees
+		// have fee asset data of "0x"
+		jsonOrder.MakerFeeAssetData = "0x" + hex.EncodeToString(order.MakerFeeAssetData)
+		jsonOrder.TakerFeeAssetData = "0x" + hex.EncodeToString(order.TakerFeeAssetData)
+	}
 	return json.Marshal(jsonOrder)
 }
 
+// rlpFields lists the fields every order is RLP encoded with, in order
+func (order *Order) rlpFields() []interface{} {
+	return []interface{}{
+		&order.Maker,
+		&order.Taker,
+		&order.MakerAssetAddress,
+		&order.TakerAssetAddress,
+		&order.MakerAssetData,
+		&order.TakerAssetData,
+		&order.FeeRecipient,
+		&order.ExchangeAddress,
+		&order.SenderAddress,
+		&order.MakerAssetAmount,
+		&order.TakerAssetAmount,
+		&order.MakerFee,
+		&order.TakerFee,
+		&order.ExpirationTimestampInSec,
+		&order.Salt,
+		&order.Signature,
+		&order.TakerAssetAmountFilled,
+		&order.Cancelled,
+		&order.PoolID,
+	}
+}
+
+// EncodeRLP appends the v3 fields to v3 orders, the domain fields to orders
+// with a custom domain, and the funded amount to fund checked orders, so
+// canonical v2 orders encode exactly as they did before any were supported.
+// Each group of fields follows the ones before it.
+func (order *Order) EncodeRLP(w io.Writer) error {
+	fields := order.rlpFields()
+	funded := order.FundedTakerAssetAmount != nil
+	if order.IsV3() || order.hasCustomDomain() || funded {
+		fields = append(fields, &order.ProtocolVersion, &order.ChainID, &order.MakerFeeAssetData, &order.TakerFeeAssetData)
+	}
+	if order.hasCustomDomain() || funded {
+		fields = append(fields, &order.DomainName, &order.DomainVersion, &order.DomainSalt)
+	}
+	if funded {
+		fields = append(fields, &order.FundedTakerAssetAmount)
+	}
+	return rlp.Encode(w, fields)
+}
+
+// DecodeRLP reads orders written by EncodeRLP. Orders without the v3 fields
+// are v2 orders, orders without the domain fields use the 0x Protocol
+// domain, and orders without a funded amount haven't been fund checked.
+func (order *Order) DecodeRLP(s *rlp.Stream) error {
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	for _, field := range order.rlpFields() {
+		if err := s.Decode(field); err != nil {
+			return err
+		}
+	}
+	order.ProtocolVersion = ProtocolV2
+	order.ChainID = 0
+	order.MakerFeeAssetData = nil
+	order.TakerFeeAssetData = nil
+	order.DomainName = ""
+	order.DomainVersion = ""
+	order.DomainSalt = nil
+	order.FundedTakerAssetAmount = nil
+	groups := [][]interface{}{
+		{&order.ProtocolVersion, &order.ChainID, &order.MakerFeeAssetData, &order.TakerFeeAssetData},
+		{&order.DomainName, &order.DomainVersion, &order.DomainSalt},
+		{&order.FundedTakerAssetAmount},
+	}
+	for _, group := range groups {
+		for i, field := range group {
+			err := s.Decode(field)
+			if err == rlp.EOL && i == 0 {
+				// The optional groups of fields end here
+				return s.ListEnd()
+			} else if err != nil {
+				return err
+			}
+		}
+		if order.ProtocolVersion == 0 {
+			// Orders built in code rather than parsed may not set a version
+			order.ProtocolVersion = ProtocolV2
+		}
+	}
+	return s.ListEnd()
+}
+
 func (order *Order) Bytes() []byte {
 	data, _ := rlp.EncodeToBytes(order)
 	return data
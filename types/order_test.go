@@ -5,11 +5,14 @@ import (
 	"database/sql/driver"
 	"encoding/hex"
 	"encoding/json"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/notegio/openrelay/common"
 	"github.com/notegio/openrelay/types"
 	"io/ioutil"
 	"reflect"
 	"testing"
 	"bytes"
+	"math/big"
 	// "log"
 )
 
@@ -233,3 +236,239 @@ func TestJsonUnmarshal(t *testing.T) {
 		t.Errorf("Failed to verify order with signature: %#x", newOrder.Signature)
 	}
 }
+
+const v3OrderJSON = `{
+	"makerAddress": "0x627306090abab3a6e1400e9345bc60c78a8bef57",
+	"takerAddress": "0x0000000000000000000000000000000000000000",
+	"feeRecipientAddress": "0x0000000000000000000000000000000000000000",
+	"senderAddress": "0x0000000000000000000000000000000000000000",
+	"makerAssetAmount": "50000000000000000000",
+	"takerAssetAmount": "1000000000000000000",
+	"makerFee": "1000",
+	"takerFee": "0",
+	"expirationTimeSeconds": "5797808836",
+	"salt": "11065671350908846865864045738088581419204014210814002044381812654087807531",
+	"makerAssetData": "0xf47261b00000000000000000000000001dad4783cf3fe3085c1426157ab175a6119a04ba",
+	"takerAssetData": "0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c",
+	"makerFeeAssetData": "0xf47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c",
+	"takerFeeAssetData": "0x",
+	"exchangeAddress": "0x61935cbdd02287b511119ddb11aeb42f1593b7ef",
+	"chainId": 1,
+	"signature": "0x"
+}`
+
+func TestV3Order(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	order := &types.Order{}
+	if err := json.Unmarshal([]byte(v3OrderJSON), order); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !order.IsV3() || order.ChainID != 1 {
+		t.Fatalf("Expected v3 order on chain 1, got version %v chain %v", order.ProtocolVersion, order.ChainID)
+	}
+	if hex.EncodeToString(order.MakerFeeAssetData) != "f47261b000000000000000000000000005d090b51c40b020eab3bfcb6a2dff130df22e9c" || len(order.TakerFeeAssetData) != 0 {
+		t.Errorf("Unexpected fee asset data: %#x %#x", order.MakerFeeAssetData, order.TakerFeeAssetData)
+	}
+	copy(order.Maker[:], crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	sig, err := crypto.Sign(order.Hash(), key)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	order.Signature = append(append([]byte{sig[64] + 27}, sig[:64]...), types.SigTypeEIP712)
+	if !order.Signature.Verify(order.Maker, order.Hash()) {
+		t.Fatalf("Signature invalid")
+	}
+
+	// The v3 fields must survive JSON and RLP round trips
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	jsonOrder := &types.Order{}
+	if err := json.Unmarshal(data, jsonOrder); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !bytes.Equal(jsonOrder.Hash(), order.Hash()) {
+		t.Errorf("Hash changed after JSON round trip: %v", string(data))
+	}
+	rlpOrder, err := types.OrderFromBytes(order.Bytes())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !rlpOrder.IsV3() || !bytes.Equal(rlpOrder.Hash(), order.Hash()) {
+		t.Errorf("Hash changed after RLP round trip")
+	}
+
+	// The chain ID and fee asset data are covered by the signature
+	changed := *order
+	changed.ChainID = 3
+	if changed.Signature.Verify(changed.Maker, changed.Hash()) {
+		t.Errorf("Signature should not cover a different chain")
+	}
+	changed = *order
+	changed.MakerFeeAssetData = order.TakerAssetData[:4]
+	if changed.Signature.Verify(changed.Maker, changed.Hash()) {
+		t.Errorf("Signature should not cover different fee asset data")
+	}
+	changed = *order
+	changed.ProtocolVersion = types.ProtocolV2
+	if bytes.Equal(changed.Hash(), order.Hash()) {
+		t.Errorf("v2 and v3 hashes should differ")
+	}
+	if len(changed.Bytes()) >= len(order.Bytes()) {
+		t.Errorf("v2 orders should not encode v3 fields")
+	}
+}
+
+// v3OrderTypedDataHash hashes an order the way the v3 exchange's LibOrder
+// does, from the schema hashes the contracts hardcode rather than the schema
+// strings Hash() builds them from
+func v3OrderTypedDataHash(order *types.Order) []byte {
+	// LibEIP712ExchangeDomain._EIP712_DOMAIN_SEPARATOR_SCHEMA_HASH
+	domainSchemaHash, _ := hex.DecodeString("8b73c3c69bb8fe3d512ecc4cf759cc79239f7b179b0ffacaa9a75d522b39400f")
+	// LibOrder._EIP712_ORDER_SCHEMA_HASH
+	orderSchemaHash, _ := hex.DecodeString("f80322eb8376aafb64eadf8f0d7623f22130fd9491a221e902b713cb984a7534")
+	word := func(value []byte) []byte {
+		return append(make([]byte, 32-len(value)), value...)
+	}
+	domainSeparator := crypto.Keccak256(
+		domainSchemaHash,
+		crypto.Keccak256([]byte("0x Protocol")),
+		crypto.Keccak256([]byte("3.0")),
+		word(new(big.Int).SetUint64(order.ChainID).Bytes()),
+		word(order.ExchangeAddress[:]),
+	)
+	structHash := crypto.Keccak256(
+		orderSchemaHash,
+		word(order.Maker[:]),
+		word(order.Taker[:]),
+		word(order.FeeRecipient[:]),
+		word(order.SenderAddress[:]),
+		order.MakerAssetAmount[:],
+		order.TakerAssetAmount[:],
+		order.MakerFee[:],
+		order.TakerFee[:],
+		order.ExpirationTimestampInSec[:],
+		order.Salt[:],
+		crypto.Keccak256(order.MakerAssetData),
+		crypto.Keccak256(order.TakerAssetData),
+		crypto.Keccak256(order.MakerFeeAssetData),
+		crypto.Keccak256(order.TakerFeeAssetData),
+	)
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+func TestV3OrderHashVector(t *testing.T) {
+	order := &types.Order{}
+	if err := json.Unmarshal([]byte(v3OrderJSON), order); err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := v3OrderTypedDataHash(order)
+	if !bytes.Equal(order.Hash(), expected) {
+		t.Errorf("Expected hash %#x, got %#x", expected, order.Hash())
+	}
+	if hex.EncodeToString(expected) != "61dc67ce6f8f92323398d2a797fa6679e5f1251273fdceac1f6d1629d7c343a7" {
+		t.Errorf("Unexpected reference hash %#x", expected)
+	}
+	// The chain ID is part of the domain, so the same order on another chain
+	// has a different hash
+	order.ChainID = 3
+	if !bytes.Equal(order.Hash(), v3OrderTypedDataHash(order)) {
+		t.Errorf("Expected hash %#x, got %#x", v3OrderTypedDataHash(order), order.Hash())
+	}
+	if bytes.Equal(order.Hash(), expected) {
+		t.Errorf("Expected chain ID to change the hash")
+	}
+}
+
+func TestOrderCustomDomain(t *testing.T) {
+	order := &types.Order{}
+	if orderData, err := ioutil.ReadFile("../formatted_transaction.json"); err == nil {
+		if err := json.Unmarshal(orderData, order); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	canonical := order.Hash()
+	order.DomainName = "0x Protocol"
+	order.DomainVersion = "2"
+	if !bytes.Equal(order.Hash(), canonical) {
+		t.Errorf("Explicit 0x Protocol domain should match the default")
+	}
+	order.DomainName = "RoboDex"
+	forked := order.Hash()
+	if bytes.Equal(forked, canonical) {
+		t.Errorf("Domain name should change the hash")
+	}
+	order.DomainSalt = []byte{1}
+	salted := order.Hash()
+	if bytes.Equal(salted, forked) {
+		t.Errorf("Domain salt should change the hash")
+	}
+	decoded, err := types.OrderFromBytes(order.Bytes())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if decoded.DomainName != "RoboDex" || !bytes.Equal(decoded.Hash(), salted) {
+		t.Errorf("Domain lost in RLP round trip: %v %#x", decoded.DomainName, decoded.Hash())
+	}
+}
+
+func TestOrderFundedAmount(t *testing.T) {
+	order := &types.Order{}
+	if orderData, err := ioutil.ReadFile("../formatted_transaction.json"); err == nil {
+		if err := json.Unmarshal(orderData, order); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	canonical := order.Bytes()
+	decoded, err := types.OrderFromBytes(canonical)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if decoded.FundedTakerAssetAmount != nil {
+		t.Errorf("Expected no funded amount, got %v", decoded.FundedTakerAssetAmount)
+	}
+	order.FundedTakerAssetAmount = common.BigToUint256(big.NewInt(12345))
+	decoded, err = types.OrderFromBytes(order.Bytes())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if decoded.FundedTakerAssetAmount == nil || decoded.FundedTakerAssetAmount.Big().Int64() != 12345 {
+		t.Errorf("Funded amount lost in RLP round trip: %v", decoded.FundedTakerAssetAmount)
+	}
+	if decoded.ProtocolVersion != types.ProtocolV2 || decoded.IsV3() {
+		t.Errorf("Expected a v2 order, got version %v", decoded.ProtocolVersion)
+	}
+	if !bytes.Equal(decoded.Hash(), order.Hash()) {
+		t.Errorf("Unequal hashes: %#x != %#x", decoded.Hash(), order.Hash())
+	}
+}
+
+func TestFillableTakerAssetAmount(t *testing.T) {
+	order := &types.Order{}
+	order.Initialize()
+	// 100 maker asset for 50 taker asset, with a maker fee of 10, 10 filled
+	order.MakerAssetAmount[31] = 100
+	order.TakerAssetAmount[31] = 50
+	order.MakerFee[31] = 10
+	order.TakerAssetAmountFilled[31] = 10
+	if fillable := order.FillableTakerAssetAmount(nil, nil, false); fillable.Int64() != 40 {
+		t.Errorf("Expected unlimited funds to cover the remaining 40, got %v", fillable)
+	}
+	if fillable := order.FillableTakerAssetAmount(big.NewInt(48), nil, false); fillable.Int64() != 24 {
+		t.Errorf("Expected 48 maker asset to cover 24, got %v", fillable)
+	}
+	if fillable := order.FillableTakerAssetAmount(big.NewInt(1000), big.NewInt(3), false); fillable.Int64() != 15 {
+		t.Errorf("Expected 3 fee tokens to cover 15, got %v", fillable)
+	}
+	if fillable := order.FillableTakerAssetAmount(big.NewInt(55), nil, true); fillable.Int64() != 25 {
+		t.Errorf("Expected 55 of a shared maker asset and fee token to cover 25, got %v", fillable)
+	}
+	order.TakerAssetAmountFilled[31] = 60
+	if fillable := order.FillableTakerAssetAmount(nil, nil, false); fillable.Sign() != 0 {
+		t.Errorf("Expected overfilled order to have nothing fillable, got %v", fillable)
+	}
+}
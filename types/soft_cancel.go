@@ -0,0 +1,223 @@
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+)
+
+// SoftCancel is a maker's signed request that the relay stop serving some of
+// their orders. It either lists specific order hashes, or cancels every order
+// with a salt below SaltUpTo for an asset pair and / or pool.
+//
+// A soft cancel only removes orders from this relay's order book. Anyone who
+// already holds a copy of a signed order can still fill it on-chain until the
+// maker cancels it with the exchange contract.
+type SoftCancel struct {
+	Maker           *Address
+	ExchangeAddress *Address
+	OrderHashes     [][32]byte
+	MakerAssetData  AssetData
+	TakerAssetData  AssetData
+	PoolID          [32]byte
+	SaltUpTo        *Uint256
+	Expiration      *Uint256
+	Signature       Signature
+}
+
+// Hash returns the EIP712 hash of the cancel message. It's signed under a
+// domain of its own, so a cancel signature can't be mistaken for an order
+// signature on the same exchange.
+func (cancel *SoftCancel) Hash() []byte {
+	eip191Header := []byte{25, 1}
+	twelveNullBytes := [12]byte{}
+	domainSchemaSha := sha3.NewKeccak256()
+	domainSchemaSha.Write([]byte("EIP712Domain(string name,string version,address verifyingContract)"))
+	nameSha := sha3.NewKeccak256()
+	nameSha.Write([]byte("OpenRelay Soft Cancel"))
+	versionSha := sha3.NewKeccak256()
+	versionSha.Write([]byte("1"))
+	domainSha := sha3.NewKeccak256()
+	domainSha.Write(domainSchemaSha.Sum(nil))
+	domainSha.Write(nameSha.Sum(nil))
+	domainSha.Write(versionSha.Sum(nil))
+	domainSha.Write(twelveNullBytes[:])
+	domainSha.Write(cancel.ExchangeAddress[:])
+
+	cancelSchemaSha := sha3.NewKeccak256()
+	cancelSchemaSha.Write([]byte("SoftCancel(address makerAddress,bytes32[] orderHashes,bytes makerAssetData,bytes takerAssetData,bytes32 poolId,uint256 saltUpTo,uint256 expirationTimeSeconds)"))
+	orderHashesSha := sha3.NewKeccak256()
+	for _, orderHash := range cancel.OrderHashes {
+		orderHashesSha.Write(orderHash[:])
+	}
+	makerAssetDataSha := sha3.NewKeccak256()
+	makerAssetDataSha.Write(cancel.MakerAssetData[:])
+	takerAssetDataSha := sha3.NewKeccak256()
+	takerAssetDataSha.Write(cancel.TakerAssetData[:])
+	cancelSha := sha3.NewKeccak256()
+	cancelSha.Write(cancelSchemaSha.Sum(nil))
+	cancelSha.Write(twelveNullBytes[:])
+	cancelSha.Write(cancel.Maker[:])
+	cancelSha.Write(orderHashesSha.Sum(nil))
+	cancelSha.Write(makerAssetDataSha.Sum(nil))
+	cancelSha.Write(takerAssetDataSha.Sum(nil))
+	cancelSha.Write(cancel.PoolID[:])
+	cancelSha.Write(cancel.SaltUpTo[:])
+	cancelSha.Write(cancel.Expiration[:])
+
+	sha := sha3.NewKeccak256()
+	sha.Write(eip191Header)
+	sha.Write(domainSha.Sum(nil))
+	sha.Write(cancelSha.Sum(nil))
+	return sha.Sum(nil)
+}
+
+// HasPair indicates whether a salt based cancel is limited to one asset pair
+func (cancel *SoftCancel) HasPair() bool {
+	return len(cancel.MakerAssetData) > 0 && len(cancel.TakerAssetData) > 0
+}
+
+// HasPool indicates whether a salt based cancel is limited to one pool
+func (cancel *SoftCancel) HasPool() bool {
+	return cancel.PoolID != [32]byte{}
+}
+
+// Validate checks that the cancel message is well formed. It does not check
+// the signature.
+func (cancel *SoftCancel) Validate() error {
+	if len(cancel.OrderHashes) > 0 {
+		if cancel.SaltUpTo.Big().Sign() != 0 {
+			return errors.New("A cancel may list order hashes or specify saltUpTo, not both")
+		}
+		return nil
+	}
+	if cancel.SaltUpTo.Big().Sign() == 0 {
+		return errors.New("A cancel must list order hashes or specify saltUpTo")
+	}
+	if (len(cancel.MakerAssetData) > 0) != (len(cancel.TakerAssetData) > 0) {
+		return errors.New("A pair requires both makerAssetData and takerAssetData")
+	}
+	if !cancel.HasPair() && !cancel.HasPool() {
+		return errors.New("A saltUpTo cancel must specify an asset pair or pool")
+	}
+	return nil
+}
+
+type jsonSoftCancel struct {
+	Maker           string   `json:"makerAddress"`
+	ExchangeAddress string   `json:"exchangeAddress"`
+	OrderHashes     []string `json:"orderHashes"`
+	MakerAssetData  string   `json:"makerAssetData"`
+	TakerAssetData  string   `json:"takerAssetData"`
+	PoolID          string   `json:"poolId"`
+	SaltUpTo        string   `json:"saltUpTo"`
+	Expiration      string   `json:"expirationTimeSeconds"`
+	Signature       string   `json:"signature"`
+}
+
+func decodeHex(field, value string) ([]byte, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %v: %v", field, err.Error())
+	}
+	return data, nil
+}
+
+func decodeUint256(field, value string) (*Uint256, error) {
+	result := &Uint256{}
+	if value == "" {
+		return result, nil
+	}
+	valueInt, ok := new(big.Int).SetString(value, 10)
+	if !ok || valueInt.Sign() < 0 || valueInt.BitLen() > 256 {
+		return nil, fmt.Errorf("Invalid %v: %v", field, value)
+	}
+	copy(result[:], abi.U256(valueInt))
+	return result, nil
+}
+
+func (cancel *SoftCancel) UnmarshalJSON(b []byte) error {
+	jCancel := jsonSoftCancel{}
+	if err := json.Unmarshal(b, &jCancel); err != nil {
+		return err
+	}
+	maker, err := decodeHex("makerAddress", jCancel.Maker)
+	if err != nil {
+		return err
+	}
+	exchange, err := decodeHex("exchangeAddress", jCancel.ExchangeAddress)
+	if err != nil {
+		return err
+	}
+	if len(maker) != 20 || len(exchange) != 20 {
+		return errors.New("Invalid address length")
+	}
+	cancel.Maker = &Address{}
+	copy(cancel.Maker[:], maker)
+	cancel.ExchangeAddress = &Address{}
+	copy(cancel.ExchangeAddress[:], exchange)
+	cancel.OrderHashes = make([][32]byte, len(jCancel.OrderHashes))
+	for i, orderHash := range jCancel.OrderHashes {
+		hashBytes, err := decodeHex("orderHashes", orderHash)
+		if err != nil {
+			return err
+		}
+		if len(hashBytes) != 32 {
+			return fmt.Errorf("Invalid order hash length: %v", orderHash)
+		}
+		copy(cancel.OrderHashes[i][:], hashBytes)
+	}
+	if cancel.MakerAssetData, err = decodeHex("makerAssetData", jCancel.MakerAssetData); err != nil {
+		return err
+	}
+	if cancel.TakerAssetData, err = decodeHex("takerAssetData", jCancel.TakerAssetData); err != nil {
+		return err
+	}
+	poolID, err := decodeHex("poolId", jCancel.PoolID)
+	if err != nil {
+		return err
+	}
+	if len(poolID) != 0 && len(poolID) != 32 {
+		return fmt.Errorf("Invalid pool id length: %v", jCancel.PoolID)
+	}
+	copy(cancel.PoolID[:], poolID)
+	if cancel.SaltUpTo, err = decodeUint256("saltUpTo", jCancel.SaltUpTo); err != nil {
+		return err
+	}
+	if cancel.Expiration, err = decodeUint256("expirationTimeSeconds", jCancel.Expiration); err != nil {
+		return err
+	}
+	if cancel.Signature, err = decodeHex("signature", jCancel.Signature); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cancel *SoftCancel) MarshalJSON() ([]byte, error) {
+	jCancel := &jsonSoftCancel{}
+	jCancel.Maker = fmt.Sprintf("%#x", cancel.Maker[:])
+	jCancel.ExchangeAddress = fmt.Sprintf("%#x", cancel.ExchangeAddress[:])
+	jCancel.OrderHashes = make([]string, len(cancel.OrderHashes))
+	for i, orderHash := range cancel.OrderHashes {
+		jCancel.OrderHashes[i] = fmt.Sprintf("%#x", orderHash[:])
+	}
+	if len(cancel.MakerAssetData) > 0 {
+		jCancel.MakerAssetData = fmt.Sprintf("%#x", cancel.MakerAssetData[:])
+	}
+	if len(cancel.TakerAssetData) > 0 {
+		jCancel.TakerAssetData = fmt.Sprintf("%#x", cancel.TakerAssetData[:])
+	}
+	if cancel.HasPool() {
+		jCancel.PoolID = fmt.Sprintf("%#x", cancel.PoolID[:])
+	}
+	jCancel.SaltUpTo = cancel.SaltUpTo.String()
+	jCancel.Expiration = cancel.Expiration.String()
+	jCancel.Signature = fmt.Sprintf("%#x", []byte(cancel.Signature))
+	return json.Marshal(jCancel)
+}
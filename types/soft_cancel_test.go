@@ -0,0 +1,85 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/notegio/openrelay/types"
+)
+
+func signedSoftCancel(t *testing.T, body string) *types.SoftCancel {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	cancel := &types.SoftCancel{}
+	if err := json.Unmarshal([]byte(body), cancel); err != nil {
+		t.Fatalf(err.Error())
+	}
+	copy(cancel.Maker[:], crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	sig, err := crypto.Sign(cancel.Hash(), key)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	cancel.Signature = append(append([]byte{sig[64] + 27}, sig[:64]...), types.SigTypeEIP712)
+	return cancel
+}
+
+func TestSoftCancelSignatureRoundTrip(t *testing.T) {
+	cancel := signedSoftCancel(t, `{
+		"makerAddress": "0x0000000000000000000000000000000000000000",
+		"exchangeAddress": "0xb69e673309512a9d726f87304c6984054f87a93b",
+		"orderHashes": ["0x1111111111111111111111111111111111111111111111111111111111111111"],
+		"expirationTimeSeconds": "1600000000",
+		"signature": "0x"
+	}`)
+	if err := cancel.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err.Error())
+	}
+	if !cancel.Signature.Verify(cancel.Maker, cancel.Hash()) {
+		t.Fatalf("Signature invalid")
+	}
+	data, err := json.Marshal(cancel)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	parsed := &types.SoftCancel{}
+	if err := json.Unmarshal(data, parsed); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !parsed.Signature.Verify(parsed.Maker, parsed.Hash()) {
+		t.Errorf("Signature invalid after JSON round trip: %v", string(data))
+	}
+	parsed.OrderHashes[0][0] = 0x22
+	if parsed.Signature.Verify(parsed.Maker, parsed.Hash()) {
+		t.Errorf("Signature should not cover a different order hash")
+	}
+}
+
+func TestSoftCancelValidate(t *testing.T) {
+	for _, body := range []string{
+		// Neither hashes nor a salt
+		`{"makerAddress": "0x0000000000000000000000000000000000000000", "exchangeAddress": "0x0000000000000000000000000000000000000000"}`,
+		// A salt with no pair or pool
+		`{"makerAddress": "0x0000000000000000000000000000000000000000", "exchangeAddress": "0x0000000000000000000000000000000000000000", "saltUpTo": "10"}`,
+		// Half a pair
+		`{"makerAddress": "0x0000000000000000000000000000000000000000", "exchangeAddress": "0x0000000000000000000000000000000000000000", "saltUpTo": "10", "makerAssetData": "0xf47261b0"}`,
+	} {
+		cancel := &types.SoftCancel{}
+		if err := json.Unmarshal([]byte(body), cancel); err != nil {
+			t.Fatalf(err.Error())
+		}
+		if err := cancel.Validate(); err == nil {
+			t.Errorf("Expected validation error for %v", body)
+		}
+	}
+	cancel := &types.SoftCancel{}
+	body := `{"makerAddress": "0x0000000000000000000000000000000000000000", "exchangeAddress": "0x0000000000000000000000000000000000000000", "saltUpTo": "10", "poolId": "0x1111111111111111111111111111111111111111111111111111111111111111"}`
+	if err := json.Unmarshal([]byte(body), cancel); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := cancel.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err.Error())
+	}
+}
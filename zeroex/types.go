@@ -73,6 +73,24 @@ type ValidationError struct {
 	Reason string              `json:"reason"`
 }
 
+// OrderSubmissionResult reports the outcome of a single order from a batch
+// submission to POST /v2/orders. Rejected orders carry an Error in the same
+// format POST /v2/order would have responded with.
+type OrderSubmissionResult struct {
+	Hash     string `json:"hash,omitempty"`
+	Accepted bool   `json:"accepted"`
+	Error    *Error `json:"error,omitempty"`
+}
+
+// OrderValidationResult is the response to a dry run through
+// POST /v2/order/validate. It lists every check the order failed, rather than
+// just the first.
+type OrderValidationResult struct {
+	Hash             string            `json:"hash"`
+	Valid            bool              `json:"valid"`
+	ValidationErrors []ValidationError `json:"validationErrors"`
+}
+
 // AssetData contains information specific to an asset.
 // https://github.com/0xProject/standard-relayer-api/blob/master/http/v2.md#get-v2asset_pairs
 type AssetData struct {
@@ -89,23 +107,28 @@ type AssetPair struct {
 	AssetDataB AssetData `json:"assetDataB"`
 }
 
-// Order contains ZeroEx signed order.
+// Order contains ZeroEx signed order. v3 orders add ChainID and the fee asset
+// data, which are omitted for v2 orders.
 // https://github.com/0xProject/standard-relayer-api/blob/master/http/v2.md#post-v2order
+// https://github.com/0xProject/standard-relayer-api/blob/master/http/v3.md#post-v3order
 type Order struct {
-	MakerAddress          string `json:"makerAddress"`
-	TakerAddress          string `json:"takerAddress"`
-	SenderAddress         string `json:"senderAddress"`
-	FeeRecipientAddress   string `json:"feeRecipientAddress"`
-	MakerFee              string `json:"makerFee"`
-	TakerFee              string `json:"takerFee"`
-	MakerAssetAmount      string `json:"makerAssetAmount"`
-	TakerAssetAmount      string `json:"takerAssetAmount"`
-	MakerAssetData        string `json:"makerAssetData"`
-	TakerAssetData        string `json:"takerAssetData"`
-	Salt                  string `json:"salt"`
-	ExchangeAddress       string `json:"exchangeAddress"`
-	ExpirationTimeSeconds string `json:"expirationTimeSeconds"`
-	Signature             string `json:"signature"`
+	MakerAddress          string  `json:"makerAddress"`
+	TakerAddress          string  `json:"takerAddress"`
+	SenderAddress         string  `json:"senderAddress"`
+	FeeRecipientAddress   string  `json:"feeRecipientAddress"`
+	MakerFee              string  `json:"makerFee"`
+	TakerFee              string  `json:"takerFee"`
+	MakerAssetAmount      string  `json:"makerAssetAmount"`
+	TakerAssetAmount      string  `json:"takerAssetAmount"`
+	MakerAssetData        string  `json:"makerAssetData"`
+	TakerAssetData        string  `json:"takerAssetData"`
+	Salt                  string  `json:"salt"`
+	ExchangeAddress       string  `json:"exchangeAddress"`
+	ExpirationTimeSeconds string  `json:"expirationTimeSeconds"`
+	Signature             string  `json:"signature"`
+	MakerFeeAssetData     string  `json:"makerFeeAssetData,omitempty"`
+	TakerFeeAssetData     string  `json:"takerFeeAssetData,omitempty"`
+	ChainID               *uint64 `json:"chainId,omitempty"`
 }
 
 // OrderEx contains ZeroEx signed order with a meta data.
@@ -121,6 +144,12 @@ type OrderMetadata struct {
 	FeeRate                   float64 `json:"feeRate"`
 	Status                    int64   `json:"status"`
 	TakerAssetAmountRemaining string  `json:"takerAssetAmountRemaining"`
+	// RemainingFillableTakerAssetAmount is how much of the remaining amount
+	// the maker's balances and allowances can cover
+	RemainingFillableTakerAssetAmount string `json:"remainingFillableTakerAssetAmount"`
+	// OverCommitted indicates the maker's open orders together need more than
+	// the maker's balances and allowances can cover
+	OverCommitted bool `json:"overCommitted,omitempty"`
 }
 
 // OrderBook contains the orderbook for a given asset pair.